@@ -0,0 +1,240 @@
+package admin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/rata"
+)
+
+type RoutesResponse struct {
+	RouteCount int     `json:"route_count"`
+	EntryCount int     `json:"entry_count"`
+	Entries    []Entry `json:"entries"`
+}
+
+type Entry struct {
+	ProcessGuid     string     `json:"process_guid"`
+	ContainerPort   uint32     `json:"container_port"`
+	Hostnames       []string   `json:"hostnames"`
+	LogGuid         string     `json:"log_guid,omitempty"`
+	RouteServiceUrl string     `json:"route_service_url,omitempty"`
+	Endpoints       []Endpoint `json:"endpoints"`
+}
+
+type Endpoint struct {
+	InstanceGuid  string `json:"instance_guid"`
+	Host          string `json:"host"`
+	Port          uint32 `json:"port"`
+	ContainerPort uint32 `json:"container_port"`
+	Domain        string `json:"domain,omitempty"`
+	Evacuating    bool   `json:"evacuating"`
+}
+
+type RouteServicesResponse struct {
+	RouteServices []RouteService `json:"route_services"`
+}
+
+// RouteService counts the routes bound to a route service url, and the
+// processes they route to.
+type RouteService struct {
+	Url          string   `json:"url"`
+	RouteCount   int      `json:"route_count"`
+	ProcessGuids []string `json:"process_guids"`
+}
+
+type handler struct {
+	table  routing_table.RoutingTable
+	logger lager.Logger
+}
+
+// NewHandler returns a read-only JSON view of the routing table, intended
+// for operators debugging missing or unexpected routes.
+func NewHandler(table routing_table.RoutingTable, logger lager.Logger) (http.Handler, error) {
+	h := &handler{
+		table:  table,
+		logger: logger.Session("admin"),
+	}
+
+	return rata.NewRouter(Routes, rata.Handlers{
+		RoutesRoute:              http.HandlerFunc(h.routes),
+		RoutesByProcessGuidRoute: http.HandlerFunc(h.routesByProcessGuid),
+		RoutesByHostnameRoute:    http.HandlerFunc(h.routesByHostname),
+		RoutesByEndpointRoute:    http.HandlerFunc(h.routesByEndpoint),
+		RouteServicesRoute:       http.HandlerFunc(h.routeServices),
+	})
+}
+
+func (h *handler) routes(w http.ResponseWriter, req *http.Request) {
+	h.respond(w, func(routing_table.RoutingKey, routing_table.RoutableEndpoints) bool {
+		return true
+	})
+}
+
+func (h *handler) routesByProcessGuid(w http.ResponseWriter, req *http.Request) {
+	processGuid := rata.Param(req, "process_guid")
+	h.respond(w, func(key routing_table.RoutingKey, _ routing_table.RoutableEndpoints) bool {
+		return key.ProcessGuid == processGuid
+	})
+}
+
+func (h *handler) routesByHostname(w http.ResponseWriter, req *http.Request) {
+	keys := map[routing_table.RoutingKey]struct{}{}
+	for _, key := range h.table.RoutingKeysForHostname(rata.Param(req, "hostname")) {
+		keys[key] = struct{}{}
+	}
+
+	h.respond(w, func(key routing_table.RoutingKey, _ routing_table.RoutableEndpoints) bool {
+		_, ok := keys[key]
+		return ok
+	})
+}
+
+func (h *handler) routesByEndpoint(w http.ResponseWriter, req *http.Request) {
+	address := rata.Param(req, "address")
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		h.logger.Error("invalid-endpoint-address", err, lager.Data{"address": address})
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	port, err := strconv.ParseUint(portString, 10, 32)
+	if err != nil {
+		h.logger.Error("invalid-endpoint-port", err, lager.Data{"address": address})
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.respond(w, func(_ routing_table.RoutingKey, entry routing_table.RoutableEndpoints) bool {
+		for _, endpoint := range entry.Endpoints {
+			if endpoint.Host == host && endpoint.Port == uint32(port) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (h *handler) routeServices(w http.ResponseWriter, req *http.Request) {
+	routeServices := map[string]*RouteService{}
+	processGuids := map[string]map[string]struct{}{}
+
+	for key, entry := range h.table.Entries() {
+		if entry.RouteServiceUrl == "" {
+			continue
+		}
+
+		routeService, ok := routeServices[entry.RouteServiceUrl]
+		if !ok {
+			routeService = &RouteService{Url: entry.RouteServiceUrl, ProcessGuids: []string{}}
+			routeServices[entry.RouteServiceUrl] = routeService
+			processGuids[entry.RouteServiceUrl] = map[string]struct{}{}
+		}
+
+		routeService.RouteCount += len(entry.Hostnames)
+		if _, seen := processGuids[entry.RouteServiceUrl][key.ProcessGuid]; !seen {
+			processGuids[entry.RouteServiceUrl][key.ProcessGuid] = struct{}{}
+			routeService.ProcessGuids = append(routeService.ProcessGuids, key.ProcessGuid)
+		}
+	}
+
+	response := RouteServicesResponse{RouteServices: []RouteService{}}
+	for _, routeService := range routeServices {
+		sort.Strings(routeService.ProcessGuids)
+		response.RouteServices = append(response.RouteServices, *routeService)
+	}
+	sort.Sort(byUrl(response.RouteServices))
+
+	h.encode(w, response)
+}
+
+func (h *handler) respond(w http.ResponseWriter, matches func(routing_table.RoutingKey, routing_table.RoutableEndpoints) bool) {
+	response := RoutesResponse{
+		RouteCount: h.table.RouteCount(),
+		Entries:    []Entry{},
+	}
+
+	entries := h.table.Entries()
+	response.EntryCount = len(entries)
+
+	for key, entry := range entries {
+		if matches(key, entry) {
+			response.Entries = append(response.Entries, entryFor(key, entry))
+		}
+	}
+	sort.Sort(byRoutingKey(response.Entries))
+
+	h.encode(w, response)
+}
+
+func (h *handler) encode(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		h.logger.Error("failed-to-encode-response", err)
+	}
+}
+
+func entryFor(key routing_table.RoutingKey, entry routing_table.RoutableEndpoints) Entry {
+	hostnames := []string{}
+	for hostname := range entry.Hostnames {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	endpoints := []Endpoint{}
+	for _, endpoint := range entry.Endpoints {
+		endpoints = append(endpoints, Endpoint{
+			InstanceGuid:  endpoint.InstanceGuid,
+			Host:          endpoint.Host,
+			Port:          endpoint.Port,
+			ContainerPort: endpoint.ContainerPort,
+			Domain:        endpoint.Domain,
+			Evacuating:    endpoint.Evacuating,
+		})
+	}
+	sort.Sort(byInstanceGuid(endpoints))
+
+	return Entry{
+		ProcessGuid:     key.ProcessGuid,
+		ContainerPort:   key.ContainerPort,
+		Hostnames:       hostnames,
+		LogGuid:         entry.LogGuid,
+		RouteServiceUrl: entry.RouteServiceUrl,
+		Endpoints:       endpoints,
+	}
+}
+
+type byRoutingKey []Entry
+
+func (e byRoutingKey) Len() int      { return len(e) }
+func (e byRoutingKey) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byRoutingKey) Less(i, j int) bool {
+	if e[i].ProcessGuid == e[j].ProcessGuid {
+		return e[i].ContainerPort < e[j].ContainerPort
+	}
+	return e[i].ProcessGuid < e[j].ProcessGuid
+}
+
+type byInstanceGuid []Endpoint
+
+func (e byInstanceGuid) Len() int      { return len(e) }
+func (e byInstanceGuid) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byInstanceGuid) Less(i, j int) bool {
+	if e[i].InstanceGuid == e[j].InstanceGuid {
+		return !e[i].Evacuating && e[j].Evacuating
+	}
+	return e[i].InstanceGuid < e[j].InstanceGuid
+}
+
+type byUrl []RouteService
+
+func (r byUrl) Len() int           { return len(r) }
+func (r byUrl) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r byUrl) Less(i, j int) bool { return r[i].Url < r[j].Url }
@@ -0,0 +1,182 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Admin Handler", func() {
+	var (
+		table    *fake_routing_table.FakeRoutingTable
+		handler  http.Handler
+		recorder *httptest.ResponseRecorder
+		response admin.RoutesResponse
+	)
+
+	key1 := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	key2 := routing_table.RoutingKey{ProcessGuid: "pg-2", ContainerPort: 8080}
+
+	BeforeEach(func() {
+		table = new(fake_routing_table.FakeRoutingTable)
+		table.RouteCountReturns(3)
+		table.EntriesReturns(map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+			key1: {
+				Hostnames: map[string]struct{}{"foo.example.com": {}, "bar.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 8080, Domain: "domain"},
+				}),
+				LogGuid: "log-guid-1",
+			},
+			key2: {
+				Hostnames: map[string]struct{}{"baz.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22, ContainerPort: 8080, Domain: "domain"},
+				}),
+				LogGuid:         "log-guid-2",
+				RouteServiceUrl: "https://rs.example.com",
+			},
+		})
+
+		var err error
+		handler, err = admin.NewHandler(table, lagertest.NewTestLogger("test"))
+		Expect(err).NotTo(HaveOccurred())
+
+		recorder = httptest.NewRecorder()
+		response = admin.RoutesResponse{}
+	})
+
+	get := func(path string) {
+		request, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		handler.ServeHTTP(recorder, request)
+	}
+
+	decode := func() {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+	}
+
+	Describe("GET /v1/routes", func() {
+		It("returns every entry with totals", func() {
+			get("/v1/routes")
+			decode()
+
+			Expect(response.RouteCount).To(Equal(3))
+			Expect(response.EntryCount).To(Equal(2))
+			Expect(response.Entries).To(Equal([]admin.Entry{
+				{
+					ProcessGuid:   "pg-1",
+					ContainerPort: 8080,
+					Hostnames:     []string{"bar.example.com", "foo.example.com"},
+					LogGuid:       "log-guid-1",
+					Endpoints: []admin.Endpoint{
+						{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 8080, Domain: "domain"},
+					},
+				},
+				{
+					ProcessGuid:     "pg-2",
+					ContainerPort:   8080,
+					Hostnames:       []string{"baz.example.com"},
+					LogGuid:         "log-guid-2",
+					RouteServiceUrl: "https://rs.example.com",
+					Endpoints: []admin.Endpoint{
+						{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22, ContainerPort: 8080, Domain: "domain"},
+					},
+				},
+			}))
+		})
+	})
+
+	Describe("GET /v1/routes/process_guid/:process_guid", func() {
+		It("returns the entries for the process guid", func() {
+			get("/v1/routes/process_guid/pg-2")
+			decode()
+
+			Expect(response.Entries).To(HaveLen(1))
+			Expect(response.Entries[0].ProcessGuid).To(Equal("pg-2"))
+		})
+
+		It("returns no entries for an unknown process guid", func() {
+			get("/v1/routes/process_guid/missing")
+			decode()
+
+			Expect(response.Entries).To(BeEmpty())
+		})
+	})
+
+	Describe("GET /v1/routes/hostname/:hostname", func() {
+		BeforeEach(func() {
+			table.RoutingKeysForHostnameReturns([]routing_table.RoutingKey{key1})
+		})
+
+		It("returns the entries routed by the hostname", func() {
+			get("/v1/routes/hostname/foo.example.com")
+			decode()
+
+			Expect(table.RoutingKeysForHostnameCallCount()).To(Equal(1))
+			Expect(table.RoutingKeysForHostnameArgsForCall(0)).To(Equal("foo.example.com"))
+
+			Expect(response.Entries).To(HaveLen(1))
+			Expect(response.Entries[0].ProcessGuid).To(Equal("pg-1"))
+		})
+	})
+
+	Describe("GET /v1/routes/endpoint/:address", func() {
+		It("returns the entries containing the endpoint", func() {
+			get("/v1/routes/endpoint/2.2.2.2:22")
+			decode()
+
+			Expect(response.Entries).To(HaveLen(1))
+			Expect(response.Entries[0].ProcessGuid).To(Equal("pg-2"))
+		})
+
+		It("rejects malformed addresses", func() {
+			get("/v1/routes/endpoint/2.2.2.2")
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("GET /v1/route_services", func() {
+		BeforeEach(func() {
+			table.EntriesReturns(map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+				key1: {
+					Hostnames:       map[string]struct{}{"foo.example.com": {}, "bar.example.com": {}},
+					RouteServiceUrl: "https://rs.example.com",
+				},
+				key2: {
+					Hostnames:       map[string]struct{}{"baz.example.com": {}},
+					RouteServiceUrl: "https://rs.example.com",
+				},
+				{ProcessGuid: "pg-2", ContainerPort: 9090}: {
+					Hostnames:       map[string]struct{}{"baz.example.com/api": {}},
+					RouteServiceUrl: "https://auth.example.com",
+				},
+				{ProcessGuid: "pg-3", ContainerPort: 8080}: {
+					Hostnames: map[string]struct{}{"qux.example.com": {}},
+				},
+			})
+		})
+
+		It("counts the routes bound to each route service", func() {
+			get("/v1/route_services")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var routeServices admin.RouteServicesResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &routeServices)).To(Succeed())
+			Expect(routeServices.RouteServices).To(Equal([]admin.RouteService{
+				{Url: "https://auth.example.com", RouteCount: 1, ProcessGuids: []string{"pg-2"}},
+				{Url: "https://rs.example.com", RouteCount: 3, ProcessGuids: []string{"pg-1", "pg-2"}},
+			}))
+		})
+	})
+})
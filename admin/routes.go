@@ -0,0 +1,19 @@
+package admin
+
+import "github.com/tedsuo/rata"
+
+const (
+	RoutesRoute              = "Routes"
+	RoutesByProcessGuidRoute = "RoutesByProcessGuid"
+	RoutesByHostnameRoute    = "RoutesByHostname"
+	RoutesByEndpointRoute    = "RoutesByEndpoint"
+	RouteServicesRoute       = "RouteServices"
+)
+
+var Routes = rata.Routes{
+	{Path: "/v1/routes", Method: "GET", Name: RoutesRoute},
+	{Path: "/v1/routes/process_guid/:process_guid", Method: "GET", Name: RoutesByProcessGuidRoute},
+	{Path: "/v1/routes/hostname/:hostname", Method: "GET", Name: RoutesByHostnameRoute},
+	{Path: "/v1/routes/endpoint/:address", Method: "GET", Name: RoutesByEndpointRoute},
+	{Path: "/v1/route_services", Method: "GET", Name: RouteServicesRoute},
+}
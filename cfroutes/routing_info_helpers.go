@@ -2,18 +2,121 @@ package cfroutes
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
 
 	"code.cloudfoundry.org/bbs/models"
 )
 
 const CF_ROUTER = "cf-router"
 
+var (
+	ErrPathMissingLeadingSlash = errors.New("path must begin with /")
+	ErrPathIsRoot              = errors.New("path must not be /")
+	ErrPathHasTrailingSlash    = errors.New("path must not end with /")
+	ErrPathHasEmptySegment     = errors.New("path must not contain empty segments")
+	ErrPathHasDotSegment       = errors.New("path must not contain . or .. segments")
+	ErrPathHasInvalidCharacter = errors.New("path must not contain ?, #, whitespace or control characters")
+)
+
 type CFRoutes []CFRoute
 
 type CFRoute struct {
 	Hostnames       []string `json:"hostnames"`
 	Port            uint32   `json:"port"`
 	RouteServiceUrl string   `json:"route_service_url,omitempty"`
+	// Weight is the relative share of traffic the route's instances should
+	// receive. Zero leaves the router's default in place.
+	Weight uint32 `json:"weight,omitempty"`
+	// Path restricts the route to requests under a context path on each of
+	// its hostnames, e.g. /api.
+	Path string `json:"path,omitempty"`
+}
+
+// URIs returns the hostnames with the route's path appended, as they are
+// registered with the router.
+func (r CFRoute) URIs() []string {
+	if r.Path == "" {
+		return r.Hostnames
+	}
+
+	uris := make([]string, len(r.Hostnames))
+	for i, hostname := range r.Hostnames {
+		uris[i] = hostname + r.Path
+	}
+	return uris
+}
+
+// SplitURI splits a route URI, as returned by URIs, into its hostname and its
+// context path, which is empty for routes without one. The hostname is
+// lowercased and loses any trailing dot, since it matches regardless of case
+// or of being written fully qualified.
+func SplitURI(uri string) (string, string) {
+	path := ""
+	if i := strings.Index(uri, "/"); i >= 0 {
+		uri, path = uri[:i], uri[i:]
+	}
+	return strings.ToLower(strings.TrimSuffix(uri, ".")), path
+}
+
+// Validate checks the route's path, and any path already embedded in its
+// hostnames, which may not be combined with a separate path.
+func (r CFRoute) Validate() error {
+	if err := ValidatePath(r.Path); err != nil {
+		return fmt.Errorf("invalid path %q: %s", r.Path, err)
+	}
+
+	for _, hostname := range r.Hostnames {
+		i := strings.Index(hostname, "/")
+		if i < 0 {
+			continue
+		}
+
+		if r.Path != "" {
+			return fmt.Errorf("hostname %q already has a path", hostname)
+		}
+		if err := ValidatePath(hostname[i:]); err != nil {
+			return fmt.Errorf("invalid path in hostname %q: %s", hostname, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidatePath checks that path is empty or a clean absolute path the router
+// can match on.
+func ValidatePath(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	switch {
+	case path[0] != '/':
+		return ErrPathMissingLeadingSlash
+	case path == "/":
+		return ErrPathIsRoot
+	case strings.HasSuffix(path, "/"):
+		return ErrPathHasTrailingSlash
+	}
+
+	for _, segment := range strings.Split(path[1:], "/") {
+		switch segment {
+		case "":
+			return ErrPathHasEmptySegment
+		case ".", "..":
+			return ErrPathHasDotSegment
+		}
+	}
+
+	for _, r := range path {
+		if r == '?' || r == '#' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return ErrPathHasInvalidCharacter
+		}
+	}
+
+	return nil
 }
 
 func (c CFRoutes) RoutingInfo() models.Routes {
@@ -32,6 +32,7 @@ var _ = Describe("RoutingInfoHelpers", func() {
 			Hostnames:       []string{"foo3.example.com", "bar3.examaple.com"},
 			Port:            33333,
 			RouteServiceUrl: "rs.example.com",
+			Weight:          5,
 		}
 
 		routes = cfroutes.CFRoutes{route1, route2, route3}
@@ -54,6 +55,17 @@ var _ = Describe("RoutingInfoHelpers", func() {
 			Expect(payload).To(MatchJSON(expectedBytes))
 		})
 
+		It("omits the weight of routes that do not set one", func() {
+			payload, err := routingInfo[cfroutes.CF_ROUTER].MarshalJSON()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(payload).To(MatchJSON(`[
+				{"hostnames": ["foo1.example.com", "bar1.examaple.com"], "port": 11111},
+				{"hostnames": ["foo2.example.com", "bar2.examaple.com"], "port": 22222},
+				{"hostnames": ["foo3.example.com", "bar3.examaple.com"], "port": 33333, "route_service_url": "rs.example.com", "weight": 5}
+			]`))
+		})
+
 		Context("when CFRoutes is empty", func() {
 			BeforeEach(func() {
 				routes = cfroutes.CFRoutes{}
@@ -123,4 +135,84 @@ var _ = Describe("RoutingInfoHelpers", func() {
 			})
 		})
 	})
+
+	Describe("URIs", func() {
+		It("returns the hostnames when there is no path", func() {
+			Expect(route1.URIs()).To(Equal([]string{"foo1.example.com", "bar1.examaple.com"}))
+		})
+
+		It("appends the path to every hostname", func() {
+			route1.Path = "/api/v1"
+			Expect(route1.URIs()).To(Equal([]string{"foo1.example.com/api/v1", "bar1.examaple.com/api/v1"}))
+		})
+	})
+
+	Describe("SplitURI", func() {
+		It("returns the hostname and an empty path when there is no path", func() {
+			hostname, path := cfroutes.SplitURI("foo.example.com")
+			Expect(hostname).To(Equal("foo.example.com"))
+			Expect(path).To(BeEmpty())
+		})
+
+		It("splits off the path", func() {
+			hostname, path := cfroutes.SplitURI("foo.example.com/api/v1")
+			Expect(hostname).To(Equal("foo.example.com"))
+			Expect(path).To(Equal("/api/v1"))
+		})
+
+		It("lowercases the hostname and strips its trailing dot, but not the path's case", func() {
+			hostname, path := cfroutes.SplitURI("Foo.Example.com./API")
+			Expect(hostname).To(Equal("foo.example.com"))
+			Expect(path).To(Equal("/API"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts a route without a path", func() {
+			Expect(route1.Validate()).To(Succeed())
+		})
+
+		It("accepts a route with a valid path", func() {
+			route1.Path = "/api"
+			Expect(route1.Validate()).To(Succeed())
+		})
+
+		It("rejects a route with an invalid path", func() {
+			route1.Path = "api"
+			Expect(route1.Validate()).To(MatchError(ContainSubstring("path must begin with /")))
+		})
+
+		It("validates paths embedded in the hostnames", func() {
+			route1.Hostnames = []string{"foo1.example.com/api", "bar1.example.com/api/"}
+			Expect(route1.Validate()).To(MatchError(ContainSubstring(`invalid path in hostname "bar1.example.com/api/"`)))
+		})
+
+		It("rejects a path combined with a hostname that already has one", func() {
+			route1.Hostnames = []string{"foo1.example.com/api"}
+			route1.Path = "/v2"
+			Expect(route1.Validate()).To(MatchError(ContainSubstring("already has a path")))
+		})
+	})
+
+	Describe("ValidatePath", func() {
+		It("accepts an empty path", func() {
+			Expect(cfroutes.ValidatePath("")).To(Succeed())
+		})
+
+		It("accepts clean absolute paths", func() {
+			Expect(cfroutes.ValidatePath("/api")).To(Succeed())
+			Expect(cfroutes.ValidatePath("/api/v1.2/users-list")).To(Succeed())
+		})
+
+		It("rejects invalid paths", func() {
+			Expect(cfroutes.ValidatePath("api")).To(Equal(cfroutes.ErrPathMissingLeadingSlash))
+			Expect(cfroutes.ValidatePath("/")).To(Equal(cfroutes.ErrPathIsRoot))
+			Expect(cfroutes.ValidatePath("/api/")).To(Equal(cfroutes.ErrPathHasTrailingSlash))
+			Expect(cfroutes.ValidatePath("/api//v1")).To(Equal(cfroutes.ErrPathHasEmptySegment))
+			Expect(cfroutes.ValidatePath("/api/../admin")).To(Equal(cfroutes.ErrPathHasDotSegment))
+			Expect(cfroutes.ValidatePath("/api?x=1")).To(Equal(cfroutes.ErrPathHasInvalidCharacter))
+			Expect(cfroutes.ValidatePath("/api#top")).To(Equal(cfroutes.ErrPathHasInvalidCharacter))
+			Expect(cfroutes.ValidatePath("/my api")).To(Equal(cfroutes.ErrPathHasInvalidCharacter))
+		})
+	})
 })
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/config"
+	"github.com/cloudfoundry-incubator/route-emitter/consul_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry-incubator/route-emitter/watcher"
+	"github.com/pivotal-golang/lager"
+)
+
+func loadConfigFile(path string) config.Errors {
+	values, err := config.Load(path)
+	if err == nil {
+		err = config.Apply(flag.CommandLine, values)
+	}
+
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case config.Errors:
+		return err
+	default:
+		return config.Errors{fmt.Errorf("%s: %s", path, err)}
+	}
+}
+
+// validateFlags checks the combined flag and config file settings, returning
+// every problem found rather than stopping at the first.
+func validateFlags() config.Errors {
+	var errs config.Errors
+
+	switch *emitterBackend {
+	case natsBackend:
+	case httpBackend:
+		if *routingApiURL == "" && !*dryRun {
+			errs = append(errs, errors.New("routingApiURL must be set when using the http emitter backend"))
+		}
+		// routes are re-emitted at half the TTL, which the Routing API only
+		// takes in whole seconds
+		if *httpRouteTTL < 2*time.Second {
+			errs = append(errs, fmt.Errorf("httpRouteTTL must be at least 2s, got %s", *httpRouteTTL))
+		}
+	case xdsBackend:
+		if *xdsAddress == "" {
+			errs = append(errs, errors.New("xdsAddress must be set when using the xds emitter backend"))
+		}
+	case templateBackend:
+		if *templateOutputPath == "" && !*dryRun {
+			errs = append(errs, errors.New("templateOutputPath must be set when using the template emitter backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("emitterBackend must be one of nats, http, xds or template, got %q", *emitterBackend))
+	}
+
+	if *routingApiURL != "" {
+		if _, err := url.Parse(*routingApiURL); err != nil {
+			errs = append(errs, fmt.Errorf("routingApiURL is invalid: %s", err))
+		}
+	}
+
+	switch *lockBackend {
+	case consulLockBackend, noLockBackend:
+	case fileLockBackend:
+		if *lockFilePath == "" {
+			errs = append(errs, errors.New("lockFilePath must be set when using the file lock backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("lockBackend must be one of consul, file or none, got %q", *lockBackend))
+	}
+
+	if *consulServices && *consulCluster == "" {
+		errs = append(errs, errors.New("consulCluster must be set when registering consul services"))
+	}
+
+	// every cell reconciles the node against its own routes only, so cells
+	// sharing a node would deregister each other's services
+	if *consulServices && *cellID != "" && *consulServiceNode == consul_emitter.DefaultNodeName {
+		errs = append(errs, fmt.Errorf("consulServiceNode must be set to a node of this cell when registering consul services with cellID, not the default %q", consul_emitter.DefaultNodeName))
+	}
+
+	if *snapshotMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("snapshotMaxAge must not be negative, got %s", *snapshotMaxAge))
+	}
+
+	if *dnsTTL < 0 {
+		errs = append(errs, fmt.Errorf("dnsTTL must not be negative, got %s", *dnsTTL))
+	}
+
+	if *shardRoutes && *cellID != "" {
+		errs = append(errs, errors.New("shardRoutes and cellID cannot be used together"))
+	}
+
+	// each sharded emitter only holds its own shard of the routing table
+	if *shardRoutes && *xdsAddress != "" {
+		errs = append(errs, errors.New("shardRoutes and xdsAddress cannot be used together"))
+	}
+
+	if *shardRoutes && *consulServices {
+		errs = append(errs, errors.New("shardRoutes and consulServices cannot be used together"))
+	}
+
+	if *shardRoutes && *dnsAddress != "" {
+		errs = append(errs, errors.New("shardRoutes and dnsAddress cannot be used together"))
+	}
+
+	if *shardRoutes && *emitterBackend == templateBackend {
+		errs = append(errs, errors.New("shardRoutes cannot be used with the template emitter backend"))
+	}
+
+	if err := validateHostnameConflictPolicy(*hostnameConflictPolicy); err != nil {
+		errs = append(errs, err)
+	}
+
+	if *routeEmittingWorkers < 1 {
+		errs = append(errs, fmt.Errorf("routeEmittingWorkers must be positive, got %d", *routeEmittingWorkers))
+	}
+
+	if *bbsResubscribeMinBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("bbsResubscribeMinBackoff must be positive, got %s", *bbsResubscribeMinBackoff))
+	}
+
+	if *bbsResubscribeMaxBackoff < *bbsResubscribeMinBackoff {
+		errs = append(errs, fmt.Errorf("bbsResubscribeMaxBackoff must be at least bbsResubscribeMinBackoff, got %s", *bbsResubscribeMaxBackoff))
+	}
+
+	if *syncInterval <= 0 {
+		errs = append(errs, fmt.Errorf("syncInterval must be positive, got %s", *syncInterval))
+	}
+
+	return errs
+}
+
+func validateHostnameConflictPolicy(policy string) error {
+	switch routing_table.HostnameConflictPolicy(policy) {
+	case routing_table.AllowHostnameConflicts, routing_table.RejectHostnameConflicts:
+		return nil
+	}
+	return fmt.Errorf("hostnameConflictPolicy must be one of allow or reject, got %q", policy)
+}
+
+// loadHostnamePolicy reads the hostname rules at path. An empty path means no
+// policy, which allows every hostname.
+func loadHostnamePolicy(path string) (routing_table.HostnamePolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("hostnamePolicyFile: %s", err)
+	}
+
+	rules := routing_table.HostnameRules{}
+	err = json.Unmarshal(contents, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("hostnamePolicyFile: %s: %s", path, err)
+	}
+
+	policy, err := routing_table.NewHostnamePolicy(rules)
+	if err != nil {
+		return nil, fmt.Errorf("hostnamePolicyFile: %s: %s", path, err)
+	}
+
+	return policy, nil
+}
+
+type settingChange struct {
+	name  string
+	value string
+	apply func() error
+}
+
+// reloadConfig returns a function that applies the settings that are safe to
+// change while running. Other settings that differ from the running
+// configuration are logged and only take effect on restart. The hostname
+// policy file is read again on every reload, even if its path is unchanged.
+// Nothing is applied unless every changed setting is valid.
+func reloadConfig(
+	explicit map[string]bool,
+	reconfigurableSink *lager.ReconfigurableSink,
+	routeSyncer *syncer.Syncer,
+	table routing_table.RoutingTable,
+	emitter nats_emitter.NATSEmitter,
+	routeWatcher *watcher.Watcher,
+	logger lager.Logger,
+) func(config.Values) error {
+	logger = logger.Session("reload-config")
+
+	// appliedPolicyPath is the path of the hostname policy in effect, which
+	// is only read from the flag at startup
+	appliedPolicyPath := *hostnamePolicyFile
+
+	return func(values config.Values) error {
+		var errs config.Errors
+		changes := []settingChange{}
+
+		policyPath := appliedPolicyPath
+
+		for name, value := range values {
+			f := flag.Lookup(name)
+			if f == nil {
+				errs = append(errs, fmt.Errorf("%s: unknown setting", name))
+				continue
+			}
+			if explicit[name] || sameSetting(f.Value.String(), value) {
+				continue
+			}
+
+			var apply func() error
+			var err error
+
+			switch name {
+			case "syncInterval":
+				var interval time.Duration
+				interval, err = time.ParseDuration(value)
+				if err == nil && interval <= 0 {
+					err = errors.New("must be positive")
+				}
+				apply = func() error {
+					routeSyncer.SetSyncInterval(interval)
+					return nil
+				}
+
+			case "logLevel":
+				var level lager.LogLevel
+				level, err = parseLogLevel(value)
+				apply = func() error {
+					reconfigurableSink.SetMinLevel(level)
+					return nil
+				}
+
+			case "routeEmittingWorkers":
+				var workers int
+				workers, err = strconv.Atoi(value)
+				if err == nil && workers < 1 {
+					err = errors.New("must be positive")
+				}
+				apply = func() error {
+					if resizable, ok := emitter.(nats_emitter.ResizableNATSEmitter); ok {
+						return resizable.SetWorkers(workers)
+					}
+					return nil
+				}
+
+			case "hostnameConflictPolicy":
+				err = validateHostnameConflictPolicy(value)
+				policy := routing_table.HostnameConflictPolicy(value)
+				apply = func() error {
+					table.SetHostnameConflictPolicy(policy)
+					return nil
+				}
+
+			case "hostnamePolicyFile":
+				// handled below, since the file is reloaded even when its
+				// path is unchanged
+				policyPath = value
+				continue
+
+			default:
+				logger.Info("ignoring-setting-that-requires-restart", lager.Data{"setting": name})
+				continue
+			}
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s", name, err))
+				continue
+			}
+
+			changes = append(changes, settingChange{name: name, value: value, apply: apply})
+		}
+
+		// a policy in effect is reloaded even if its path is unchanged, and
+		// removed if its path is cleared
+		if policyPath != "" || appliedPolicyPath != "" {
+			policy, err := loadHostnamePolicy(policyPath)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				changes = append(changes, settingChange{
+					name:  "hostnamePolicyFile",
+					value: policyPath,
+					apply: func() error {
+						routeWatcher.SetHostnamePolicy(policy)
+						appliedPolicyPath = policyPath
+						return nil
+					},
+				})
+			}
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+
+		for _, change := range changes {
+			err := change.apply()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s", change.name, err))
+				continue
+			}
+
+			flag.Set(change.name, change.value)
+			logger.Info("applied-setting", lager.Data{"setting": change.name, "value": change.value})
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+
+		return nil
+	}
+}
+
+// sameSetting compares two flag values, treating durations written
+// differently (e.g. 1m and 1m0s) as equal.
+func sameSetting(current, value string) bool {
+	if current == value {
+		return true
+	}
+
+	currentDuration, err := time.ParseDuration(current)
+	if err != nil {
+		return false
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return false
+	}
+
+	return currentDuration == duration
+}
+
+func parseLogLevel(level string) (lager.LogLevel, error) {
+	switch level {
+	case "debug":
+		return lager.DEBUG, nil
+	case "info":
+		return lager.INFO, nil
+	case "error":
+		return lager.ERROR, nil
+	case "fatal":
+		return lager.FATAL, nil
+	}
+	return lager.INFO, fmt.Errorf("must be one of debug, info, error or fatal, got %q", level)
+}
@@ -3,29 +3,53 @@ package main
 import (
 	"flag"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
 	"github.com/cloudfoundry-incubator/cf-debug-server"
 	"github.com/cloudfoundry-incubator/cf-lager"
 	"github.com/cloudfoundry-incubator/cf_http"
 	"github.com/cloudfoundry-incubator/consuladapter"
 	"github.com/cloudfoundry-incubator/locket"
 	route_emitter "github.com/cloudfoundry-incubator/route-emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/admin"
+	"github.com/cloudfoundry-incubator/route-emitter/config"
+	"github.com/cloudfoundry-incubator/route-emitter/consul_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/dns_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/health"
+	"github.com/cloudfoundry-incubator/route-emitter/http_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/lock"
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
 	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/recording_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/shard"
+	"github.com/cloudfoundry-incubator/route-emitter/snapshot"
 	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/template_emitter"
 	"github.com/cloudfoundry-incubator/route-emitter/watcher"
+	"github.com/cloudfoundry-incubator/route-emitter/xds_emitter"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/cloudfoundry/gunk/diegonats"
 	"github.com/cloudfoundry/gunk/workpool"
+	"github.com/hashicorp/consul/api"
 	"github.com/nu7hatch/gouuid"
 	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/http_server"
 	"github.com/tedsuo/ifrit/sigmon"
 )
 
@@ -35,6 +59,12 @@ var bbsAddress = flag.String(
 	"Address of the BBS API Server",
 )
 
+var configFile = flag.String(
+	"configFile",
+	"",
+	"path to a JSON or YAML file of flag values; flags given on the command line take precedence, and sending SIGHUP reloads the settings that can change at runtime",
+)
+
 var sessionName = flag.String(
 	"sessionName",
 	"route-emitter",
@@ -47,6 +77,18 @@ var consulCluster = flag.String(
 	"comma-separated list of consul server URLs (scheme://ip:port)",
 )
 
+var lockBackend = flag.String(
+	"lockBackend",
+	consulLockBackend,
+	"how the active emitter is elected: consul, file (flock on -lockFilePath, for emitters sharing a VM) or none",
+)
+
+var lockFilePath = flag.String(
+	"lockFilePath",
+	"",
+	"path of the file locked when -lockBackend is file",
+)
+
 var lockTTL = flag.Duration(
 	"lockTTL",
 	locket.LockTTL,
@@ -131,8 +173,288 @@ var routeEmittingWorkers = flag.Int(
 	"Max concurrency for sending route messages",
 )
 
+var natsBatchRegistrations = flag.Bool(
+	"natsBatchRegistrations",
+	false,
+	"publish registrations in batches on router.register.batch when the routers advertise support for it",
+)
+
+var natsMaxBatchBytes = flag.Int(
+	"natsMaxBatchBytes",
+	nats_emitter.DefaultMaxBatchBytes,
+	"maximum size in bytes of a batched registration payload",
+)
+
+var natsRetryQueueSize = flag.Int(
+	"natsRetryQueueSize",
+	1000,
+	"maximum number of failed NATS publishes queued for retry (retries are disabled if zero)",
+)
+
+var natsRetryMaxAttempts = flag.Int(
+	"natsRetryMaxAttempts",
+	5,
+	"number of publish attempts after which a failed NATS message is dropped",
+)
+
+var natsRetryBaseDelay = flag.Duration(
+	"natsRetryBaseDelay",
+	500*time.Millisecond,
+	"delay before the first retry of a failed NATS publish; doubles with every attempt",
+)
+
+var natsRetryMaxDelay = flag.Duration(
+	"natsRetryMaxDelay",
+	30*time.Second,
+	"maximum delay between retries of a failed NATS publish",
+)
+
+var coalesceWindow = flag.Duration(
+	"coalesceWindow",
+	0,
+	"merge route messages from BBS events until none arrive for this long before emitting them (0 disables coalescing)",
+)
+
+var coalesceMaxLatency = flag.Duration(
+	"coalesceMaxLatency",
+	time.Second,
+	"maximum time a coalesced route message may be held before it is emitted",
+)
+
+var bbsResubscribeMinBackoff = flag.Duration(
+	"bbsResubscribeMinBackoff",
+	500*time.Millisecond,
+	"initial delay before resubscribing to BBS events after a failure; doubles on each consecutive failure",
+)
+
+var bbsResubscribeMaxBackoff = flag.Duration(
+	"bbsResubscribeMaxBackoff",
+	30*time.Second,
+	"maximum delay before resubscribing to BBS events after a failure",
+)
+
+var routingApiURL = flag.String(
+	"routingApiURL",
+	"",
+	"URL of the Routing API used to register TCP routes and, with the http emitter backend, HTTP routes (TCP route emission is disabled if empty)",
+)
+
+var routingApiTokenURL = flag.String(
+	"routingApiTokenURL",
+	"",
+	"URL of the OAuth token endpoint used to authenticate with the Routing API (requests are unauthenticated if empty)",
+)
+
+var routingApiClientID = flag.String(
+	"routingApiClientID",
+	"",
+	"OAuth client ID used to authenticate with the Routing API",
+)
+
+var routingApiClientSecret = flag.String(
+	"routingApiClientSecret",
+	"",
+	"OAuth client secret used to authenticate with the Routing API",
+)
+
+var routingApiBatchSize = flag.Int(
+	"routingApiBatchSize",
+	http_emitter.DefaultBatchSize,
+	"Maximum number of routes sent to the Routing API in a single request",
+)
+
+var emitterBackend = flag.String(
+	"emitterBackend",
+	natsBackend,
+	"Backend used to emit HTTP routes (nats|http|xds|template)",
+)
+
+var templateFile = flag.String(
+	"templateFile",
+	"haproxy",
+	"template the template emitter backend renders the routing table through: the built-in haproxy or nginx template, or the path of a Go template file",
+)
+
+var templateOutputPath = flag.String(
+	"templateOutputPath",
+	"",
+	"path of the file the template emitter backend renders to; it is replaced atomically",
+)
+
+var templateReloadCommand = flag.String(
+	"templateReloadCommand",
+	"",
+	"shell command run after the template emitter backend changes its output file, e.g. to reload the proxy",
+)
+
+var templateDebounce = flag.Duration(
+	"templateDebounce",
+	template_emitter.DefaultDebounce,
+	"how long the template emitter backend waits after a change before rendering, so that bursts of changes cause a single reload",
+)
+
+var consulServices = flag.Bool(
+	"consulServices",
+	false,
+	"also register the endpoints of every route as services in the consul catalog, tagged with their hostnames",
+)
+
+var consulServiceNode = flag.String(
+	"consulServiceNode",
+	consul_emitter.DefaultNodeName,
+	"consul catalog node the route services are registered under, and reconciled against; emitters sharing the lock must use the same node, and cell-local emitters one each",
+)
+
+var consulServicePrefix = flag.String(
+	"consulServicePrefix",
+	"",
+	"prefix of the names of the services registered with consul, which are otherwise named <process-guid>-<container-port>",
+)
+
+var xdsAddress = flag.String(
+	"xdsAddress",
+	"",
+	"host:port to serve the routing table on as an Envoy xDS management server; required by the xds emitter backend, and served alongside the nats or http backend if set (disabled if empty)",
+)
+
+var xdsRouteConfigName = flag.String(
+	"xdsRouteConfigName",
+	xds_emitter.DefaultRouteConfigurationName,
+	"name of the route configuration served over xDS",
+)
+
+var xdsConnectTimeout = flag.Duration(
+	"xdsConnectTimeout",
+	5*time.Second,
+	"connect timeout of the clusters served over xDS",
+)
+
+var dnsAddress = flag.String(
+	"dnsAddress",
+	"",
+	"host:port to answer DNS queries for routed hostnames on, over UDP and TCP, with the addresses of their endpoints (disabled if empty)",
+)
+
+var dnsTTL = flag.Duration(
+	"dnsTTL",
+	dns_emitter.DefaultTTL,
+	"TTL of the records answered by the DNS server, rounded down to whole seconds",
+)
+
+var httpRouteTTL = flag.Duration(
+	"httpRouteTTL",
+	2*time.Minute,
+	"TTL for HTTP routes registered with the Routing API; routes are re-emitted at half this interval",
+)
+
+var tcpRouteTTL = flag.Duration(
+	"tcpRouteTTL",
+	2*time.Minute,
+	"TTL for TCP route mappings registered with the Routing API",
+)
+
+var hostnameConflictPolicy = flag.String(
+	"hostnameConflictPolicy",
+	string(routing_table.AllowHostnameConflicts),
+	"What to do when a hostname is claimed by more than one process guid (allow|reject); conflicts are always logged and counted",
+)
+
+var hostnamePolicyFile = flag.String(
+	"hostnamePolicyFile",
+	"",
+	"path of a JSON file of allowed, denied and reserved hostname patterns; rejected routes are logged and counted instead of registered (all hostnames allowed if empty)",
+)
+
+var allowInsecureRouteServices = flag.Bool(
+	"allowInsecureRouteServices",
+	false,
+	"allow routes to be bound to http route services; otherwise only https route services are registered",
+)
+
+var routeServiceDeniedNetworks = flag.String(
+	"routeServiceDeniedNetworks",
+	"127.0.0.0/8,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,169.254.0.0/16,::1/128,fc00::/7,fe80::/10",
+	"comma-separated CIDRs route services may not be addressed by IP within; routes bound to them are logged and counted instead of registered",
+)
+
+var snapshotPath = flag.String(
+	"snapshotPath",
+	"",
+	"path of the file the routing table is periodically snapshotted to and restored from at startup (disabled if empty)",
+)
+
+var snapshotInterval = flag.Duration(
+	"snapshotInterval",
+	30*time.Second,
+	"the interval between snapshots of the routing table",
+)
+
+var snapshotMaxAge = flag.Duration(
+	"snapshotMaxAge",
+	10*time.Minute,
+	"snapshots older than this are ignored at startup, since their routes are likely to be stale (0 restores any snapshot)",
+)
+
+var adminAddress = flag.String(
+	"adminAddress",
+	"",
+	"host:port to serve the read-only routing table admin API on (disabled if empty)",
+)
+
+var healthAddress = flag.String(
+	"healthAddress",
+	"",
+	"host:port to serve /health and /ready on for process supervisors (disabled if empty)",
+)
+
+var metricsAddress = flag.String(
+	"metricsAddress",
+	"",
+	"host:port to serve Prometheus metrics on at /metrics (disabled if empty)",
+)
+
+var dryRun = flag.Bool(
+	"dryRun",
+	false,
+	"record route messages as newline-delimited JSON instead of publishing them, without acquiring the lock",
+)
+
+var dryRunOutput = flag.String(
+	"dryRunOutput",
+	"",
+	"path of the file dry run messages are appended to (stdout if empty)",
+)
+
+var cellID = flag.String(
+	"cellID",
+	"",
+	"only emit routes for actual LRPs on this cell, without acquiring the lock (every cell runs its own emitter)",
+)
+
+var shardRoutes = flag.Bool(
+	"shardRoutes",
+	false,
+	"split process guids between all running emitters by consistent hashing instead of acquiring the lock",
+)
+
+var shardPollInterval = flag.Duration(
+	"shardPollInterval",
+	5*time.Second,
+	"how often to check consul for emitters joining or leaving the shard ring",
+)
+
 const (
 	dropsondeOrigin = "route_emitter"
+
+	natsBackend = "nats"
+	httpBackend = "http"
+	xdsBackend  = "xds"
+
+	templateBackend = "template"
+
+	consulLockBackend = "consul"
+	fileLockBackend   = "file"
+	noLockBackend     = "none"
 )
 
 func main() {
@@ -140,34 +462,240 @@ func main() {
 	cf_lager.AddFlags(flag.CommandLine)
 	flag.Parse()
 
+	// remember which flags were given on the command line before the config
+	// file sets the rest, so that reloading never overrides them either
+	commandLineFlags := config.ExplicitFlags(flag.CommandLine)
+
+	var configErrors config.Errors
+	if *configFile != "" {
+		configErrors = loadConfigFile(*configFile)
+	}
+
 	cf_http.Initialize(*communicationTimeout)
+	rand.Seed(time.Now().UnixNano())
 
 	logger, reconfigurableSink := cf_lager.New(*sessionName)
-	natsClient := diegonats.NewClient()
 	clock := clock.NewClock()
-	syncer := syncer.NewSyncer(clock, *syncInterval, natsClient, logger)
+
+	configErrors = append(configErrors, validateFlags()...)
+
+	hostnamePolicy, err := loadHostnamePolicy(*hostnamePolicyFile)
+	if err != nil {
+		configErrors = append(configErrors, err)
+	}
+
+	routeServicePolicy, err := initializeRouteServicePolicy()
+	if err != nil {
+		configErrors = append(configErrors, err)
+	}
+
+	var routeTemplate *template.Template
+	if *emitterBackend == templateBackend {
+		routeTemplate, err = template_emitter.LoadTemplate(*templateFile)
+		if err != nil {
+			configErrors = append(configErrors, fmt.Errorf("templateFile: %s", err))
+		}
+	}
+
+	if len(configErrors) > 0 {
+		logger.Fatal("invalid-configuration", configErrors)
+	}
 
 	initializeDropsonde(logger)
 
-	natsClientRunner := diegonats.NewClientRunner(*natsAddresses, *natsUsername, *natsPassword, logger, natsClient)
+	routingAPIClient := initializeRoutingAPIClient(logger, clock)
+
+	var (
+		natsClient       diegonats.NATSClient
+		natsClientRunner ifrit.Runner
+		retryQueue       *nats_emitter.RetryQueue
+		routeSyncer      *syncer.Syncer
+		emitter          nats_emitter.NATSEmitter
+	)
+
+	switch *emitterBackend {
+	case natsBackend:
+		natsClient = diegonats.NewClient()
+		natsClientRunner = diegonats.NewClientRunner(*natsAddresses, *natsUsername, *natsPassword, logger, natsClient)
+		routeSyncer = syncer.NewSyncer(clock, *syncInterval, natsClient, logger)
+		if *natsRetryQueueSize > 0 {
+			retryQueue = nats_emitter.NewRetryQueue(natsClient, clock, *natsRetryQueueSize, *natsRetryBaseDelay, *natsRetryMaxDelay, *natsRetryMaxAttempts, logger)
+		}
+		emitter = initializeNatsEmitter(natsClient, retryQueue, routeSyncer, logger)
+	case httpBackend:
+		routeSyncer = syncer.NewSyncerWithEmitInterval(clock, *syncInterval, *httpRouteTTL/2, logger)
+		emitter = http_emitter.New(routingAPIClient, *httpRouteTTL, *routingApiBatchSize, logger)
+	case xdsBackend, templateBackend:
+		routeSyncer = syncer.NewSyncerWithEmitInterval(clock, *syncInterval, *syncInterval, logger)
+	}
 
 	table := initializeRoutingTable(logger)
-	emitter := initializeNatsEmitter(natsClient, logger)
-	watcher := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
-		return watcher.NewWatcher(initializeBBSClient(logger), clock, table, emitter, syncer.Events(), logger).Run(signals, ready)
-	})
+
+	var templateEmitter *template_emitter.Emitter
+	if *emitterBackend == templateBackend {
+		templateEmitter = template_emitter.New(table, routeTemplate, *templateOutputPath, *templateReloadCommand, *templateDebounce, clock, logger)
+		emitter = templateEmitter
+	}
+
+	var xdsEmitter *xds_emitter.Emitter
+	if *xdsAddress != "" && !*dryRun {
+		xdsEmitter = xds_emitter.New(table, *xdsRouteConfigName, *xdsConnectTimeout, clock, logger)
+	}
+	var dnsEmitter *dns_emitter.Emitter
+	if *dnsAddress != "" && !*dryRun {
+		dnsEmitter = dns_emitter.New(table, *dnsTTL, clock, logger)
+	}
+	restored := false
+	if *snapshotPath != "" {
+		restored = restoreSnapshot(table, hostnamePolicy, routeServicePolicy, clock, logger)
+	}
+	tcpTable := initializeTCPRoutingTable(logger)
+	tcpEmitter := initializeTCPEmitter(routingAPIClient, logger)
+
+	if *dryRun {
+		recorder := initializeRecorder(logger)
+		emitter = recorder.Emitter()
+		tcpEmitter = recorder.TCPEmitter()
+	}
+
+	var consulEmitter *consul_emitter.Emitter
+	if *consulServices && !*dryRun {
+		consulEmitter = initializeConsulEmitter(table, logger)
+	}
+
+	emitters := []nats_emitter.NATSEmitter{}
+	if emitter != nil {
+		emitters = append(emitters, emitter)
+	}
+	if xdsEmitter != nil {
+		emitters = append(emitters, xdsEmitter)
+	}
+	if consulEmitter != nil {
+		emitters = append(emitters, consulEmitter)
+	}
+	if dnsEmitter != nil {
+		emitters = append(emitters, dnsEmitter)
+	}
+
+	routeEmitter := emitter
+	if len(emitters) > 1 {
+		routeEmitter = nats_emitter.NewMulti(emitters...)
+	} else if len(emitters) == 1 {
+		routeEmitter = emitters[0]
+	}
+
+	var (
+		shardPresence   ifrit.Runner
+		shardMembership *shard.Membership
+		shardFilter     shard.Filter
+	)
+	if *shardRoutes {
+		shardPresence, shardMembership = initializeSharding(logger, *consulCluster, *lockTTL, *lockRetryInterval, *shardPollInterval, clock)
+		shardFilter = shardMembership
+	}
+
+	routeWatcher := watcher.NewWatcher(initializeBBSClient(logger), clock, table, tcpTable, routeEmitter, tcpEmitter, *coalesceWindow, *coalesceMaxLatency, *bbsResubscribeMinBackoff, *bbsResubscribeMaxBackoff, *cellID, shardFilter, routeSyncer.Events(), logger)
+	if hostnamePolicy != nil {
+		routeWatcher.SetHostnamePolicy(hostnamePolicy)
+	}
+	routeWatcher.SetRouteServicePolicy(routeServicePolicy)
+	if restored {
+		routeWatcher.EmitOnStart()
+	}
+	if consulEmitter != nil {
+		routeWatcher.ObserveSyncs(consulEmitter.Reconcile)
+	}
+
+	var status *health.Status
+	if *healthAddress != "" {
+		status = initializeHealthStatus(natsClient, routeSyncer, routeWatcher)
+	}
 
 	syncRunner := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
-		return syncer.Run(signals, ready)
+		return routeSyncer.Run(signals, ready)
 	})
 
-	lockMaintainer := initializeLockMaintainer(logger, *consulCluster, *sessionName, *lockTTL, *lockRetryInterval, clock)
+	members := grouper.Members{}
+
+	switch {
+	case *dryRun:
+		logger.Info("dry-run-skipping-lock")
+	case *cellID != "":
+		logger.Info("cell-local-skipping-lock", lager.Data{"cell-id": *cellID})
+	case *shardRoutes:
+		members = append(members,
+			grouper.Member{"shard-presence", shardPresence},
+			grouper.Member{"shard-membership", shardMembership},
+		)
+	default:
+		lockMaintainer := initializeLockMaintainer(logger, *lockBackend, *lockFilePath, *consulCluster, *sessionName, *lockTTL, *lockRetryInterval, clock)
+		if status != nil {
+			lockMaintainer = health.TrackLock(lockMaintainer, status)
+		}
+		members = append(members, grouper.Member{"lock-maintainer", lockMaintainer})
+	}
+
+	if natsClientRunner != nil {
+		members = append(members, grouper.Member{"nats-client", natsClientRunner})
+	}
+
+	if retryQueue != nil && !*dryRun {
+		members = append(members, grouper.Member{"nats-retry-queue", retryQueue})
+	}
+
+	if templateEmitter != nil && !*dryRun {
+		members = append(members, grouper.Member{"template-emitter", templateEmitter})
+	}
+
+	if consulEmitter != nil {
+		members = append(members, grouper.Member{"consul-emitter", consulEmitter})
+	}
+
+	if xdsEmitter != nil {
+		members = append(members, grouper.Member{"xds-emitter", xdsEmitter})
+	}
+
+	if dnsEmitter != nil {
+		members = append(members, grouper.Member{"dns-emitter", dnsEmitter})
+	}
 
-	members := grouper.Members{
-		{"lock-maintainer", lockMaintainer},
-		{"nats-client", natsClientRunner},
-		{"watcher", watcher},
+	members = append(members, grouper.Members{
+		{"watcher", routeWatcher},
 		{"syncer", syncRunner},
+	}...)
+
+	if *snapshotPath != "" {
+		members = append(members, grouper.Member{"snapshotter", snapshot.NewSnapshotter(*snapshotPath, *snapshotInterval, table, clock, logger)})
+	}
+
+	if *metricsAddress != "" {
+		members = append(grouper.Members{
+			{"metrics-server", initializeMetricsServer()},
+		}, members...)
+	}
+
+	if status != nil {
+		members = append(grouper.Members{
+			{"health-server", initializeHealthServer(status, logger)},
+		}, members...)
+	}
+
+	if *adminAddress != "" {
+		members = append(grouper.Members{
+			{"admin-server", initializeAdminServer(table, logger)},
+		}, members...)
+	}
+
+	if xdsEmitter != nil {
+		members = append(grouper.Members{
+			{"xds-server", xds_emitter.NewServer(*xdsAddress, xdsEmitter.Cache(), logger)},
+		}, members...)
+	}
+
+	if dnsEmitter != nil {
+		members = append(grouper.Members{
+			{"dns-server", dns_emitter.NewServer(*dnsAddress, dnsEmitter, logger)},
+		}, members...)
 	}
 
 	if dbgAddr := cf_debug_server.DebugAddress(flag.CommandLine); dbgAddr != "" {
@@ -176,13 +704,20 @@ func main() {
 		}, members...)
 	}
 
+	if *configFile != "" {
+		reloadSignals := make(chan os.Signal, 1)
+		signal.Notify(reloadSignals, syscall.SIGHUP)
+		reload := reloadConfig(commandLineFlags, reconfigurableSink, routeSyncer, table, emitter, routeWatcher, logger)
+		members = append(members, grouper.Member{"config-reloader", config.NewReloader(*configFile, reloadSignals, reload, logger)})
+	}
+
 	group := grouper.NewOrdered(os.Interrupt, members)
 
 	monitor := ifrit.Invoke(sigmon.New(group))
 
 	logger.Info("started")
 
-	err := <-monitor.Wait()
+	err = <-monitor.Wait()
 	if err != nil {
 		logger.Error("exited-with-failure", err)
 		os.Exit(1)
@@ -199,25 +734,217 @@ func initializeDropsonde(logger lager.Logger) {
 	}
 }
 
-func initializeNatsEmitter(natsClient diegonats.NATSClient, logger lager.Logger) nats_emitter.NATSEmitter {
+func initializeNatsEmitter(natsClient diegonats.NATSClient, retryQueue *nats_emitter.RetryQueue, routeSyncer *syncer.Syncer, logger lager.Logger) nats_emitter.NATSEmitter {
 	workPool, err := workpool.NewWorkPool(*routeEmittingWorkers)
 	if err != nil {
 		logger.Fatal("failed-to-construct-nats-emitter-workpool", err, lager.Data{"num-workers": *routeEmittingWorkers}) // should never happen
 	}
 
-	return nats_emitter.New(natsClient, workPool, logger)
+	if *natsBatchRegistrations {
+		emitter := nats_emitter.NewBatching(natsClient, workPool, *natsMaxBatchBytes, retryQueue, logger)
+		routeSyncer.ObserveRouterGreetings(emitter.HandleRouterGreeting)
+		return emitter
+	}
+
+	return nats_emitter.New(natsClient, workPool, retryQueue, logger)
+}
+
+func initializeRoutingAPIClient(logger lager.Logger, clock clock.Clock) routing_api.Client {
+	if *routingApiURL == "" {
+		return nil
+	}
+
+	_, err := url.Parse(*routingApiURL)
+	if err != nil {
+		logger.Fatal("invalid-routing-api-url", err)
+	}
+
+	var tokenFetcher routing_api.TokenFetcher
+	if *routingApiTokenURL != "" {
+		tokenFetcher = routing_api.NewOAuthTokenFetcher(*routingApiTokenURL, *routingApiClientID, *routingApiClientSecret, cf_http.NewClient(), clock)
+	}
+
+	return routing_api.NewClient(*routingApiURL, cf_http.NewClient(), tokenFetcher)
+}
+
+func initializeRecorder(logger lager.Logger) *recording_emitter.Recorder {
+	if *dryRunOutput == "" {
+		return recording_emitter.NewRecorder(os.Stdout, logger)
+	}
+
+	output, err := os.OpenFile(*dryRunOutput, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Fatal("failed-to-open-dry-run-output", err, lager.Data{"path": *dryRunOutput})
+	}
+
+	return recording_emitter.NewRecorder(output, logger)
+}
+
+func initializeTCPEmitter(routingAPIClient routing_api.Client, logger lager.Logger) tcp_emitter.TCPEmitter {
+	if routingAPIClient == nil {
+		return nil
+	}
+
+	return tcp_emitter.New(routingAPIClient, *tcpRouteTTL, logger)
+}
+
+func initializeConsulEmitter(table routing_table.RoutingTable, logger lager.Logger) *consul_emitter.Emitter {
+	consulURL, err := url.Parse(strings.Split(*consulCluster, ",")[0])
+	if err != nil {
+		logger.Fatal("invalid-consul-url", err)
+	}
+
+	consulClient, err := api.NewClient(&api.Config{Address: consulURL.Host, Scheme: consulURL.Scheme})
+	if err != nil {
+		logger.Fatal("new-client-failed", err)
+	}
+
+	return consul_emitter.New(consulClient.Catalog(), *consulServiceNode, table, *consulServicePrefix, logger)
+}
+
+func initializeRouteServicePolicy() (routing_table.RouteServicePolicy, error) {
+	deniedNetworks := []string{}
+	for _, network := range strings.Split(*routeServiceDeniedNetworks, ",") {
+		if network = strings.TrimSpace(network); network != "" {
+			deniedNetworks = append(deniedNetworks, network)
+		}
+	}
+
+	policy, err := routing_table.NewRouteServicePolicy(routing_table.RouteServiceRules{
+		AllowInsecure:  *allowInsecureRouteServices,
+		DeniedNetworks: deniedNetworks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("routeServiceDeniedNetworks: %s", err)
+	}
+
+	return policy, nil
+}
+
+func initializeAdminServer(table routing_table.RoutingTable, logger lager.Logger) ifrit.Runner {
+	handler, err := admin.NewHandler(table, logger)
+	if err != nil {
+		logger.Fatal("failed-to-construct-admin-handler", err)
+	}
+
+	return http_server.New(*adminAddress, handler)
+}
+
+func initializeHealthStatus(natsClient diegonats.NATSClient, routeSyncer *syncer.Syncer, routeWatcher *watcher.Watcher) *health.Status {
+	requireLock := !*dryRun && *cellID == "" && !*shardRoutes
+	requireRouterGreeting := natsClient != nil && !*dryRun
+
+	var natsPinger health.NATSPinger
+	if requireRouterGreeting {
+		natsPinger = natsClient
+	}
+
+	status := health.NewStatus(requireLock, requireRouterGreeting, natsPinger)
+	routeSyncer.ObserveRouterGreetings(status.RouterGreeted)
+	routeWatcher.ObserveSyncs(status.SyncCompleted)
+	routeWatcher.ObserveEventSubscription(status.SetEventsSubscribed)
+
+	return status
+}
+
+func initializeHealthServer(status *health.Status, logger lager.Logger) ifrit.Runner {
+	handler, err := health.NewHandler(status, logger)
+	if err != nil {
+		logger.Fatal("failed-to-construct-health-handler", err)
+	}
+
+	return http_server.New(*healthAddress, handler)
+}
+
+// restoreSnapshot swaps the snapshot's routes that the policies still allow
+// into the table, and reports whether it restored any.
+func restoreSnapshot(
+	table routing_table.RoutingTable,
+	hostnamePolicy routing_table.HostnamePolicy,
+	routeServicePolicy routing_table.RouteServicePolicy,
+	clock clock.Clock,
+	logger lager.Logger,
+) bool {
+	logger = logger.Session("restore-snapshot", lager.Data{"path": *snapshotPath})
+
+	restored, err := snapshot.Read(*snapshotPath)
+	if os.IsNotExist(err) {
+		logger.Info("no-snapshot-found")
+		return false
+	}
+	if err != nil {
+		logger.Error("failed-to-read-snapshot", err)
+		return false
+	}
+
+	createdAt := time.Unix(0, restored.CreatedAt)
+	if age := clock.Since(createdAt); *snapshotMaxAge > 0 && age > *snapshotMaxAge {
+		logger.Info("ignoring-stale-snapshot", lager.Data{"created-at": createdAt, "age": age.String(), "max-age": snapshotMaxAge.String()})
+		return false
+	}
+
+	restoredTable, rejections := restored.RoutingTable(hostnamePolicy, routeServicePolicy)
+	if len(rejections) > 0 {
+		logger.Info("left-out-rejected-routes", lager.Data{"routes": len(rejections)})
+	}
+
+	// no domains are fresh yet, so the first sync will reconcile these entries
+	table.Swap(restoredTable, models.DomainSet{})
+	logger.Info("restored", lager.Data{"entries": len(restored.Entries), "created-at": createdAt})
+
+	return table.RouteCount() > 0
+}
+
+func initializeMetricsServer() ifrit.Runner {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metric.Handler())
+
+	return http_server.New(*metricsAddress, mux)
 }
 
 func initializeRoutingTable(logger lager.Logger) routing_table.RoutingTable {
-	return routing_table.NewTable(logger)
+	return routing_table.NewTable(logger, routing_table.HostnameConflictPolicy(*hostnameConflictPolicy))
+}
+
+func initializeTCPRoutingTable(logger lager.Logger) routing_table.TCPRoutingTable {
+	return routing_table.NewTCPTable(logger)
 }
 
 func initializeLockMaintainer(
 	logger lager.Logger,
-	consulCluster, sessionName string,
+	lockBackend, lockFilePath, consulCluster, sessionName string,
 	lockTTL, lockRetryInterval time.Duration,
 	clock clock.Clock,
 ) ifrit.Runner {
+	var serviceClient route_emitter.ServiceClient
+
+	switch lockBackend {
+	case consulLockBackend:
+		consulClient, err := consuladapter.NewClientFromUrl(consulCluster)
+		if err != nil {
+			logger.Fatal("new-client-failed", err)
+		}
+		serviceClient = route_emitter.NewServiceClient(consulClient, clock)
+	case fileLockBackend:
+		serviceClient = route_emitter.NewServiceClientWithLock(nil, clock, lock.NewFileLock(lockFilePath, clock))
+	case noLockBackend:
+		serviceClient = route_emitter.NewServiceClientWithLock(nil, clock, lock.NewNoLock())
+	}
+
+	uuid, err := uuid.NewV4()
+	if err != nil {
+		logger.Fatal("Couldn't generate uuid", err)
+	}
+
+	return serviceClient.NewRouteEmitterLockRunner(logger, uuid.String(), lockRetryInterval, lockTTL)
+}
+
+func initializeSharding(
+	logger lager.Logger,
+	consulCluster string,
+	presenceTTL, retryInterval, pollInterval time.Duration,
+	clock clock.Clock,
+) (ifrit.Runner, *shard.Membership) {
 	consulClient, err := consuladapter.NewClientFromUrl(consulCluster)
 	if err != nil {
 		logger.Fatal("new-client-failed", err)
@@ -227,10 +954,13 @@ func initializeLockMaintainer(
 	if err != nil {
 		logger.Fatal("Couldn't generate uuid", err)
 	}
+	emitterID := uuid.String()
 
 	serviceClient := route_emitter.NewServiceClient(consulClient, clock)
+	presence := serviceClient.NewRouteEmitterShardPresenceRunner(logger, emitterID, retryInterval, presenceTTL)
+	membership := shard.NewMembership(emitterID, serviceClient.RouteEmitterShardMembers(), pollInterval, clock, logger)
 
-	return serviceClient.NewRouteEmitterLockRunner(logger, uuid.String(), lockRetryInterval, lockTTL)
+	return presence, membership
 }
 
 func initializeBBSClient(logger lager.Logger) bbs.Client {
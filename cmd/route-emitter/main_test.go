@@ -2,6 +2,9 @@ package main_test
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"time"
 
 	"code.cloudfoundry.org/bbs/models"
@@ -51,6 +54,7 @@ var _ = Describe("Route Emitter", func() {
 		hostnames     []string
 		containerPort uint32
 		routes        *models.Routes
+		expectedTags  map[string]string
 	)
 
 	BeforeEach(func() {
@@ -82,6 +86,13 @@ var _ = Describe("Route Emitter", func() {
 		instanceKey = models.NewActualLRPInstanceKey("iguid1", "cell-id")
 
 		netInfo = models.NewActualLRPNetInfo("1.2.3.4", models.NewPortMapping(65100, 8080))
+		expectedTags = map[string]string{
+			"component":      "route-emitter",
+			"process_guid":   processGuid,
+			"instance_index": "0",
+			"cell_id":        "cell-id",
+			"domain":         domain,
+		}
 		registeredRoutes = listenForRoutes("router.register")
 		unregisteredRoutes = listenForRoutes("router.unregister")
 
@@ -134,7 +145,7 @@ var _ = Describe("Route Emitter", func() {
 						App:               desiredLRP.LogGuid,
 						PrivateInstanceId: instanceKey.InstanceGuid,
 						RouteServiceUrl:   "https://awesome.com",
-						Tags:              map[string]string{"component": "route-emitter"},
+						Tags:              expectedTags,
 					})))
 				})
 			})
@@ -178,7 +189,7 @@ var _ = Describe("Route Emitter", func() {
 						App:               desiredLRP.LogGuid,
 						PrivateInstanceId: instanceKey.InstanceGuid,
 						RouteServiceUrl:   "https://awesome.com",
-						Tags:              map[string]string{"component": "route-emitter"},
+						Tags:              expectedTags,
 					})))
 				})
 
@@ -262,6 +273,47 @@ var _ = Describe("Route Emitter", func() {
 		})
 	})
 
+	Context("when the emitters elect a leader with a file lock", func() {
+		var (
+			lockDir       string
+			firstEmitter  ifrit.Process
+			secondRunner  *ginkgomon.Runner
+			secondEmitter ifrit.Process
+		)
+
+		BeforeEach(func() {
+			var err error
+			lockDir, err = ioutil.TempDir("", "route-emitter-lock")
+			Expect(err).NotTo(HaveOccurred())
+			lockArgs := []string{
+				"-lockBackend", "file",
+				"-lockFilePath", filepath.Join(lockDir, "route_emitter.lock"),
+				"-consulCluster", "",
+			}
+
+			firstRunner := createEmitterRunner("emitter1", lockArgs...)
+			firstRunner.StartCheck = "emitter1.started"
+			firstEmitter = ginkgomon.Invoke(firstRunner)
+
+			secondRunner = createEmitterRunner("emitter2", lockArgs...)
+			secondRunner.StartCheck = "file-lock.acquiring-lock"
+			secondEmitter = ginkgomon.Invoke(secondRunner)
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(firstEmitter, emitterInterruptTimeout)
+			ginkgomon.Interrupt(secondEmitter, emitterInterruptTimeout)
+			os.RemoveAll(lockDir)
+		})
+
+		It("only activates one emitter at a time", func() {
+			Consistently(secondRunner.Buffer, 3*time.Second).ShouldNot(gbytes.Say("emitter2.started"))
+
+			ginkgomon.Interrupt(firstEmitter, emitterInterruptTimeout)
+			Eventually(secondRunner.Buffer, 10).Should(gbytes.Say("emitter2.started"))
+		})
+	})
+
 	Context("when the legacyBBS has routes to emit in /desired and /actual", func() {
 		var emitter ifrit.Process
 
@@ -290,7 +342,7 @@ var _ = Describe("Route Emitter", func() {
 					App:               "some-log-guid",
 					PrivateInstanceId: "iguid1",
 					RouteServiceUrl:   "https://awesome.com",
-					Tags:              map[string]string{"component": "route-emitter"},
+					Tags:              expectedTags,
 				})))
 			})
 
@@ -316,7 +368,7 @@ var _ = Describe("Route Emitter", func() {
 						Port:              65100,
 						App:               "some-log-guid",
 						PrivateInstanceId: "iguid1",
-						Tags:              map[string]string{"component": "route-emitter"},
+						Tags:              expectedTags,
 					})))
 				})
 			})
@@ -342,7 +394,7 @@ var _ = Describe("Route Emitter", func() {
 						Port:              65100,
 						App:               "some-log-guid",
 						PrivateInstanceId: "iguid1",
-						Tags:              map[string]string{"component": "route-emitter"},
+						Tags:              expectedTags,
 					})))
 				})
 			})
@@ -0,0 +1,143 @@
+// Package config loads flag values from a JSON or YAML file so that the
+// emitter can be configured without a long command line.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Errors collects every problem found in a configuration so that they can be
+// reported together.
+type Errors []error
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Values maps flag names to their values as they would be given on the
+// command line.
+type Values map[string]string
+
+// Load reads a file of flag values. Files ending in .yml or .yaml are parsed
+// as YAML and anything else as JSON. Lists are joined with commas.
+func Load(path string) (Values, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(contents, &raw)
+	default:
+		err = json.Unmarshal(contents, &raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := Values{}
+	var errs Errors
+	for name, value := range raw {
+		stringValue, err := stringify(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+			continue
+		}
+		values[name] = stringValue
+	}
+
+	if len(errs) > 0 {
+		return nil, sorted(errs)
+	}
+
+	return values, nil
+}
+
+// Apply sets each flag in values unless it was given explicitly on the
+// command line, which takes precedence over the file.
+func Apply(flags *flag.FlagSet, values Values) error {
+	explicit := ExplicitFlags(flags)
+
+	var errs Errors
+	for name, value := range values {
+		if flags.Lookup(name) == nil {
+			errs = append(errs, fmt.Errorf("%s: unknown setting", name))
+			continue
+		}
+		if explicit[name] {
+			continue
+		}
+
+		err := flags.Set(name, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return sorted(errs)
+	}
+
+	return nil
+}
+
+// ExplicitFlags returns the names of the flags given on the command line.
+func ExplicitFlags(flags *flag.FlagSet) map[string]bool {
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	return explicit
+}
+
+func stringify(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			stringItem, err := stringify(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = stringItem
+		}
+		return strings.Join(items, ","), nil
+	}
+
+	return "", errors.New("must be a string, number, boolean or list")
+}
+
+func sorted(errs Errors) Errors {
+	sort.Sort(byMessage(errs))
+	return errs
+}
+
+type byMessage Errors
+
+func (e byMessage) Len() int           { return len(e) }
+func (e byMessage) Less(i, j int) bool { return e[i].Error() < e[j].Error() }
+func (e byMessage) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
@@ -0,0 +1,139 @@
+package config_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var tmpDir string
+
+	writeFile := func(name, contents string) string {
+		path := filepath.Join(tmpDir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "config")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("Load", func() {
+		It("reads JSON files", func() {
+			path := writeFile("config.json", `{
+				"syncInterval": "30s",
+				"routeEmittingWorkers": 20,
+				"dryRun": true,
+				"natsAddresses": ["10.0.0.1:4222", "10.0.0.2:4222"]
+			}`)
+
+			values, err := config.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(Equal(config.Values{
+				"syncInterval":         "30s",
+				"routeEmittingWorkers": "20",
+				"dryRun":               "true",
+				"natsAddresses":        "10.0.0.1:4222,10.0.0.2:4222",
+			}))
+		})
+
+		It("reads YAML files", func() {
+			path := writeFile("config.yml", `
+syncInterval: 30s
+routeEmittingWorkers: 20
+dryRun: true
+natsAddresses:
+- 10.0.0.1:4222
+- 10.0.0.2:4222
+`)
+
+			values, err := config.Load(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(Equal(config.Values{
+				"syncInterval":         "30s",
+				"routeEmittingWorkers": "20",
+				"dryRun":               "true",
+				"natsAddresses":        "10.0.0.1:4222,10.0.0.2:4222",
+			}))
+		})
+
+		It("reports every value it cannot use", func() {
+			path := writeFile("config.json", `{"a": {"nested": true}, "b": null, "c": "fine"}`)
+
+			_, err := config.Load(path)
+			Expect(err).To(MatchError("a: must be a string, number, boolean or list; b: must be a string, number, boolean or list"))
+		})
+
+		It("fails on malformed files", func() {
+			path := writeFile("config.json", `{`)
+
+			_, err := config.Load(path)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Apply", func() {
+		var (
+			flags        *flag.FlagSet
+			syncInterval *time.Duration
+			workers      *int
+			sessionName  *string
+		)
+
+		BeforeEach(func() {
+			flags = flag.NewFlagSet("test", flag.ContinueOnError)
+			syncInterval = flags.Duration("syncInterval", time.Minute, "")
+			workers = flags.Int("routeEmittingWorkers", 20, "")
+			sessionName = flags.String("sessionName", "route-emitter", "")
+		})
+
+		It("sets the flags from the values", func() {
+			Expect(flags.Parse([]string{})).To(Succeed())
+
+			err := config.Apply(flags, config.Values{"syncInterval": "30s", "routeEmittingWorkers": "5"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*syncInterval).To(Equal(30 * time.Second))
+			Expect(*workers).To(Equal(5))
+		})
+
+		It("does not override flags given on the command line", func() {
+			Expect(flags.Parse([]string{"-syncInterval", "10s"})).To(Succeed())
+
+			err := config.Apply(flags, config.Values{"syncInterval": "30s", "sessionName": "from-file"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*syncInterval).To(Equal(10 * time.Second))
+			Expect(*sessionName).To(Equal("from-file"))
+		})
+
+		It("reports every unknown or invalid setting at once", func() {
+			Expect(flags.Parse([]string{})).To(Succeed())
+
+			err := config.Apply(flags, config.Values{
+				"syncInterval":         "soon",
+				"routeEmittingWorkers": "many",
+				"bogus":                "true",
+			})
+
+			errs, ok := err.(config.Errors)
+			Expect(ok).To(BeTrue())
+			Expect(errs).To(HaveLen(3))
+			Expect(errs[0]).To(MatchError("bogus: unknown setting"))
+			Expect(errs[1].Error()).To(HavePrefix("routeEmittingWorkers:"))
+			Expect(errs[2].Error()).To(HavePrefix("syncInterval:"))
+		})
+	})
+})
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// Reloader re-reads the configuration file whenever it is sent a reload
+// signal and hands the values to reload. A file that fails to load or apply
+// is logged and the previous configuration is kept.
+type Reloader struct {
+	path          string
+	reloadSignals <-chan os.Signal
+	reload        func(Values) error
+	logger        lager.Logger
+}
+
+func NewReloader(path string, reloadSignals <-chan os.Signal, reload func(Values) error, logger lager.Logger) *Reloader {
+	return &Reloader{
+		path:          path,
+		reloadSignals: reloadSignals,
+		reload:        reload,
+		logger:        logger.Session("config-reloader", lager.Data{"path": path}),
+	}
+}
+
+func (r *Reloader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	for {
+		select {
+		case <-r.reloadSignals:
+			r.logger.Info("reloading")
+
+			values, err := Load(r.path)
+			if err == nil {
+				err = r.reload(values)
+			}
+			if err != nil {
+				r.logger.Error("failed-to-reload", err)
+				continue
+			}
+
+			r.logger.Info("reloaded")
+
+		case <-signals:
+			return nil
+		}
+	}
+}
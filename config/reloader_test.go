@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/route-emitter/config"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Reloader", func() {
+	var (
+		tmpDir        string
+		path          string
+		reloadSignals chan os.Signal
+		reloaded      chan config.Values
+		reloadErr     error
+		logger        *lagertest.TestLogger
+		process       ifrit.Process
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "reloader")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(tmpDir, "config.json")
+		Expect(ioutil.WriteFile(path, []byte(`{"syncInterval": "30s"}`), 0644)).To(Succeed())
+
+		reloadSignals = make(chan os.Signal)
+		reloaded = make(chan config.Values, 1)
+		reloadErr = nil
+		logger = lagertest.NewTestLogger("test")
+
+		reload := func(values config.Values) error {
+			reloaded <- values
+			return reloadErr
+		}
+
+		process = ifrit.Invoke(config.NewReloader(path, reloadSignals, reload, logger))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		os.RemoveAll(tmpDir)
+	})
+
+	It("reloads the file when signalled", func() {
+		Consistently(reloaded).ShouldNot(Receive())
+
+		Expect(ioutil.WriteFile(path, []byte(`{"syncInterval": "1m"}`), 0644)).To(Succeed())
+		reloadSignals <- syscall.SIGHUP
+
+		Eventually(reloaded).Should(Receive(Equal(config.Values{"syncInterval": "1m"})))
+		Eventually(logger).Should(gbytes.Say("config-reloader.reloaded"))
+	})
+
+	Context("when the file cannot be loaded", func() {
+		It("logs the error and keeps running", func() {
+			Expect(ioutil.WriteFile(path, []byte(`{`), 0644)).To(Succeed())
+			reloadSignals <- syscall.SIGHUP
+
+			Eventually(logger).Should(gbytes.Say("config-reloader.failed-to-reload"))
+			Consistently(reloaded).ShouldNot(Receive())
+			Expect(process.Wait()).NotTo(Receive())
+		})
+	})
+
+	Context("when the values are rejected", func() {
+		BeforeEach(func() {
+			reloadErr = errors.New("invalid")
+		})
+
+		It("logs the error and keeps running", func() {
+			reloadSignals <- syscall.SIGHUP
+
+			Eventually(reloaded).Should(Receive())
+			Eventually(logger).Should(gbytes.Say("config-reloader.failed-to-reload"))
+			Expect(process.Wait()).NotTo(Receive())
+		})
+	})
+})
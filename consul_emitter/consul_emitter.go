@@ -0,0 +1,331 @@
+package consul_emitter
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/hashicorp/consul/api"
+	"github.com/pivotal-golang/lager"
+)
+
+// ServiceIDPrefix marks the services registered by the emitter, so that
+// reconciliation never deregisters services registered by anything else.
+const ServiceIDPrefix = "route-emitter:"
+
+const DefaultNodeName = "route-emitter"
+
+var (
+	consulServicesRegistered   = metric.Counter("ConsulServicesRegistered")
+	consulServicesDeregistered = metric.Counter("ConsulServicesDeregistered")
+)
+
+//go:generate counterfeiter -o fake_consul_emitter/fake_catalog.go . Catalog
+
+// Catalog is the part of the consul catalog API the emitter uses; *api.Catalog
+// satisfies it.
+type Catalog interface {
+	Node(node string, q *api.QueryOptions) (*api.CatalogNode, *api.QueryMeta, error)
+	Register(reg *api.CatalogRegistration, q *api.WriteOptions) (*api.WriteMeta, error)
+	Deregister(dereg *api.CatalogDeregistration, q *api.WriteOptions) (*api.WriteMeta, error)
+}
+
+// Emitter registers the endpoints of every routing key in the consul catalog,
+// under a node of their own rather than that of any agent, so that whichever
+// emitter holds the lock owns every registration, and the agents'
+// anti-entropy leaves them alone. Each routing key becomes a service, named
+// after its process guid and container port with an optional prefix, with one
+// instance per endpoint, tagged with the route's hostnames. Entries
+// BoundToRouteService are left out.
+//
+// Emit queues the process guids the messages concern, found through the
+// process_guid tag of each message; a message without one causes every
+// routing key to be updated. Reconcile queues a comparison of the whole
+// routing table with the node's services, removing stale registrations left
+// behind by failures, restarts or a previous lock holder. Run makes the
+// catalog calls for both, so neither blocks the watcher.
+type Emitter struct {
+	catalog    Catalog
+	node       string
+	table      routing_table.RoutingTable
+	namePrefix string
+	logger     lager.Logger
+
+	// registered is only used by Run
+	registered map[string]*api.AgentService
+
+	lock                sync.Mutex
+	pendingProcessGuids map[string]bool
+	pendingAll          bool
+
+	updates    chan struct{}
+	reconciles chan struct{}
+}
+
+func New(catalog Catalog, node string, table routing_table.RoutingTable, namePrefix string, logger lager.Logger) *Emitter {
+	return &Emitter{
+		catalog:    catalog,
+		node:       node,
+		table:      table,
+		namePrefix: namePrefix,
+		logger:     logger.Session("consul-emitter", lager.Data{"node": node}),
+		registered: map[string]*api.AgentService{},
+
+		pendingProcessGuids: map[string]bool{},
+
+		updates:    make(chan struct{}, 1),
+		reconciles: make(chan struct{}, 1),
+	}
+}
+
+// ServiceName returns the name of the consul service for the routing key.
+func (e *Emitter) ServiceName(key routing_table.RoutingKey) string {
+	return fmt.Sprintf("%s%s-%d", e.namePrefix, key.ProcessGuid, key.ContainerPort)
+}
+
+func (e *Emitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	if len(messagesToEmit.RegistrationMessages) == 0 && len(messagesToEmit.UnregistrationMessages) == 0 {
+		return nil
+	}
+
+	processGuids, known := messagesToEmit.ProcessGuids()
+
+	e.lock.Lock()
+	if known {
+		for processGuid := range processGuids {
+			e.pendingProcessGuids[processGuid] = true
+		}
+	} else {
+		e.pendingAll = true
+	}
+	e.lock.Unlock()
+
+	select {
+	case e.updates <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Reconcile requests a comparison of the routing table with the services
+// registered in the catalog. It does not block; the comparison happens in
+// Run, so it can be registered as an observer of the watcher's syncs.
+func (e *Emitter) Reconcile(time.Time) {
+	select {
+	case e.reconciles <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts from the services already registered under the node, so that
+// updates made before the first reconcile neither re-register them nor leave
+// stale ones behind.
+func (e *Emitter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	e.logger.Info("starting")
+
+	current, err := e.nodeServices()
+	if err != nil {
+		// the first reconcile catches up with whatever is registered
+		e.logger.Error("failed-to-list-services", err)
+	} else {
+		e.registered = current
+	}
+
+	close(ready)
+	e.logger.Info("started")
+
+	for {
+		select {
+		case <-e.updates:
+			e.update()
+		case <-e.reconciles:
+			e.reconcile()
+		case <-signals:
+			e.logger.Info("stopping")
+			return nil
+		}
+	}
+}
+
+// update registers and deregisters the instances of the queued process
+// guids.
+func (e *Emitter) update() {
+	e.lock.Lock()
+	processGuids, all := e.pendingProcessGuids, e.pendingAll
+	e.pendingProcessGuids, e.pendingAll = map[string]bool{}, false
+	e.lock.Unlock()
+
+	if len(processGuids) == 0 && !all {
+		return
+	}
+
+	affected := func(processGuid string) bool {
+		return all || processGuids[processGuid]
+	}
+
+	desired := e.registrationsFor(e.table.Entries(), affected)
+
+	current := map[string]*api.AgentService{}
+	for id, service := range e.registered {
+		if affected(e.processGuidOf(service.Service)) {
+			current[id] = service
+		}
+	}
+
+	err := e.apply(current, desired)
+	if err != nil {
+		e.logger.Error("failed-to-update", err)
+	}
+}
+
+func (e *Emitter) reconcile() {
+	logger := e.logger.Session("reconcile")
+
+	current, err := e.nodeServices()
+	if err != nil {
+		logger.Error("failed-to-list-services", err)
+		return
+	}
+
+	desired := e.registrationsFor(e.table.Entries(), func(string) bool { return true })
+
+	e.registered = current
+	err = e.apply(current, desired)
+	if err != nil {
+		logger.Error("failed-to-reconcile", err)
+		return
+	}
+
+	logger.Debug("reconciled", lager.Data{"services": len(e.registered)})
+}
+
+// nodeServices returns the services the emitter registered under the node.
+func (e *Emitter) nodeServices() (map[string]*api.AgentService, error) {
+	node, _, err := e.catalog.Node(e.node, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]*api.AgentService{}
+	if node != nil {
+		for id, service := range node.Services {
+			if strings.HasPrefix(id, ServiceIDPrefix) {
+				current[id] = service
+			}
+		}
+	}
+	return current, nil
+}
+
+// apply registers the desired services that differ from the current ones and
+// deregisters the current services that are no longer desired, keeping track
+// of what is registered as it goes. It carries on past failures, returning
+// the first.
+func (e *Emitter) apply(current, desired map[string]*api.AgentService) error {
+	var finalError error
+	var registered, deregistered uint64
+
+	for id, service := range desired {
+		if existing, ok := current[id]; ok && sameService(existing, service) {
+			continue
+		}
+
+		_, err := e.catalog.Register(&api.CatalogRegistration{
+			Node: e.node,
+			// the node is not a machine; every service carries its own address
+			Address: e.node,
+			Service: service,
+		}, nil)
+		if err != nil {
+			e.logger.Error("failed-to-register-service", err, lager.Data{"service-id": id})
+			if finalError == nil {
+				finalError = err
+			}
+			continue
+		}
+
+		e.registered[id] = service
+		registered++
+	}
+
+	for id := range current {
+		if _, ok := desired[id]; ok {
+			continue
+		}
+
+		_, err := e.catalog.Deregister(&api.CatalogDeregistration{Node: e.node, ServiceID: id}, nil)
+		if err != nil {
+			e.logger.Error("failed-to-deregister-service", err, lager.Data{"service-id": id})
+			if finalError == nil {
+				finalError = err
+			}
+			continue
+		}
+
+		delete(e.registered, id)
+		deregistered++
+	}
+
+	if registered > 0 {
+		consulServicesRegistered.Add(registered)
+	}
+	if deregistered > 0 {
+		consulServicesDeregistered.Add(deregistered)
+	}
+
+	return finalError
+}
+
+func (e *Emitter) registrationsFor(
+	entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints,
+	affected func(processGuid string) bool,
+) map[string]*api.AgentService {
+	services := map[string]*api.AgentService{}
+
+	for key, entry := range entries {
+		if !affected(key.ProcessGuid) || len(entry.Hostnames) == 0 || entry.BoundToRouteService() {
+			continue
+		}
+
+		tags := make([]string, 0, len(entry.Hostnames))
+		for hostname := range entry.Hostnames {
+			tags = append(tags, hostname)
+		}
+		sort.Strings(tags)
+
+		name := e.ServiceName(key)
+		for _, endpoint := range entry.Endpoints {
+			id := fmt.Sprintf("%s%s:%s:%d", ServiceIDPrefix, name, endpoint.Host, endpoint.Port)
+			services[id] = &api.AgentService{
+				ID:      id,
+				Service: name,
+				Tags:    tags,
+				Address: endpoint.Host,
+				Port:    int(endpoint.Port),
+			}
+		}
+	}
+
+	return services
+}
+
+// processGuidOf recovers the process guid from a service name; see
+// ServiceName.
+func (e *Emitter) processGuidOf(name string) string {
+	name = strings.TrimPrefix(name, e.namePrefix)
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func sameService(a, b *api.AgentService) bool {
+	return a.Service == b.Service && a.Address == b.Address && a.Port == b.Port && reflect.DeepEqual(a.Tags, b.Tags)
+}
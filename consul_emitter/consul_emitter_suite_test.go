@@ -0,0 +1,13 @@
+package consul_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestConsulEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Consul Emitter Suite")
+}
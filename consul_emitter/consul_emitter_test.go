@@ -0,0 +1,341 @@
+package consul_emitter_test
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/consul_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/consul_emitter/fake_consul_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/hashicorp/consul/api"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Emitter", func() {
+	var (
+		catalog  *fake_consul_emitter.FakeCatalog
+		table    *fake_routing_table.FakeRoutingTable
+		emitter  *consul_emitter.Emitter
+		entries  map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+		services map[string]*api.AgentService
+		lock     sync.Mutex
+	)
+
+	key1 := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	key2 := routing_table.RoutingKey{ProcessGuid: "pg-2", ContainerPort: 8080}
+
+	messagesFor := func(processGuids ...string) routing_table.MessagesToEmit {
+		messagesToEmit := routing_table.MessagesToEmit{}
+		for _, processGuid := range processGuids {
+			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, routing_table.RegistryMessage{
+				URIs: []string{"foo.example.com"},
+				Tags: map[string]string{"process_guid": processGuid},
+			})
+		}
+		return messagesToEmit
+	}
+
+	serviceIDs := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+
+		ids := []string{}
+		for id := range services {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	deregisteredID := func(i int) string {
+		deregistration, _ := catalog.DeregisterArgsForCall(i)
+		return deregistration.ServiceID
+	}
+
+	BeforeEach(func() {
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+			key1: {
+				Hostnames: map[string]struct{}{"foo.example.com": {}, "bar.example.com/api": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11},
+					{InstanceGuid: "ig-2", Host: "1.1.1.2", Port: 12},
+				}),
+			},
+			key2: {
+				Hostnames: map[string]struct{}{"baz.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{{InstanceGuid: "ig-3", Host: "2.2.2.2", Port: 22}}),
+			},
+		}
+		table = new(fake_routing_table.FakeRoutingTable)
+		table.EntriesStub = func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+			lock.Lock()
+			defer lock.Unlock()
+
+			copied := map[routing_table.RoutingKey]routing_table.RoutableEndpoints{}
+			for key, entry := range entries {
+				copied[key] = entry
+			}
+			return copied
+		}
+
+		services = map[string]*api.AgentService{
+			"other": {ID: "other", Service: "other", Port: 8080},
+		}
+		catalog = new(fake_consul_emitter.FakeCatalog)
+		catalog.NodeStub = func(node string, _ *api.QueryOptions) (*api.CatalogNode, *api.QueryMeta, error) {
+			Expect(node).To(Equal("routes"))
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			copied := map[string]*api.AgentService{}
+			for id, service := range services {
+				copied[id] = service
+			}
+			return &api.CatalogNode{Services: copied}, nil, nil
+		}
+		catalog.RegisterStub = func(registration *api.CatalogRegistration, _ *api.WriteOptions) (*api.WriteMeta, error) {
+			Expect(registration.Node).To(Equal("routes"))
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			services[registration.Service.ID] = registration.Service
+			return nil, nil
+		}
+		catalog.DeregisterStub = func(deregistration *api.CatalogDeregistration, _ *api.WriteOptions) (*api.WriteMeta, error) {
+			Expect(deregistration.Node).To(Equal("routes"))
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			delete(services, deregistration.ServiceID)
+			return nil, nil
+		}
+
+		emitter = consul_emitter.New(catalog, "routes", table, "cf-", lagertest.NewTestLogger("test"))
+	})
+
+	It("names services after the routing key", func() {
+		Expect(emitter.ServiceName(key1)).To(Equal("cf-pg-1-8080"))
+	})
+
+	Describe("Emit", func() {
+		var (
+			logger  *lagertest.TestLogger
+			process ifrit.Process
+		)
+
+		BeforeEach(func() {
+			logger = lagertest.NewTestLogger("test")
+			emitter = consul_emitter.New(catalog, "routes", table, "cf-", logger)
+			process = ifrit.Invoke(emitter)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		})
+
+		It("registers an instance of the routing key's service for every endpoint", func() {
+			Expect(emitter.Emit(messagesFor("pg-1"))).To(Succeed())
+
+			Eventually(catalog.RegisterCallCount).Should(Equal(2))
+			Expect(serviceIDs()).To(ConsistOf("other", "route-emitter:cf-pg-1-8080:1.1.1.1:11", "route-emitter:cf-pg-1-8080:1.1.1.2:12"))
+
+			registration, _ := catalog.RegisterArgsForCall(0)
+			if registration.Service.Port != 11 {
+				registration, _ = catalog.RegisterArgsForCall(1)
+			}
+			Expect(registration.Service).To(Equal(&api.AgentService{
+				ID:      "route-emitter:cf-pg-1-8080:1.1.1.1:11",
+				Service: "cf-pg-1-8080",
+				Tags:    []string{"bar.example.com/api", "foo.example.com"},
+				Address: "1.1.1.1",
+				Port:    11,
+			}))
+		})
+
+		It("does not register the same instance twice", func() {
+			Expect(emitter.Emit(messagesFor("pg-1"))).To(Succeed())
+			Eventually(catalog.RegisterCallCount).Should(Equal(2))
+
+			Expect(emitter.Emit(messagesFor("pg-1"))).To(Succeed())
+			Consistently(catalog.RegisterCallCount).Should(Equal(2))
+		})
+
+		It("re-registers instances whose hostnames changed", func() {
+			Expect(emitter.Emit(messagesFor("pg-2"))).To(Succeed())
+			Eventually(catalog.RegisterCallCount).Should(Equal(1))
+
+			lock.Lock()
+			entries[key2] = routing_table.RoutableEndpoints{
+				Hostnames: map[string]struct{}{"qux.example.com": {}},
+				Endpoints: entries[key2].Endpoints,
+			}
+			lock.Unlock()
+			Expect(emitter.Emit(messagesFor("pg-2"))).To(Succeed())
+
+			Eventually(catalog.RegisterCallCount).Should(Equal(2))
+			registration, _ := catalog.RegisterArgsForCall(1)
+			Expect(registration.Service.Tags).To(Equal([]string{"qux.example.com"}))
+		})
+
+		It("deregisters the instances of endpoints that are gone", func() {
+			Expect(emitter.Emit(messagesFor("pg-1", "pg-2"))).To(Succeed())
+			Eventually(serviceIDs).Should(HaveLen(4))
+
+			lock.Lock()
+			delete(entries, key2)
+			lock.Unlock()
+			Expect(emitter.Emit(routing_table.MessagesToEmit{
+				UnregistrationMessages: messagesFor("pg-2").RegistrationMessages,
+			})).To(Succeed())
+
+			Eventually(catalog.DeregisterCallCount).Should(Equal(1))
+			Expect(deregisteredID(0)).To(Equal("route-emitter:cf-pg-2-8080:2.2.2.2:22"))
+			Expect(serviceIDs()).To(ConsistOf("other", "route-emitter:cf-pg-1-8080:1.1.1.1:11", "route-emitter:cf-pg-1-8080:1.1.1.2:12"))
+		})
+
+		It("updates every routing key when a message has no process guid", func() {
+			Expect(emitter.Emit(routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{{URIs: []string{"foo.example.com"}}},
+			})).To(Succeed())
+			Eventually(catalog.RegisterCallCount).Should(Equal(3))
+		})
+
+		It("leaves out routes bound to a route service", func() {
+			lock.Lock()
+			entries[key2] = routing_table.RoutableEndpoints{
+				Hostnames:       entries[key2].Hostnames,
+				Endpoints:       entries[key2].Endpoints,
+				RouteServiceUrl: "https://rs.example.com",
+			}
+			lock.Unlock()
+
+			Expect(emitter.Emit(messagesFor("pg-2"))).To(Succeed())
+			Consistently(catalog.RegisterCallCount).Should(BeZero())
+		})
+
+		It("carries on when registering fails, logging the error", func() {
+			catalog.RegisterReturns(nil, errors.New("boom"))
+			catalog.RegisterStub = nil
+
+			Expect(emitter.Emit(messagesFor("pg-1"))).To(Succeed())
+			Eventually(catalog.RegisterCallCount).Should(Equal(2))
+			Eventually(logger).Should(gbytes.Say("failed-to-update"))
+
+			catalog.RegisterReturns(nil, nil)
+			Expect(emitter.Emit(messagesFor("pg-1"))).To(Succeed())
+			Eventually(catalog.RegisterCallCount).Should(Equal(4))
+		})
+
+		Context("when the node already has the emitter's services", func() {
+			BeforeEach(func() {
+				process.Signal(os.Interrupt)
+				Eventually(process.Wait()).Should(Receive())
+
+				services["route-emitter:cf-pg-2-8080:2.2.2.2:22"] = &api.AgentService{
+					ID:      "route-emitter:cf-pg-2-8080:2.2.2.2:22",
+					Service: "cf-pg-2-8080",
+					Tags:    []string{"baz.example.com"},
+					Address: "2.2.2.2",
+					Port:    22,
+				}
+				services["route-emitter:cf-pg-2-8080:2.2.2.3:23"] = &api.AgentService{
+					ID:      "route-emitter:cf-pg-2-8080:2.2.2.3:23",
+					Service: "cf-pg-2-8080",
+					Tags:    []string{"baz.example.com"},
+					Address: "2.2.2.3",
+					Port:    23,
+				}
+
+				process = ifrit.Invoke(emitter)
+			})
+
+			It("starts from them", func() {
+				Expect(emitter.Emit(messagesFor("pg-2"))).To(Succeed())
+
+				Eventually(catalog.DeregisterCallCount).Should(Equal(1))
+				Expect(deregisteredID(0)).To(Equal("route-emitter:cf-pg-2-8080:2.2.2.3:23"))
+				Expect(catalog.RegisterCallCount()).To(BeZero())
+			})
+		})
+	})
+
+	It("does not call the catalog from Emit", func() {
+		Expect(emitter.Emit(messagesFor("pg-1"))).To(Succeed())
+		Expect(catalog.RegisterCallCount()).To(BeZero())
+		Expect(catalog.NodeCallCount()).To(BeZero())
+	})
+
+	Describe("Reconcile", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			services["route-emitter:cf-pg-3-8080:3.3.3.3:33"] = &api.AgentService{
+				ID:      "route-emitter:cf-pg-3-8080:3.3.3.3:33",
+				Service: "cf-pg-3-8080",
+				Address: "3.3.3.3",
+				Port:    33,
+			}
+			services["route-emitter:cf-pg-2-8080:2.2.2.2:22"] = &api.AgentService{
+				ID:      "route-emitter:cf-pg-2-8080:2.2.2.2:22",
+				Service: "cf-pg-2-8080",
+				Tags:    []string{"baz.example.com"},
+				Address: "2.2.2.2",
+				Port:    22,
+			}
+
+			process = ifrit.Invoke(emitter)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		})
+
+		It("makes the node's services match the routing table, leaving other services alone", func() {
+			emitter.Reconcile(time.Now())
+
+			Eventually(serviceIDs).Should(ConsistOf(
+				"other",
+				"route-emitter:cf-pg-1-8080:1.1.1.1:11",
+				"route-emitter:cf-pg-1-8080:1.1.1.2:12",
+				"route-emitter:cf-pg-2-8080:2.2.2.2:22",
+			))
+			Expect(catalog.RegisterCallCount()).To(Equal(2))
+			Expect(catalog.DeregisterCallCount()).To(Equal(1))
+			Expect(deregisteredID(0)).To(Equal("route-emitter:cf-pg-3-8080:3.3.3.3:33"))
+		})
+
+		It("registers every service when the node does not exist yet", func() {
+			catalog.NodeStub = nil
+			catalog.NodeReturns(nil, nil, nil)
+
+			emitter.Reconcile(time.Now())
+
+			Eventually(catalog.RegisterCallCount).Should(Equal(3))
+			Expect(catalog.DeregisterCallCount()).To(BeZero())
+		})
+
+		It("restores services removed behind the emitter's back", func() {
+			Expect(emitter.Emit(messagesFor("pg-1"))).To(Succeed())
+			Eventually(catalog.RegisterCallCount).Should(Equal(2))
+
+			lock.Lock()
+			delete(services, "route-emitter:cf-pg-1-8080:1.1.1.1:11")
+			lock.Unlock()
+
+			emitter.Reconcile(time.Now())
+			Eventually(serviceIDs).Should(ContainElement("route-emitter:cf-pg-1-8080:1.1.1.1:11"))
+		})
+	})
+})
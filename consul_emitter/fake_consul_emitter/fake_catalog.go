@@ -0,0 +1,148 @@
+// This file was generated by counterfeiter
+package fake_consul_emitter
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/consul_emitter"
+	"github.com/hashicorp/consul/api"
+)
+
+type FakeCatalog struct {
+	NodeStub        func(node string, q *api.QueryOptions) (*api.CatalogNode, *api.QueryMeta, error)
+	nodeMutex       sync.RWMutex
+	nodeArgsForCall []struct {
+		node string
+		q    *api.QueryOptions
+	}
+	nodeReturns struct {
+		result1 *api.CatalogNode
+		result2 *api.QueryMeta
+		result3 error
+	}
+	RegisterStub        func(reg *api.CatalogRegistration, q *api.WriteOptions) (*api.WriteMeta, error)
+	registerMutex       sync.RWMutex
+	registerArgsForCall []struct {
+		reg *api.CatalogRegistration
+		q   *api.WriteOptions
+	}
+	registerReturns struct {
+		result1 *api.WriteMeta
+		result2 error
+	}
+	DeregisterStub        func(dereg *api.CatalogDeregistration, q *api.WriteOptions) (*api.WriteMeta, error)
+	deregisterMutex       sync.RWMutex
+	deregisterArgsForCall []struct {
+		dereg *api.CatalogDeregistration
+		q     *api.WriteOptions
+	}
+	deregisterReturns struct {
+		result1 *api.WriteMeta
+		result2 error
+	}
+}
+
+func (fake *FakeCatalog) Node(node string, q *api.QueryOptions) (*api.CatalogNode, *api.QueryMeta, error) {
+	fake.nodeMutex.Lock()
+	fake.nodeArgsForCall = append(fake.nodeArgsForCall, struct {
+		node string
+		q    *api.QueryOptions
+	}{node, q})
+	fake.nodeMutex.Unlock()
+	if fake.NodeStub != nil {
+		return fake.NodeStub(node, q)
+	} else {
+		return fake.nodeReturns.result1, fake.nodeReturns.result2, fake.nodeReturns.result3
+	}
+}
+
+func (fake *FakeCatalog) NodeCallCount() int {
+	fake.nodeMutex.RLock()
+	defer fake.nodeMutex.RUnlock()
+	return len(fake.nodeArgsForCall)
+}
+
+func (fake *FakeCatalog) NodeArgsForCall(i int) (string, *api.QueryOptions) {
+	fake.nodeMutex.RLock()
+	defer fake.nodeMutex.RUnlock()
+	return fake.nodeArgsForCall[i].node, fake.nodeArgsForCall[i].q
+}
+
+func (fake *FakeCatalog) NodeReturns(result1 *api.CatalogNode, result2 *api.QueryMeta, result3 error) {
+	fake.NodeStub = nil
+	fake.nodeReturns = struct {
+		result1 *api.CatalogNode
+		result2 *api.QueryMeta
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeCatalog) Register(reg *api.CatalogRegistration, q *api.WriteOptions) (*api.WriteMeta, error) {
+	fake.registerMutex.Lock()
+	fake.registerArgsForCall = append(fake.registerArgsForCall, struct {
+		reg *api.CatalogRegistration
+		q   *api.WriteOptions
+	}{reg, q})
+	fake.registerMutex.Unlock()
+	if fake.RegisterStub != nil {
+		return fake.RegisterStub(reg, q)
+	} else {
+		return fake.registerReturns.result1, fake.registerReturns.result2
+	}
+}
+
+func (fake *FakeCatalog) RegisterCallCount() int {
+	fake.registerMutex.RLock()
+	defer fake.registerMutex.RUnlock()
+	return len(fake.registerArgsForCall)
+}
+
+func (fake *FakeCatalog) RegisterArgsForCall(i int) (*api.CatalogRegistration, *api.WriteOptions) {
+	fake.registerMutex.RLock()
+	defer fake.registerMutex.RUnlock()
+	return fake.registerArgsForCall[i].reg, fake.registerArgsForCall[i].q
+}
+
+func (fake *FakeCatalog) RegisterReturns(result1 *api.WriteMeta, result2 error) {
+	fake.RegisterStub = nil
+	fake.registerReturns = struct {
+		result1 *api.WriteMeta
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCatalog) Deregister(dereg *api.CatalogDeregistration, q *api.WriteOptions) (*api.WriteMeta, error) {
+	fake.deregisterMutex.Lock()
+	fake.deregisterArgsForCall = append(fake.deregisterArgsForCall, struct {
+		dereg *api.CatalogDeregistration
+		q     *api.WriteOptions
+	}{dereg, q})
+	fake.deregisterMutex.Unlock()
+	if fake.DeregisterStub != nil {
+		return fake.DeregisterStub(dereg, q)
+	} else {
+		return fake.deregisterReturns.result1, fake.deregisterReturns.result2
+	}
+}
+
+func (fake *FakeCatalog) DeregisterCallCount() int {
+	fake.deregisterMutex.RLock()
+	defer fake.deregisterMutex.RUnlock()
+	return len(fake.deregisterArgsForCall)
+}
+
+func (fake *FakeCatalog) DeregisterArgsForCall(i int) (*api.CatalogDeregistration, *api.WriteOptions) {
+	fake.deregisterMutex.RLock()
+	defer fake.deregisterMutex.RUnlock()
+	return fake.deregisterArgsForCall[i].dereg, fake.deregisterArgsForCall[i].q
+}
+
+func (fake *FakeCatalog) DeregisterReturns(result1 *api.WriteMeta, result2 error) {
+	fake.DeregisterStub = nil
+	fake.deregisterReturns = struct {
+		result1 *api.WriteMeta
+		result2 error
+	}{result1, result2}
+}
+
+var _ consul_emitter.Catalog = new(FakeCatalog)
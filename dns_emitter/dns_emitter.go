@@ -0,0 +1,300 @@
+package dns_emitter
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+const DefaultTTL = 5 * time.Second
+
+// RebuildInterval is how long Run waits after a change before rebuilding the
+// records, so that bursts of changes cause a single rebuild.
+const RebuildInterval = time.Second
+
+// optSize is the size of the OPT record added to replies to EDNS0 queries.
+const optSize = 11
+
+var (
+	dnsHostnames  = metric.Metric("DNSHostnamesTotal")
+	dnsQueries    = metric.Counter("DNSQueries")
+	dnsNameErrors = metric.Counter("DNSNameErrors")
+)
+
+// Emitter answers DNS queries for routed hostnames with the addresses of
+// their endpoints. It implements the emitter interface so that its records
+// follow the routes emitted to the router, but Emit only schedules a rebuild
+// of them from the whole routing table: rebuilds run in Run, right away for
+// the first Emit and then at most once per RebuildInterval.
+//
+// A and AAAA queries for a hostname return the addresses of its endpoints.
+// SRV queries, for the hostname itself or prefixed with service and protocol
+// labels such as _http._tcp, also return their ports; each target is named
+// by its address label under the queried hostname, and resolves to that
+// address alone. Answers are authoritative and shuffled on every query.
+// Names without routes get NXDOMAIN and routed hostnames get empty answers for
+// other record types, neither with an SOA record, so resolvers do not cache
+// them. Until the records are first built, which only happens on the emitter
+// holding the lock, every query is REFUSED, so that clients ask another
+// server rather than trusting an empty table or treating the names as gone.
+type Emitter struct {
+	table  routing_table.RoutingTable
+	ttl    uint32
+	clock  clock.Clock
+	logger lager.Logger
+
+	triggers chan struct{}
+
+	lock    sync.RWMutex
+	records *Records
+}
+
+func New(table routing_table.RoutingTable, ttl time.Duration, clock clock.Clock, logger lager.Logger) *Emitter {
+	return &Emitter{
+		table:    table,
+		ttl:      uint32(ttl / time.Second),
+		clock:    clock,
+		logger:   logger.Session("dns-emitter"),
+		triggers: make(chan struct{}, 1),
+	}
+}
+
+func (e *Emitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	// the first emit builds the records even if the table is empty
+	if e.Records() != nil && len(messagesToEmit.RegistrationMessages) == 0 && len(messagesToEmit.UnregistrationMessages) == 0 {
+		return nil
+	}
+
+	select {
+	case e.triggers <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (e *Emitter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	e.logger.Info("starting")
+	close(ready)
+	e.logger.Info("started")
+
+	var timer clock.Timer
+	var timerC <-chan time.Time
+
+	for {
+		select {
+		case <-e.triggers:
+			if e.Records() == nil {
+				e.rebuild()
+			} else if timerC == nil {
+				timer = e.clock.NewTimer(RebuildInterval)
+				timerC = timer.C()
+			}
+
+		case <-timerC:
+			timerC = nil
+			e.rebuild()
+
+		case <-signals:
+			e.logger.Info("stopping")
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+	}
+}
+
+func (e *Emitter) rebuild() {
+	records := NewRecords(e.table.Entries())
+
+	e.lock.Lock()
+	e.records = records
+	e.lock.Unlock()
+
+	err := dnsHostnames.Send(records.Len())
+	if err != nil {
+		e.logger.Error("failed-to-send-dns-hostnames-metric", err)
+	}
+}
+
+// Records returns the records currently served, or nil before they are first
+// built.
+func (e *Emitter) Records() *Records {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.records
+}
+
+func (e *Emitter) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	dnsQueries.Increment()
+
+	response := e.answer(req, e.Records())
+	if response.Rcode == dns.RcodeNameError {
+		dnsNameErrors.Increment()
+	}
+
+	size := dns.MinMsgSize
+	opt := req.IsEdns0()
+	if opt != nil && int(opt.UDPSize()) > size {
+		size = int(opt.UDPSize())
+	}
+
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		limit := size
+		if opt != nil {
+			limit -= optSize
+		}
+		truncate(response, limit)
+	}
+
+	if opt != nil {
+		response.SetEdns0(uint16(size), false)
+	}
+
+	err := w.WriteMsg(response)
+	if err != nil {
+		e.logger.Error("failed-to-write-response", err, lager.Data{"remote-addr": w.RemoteAddr().String()})
+	}
+}
+
+func (e *Emitter) answer(req *dns.Msg, records *Records) *dns.Msg {
+	response := new(dns.Msg)
+	response.SetReply(req)
+
+	switch {
+	case records == nil:
+		response.Rcode = dns.RcodeRefused
+		return response
+	case req.Opcode != dns.OpcodeQuery:
+		response.Rcode = dns.RcodeNotImplemented
+		return response
+	case len(req.Question) != 1:
+		response.Rcode = dns.RcodeFormatError
+		return response
+	}
+
+	question := req.Question[0]
+	if question.Qclass != dns.ClassINET {
+		response.Rcode = dns.RcodeRefused
+		return response
+	}
+
+	response.Authoritative = true
+
+	switch question.Qtype {
+	case dns.TypeSRV:
+		hostname := serviceHostname(question.Name)
+		targets, ok := records.Lookup(hostname)
+		if !ok {
+			response.Rcode = dns.RcodeNameError
+			return response
+		}
+
+		for _, target := range shuffle(targets) {
+			name := dns.Fqdn(target.Label + "." + hostname)
+			response.Answer = append(response.Answer, &dns.SRV{
+				Hdr:      e.header(question.Name, dns.TypeSRV),
+				Priority: 0,
+				Weight:   1,
+				Port:     uint16(target.Port),
+				Target:   name,
+			})
+			if rr := e.addressRecord(name, target); rr != nil {
+				response.Extra = append(response.Extra, rr)
+			}
+		}
+
+	case dns.TypeA, dns.TypeAAAA:
+		targets, ok := records.LookupAddress(question.Name)
+		if !ok {
+			response.Rcode = dns.RcodeNameError
+			return response
+		}
+
+		seen := map[string]struct{}{}
+		for _, target := range shuffle(targets) {
+			if _, ok := seen[target.Host]; ok {
+				continue
+			}
+			seen[target.Host] = struct{}{}
+
+			rr := e.addressRecord(question.Name, target)
+			if rr != nil && rr.Header().Rrtype == question.Qtype {
+				response.Answer = append(response.Answer, rr)
+			}
+		}
+
+	default:
+		if _, ok := records.LookupAddress(question.Name); !ok {
+			response.Rcode = dns.RcodeNameError
+		}
+	}
+
+	return response
+}
+
+func (e *Emitter) header(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: e.ttl}
+}
+
+// addressRecord returns the A or AAAA record of the target, or nil if its
+// host is not an IP address.
+func (e *Emitter) addressRecord(name string, target Target) dns.RR {
+	ip := target.IP()
+	switch {
+	case ip == nil:
+		return nil
+	case ip.To4() != nil:
+		return &dns.A{Hdr: e.header(name, dns.TypeA), A: ip.To4()}
+	default:
+		return &dns.AAAA{Hdr: e.header(name, dns.TypeAAAA), AAAA: ip}
+	}
+}
+
+// serviceHostname strips leading service and protocol labels, such as
+// _http._tcp, from the name of an SRV query.
+func serviceHostname(name string) string {
+	hostname, _ := cfroutes.SplitURI(name)
+	for strings.HasPrefix(hostname, "_") {
+		i := strings.Index(hostname, ".")
+		if i < 0 {
+			return ""
+		}
+		hostname = hostname[i+1:]
+	}
+	return hostname
+}
+
+func shuffle(targets []Target) []Target {
+	shuffled := make([]Target, len(targets))
+	for i, j := range rand.Perm(len(targets)) {
+		shuffled[i] = targets[j]
+	}
+	return shuffled
+}
+
+// truncate drops additional records and then answers until the response fits
+// in size bytes, marking it truncated if any answers were dropped so that the
+// client retries over TCP.
+func truncate(response *dns.Msg, size int) {
+	if response.Len() <= size {
+		return
+	}
+
+	response.Extra = nil
+	for response.Len() > size && len(response.Answer) > 0 {
+		response.Answer = response.Answer[:len(response.Answer)-1]
+		response.Truncated = true
+	}
+}
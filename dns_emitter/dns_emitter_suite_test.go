@@ -0,0 +1,13 @@
+package dns_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDnsEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DNS Emitter Suite")
+}
@@ -0,0 +1,235 @@
+package dns_emitter_test
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/dns_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Emitter", func() {
+	var (
+		table          *fake_routing_table.FakeRoutingTable
+		clock          *fakeclock.FakeClock
+		emitter        *dns_emitter.Emitter
+		entries        map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+		address        string
+		process        ifrit.Process
+		emitterProcess ifrit.Process
+	)
+
+	key1 := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	key2 := routing_table.RoutingKey{ProcessGuid: "pg-2", ContainerPort: 8080}
+
+	messages := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{{URIs: []string{"foo.example.com"}}},
+	}
+
+	query := func(net, name string, qtype uint16) *dns.Msg {
+		req := new(dns.Msg)
+		req.SetQuestion(name, qtype)
+
+		client := &dns.Client{Net: net}
+		response, _, err := client.Exchange(req, address)
+		Expect(err).NotTo(HaveOccurred())
+		return response
+	}
+
+	addresses := func(rrs []dns.RR) []string {
+		addresses := []string{}
+		for _, rr := range rrs {
+			switch rr := rr.(type) {
+			case *dns.A:
+				addresses = append(addresses, rr.Hdr.Name+" "+rr.A.String())
+			case *dns.AAAA:
+				addresses = append(addresses, rr.Hdr.Name+" "+rr.AAAA.String())
+			}
+		}
+		return addresses
+	}
+
+	BeforeEach(func() {
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+			key1: {
+				Hostnames: map[string]struct{}{"foo.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-1", Host: "10.0.0.1", Port: 61001},
+					{InstanceGuid: "ig-2", Host: "10.0.0.2", Port: 61002},
+				}),
+			},
+			key2: {
+				Hostnames: map[string]struct{}{"foo.example.com": {}, "bar.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-3", Host: "fd00::3", Port: 61003},
+				}),
+			},
+		}
+
+		table = new(fake_routing_table.FakeRoutingTable)
+		table.EntriesStub = func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+			return entries
+		}
+
+		address = fmt.Sprintf("127.0.0.1:%d", 15353+GinkgoParallelNode())
+		clock = fakeclock.NewFakeClock(time.Now())
+		emitter = dns_emitter.New(table, 30*time.Second, clock, lagertest.NewTestLogger("test"))
+		emitterProcess = ifrit.Invoke(emitter)
+		process = ifrit.Invoke(dns_emitter.NewServer(address, emitter, lagertest.NewTestLogger("test")))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		emitterProcess.Signal(os.Interrupt)
+		Eventually(emitterProcess.Wait()).Should(Receive())
+	})
+
+	It("refuses queries until the first emit", func() {
+		response := query("udp", "foo.example.com.", dns.TypeA)
+		Expect(response.Rcode).To(Equal(dns.RcodeRefused))
+		Expect(response.Authoritative).To(BeFalse())
+	})
+
+	It("builds the records on the first emit, even without messages", func() {
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{}
+		Expect(emitter.Emit(routing_table.MessagesToEmit{})).To(Succeed())
+		Eventually(emitter.Records).ShouldNot(BeNil())
+		Expect(table.EntriesCallCount()).To(Equal(1))
+
+		response := query("udp", "foo.example.com.", dns.TypeA)
+		Expect(response.Rcode).To(Equal(dns.RcodeNameError))
+
+		Expect(emitter.Emit(routing_table.MessagesToEmit{})).To(Succeed())
+		Consistently(clock.WatcherCount).Should(Equal(0))
+		Expect(table.EntriesCallCount()).To(Equal(1))
+	})
+
+	It("rebuilds the records once for changes emitted within the rebuild interval", func() {
+		Expect(emitter.Emit(messages)).To(Succeed())
+		Eventually(emitter.Records).ShouldNot(BeNil())
+
+		Expect(emitter.Emit(messages)).To(Succeed())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		Expect(emitter.Emit(messages)).To(Succeed())
+		Expect(table.EntriesCallCount()).To(Equal(1))
+
+		clock.Increment(dns_emitter.RebuildInterval)
+		Eventually(table.EntriesCallCount).Should(Equal(2))
+		Consistently(table.EntriesCallCount).Should(Equal(2))
+	})
+
+	Context("once routes are emitted", func() {
+		BeforeEach(func() {
+			Expect(emitter.Emit(messages)).To(Succeed())
+			Eventually(emitter.Records).ShouldNot(BeNil())
+		})
+
+		It("answers A queries with the addresses of the hostname's endpoints", func() {
+			response := query("udp", "foo.example.com.", dns.TypeA)
+			Expect(response.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(response.Authoritative).To(BeTrue())
+			Expect(addresses(response.Answer)).To(ConsistOf("foo.example.com. 10.0.0.1", "foo.example.com. 10.0.0.2"))
+			Expect(response.Answer[0].Header().Ttl).To(BeEquivalentTo(30))
+		})
+
+		It("answers AAAA queries with the IPv6 addresses", func() {
+			response := query("udp", "foo.example.com.", dns.TypeAAAA)
+			Expect(addresses(response.Answer)).To(ConsistOf("foo.example.com. fd00::3"))
+		})
+
+		It("answers SRV queries with the ports of the endpoints, and their addresses", func() {
+			response := query("udp", "_http._tcp.foo.example.com.", dns.TypeSRV)
+			Expect(response.Rcode).To(Equal(dns.RcodeSuccess))
+
+			targets := []string{}
+			for _, rr := range response.Answer {
+				srv := rr.(*dns.SRV)
+				Expect(srv.Hdr.Name).To(Equal("_http._tcp.foo.example.com."))
+				targets = append(targets, fmt.Sprintf("%s:%d", srv.Target, srv.Port))
+			}
+			Expect(targets).To(ConsistOf(
+				"10-0-0-1.foo.example.com.:61001",
+				"10-0-0-2.foo.example.com.:61002",
+				"fd00--3.foo.example.com.:61003",
+			))
+
+			Expect(addresses(response.Extra)).To(ConsistOf(
+				"10-0-0-1.foo.example.com. 10.0.0.1",
+				"10-0-0-2.foo.example.com. 10.0.0.2",
+				"fd00--3.foo.example.com. fd00::3",
+			))
+		})
+
+		It("resolves SRV targets to their own address", func() {
+			response := query("udp", "10-0-0-2.foo.example.com.", dns.TypeA)
+			Expect(addresses(response.Answer)).To(ConsistOf("10-0-0-2.foo.example.com. 10.0.0.2"))
+		})
+
+		It("answers other queries for routed hostnames with no records", func() {
+			response := query("udp", "bar.example.com.", dns.TypeTXT)
+			Expect(response.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(response.Answer).To(BeEmpty())
+
+			response = query("udp", "bar.example.com.", dns.TypeA)
+			Expect(response.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(response.Answer).To(BeEmpty())
+		})
+
+		It("answers NXDOMAIN for names without routes", func() {
+			response := query("tcp", "baz.example.com.", dns.TypeA)
+			Expect(response.Rcode).To(Equal(dns.RcodeNameError))
+		})
+
+		It("answers from the table as of the latest rebuild", func() {
+			entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{key2: entries[key2]}
+			Expect(emitter.Emit(messages)).To(Succeed())
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			clock.Increment(dns_emitter.RebuildInterval)
+			Eventually(table.EntriesCallCount).Should(Equal(2))
+
+			Eventually(func() []dns.RR {
+				return query("udp", "foo.example.com.", dns.TypeA).Answer
+			}).Should(BeEmpty())
+		})
+	})
+
+	Context("when the answer does not fit in a UDP response", func() {
+		BeforeEach(func() {
+			endpoints := []routing_table.Endpoint{}
+			for i := 0; i < 100; i++ {
+				endpoints = append(endpoints, routing_table.Endpoint{
+					InstanceGuid: fmt.Sprintf("ig-%d", i),
+					Host:         fmt.Sprintf("10.0.1.%d", i),
+					Port:         61000,
+				})
+			}
+			entries[key1] = routing_table.RoutableEndpoints{
+				Hostnames: map[string]struct{}{"foo.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap(endpoints),
+			}
+
+			Expect(emitter.Emit(messages)).To(Succeed())
+			Eventually(emitter.Records).ShouldNot(BeNil())
+		})
+
+		It("truncates it, so that the client retries over TCP", func() {
+			response := query("udp", "foo.example.com.", dns.TypeA)
+			Expect(response.Truncated).To(BeTrue())
+			Expect(len(response.Answer)).To(BeNumerically("<", 100))
+
+			response = query("tcp", "foo.example.com.", dns.TypeA)
+			Expect(response.Truncated).To(BeFalse())
+			Expect(response.Answer).To(HaveLen(100))
+		})
+	})
+})
@@ -0,0 +1,152 @@
+package dns_emitter
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+)
+
+// Target is an endpoint a hostname resolves to. Label names the target within
+// the hostname in SRV answers, so that the target can itself be resolved.
+type Target struct {
+	Host  string
+	Port  uint32
+	Label string
+}
+
+// IP returns the address of the target, or nil if its host is not an IP
+// address.
+func (t Target) IP() net.IP {
+	return net.ParseIP(t.Host)
+}
+
+// Records maps each routed hostname to the endpoints it resolves to. It is
+// immutable once built.
+type Records struct {
+	targets map[string][]Target
+}
+
+// NewRecords indexes the endpoints of the routing table entries by hostname,
+// ignoring any context path, so that every endpoint the router would send a
+// hostname's requests to is among its targets. Entries BoundToRouteService
+// are left out.
+func NewRecords(entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints) *Records {
+	seen := map[string]map[Target]struct{}{}
+
+	for _, entry := range entries {
+		if entry.BoundToRouteService() || len(entry.Endpoints) == 0 {
+			continue
+		}
+
+		for uri := range entry.Hostnames {
+			hostname, _ := cfroutes.SplitURI(uri)
+			if hostname == "" {
+				continue
+			}
+
+			targets, ok := seen[hostname]
+			if !ok {
+				targets = map[Target]struct{}{}
+				seen[hostname] = targets
+			}
+
+			for _, endpoint := range entry.Endpoints {
+				targets[Target{
+					Host:  endpoint.Host,
+					Port:  endpoint.Port,
+					Label: addressLabel(endpoint.Host),
+				}] = struct{}{}
+			}
+		}
+	}
+
+	records := &Records{targets: make(map[string][]Target, len(seen))}
+	for hostname, targets := range seen {
+		sorted := make([]Target, 0, len(targets))
+		for target := range targets {
+			sorted = append(sorted, target)
+		}
+		sort.Sort(byAddress(sorted))
+		records.targets[hostname] = sorted
+	}
+
+	return records
+}
+
+// Len returns the number of hostnames with records.
+func (r *Records) Len() int {
+	return len(r.targets)
+}
+
+// Lookup returns the targets of a hostname. As with the router, a hostname
+// without routes of its own matches the most specific wildcard route above
+// it, so foo.bar.example.com falls back to *.bar.example.com and then to
+// *.example.com.
+func (r *Records) Lookup(name string) ([]Target, bool) {
+	hostname, _ := cfroutes.SplitURI(name)
+
+	if targets, ok := r.targets[hostname]; ok {
+		return targets, true
+	}
+
+	return r.wildcard(hostname)
+}
+
+// LookupAddress returns the targets an address query for the name resolves
+// to. Besides routed hostnames, these are the names of SRV targets: an
+// address label followed by the hostname the target belongs to. Those take
+// precedence over wildcard routes, so that a target under a wildcard resolves
+// to its own address.
+func (r *Records) LookupAddress(name string) ([]Target, bool) {
+	hostname, _ := cfroutes.SplitURI(name)
+
+	if targets, ok := r.targets[hostname]; ok {
+		return targets, true
+	}
+
+	if i := strings.Index(hostname, "."); i >= 0 {
+		if targets, ok := r.Lookup(hostname[i+1:]); ok {
+			for _, target := range targets {
+				if target.Label == hostname[:i] {
+					return []Target{target}, true
+				}
+			}
+		}
+	}
+
+	return r.wildcard(hostname)
+}
+
+func (r *Records) wildcard(hostname string) ([]Target, bool) {
+	for parent := hostname; ; {
+		i := strings.Index(parent, ".")
+		if i < 0 {
+			return nil, false
+		}
+		parent = parent[i+1:]
+
+		if targets, ok := r.targets["*."+parent]; ok {
+			return targets, true
+		}
+	}
+}
+
+// addressLabel turns a host into a single DNS label, e.g. 10.0.16.4 into
+// 10-0-16-4.
+func addressLabel(host string) string {
+	return strings.NewReplacer(".", "-", ":", "-").Replace(strings.ToLower(host))
+}
+
+type byAddress []Target
+
+func (t byAddress) Len() int      { return len(t) }
+func (t byAddress) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t byAddress) Less(i, j int) bool {
+	if t[i].Host != t[j].Host {
+		return t[i].Host < t[j].Host
+	}
+	return t[i].Port < t[j].Port
+}
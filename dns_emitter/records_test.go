@@ -0,0 +1,117 @@
+package dns_emitter_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/dns_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Records", func() {
+	var (
+		entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+		records *dns_emitter.Records
+	)
+
+	key1 := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	key2 := routing_table.RoutingKey{ProcessGuid: "pg-2", ContainerPort: 8080}
+
+	target := func(host string, port uint32, label string) dns_emitter.Target {
+		return dns_emitter.Target{Host: host, Port: port, Label: label}
+	}
+
+	BeforeEach(func() {
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+			key1: {
+				Hostnames: map[string]struct{}{"Foo.example.com": {}, "foo.example.com/api": {}, "*.apps.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-1", Host: "10.0.0.2", Port: 61001},
+					{InstanceGuid: "ig-2", Host: "10.0.0.1", Port: 61002},
+				}),
+			},
+			key2: {
+				Hostnames: map[string]struct{}{"foo.example.com": {}, "*.bar.apps.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-3", Host: "10.0.0.3", Port: 61003},
+				}),
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		records = dns_emitter.NewRecords(entries)
+	})
+
+	It("resolves hostnames to the endpoints of every route claiming them, ignoring case and context paths", func() {
+		targets, ok := records.Lookup("FOO.example.com.")
+		Expect(ok).To(BeTrue())
+		Expect(targets).To(Equal([]dns_emitter.Target{
+			target("10.0.0.1", 61002, "10-0-0-1"),
+			target("10.0.0.2", 61001, "10-0-0-2"),
+			target("10.0.0.3", 61003, "10-0-0-3"),
+		}))
+		Expect(records.Len()).To(Equal(3))
+	})
+
+	It("does not resolve hostnames without routes", func() {
+		_, ok := records.Lookup("bar.example.com")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("falls back to the most specific wildcard route", func() {
+		targets, ok := records.Lookup("baz.apps.example.com")
+		Expect(ok).To(BeTrue())
+		Expect(targets).To(HaveLen(2))
+
+		targets, ok = records.Lookup("baz.bar.apps.example.com")
+		Expect(ok).To(BeTrue())
+		Expect(targets).To(Equal([]dns_emitter.Target{target("10.0.0.3", 61003, "10-0-0-3")}))
+
+		_, ok = records.Lookup("apps.example.com")
+		Expect(ok).To(BeFalse())
+	})
+
+	Context("when a route is bound to a route service", func() {
+		BeforeEach(func() {
+			entry := entries[key2]
+			entry.RouteServiceUrl = "https://rs.example.com"
+			entries[key2] = entry
+		})
+
+		It("leaves its endpoints out", func() {
+			targets, ok := records.Lookup("foo.example.com")
+			Expect(ok).To(BeTrue())
+			Expect(targets).To(HaveLen(2))
+
+			targets, ok = records.Lookup("baz.bar.apps.example.com")
+			Expect(ok).To(BeTrue())
+			Expect(targets).To(HaveLen(2))
+		})
+	})
+
+	Describe("LookupAddress", func() {
+		It("resolves hostnames", func() {
+			targets, ok := records.LookupAddress("foo.example.com")
+			Expect(ok).To(BeTrue())
+			Expect(targets).To(HaveLen(3))
+		})
+
+		It("resolves targets by their address label", func() {
+			targets, ok := records.LookupAddress("10-0-0-3.foo.example.com")
+			Expect(ok).To(BeTrue())
+			Expect(targets).To(Equal([]dns_emitter.Target{target("10.0.0.3", 61003, "10-0-0-3")}))
+		})
+
+		It("prefers targets to wildcard routes", func() {
+			targets, ok := records.LookupAddress("10-0-0-1.baz.apps.example.com")
+			Expect(ok).To(BeTrue())
+			Expect(targets).To(Equal([]dns_emitter.Target{target("10.0.0.1", 61002, "10-0-0-1")}))
+		})
+
+		It("does not resolve labels of other hostnames' targets", func() {
+			_, ok := records.LookupAddress("10-0-0-1.bar.example.com")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package dns_emitter
+
+import (
+	"net"
+	"os"
+
+	"github.com/miekg/dns"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// NewServer returns a runner answering DNS queries with the handler on
+// address, over both UDP and TCP so that clients can retry truncated answers.
+func NewServer(address string, handler dns.Handler, logger lager.Logger) ifrit.Runner {
+	logger = logger.Session("dns-server", lager.Data{"address": address})
+
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		packetConn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			logger.Error("failed-to-listen", err, lager.Data{"net": "udp"})
+			return err
+		}
+
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			packetConn.Close()
+			logger.Error("failed-to-listen", err, lager.Data{"net": "tcp"})
+			return err
+		}
+
+		// the servers can only be shut down once they have started
+		started := make(chan struct{}, 2)
+		notifyStarted := func() { started <- struct{}{} }
+
+		udpServer := &dns.Server{PacketConn: packetConn, Handler: handler, NotifyStartedFunc: notifyStarted}
+		tcpServer := &dns.Server{Listener: listener, Handler: handler, NotifyStartedFunc: notifyStarted}
+
+		errs := make(chan error, 2)
+		go func() {
+			errs <- udpServer.ActivateAndServe()
+		}()
+		go func() {
+			errs <- tcpServer.ActivateAndServe()
+		}()
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-started:
+			case err := <-errs:
+				logger.Error("failed-to-serve", err)
+				packetConn.Close()
+				listener.Close()
+				return err
+			}
+		}
+
+		logger.Info("started")
+		close(ready)
+
+		select {
+		case <-signals:
+			logger.Info("stopping")
+			udpServer.Shutdown()
+			tcpServer.Shutdown()
+			return nil
+		case err := <-errs:
+			logger.Error("failed-to-serve", err)
+			udpServer.Shutdown()
+			tcpServer.Shutdown()
+			return err
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/rata"
+)
+
+type handler struct {
+	status *Status
+	logger lager.Logger
+}
+
+// NewHandler serves the status report. /health always succeeds while the
+// emitter is running; /ready fails with 503 until the emitter is emitting
+// routes.
+func NewHandler(status *Status, logger lager.Logger) (http.Handler, error) {
+	h := &handler{
+		status: status,
+		logger: logger.Session("health"),
+	}
+
+	return rata.NewRouter(Routes, rata.Handlers{
+		HealthRoute: http.HandlerFunc(h.health),
+		ReadyRoute:  http.HandlerFunc(h.ready),
+	})
+}
+
+func (h *handler) health(w http.ResponseWriter, req *http.Request) {
+	h.respond(w, h.status.Report(), http.StatusOK)
+}
+
+func (h *handler) ready(w http.ResponseWriter, req *http.Request) {
+	report := h.status.Report()
+
+	statusCode := http.StatusOK
+	if !report.Ready {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	h.respond(w, report, statusCode)
+}
+
+func (h *handler) respond(w http.ResponseWriter, report Report, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		h.logger.Error("failed-to-encode-response", err)
+	}
+}
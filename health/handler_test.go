@@ -0,0 +1,139 @@
+package health_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/health"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakePinger struct {
+	connected bool
+}
+
+func (p *fakePinger) Ping() bool {
+	return p.connected
+}
+
+var _ = Describe("Health Handler", func() {
+	var (
+		pinger   *fakePinger
+		status   *health.Status
+		handler  http.Handler
+		recorder *httptest.ResponseRecorder
+		report   health.Report
+		syncTime time.Time
+	)
+
+	get := func(path string) {
+		request, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		handler.ServeHTTP(recorder, request)
+
+		report = health.Report{}
+		err = json.NewDecoder(recorder.Body).Decode(&report)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	makeReady := func() {
+		status.SetLockHeld(true)
+		status.RouterGreeted(routing_table.RouterGreetingMessage{MinimumRegisterInterval: 20, PruneThresholdInSeconds: 120})
+		status.SyncCompleted(syncTime)
+		status.SetEventsSubscribed(true)
+	}
+
+	BeforeEach(func() {
+		pinger = &fakePinger{connected: true}
+		status = health.NewStatus(true, true, pinger)
+		syncTime = time.Date(2016, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+		var err error
+		handler, err = health.NewHandler(status, lagertest.NewTestLogger("test"))
+		Expect(err).NotTo(HaveOccurred())
+
+		recorder = httptest.NewRecorder()
+	})
+
+	Describe("GET /health", func() {
+		It("succeeds and reports each state before the emitter is ready", func() {
+			get("/health")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(report.Ready).To(BeFalse())
+			Expect(report.LockRequired).To(BeTrue())
+			Expect(report.LockHeld).To(BeFalse())
+			Expect(report.RouterGreeted).To(BeFalse())
+			Expect(report.PruneInterval).To(BeEmpty())
+			Expect(report.LastSyncTime).To(BeNil())
+			Expect(report.EventsSubscribed).To(BeFalse())
+			Expect(*report.NATSConnected).To(BeTrue())
+		})
+
+		It("reports the router's prune interval and the last sync", func() {
+			makeReady()
+			get("/health")
+
+			Expect(report.RouterGreeted).To(BeTrue())
+			Expect(report.PruneInterval).To(Equal("2m0s"))
+			Expect(report.LastSyncTime.Equal(syncTime)).To(BeTrue())
+		})
+	})
+
+	Describe("GET /ready", func() {
+		It("fails until the emitter is ready", func() {
+			get("/ready")
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("succeeds once every required state is reached", func() {
+			makeReady()
+			get("/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(report.Ready).To(BeTrue())
+		})
+
+		It("fails when the event subscription is lost", func() {
+			makeReady()
+			status.SetEventsSubscribed(false)
+			get("/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("fails when NATS is disconnected", func() {
+			makeReady()
+			pinger.connected = false
+			get("/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(*report.NATSConnected).To(BeFalse())
+		})
+
+		Context("when neither the lock nor NATS are used", func() {
+			BeforeEach(func() {
+				status = health.NewStatus(false, false, nil)
+
+				var err error
+				handler, err = health.NewHandler(status, lagertest.NewTestLogger("test"))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("only waits for the first sync and the event subscription", func() {
+				status.SyncCompleted(syncTime)
+				status.SetEventsSubscribed(true)
+				get("/ready")
+
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(report.NATSConnected).To(BeNil())
+			})
+		})
+	})
+})
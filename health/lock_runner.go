@@ -0,0 +1,30 @@
+package health
+
+import (
+	"os"
+
+	"github.com/tedsuo/ifrit"
+)
+
+// TrackLock wraps a lock runner so that the status reports the lock as held
+// while the runner is ready.
+func TrackLock(lockRunner ifrit.Runner, status *Status) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		process := ifrit.Background(lockRunner)
+		defer status.SetLockHeld(false)
+
+		lockReady := process.Ready()
+		for {
+			select {
+			case <-lockReady:
+				status.SetLockHeld(true)
+				close(ready)
+				lockReady = nil
+			case signal := <-signals:
+				process.Signal(signal)
+			case err := <-process.Wait():
+				return err
+			}
+		}
+	})
+}
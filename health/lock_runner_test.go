@@ -0,0 +1,60 @@
+package health_test
+
+import (
+	"os"
+
+	"github.com/cloudfoundry-incubator/route-emitter/health"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TrackLock", func() {
+	var (
+		status      *health.Status
+		acquireLock chan struct{}
+		process     ifrit.Process
+	)
+
+	BeforeEach(func() {
+		status = health.NewStatus(true, false, nil)
+		acquireLock = make(chan struct{})
+
+		lockRunner := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+			select {
+			case <-acquireLock:
+				close(ready)
+			case <-signals:
+				return nil
+			}
+			<-signals
+			return nil
+		})
+
+		process = ifrit.Background(health.TrackLock(lockRunner, status))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("reports the lock as held once the lock runner is ready", func() {
+		Consistently(process.Ready()).ShouldNot(BeClosed())
+		Expect(status.Report().LockHeld).To(BeFalse())
+
+		close(acquireLock)
+		Eventually(process.Ready()).Should(BeClosed())
+		Expect(status.Report().LockHeld).To(BeTrue())
+	})
+
+	It("reports the lock as released when the lock runner exits", func() {
+		close(acquireLock)
+		Eventually(process.Ready()).Should(BeClosed())
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		Expect(status.Report().LockHeld).To(BeFalse())
+	})
+})
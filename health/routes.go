@@ -0,0 +1,13 @@
+package health
+
+import "github.com/tedsuo/rata"
+
+const (
+	HealthRoute = "Health"
+	ReadyRoute  = "Ready"
+)
+
+var Routes = rata.Routes{
+	{Path: "/health", Method: "GET", Name: HealthRoute},
+	{Path: "/ready", Method: "GET", Name: ReadyRoute},
+}
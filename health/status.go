@@ -0,0 +1,116 @@
+package health
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+// NATSPinger reports whether the NATS connection is usable.
+type NATSPinger interface {
+	Ping() bool
+}
+
+// Status collects the state the emitter's components report about
+// themselves so that it can be served to a process supervisor.
+type Status struct {
+	requireLock           bool
+	requireRouterGreeting bool
+	natsPinger            NATSPinger
+
+	lock             sync.RWMutex
+	lockHeld         bool
+	routerGreeted    bool
+	pruneInterval    time.Duration
+	lastSyncTime     time.Time
+	eventsSubscribed bool
+}
+
+// NewStatus returns a status that is ready once the first sync has completed
+// and BBS events are subscribed to, and additionally once the lock is held and
+// a router has greeted the emitter when those are required. natsPinger may be
+// nil when the emitter does not talk to NATS.
+func NewStatus(requireLock, requireRouterGreeting bool, natsPinger NATSPinger) *Status {
+	return &Status{
+		requireLock:           requireLock,
+		requireRouterGreeting: requireRouterGreeting,
+		natsPinger:            natsPinger,
+	}
+}
+
+func (s *Status) SetLockHeld(held bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lockHeld = held
+}
+
+func (s *Status) RouterGreeted(greeting routing_table.RouterGreetingMessage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.routerGreeted = true
+	s.pruneInterval = time.Duration(greeting.PruneThresholdInSeconds) * time.Second
+}
+
+func (s *Status) SyncCompleted(at time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lastSyncTime = at
+}
+
+func (s *Status) SetEventsSubscribed(subscribed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.eventsSubscribed = subscribed
+}
+
+type Report struct {
+	Ready            bool       `json:"ready"`
+	LockRequired     bool       `json:"lock_required"`
+	LockHeld         bool       `json:"lock_held"`
+	RouterGreeted    bool       `json:"router_greeted"`
+	PruneInterval    string     `json:"prune_interval,omitempty"`
+	LastSyncTime     *time.Time `json:"last_sync_time,omitempty"`
+	EventsSubscribed bool       `json:"events_subscribed"`
+	NATSConnected    *bool      `json:"nats_connected,omitempty"`
+}
+
+func (s *Status) Report() Report {
+	var natsConnected *bool
+	if s.natsPinger != nil {
+		connected := s.natsPinger.Ping()
+		natsConnected = &connected
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	report := Report{
+		LockRequired:     s.requireLock,
+		LockHeld:         s.lockHeld,
+		RouterGreeted:    s.routerGreeted,
+		EventsSubscribed: s.eventsSubscribed,
+		NATSConnected:    natsConnected,
+	}
+
+	if s.routerGreeted {
+		report.PruneInterval = s.pruneInterval.String()
+	}
+
+	if !s.lastSyncTime.IsZero() {
+		lastSyncTime := s.lastSyncTime
+		report.LastSyncTime = &lastSyncTime
+	}
+
+	report.Ready = (s.lockHeld || !s.requireLock) &&
+		(s.routerGreeted || !s.requireRouterGreeting) &&
+		report.LastSyncTime != nil &&
+		s.eventsSubscribed &&
+		(natsConnected == nil || *natsConnected)
+
+	return report
+}
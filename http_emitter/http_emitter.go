@@ -0,0 +1,106 @@
+package http_emitter
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager"
+)
+
+const DefaultBatchSize = 100
+
+var messagesEmitted = metric.Counter("MessagesEmitted")
+
+type httpEmitter struct {
+	routingAPIClient routing_api.Client
+	ttl              int
+	batchSize        int
+	logger           lager.Logger
+}
+
+// New returns an emitter that registers routes with the Routing API instead
+// of publishing them over NATS. Registrations carry the given TTL so that the
+// Routing API expires them if the emitter stops refreshing them.
+func New(routingAPIClient routing_api.Client, ttl time.Duration, batchSize int, logger lager.Logger) nats_emitter.NATSEmitter {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &httpEmitter{
+		routingAPIClient: routingAPIClient,
+		ttl:              int(ttl.Seconds()),
+		batchSize:        batchSize,
+		logger:           logger.Session("http-emitter"),
+	}
+}
+
+func (h *httpEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	registrations := h.routesFor(messagesToEmit.RegistrationMessages)
+	unregistrations := h.routesFor(messagesToEmit.UnregistrationMessages)
+
+	var finalError error
+
+	for _, batch := range h.batches(registrations) {
+		h.logger.Debug("upserting-routes", lager.Data{"count": len(batch)})
+		err := h.routingAPIClient.UpsertRoutes(batch)
+		if err != nil {
+			h.logger.Error("failed-to-upsert-routes", err, lager.Data{"count": len(batch)})
+			if finalError == nil {
+				finalError = err
+			}
+		}
+	}
+
+	for _, batch := range h.batches(unregistrations) {
+		h.logger.Debug("deleting-routes", lager.Data{"count": len(batch)})
+		err := h.routingAPIClient.DeleteRoutes(batch)
+		if err != nil {
+			h.logger.Error("failed-to-delete-routes", err, lager.Data{"count": len(batch)})
+			if finalError == nil {
+				finalError = err
+			}
+		}
+	}
+
+	if finalError != nil {
+		return finalError
+	}
+
+	numberOfMessages := uint64(len(messagesToEmit.RegistrationMessages) + len(messagesToEmit.UnregistrationMessages))
+	messagesEmitted.Add(numberOfMessages)
+
+	return nil
+}
+
+func (h *httpEmitter) routesFor(messages []routing_table.RegistryMessage) []routing_api.Route {
+	routes := []routing_api.Route{}
+	for _, message := range messages {
+		for _, uri := range message.URIs {
+			routes = append(routes, routing_api.Route{
+				Route:           uri,
+				Port:            message.Port,
+				IP:              message.Host,
+				TTL:             h.ttl,
+				LogGuid:         message.App,
+				RouteServiceUrl: message.RouteServiceUrl,
+			})
+		}
+	}
+	return routes
+}
+
+func (h *httpEmitter) batches(routes []routing_api.Route) [][]routing_api.Route {
+	batches := [][]routing_api.Route{}
+	for len(routes) > 0 {
+		size := h.batchSize
+		if len(routes) < size {
+			size = len(routes)
+		}
+		batches = append(batches, routes[:size])
+		routes = routes[size:]
+	}
+	return batches
+}
@@ -0,0 +1,13 @@
+package http_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHttpEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "HTTP Emitter Suite")
+}
@@ -0,0 +1,130 @@
+package http_emitter_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/http_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPEmitter", func() {
+	var (
+		server           *ghttp.Server
+		emitter          nats_emitter.NATSEmitter
+		batchSize        int
+		fakeMetricSender *fake_metrics_sender.FakeMetricSender
+	)
+
+	messagesToEmit := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"foo.com", "bar.com"}, Host: "1.1.1.1", Port: 11, App: "log-guid"},
+			{URIs: []string{"baz.com"}, Host: "2.2.2.2", Port: 22, RouteServiceUrl: "https://rs.example.com"},
+		},
+		UnregistrationMessages: []routing_table.RegistryMessage{
+			{URIs: []string{"wibble.com"}, Host: "1.1.1.1", Port: 11},
+		},
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		batchSize = 100
+		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+		metrics.Initialize(fakeMetricSender, nil)
+	})
+
+	JustBeforeEach(func() {
+		client := routing_api.NewClient(server.URL(), &http.Client{}, nil)
+		emitter = http_emitter.New(client, 2*time.Minute, batchSize, lagertest.NewTestLogger("test"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Emit", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", routing_api.UpsertRoutesPath),
+					ghttp.VerifyJSON(`[
+						{"route":"foo.com","port":11,"ip":"1.1.1.1","ttl":120,"log_guid":"log-guid"},
+						{"route":"bar.com","port":11,"ip":"1.1.1.1","ttl":120,"log_guid":"log-guid"},
+						{"route":"baz.com","port":22,"ip":"2.2.2.2","ttl":120,"route_service_url":"https://rs.example.com"}
+					]`),
+					ghttp.RespondWith(http.StatusCreated, nil),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", routing_api.DeleteRoutesPath),
+					ghttp.VerifyJSON(`[{"route":"wibble.com","port":11,"ip":"1.1.1.1","ttl":120}]`),
+					ghttp.RespondWith(http.StatusNoContent, nil),
+				),
+			)
+		})
+
+		It("upserts registrations and deletes unregistrations with a TTL", func() {
+			Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+			Expect(fakeMetricSender.GetCounter("MessagesEmitted")).To(BeEquivalentTo(3))
+		})
+
+		Context("when there are more routes than fit in a batch", func() {
+			BeforeEach(func() {
+				batchSize = 2
+				server.Reset()
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", routing_api.UpsertRoutesPath),
+						ghttp.VerifyJSON(`[
+							{"route":"foo.com","port":11,"ip":"1.1.1.1","ttl":120,"log_guid":"log-guid"},
+							{"route":"bar.com","port":11,"ip":"1.1.1.1","ttl":120,"log_guid":"log-guid"}
+						]`),
+						ghttp.RespondWith(http.StatusCreated, nil),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", routing_api.UpsertRoutesPath),
+						ghttp.VerifyJSON(`[{"route":"baz.com","port":22,"ip":"2.2.2.2","ttl":120,"route_service_url":"https://rs.example.com"}]`),
+						ghttp.RespondWith(http.StatusCreated, nil),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("DELETE", routing_api.DeleteRoutesPath),
+						ghttp.RespondWith(http.StatusNoContent, nil),
+					),
+				)
+			})
+
+			It("splits the routes into multiple requests", func() {
+				Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+				Expect(server.ReceivedRequests()).To(HaveLen(3))
+			})
+		})
+
+		Context("when the routing api returns an error", func() {
+			BeforeEach(func() {
+				server.SetHandler(0, ghttp.RespondWith(http.StatusInternalServerError, nil))
+			})
+
+			It("still attempts the remaining requests and returns the error", func() {
+				Expect(emitter.Emit(messagesToEmit)).NotTo(Succeed())
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+				Expect(fakeMetricSender.GetCounter("MessagesEmitted")).To(BeZero())
+			})
+		})
+
+		Context("when there is nothing to emit", func() {
+			It("does not call the routing api", func() {
+				Expect(emitter.Emit(routing_table.MessagesToEmit{})).To(Succeed())
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package lock
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+type fileLock struct {
+	path  string
+	clock clock.Clock
+}
+
+// NewFileLock returns a lock held with flock(2) on the file at path, for
+// emitters that share a single VM. The lock is released by the kernel if the
+// holder dies, so lockTTL is ignored.
+func NewFileLock(path string, clock clock.Clock) Lock {
+	return &fileLock{
+		path:  path,
+		clock: clock,
+	}
+}
+
+func (l *fileLock) NewRunner(logger lager.Logger, holderID string, retryInterval, lockTTL time.Duration) ifrit.Runner {
+	return &fileLockRunner{
+		path:          l.path,
+		holderID:      holderID,
+		retryInterval: retryInterval,
+		clock:         l.clock,
+		logger:        logger.Session("file-lock", lager.Data{"path": l.path, "holder-id": holderID}),
+	}
+}
+
+type fileLockRunner struct {
+	path          string
+	holderID      string
+	retryInterval time.Duration
+	clock         clock.Clock
+	logger        lager.Logger
+}
+
+func (r *fileLockRunner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	file, err := os.OpenFile(r.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		r.logger.Error("failed-opening-lock-file", err)
+		return err
+	}
+	defer file.Close()
+
+	r.logger.Info("acquiring-lock")
+
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != syscall.EWOULDBLOCK {
+			r.logger.Error("failed-acquiring-lock", err)
+			return err
+		}
+
+		timer := r.clock.NewTimer(r.retryInterval)
+		select {
+		case <-timer.C():
+		case <-signals:
+			timer.Stop()
+			r.logger.Info("stopped-acquiring-lock")
+			return nil
+		}
+	}
+
+	r.logger.Info("acquired-lock")
+
+	err = file.Truncate(0)
+	if err == nil {
+		_, err = file.WriteAt([]byte(r.holderID), 0)
+	}
+	if err != nil {
+		r.logger.Error("failed-recording-lock-holder", err)
+	}
+
+	close(ready)
+
+	<-signals
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	if err != nil {
+		r.logger.Error("failed-releasing-lock", err)
+		return err
+	}
+
+	r.logger.Info("released-lock")
+	return nil
+}
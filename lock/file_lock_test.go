@@ -0,0 +1,84 @@
+package lock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/lock"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("FileLock", func() {
+	const retryInterval = time.Second
+
+	var (
+		tmpDir   string
+		lockPath string
+		clock    *fakeclock.FakeClock
+		logger   *lagertest.TestLogger
+
+		fileLock lock.Lock
+		first    ifrit.Process
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "file-lock")
+		Expect(err).NotTo(HaveOccurred())
+
+		lockPath = filepath.Join(tmpDir, "route_emitter.lock")
+		clock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+
+		fileLock = lock.NewFileLock(lockPath, clock)
+		first = ifrit.Invoke(fileLock.NewRunner(logger, "first", retryInterval, 0))
+	})
+
+	AfterEach(func() {
+		first.Signal(os.Interrupt)
+		Eventually(first.Wait()).Should(Receive())
+		os.RemoveAll(tmpDir)
+	})
+
+	It("acquires the lock and records the holder", func() {
+		Expect(first.Ready()).To(BeClosed())
+
+		contents, err := ioutil.ReadFile(lockPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("first"))
+	})
+
+	Context("when another runner wants the lock", func() {
+		var second ifrit.Process
+
+		BeforeEach(func() {
+			second = ifrit.Background(fileLock.NewRunner(logger, "second", retryInterval, 0))
+			Eventually(logger).Should(gbytes.Say("test.file-lock.acquiring-lock.*second"))
+		})
+
+		AfterEach(func() {
+			second.Signal(os.Interrupt)
+			Eventually(second.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("waits until the lock is released", func() {
+			Consistently(second.Ready()).ShouldNot(BeClosed())
+
+			first.Signal(os.Interrupt)
+			Eventually(first.Wait()).Should(Receive(BeNil()))
+
+			Eventually(func() <-chan struct{} {
+				clock.Increment(retryInterval)
+				return second.Ready()
+			}).Should(BeClosed())
+		})
+	})
+})
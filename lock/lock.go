@@ -0,0 +1,36 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/consuladapter"
+	"github.com/cloudfoundry-incubator/locket"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// Lock hands out runners that become ready once they hold the lock and
+// release it when signalled.
+type Lock interface {
+	NewRunner(logger lager.Logger, holderID string, retryInterval, lockTTL time.Duration) ifrit.Runner
+}
+
+type consulLock struct {
+	consulClient consuladapter.Client
+	schemaPath   string
+	clock        clock.Clock
+}
+
+// NewConsulLock returns a lock held through a consul session on schemaPath.
+func NewConsulLock(consulClient consuladapter.Client, schemaPath string, clock clock.Clock) Lock {
+	return &consulLock{
+		consulClient: consulClient,
+		schemaPath:   schemaPath,
+		clock:        clock,
+	}
+}
+
+func (l *consulLock) NewRunner(logger lager.Logger, holderID string, retryInterval, lockTTL time.Duration) ifrit.Runner {
+	return locket.NewLock(logger, l.consulClient, l.schemaPath, []byte(holderID), l.clock, retryInterval, lockTTL)
+}
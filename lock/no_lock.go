@@ -0,0 +1,28 @@
+package lock
+
+import (
+	"os"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+type noLock struct{}
+
+// NewNoLock returns a lock that is always immediately acquired, for
+// deployments that run a single emitter.
+func NewNoLock() Lock {
+	return noLock{}
+}
+
+func (noLock) NewRunner(logger lager.Logger, holderID string, retryInterval, lockTTL time.Duration) ifrit.Runner {
+	logger = logger.Session("no-lock")
+
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		logger.Info("skipping-lock")
+		close(ready)
+		<-signals
+		return nil
+	})
+}
@@ -0,0 +1,25 @@
+package lock_test
+
+import (
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/lock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NoLock", func() {
+	It("is ready immediately and exits when signalled", func() {
+		process := ifrit.Invoke(lock.NewNoLock().NewRunner(lagertest.NewTestLogger("test"), "id", time.Second, time.Second))
+		Expect(process.Ready()).To(BeClosed())
+
+		Consistently(process.Wait()).ShouldNot(Receive())
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+})
@@ -0,0 +1,35 @@
+// Package metric mirrors the runtime-schema metric types, sending every value
+// to dropsonde as before and additionally recording it in a Prometheus
+// registry that can be served with Handler.
+package metric
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/runtime-schema/metric"
+)
+
+type Counter string
+
+func (name Counter) Increment() {
+	name.Add(1)
+}
+
+func (name Counter) Add(i uint64) {
+	metric.Counter(name).Add(i)
+	prometheusCounter(string(name)).Add(float64(i))
+}
+
+type Metric string
+
+func (name Metric) Send(value int) error {
+	prometheusGauge(string(name)).Set(float64(value))
+	return metric.Metric(name).Send(value)
+}
+
+type Duration string
+
+func (name Duration) Send(duration time.Duration) error {
+	prometheusHistogram(string(name)).Observe(duration.Seconds())
+	return metric.Duration(name).Send(duration)
+}
@@ -0,0 +1,76 @@
+package metric_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metric", func() {
+	var fakeMetricSender *fake_metrics_sender.FakeMetricSender
+
+	BeforeEach(func() {
+		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+		metrics.Initialize(fakeMetricSender, nil)
+	})
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		metric.Handler().ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		body, err := ioutil.ReadAll(recorder.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return string(body)
+	}
+
+	Describe("Counter", func() {
+		It("sends to dropsonde and prometheus", func() {
+			counter := metric.Counter("TestCounterMetric")
+			counter.Add(3)
+			counter.Increment()
+
+			Expect(fakeMetricSender.GetCounter("TestCounterMetric")).To(BeEquivalentTo(4))
+			Expect(scrape()).To(ContainSubstring("route_emitter_test_counter_metric_total 4"))
+		})
+	})
+
+	Describe("Metric", func() {
+		It("sends to dropsonde and prometheus", func() {
+			Expect(metric.Metric("TestGaugeMetric").Send(42)).To(Succeed())
+
+			Expect(fakeMetricSender.GetValue("TestGaugeMetric").Value).To(BeEquivalentTo(42))
+			Expect(scrape()).To(ContainSubstring("route_emitter_test_gauge_metric 42"))
+		})
+	})
+
+	Describe("Duration", func() {
+		It("sends to dropsonde and records a prometheus histogram", func() {
+			Expect(metric.Duration("TestDurationMetric").Send(2 * time.Second)).To(Succeed())
+
+			Expect(fakeMetricSender.GetValue("TestDurationMetric").Unit).To(Equal("nanos"))
+			body := scrape()
+			Expect(body).To(ContainSubstring("route_emitter_test_duration_metric_seconds_count 1"))
+			Expect(body).To(ContainSubstring("route_emitter_test_duration_metric_seconds_sum 2"))
+		})
+	})
+
+	Describe("PrometheusName", func() {
+		It("converts metric names to snake case", func() {
+			Expect(metric.PrometheusName("RoutesTotal")).To(Equal("routes_total"))
+			Expect(metric.PrometheusName("TCPRoutesRegistered")).To(Equal("tcp_routes_registered"))
+			Expect(metric.PrometheusName("RouteEmitterSyncDuration")).To(Equal("sync_duration"))
+		})
+	})
+})
@@ -0,0 +1,101 @@
+package metric
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "route_emitter"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	collectorsLock sync.Mutex
+	counters       = map[string]prometheus.Counter{}
+	gauges         = map[string]prometheus.Gauge{}
+	histograms     = map[string]prometheus.Histogram{}
+)
+
+// Handler serves every metric recorded so far in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func prometheusCounter(name string) prometheus.Counter {
+	collectorsLock.Lock()
+	defer collectorsLock.Unlock()
+
+	counter, ok := counters[name]
+	if !ok {
+		counter = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      PrometheusName(name) + "_total",
+			Help:      name,
+		})
+		registry.MustRegister(counter)
+		counters[name] = counter
+	}
+	return counter
+}
+
+func prometheusGauge(name string) prometheus.Gauge {
+	collectorsLock.Lock()
+	defer collectorsLock.Unlock()
+
+	gauge, ok := gauges[name]
+	if !ok {
+		gauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      PrometheusName(name),
+			Help:      name,
+		})
+		registry.MustRegister(gauge)
+		gauges[name] = gauge
+	}
+	return gauge
+}
+
+func prometheusHistogram(name string) prometheus.Histogram {
+	collectorsLock.Lock()
+	defer collectorsLock.Unlock()
+
+	histogram, ok := histograms[name]
+	if !ok {
+		histogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      PrometheusName(name) + "_seconds",
+			Help:      name,
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		})
+		registry.MustRegister(histogram)
+		histograms[name] = histogram
+	}
+	return histogram
+}
+
+// PrometheusName converts a dropsonde metric name such as
+// "RouteEmitterSyncDuration" or "TCPRoutesRegistered" to snake case,
+// dropping a leading "RouteEmitter" that would repeat the namespace.
+func PrometheusName(name string) string {
+	name = strings.TrimPrefix(name, "RouteEmitter")
+	runes := []rune(name)
+
+	converted := make([]rune, 0, len(runes)+4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				converted = append(converted, '_')
+			}
+		}
+		converted = append(converted, unicode.ToLower(r))
+	}
+
+	return string(converted)
+}
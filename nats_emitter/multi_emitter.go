@@ -0,0 +1,24 @@
+package nats_emitter
+
+import "github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+type multiEmitter []NATSEmitter
+
+// NewMulti returns an emitter that passes every message to each of the
+// emitters in turn, so routes can be emitted to several backends at once. An
+// emitter failing does not stop the others; the first error is returned.
+func NewMulti(emitters ...NATSEmitter) NATSEmitter {
+	return multiEmitter(emitters)
+}
+
+func (m multiEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	var finalError error
+	for _, emitter := range m {
+		err := emitter.Emit(messagesToEmit)
+		if err != nil && finalError == nil {
+			finalError = err
+		}
+	}
+
+	return finalError
+}
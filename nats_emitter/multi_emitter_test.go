@@ -0,0 +1,45 @@
+package nats_emitter_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter/fake_nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MultiEmitter", func() {
+	var (
+		first, second  *fake_nats_emitter.FakeNATSEmitter
+		messagesToEmit routing_table.MessagesToEmit
+	)
+
+	BeforeEach(func() {
+		first = new(fake_nats_emitter.FakeNATSEmitter)
+		second = new(fake_nats_emitter.FakeNATSEmitter)
+		messagesToEmit = routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{{URIs: []string{"foo.example.com"}, Host: "1.1.1.1", Port: 11}},
+		}
+	})
+
+	It("emits the messages with every emitter", func() {
+		Expect(nats_emitter.NewMulti(first, second).Emit(messagesToEmit)).To(Succeed())
+
+		Expect(first.EmitCallCount()).To(Equal(1))
+		Expect(first.EmitArgsForCall(0)).To(Equal(messagesToEmit))
+		Expect(second.EmitCallCount()).To(Equal(1))
+		Expect(second.EmitArgsForCall(0)).To(Equal(messagesToEmit))
+	})
+
+	It("emits with the remaining emitters when one fails and returns the first error", func() {
+		first.EmitReturns(errors.New("first"))
+		second.EmitReturns(errors.New("second"))
+
+		err := nats_emitter.NewMulti(first, second).Emit(messagesToEmit)
+		Expect(err).To(MatchError("first"))
+		Expect(second.EmitCallCount()).To(Equal(1))
+	})
+})
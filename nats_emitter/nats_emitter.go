@@ -1,48 +1,166 @@
 package nats_emitter
 
 import (
+	"bytes"
 	"encoding/json"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
-	"github.com/cloudfoundry-incubator/runtime-schema/metric"
 	"github.com/cloudfoundry/gunk/diegonats"
 	"github.com/cloudfoundry/gunk/workpool"
 	"github.com/pivotal-golang/lager"
 )
 
-var messagesEmitted = metric.Counter("MessagesEmitted")
+const (
+	RegisterSubject      = "router.register"
+	RegisterBatchSubject = "router.register.batch"
+	UnregisterSubject    = "router.unregister"
+
+	DefaultMaxBatchBytes = 256 * 1024
+)
+
+var (
+	messagesEmitted = metric.Counter("MessagesEmitted")
+	batchesEmitted  = metric.Counter("MessageBatchesEmitted")
+	emitDuration    = metric.Duration("MessagesEmitDuration")
+)
 
 //go:generate counterfeiter -o fake_nats_emitter/fake_nats_emitter.go . NATSEmitter
 type NATSEmitter interface {
 	Emit(messagesToEmit routing_table.MessagesToEmit) error
 }
 
+// BatchingNATSEmitter packs registrations into batched payloads on
+// RegisterBatchSubject once the routers advertise support for it in their
+// greeting.
+type BatchingNATSEmitter interface {
+	NATSEmitter
+	HandleRouterGreeting(greeting routing_table.RouterGreetingMessage)
+}
+
+// ResizableNATSEmitter can change how many messages it publishes concurrently
+// while running.
+type ResizableNATSEmitter interface {
+	NATSEmitter
+	SetWorkers(workers int) error
+}
+
 type natsEmitter struct {
-	natsClient diegonats.NATSClient
-	workPool   *workpool.WorkPool
-	logger     lager.Logger
+	natsClient   diegonats.NATSClient
+	workPool     *workpool.WorkPool
+	workPoolLock sync.RWMutex
+	retryQueue   *RetryQueue
+	logger       lager.Logger
+
+	maxBatchBytes        int
+	batchingSupported    int32
+	batchingNotSupported int32
 }
 
-func New(natsClient diegonats.NATSClient, workPool *workpool.WorkPool, logger lager.Logger) NATSEmitter {
+// New returns an emitter that publishes every message individually. Failed
+// publishes are handed to retryQueue, if it is not nil.
+func New(natsClient diegonats.NATSClient, workPool *workpool.WorkPool, retryQueue *RetryQueue, logger lager.Logger) NATSEmitter {
 	return &natsEmitter{
 		natsClient: natsClient,
 		workPool:   workPool,
+		retryQueue: retryQueue,
 		logger:     logger.Session("nats-emitter"),
 	}
 }
 
+func NewBatching(natsClient diegonats.NATSClient, workPool *workpool.WorkPool, maxBatchBytes int, retryQueue *RetryQueue, logger lager.Logger) BatchingNATSEmitter {
+	if maxBatchBytes <= 0 {
+		maxBatchBytes = DefaultMaxBatchBytes
+	}
+
+	return &natsEmitter{
+		natsClient:    natsClient,
+		workPool:      workPool,
+		retryQueue:    retryQueue,
+		logger:        logger.Session("nats-emitter"),
+		maxBatchBytes: maxBatchBytes,
+	}
+}
+
+// HandleRouterGreeting enables batching when a router advertises support for
+// it. Once any router greets without advertising support, batching stays
+// disabled so that router never misses registrations.
+func (n *natsEmitter) HandleRouterGreeting(greeting routing_table.RouterGreetingMessage) {
+	if greeting.SupportsBatchRegistration {
+		atomic.StoreInt32(&n.batchingSupported, 1)
+		return
+	}
+
+	if atomic.SwapInt32(&n.batchingNotSupported, 1) == 0 && atomic.LoadInt32(&n.batchingSupported) == 1 {
+		n.logger.Info("disabling-batched-registration")
+	}
+}
+
+func (n *natsEmitter) batching() bool {
+	return n.maxBatchBytes > 0 &&
+		atomic.LoadInt32(&n.batchingSupported) == 1 &&
+		atomic.LoadInt32(&n.batchingNotSupported) == 0
+}
+
+// SetWorkers replaces the work pool with one of the given size once any emit
+// in progress has finished.
+func (n *natsEmitter) SetWorkers(workers int) error {
+	workPool, err := workpool.NewWorkPool(workers)
+	if err != nil {
+		return err
+	}
+
+	n.workPoolLock.Lock()
+	previous := n.workPool
+	n.workPool = workPool
+	n.workPoolLock.Unlock()
+
+	previous.Stop()
+	n.logger.Info("resized-work-pool", lager.Data{"num-workers": workers})
+
+	return nil
+}
+
 func (n *natsEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	n.workPoolLock.RLock()
+	defer n.workPoolLock.RUnlock()
+
+	startedAt := time.Now()
+	defer func() {
+		err := emitDuration.Send(time.Since(startedAt))
+		if err != nil {
+			n.logger.Error("failed-to-send-emit-duration-metric", err)
+		}
+	}()
+
 	errors := make(chan error, 1)
 	var wg sync.WaitGroup
-	wg.Add(len(messagesToEmit.RegistrationMessages))
-	for _, message := range messagesToEmit.RegistrationMessages {
-		n.emit("router.register", message, &wg, errors)
+	numberOfBatches := 0
+
+	if n.batching() {
+		batches, err := n.batches(messagesToEmit.RegistrationMessages)
+		if err != nil {
+			return err
+		}
+
+		wg.Add(len(batches))
+		for _, batch := range batches {
+			n.publish(RegisterBatchSubject, batch.payload, batch.messages, &wg, errors)
+		}
+		numberOfBatches = len(batches)
+	} else {
+		wg.Add(len(messagesToEmit.RegistrationMessages))
+		for _, message := range messagesToEmit.RegistrationMessages {
+			n.emit(RegisterSubject, message, &wg, errors)
+		}
 	}
 
 	wg.Add(len(messagesToEmit.UnregistrationMessages))
 	for _, message := range messagesToEmit.UnregistrationMessages {
-		n.emit("router.unregister", message, &wg, errors)
+		n.emit(UnregisterSubject, message, &wg, errors)
 	}
 
 	wg.Wait()
@@ -55,11 +173,76 @@ func (n *natsEmitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
 
 	numberOfMessages := uint64(len(messagesToEmit.RegistrationMessages) + len(messagesToEmit.UnregistrationMessages))
 	messagesEmitted.Add(numberOfMessages)
+	if numberOfBatches > 0 {
+		batchesEmitted.Add(uint64(numberOfBatches))
+	}
 
 	return nil
 }
 
+type batch struct {
+	payload  []byte
+	messages []routing_table.RegistryMessage
+}
+
+// batches packs messages into JSON arrays no larger than maxBatchBytes. A
+// single message that exceeds the limit on its own is sent in a batch of one.
+func (n *natsEmitter) batches(messages []routing_table.RegistryMessage) ([]batch, error) {
+	batches := []batch{}
+	buffer := new(bytes.Buffer)
+	start := 0
+
+	for i, message := range messages {
+		payload, err := json.Marshal(message)
+		if err != nil {
+			n.logger.Error("failed-to-marshal", err, lager.Data{"message": message})
+			return nil, err
+		}
+
+		if buffer.Len() > 0 && buffer.Len()+len(payload)+2 > n.maxBatchBytes {
+			buffer.WriteByte(']')
+			batches = append(batches, batch{payload: buffer.Bytes(), messages: messages[start:i]})
+			buffer = new(bytes.Buffer)
+			start = i
+		}
+
+		if buffer.Len() == 0 {
+			buffer.WriteByte('[')
+		} else {
+			buffer.WriteByte(',')
+		}
+		buffer.Write(payload)
+	}
+
+	if buffer.Len() > 0 {
+		buffer.WriteByte(']')
+		batches = append(batches, batch{payload: buffer.Bytes(), messages: messages[start:]})
+	}
+
+	return batches, nil
+}
+
 func (n *natsEmitter) emit(subject string, message routing_table.RegistryMessage, wg *sync.WaitGroup, errors chan error) {
+	n.logger.Debug("emit", lager.Data{
+		"subject": subject,
+		"message": message,
+	})
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		n.logger.Error("failed-to-marshal", err, lager.Data{
+			"message": message,
+			"subject": subject,
+		})
+	}
+
+	n.publish(subject, payload, []routing_table.RegistryMessage{message}, wg, errors)
+}
+
+// publish sends the payload carrying the messages. Pending retries of their
+// routes are discarded first, and the messages are queued for retry if the
+// publish fails; registrations from a failed batch are retried one by one.
+func (n *natsEmitter) publish(subject string, payload []byte, messages []routing_table.RegistryMessage, wg *sync.WaitGroup, errors chan error) {
 	n.workPool.Submit(func() {
 		var err error
 		defer func() {
@@ -72,25 +255,28 @@ func (n *natsEmitter) emit(subject string, message routing_table.RegistryMessage
 			wg.Done()
 		}()
 
-		n.logger.Debug("emit", lager.Data{
-			"subject": subject,
-			"message": message,
-		})
-
-		payload, err := json.Marshal(message)
-		if err != nil {
-			n.logger.Error("failed-to-marshal", err, lager.Data{
-				"message": message,
-				"subject": subject,
-			})
+		if n.retryQueue != nil {
+			for _, message := range messages {
+				n.retryQueue.Supersede(message)
+			}
 		}
 
 		err = n.natsClient.Publish(subject, payload)
 		if err != nil {
 			n.logger.Error("failed-to-publish", err, lager.Data{
-				"message": message,
+				"payload": string(payload),
 				"subject": subject,
 			})
+
+			if n.retryQueue != nil {
+				retrySubject := subject
+				if subject == RegisterBatchSubject {
+					retrySubject = RegisterSubject
+				}
+				for _, message := range messages {
+					n.retryQueue.Enqueue(retrySubject, message)
+				}
+			}
 		}
 	})
 }
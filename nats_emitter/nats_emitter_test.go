@@ -1,7 +1,9 @@
 package nats_emitter_test
 
 import (
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/apcera/nats"
 	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
@@ -10,6 +12,7 @@ import (
 	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/cloudfoundry/gunk/diegonats"
 	"github.com/cloudfoundry/gunk/workpool"
+	"github.com/pivotal-golang/clock/fakeclock"
 	"github.com/pivotal-golang/lager/lagertest"
 
 	. "github.com/onsi/ginkgo"
@@ -37,7 +40,7 @@ var _ = Describe("NatsEmitter", func() {
 		logger := lagertest.NewTestLogger("test")
 		workPool, err := workpool.NewWorkPool(1)
 		Expect(err).NotTo(HaveOccurred())
-		emitter = nats_emitter.New(natsClient, workPool, logger)
+		emitter = nats_emitter.New(natsClient, workPool, nil, logger)
 		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
 		metrics.Initialize(fakeMetricSender, nil)
 	})
@@ -93,6 +96,84 @@ var _ = Describe("NatsEmitter", func() {
       `)))
 
 			Expect(fakeMetricSender.GetCounter("MessagesEmitted")).To(BeEquivalentTo(4))
+			Expect(fakeMetricSender.GetValue("MessagesEmitDuration").Unit).To(Equal("nanos"))
+		})
+
+		Context("when batching is enabled", func() {
+			var batchingEmitter nats_emitter.BatchingNATSEmitter
+
+			BeforeEach(func() {
+				workPool, err := workpool.NewWorkPool(1)
+				Expect(err).NotTo(HaveOccurred())
+				batchingEmitter = nats_emitter.NewBatching(natsClient, workPool, 200, nil, lagertest.NewTestLogger("test"))
+			})
+
+			Context("and the router has not advertised support", func() {
+				It("publishes registrations individually", func() {
+					Expect(batchingEmitter.Emit(messagesToEmit)).To(Succeed())
+
+					Expect(natsClient.PublishedMessages("router.register")).To(HaveLen(2))
+					Expect(natsClient.PublishedMessages("router.register.batch")).To(BeEmpty())
+				})
+			})
+
+			Context("and the router advertises support", func() {
+				BeforeEach(func() {
+					batchingEmitter.HandleRouterGreeting(routing_table.RouterGreetingMessage{SupportsBatchRegistration: true})
+				})
+
+				It("packs registrations into a batch and unregisters individually", func() {
+					Expect(batchingEmitter.Emit(messagesToEmit)).To(Succeed())
+
+					Expect(natsClient.PublishedMessages("router.register")).To(BeEmpty())
+					Expect(natsClient.PublishedMessages("router.unregister")).To(HaveLen(2))
+
+					batches := natsClient.PublishedMessages("router.register.batch")
+					Expect(batches).To(HaveLen(1))
+					Expect(batches[0].Data).To(MatchJSON(`[
+						{"uris":["foo.com", "bar.com"], "host":"1.1.1.1", "port":11},
+						{"uris":["baz.com"], "host":"2.2.2.2", "port":22}
+					]`))
+
+					Expect(fakeMetricSender.GetCounter("MessagesEmitted")).To(BeEquivalentTo(4))
+					Expect(fakeMetricSender.GetCounter("MessageBatchesEmitted")).To(BeEquivalentTo(1))
+				})
+
+				Context("when the registrations exceed the maximum batch size", func() {
+					BeforeEach(func() {
+						workPool, err := workpool.NewWorkPool(1)
+						Expect(err).NotTo(HaveOccurred())
+						batchingEmitter = nats_emitter.NewBatching(natsClient, workPool, 60, nil, lagertest.NewTestLogger("test"))
+						batchingEmitter.HandleRouterGreeting(routing_table.RouterGreetingMessage{SupportsBatchRegistration: true})
+					})
+
+					It("splits them across several batches", func() {
+						Expect(batchingEmitter.Emit(messagesToEmit)).To(Succeed())
+
+						batches := natsClient.PublishedMessages("router.register.batch")
+						Expect(batches).To(HaveLen(2))
+						for _, batch := range batches {
+							var messages []routing_table.RegistryMessage
+							Expect(json.Unmarshal(batch.Data, &messages)).To(Succeed())
+							Expect(messages).To(HaveLen(1))
+						}
+					})
+				})
+
+				Context("when another router greets without advertising support", func() {
+					BeforeEach(func() {
+						batchingEmitter.HandleRouterGreeting(routing_table.RouterGreetingMessage{})
+						batchingEmitter.HandleRouterGreeting(routing_table.RouterGreetingMessage{SupportsBatchRegistration: true})
+					})
+
+					It("falls back to individual registrations", func() {
+						Expect(batchingEmitter.Emit(messagesToEmit)).To(Succeed())
+
+						Expect(natsClient.PublishedMessages("router.register")).To(HaveLen(2))
+						Expect(natsClient.PublishedMessages("router.register.batch")).To(BeEmpty())
+					})
+				})
+			})
 		})
 
 		Context("when the nats client errors", func() {
@@ -105,6 +186,75 @@ var _ = Describe("NatsEmitter", func() {
 			It("should error", func() {
 				Expect(emitter.Emit(messagesToEmit)).To(MatchError(errors.New("bam")))
 			})
+
+			Context("when a retry queue is configured", func() {
+				var retryQueue *nats_emitter.RetryQueue
+
+				BeforeEach(func() {
+					workPool, err := workpool.NewWorkPool(1)
+					Expect(err).NotTo(HaveOccurred())
+
+					retryQueue = nats_emitter.NewRetryQueue(natsClient, fakeclock.NewFakeClock(time.Now()), 10, time.Second, time.Minute, 3, lagertest.NewTestLogger("test"))
+					emitter = nats_emitter.New(natsClient, workPool, retryQueue, lagertest.NewTestLogger("test"))
+				})
+
+				It("queues the failed messages for retry", func() {
+					Expect(emitter.Emit(messagesToEmit)).To(MatchError(errors.New("bam")))
+					Expect(retryQueue.Depth()).To(Equal(2))
+				})
+
+				It("discards the pending retries of the routes it publishes", func() {
+					Expect(emitter.Emit(messagesToEmit)).To(MatchError(errors.New("bam")))
+
+					natsClient.WhenPublishing("router.register", func(*nats.Msg) error {
+						return nil
+					})
+					Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+					Expect(retryQueue.Depth()).To(BeZero())
+				})
+
+				Context("and registrations are batched", func() {
+					BeforeEach(func() {
+						workPool, err := workpool.NewWorkPool(1)
+						Expect(err).NotTo(HaveOccurred())
+
+						natsClient.WhenPublishing("router.register.batch", func(*nats.Msg) error {
+							return errors.New("bam")
+						})
+
+						batchingEmitter := nats_emitter.NewBatching(natsClient, workPool, 0, retryQueue, lagertest.NewTestLogger("test"))
+						batchingEmitter.HandleRouterGreeting(routing_table.RouterGreetingMessage{SupportsBatchRegistration: true})
+						emitter = batchingEmitter
+					})
+
+					It("queues each registration of the failed batch for retry", func() {
+						Expect(emitter.Emit(messagesToEmit)).To(MatchError(errors.New("bam")))
+						Expect(retryQueue.Depth()).To(Equal(2))
+					})
+				})
+			})
+		})
+	})
+
+	Describe("resizing the work pool", func() {
+		It("keeps emitting with the new number of workers", func() {
+			resizable, ok := emitter.(nats_emitter.ResizableNATSEmitter)
+			Expect(ok).To(BeTrue())
+
+			Expect(resizable.SetWorkers(5)).To(Succeed())
+
+			err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(natsClient.PublishedMessages("router.register")).To(HaveLen(2))
+			Expect(natsClient.PublishedMessages("router.unregister")).To(HaveLen(2))
+		})
+
+		It("rejects an invalid number of workers", func() {
+			resizable := emitter.(nats_emitter.ResizableNATSEmitter)
+			Expect(resizable.SetWorkers(0)).NotTo(Succeed())
+
+			err := emitter.Emit(messagesToEmit)
+			Expect(err).NotTo(HaveOccurred())
 		})
 	})
 })
@@ -0,0 +1,344 @@
+package nats_emitter
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry/gunk/diegonats"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+var (
+	publishRetries  = metric.Counter("MessagePublishRetries")
+	publishDrops    = metric.Counter("MessagePublishDrops")
+	retryQueueDepth = metric.Metric("MessageRetryQueueDepth")
+)
+
+type retryItem struct {
+	subject     string
+	message     routing_table.RegistryMessage
+	attempts    int
+	nextAttempt time.Time
+}
+
+// RetryQueue republishes messages whose first publish failed, backing off
+// exponentially between attempts. Unregistrations are retried first and are
+// never evicted to make room for registrations, since registrations are
+// repaired by the next periodic emit anyway.
+//
+// A retry only ever covers the routes, each an endpoint and one of its URIs,
+// that no newer message has been published or queued for since, so that an
+// old unregistration is never replayed after a newer registration.
+type RetryQueue struct {
+	natsClient  diegonats.NATSClient
+	clock       clock.Clock
+	capacity    int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxAttempts int
+	logger      lager.Logger
+
+	lock            sync.Mutex
+	unregistrations []*retryItem
+	registrations   []*retryItem
+	inFlight        []*retryItem
+	sentDepth       int
+	wakeup          chan struct{}
+}
+
+func NewRetryQueue(
+	natsClient diegonats.NATSClient,
+	clock clock.Clock,
+	capacity int,
+	baseDelay time.Duration,
+	maxDelay time.Duration,
+	maxAttempts int,
+	logger lager.Logger,
+) *RetryQueue {
+	return &RetryQueue{
+		natsClient:  natsClient,
+		clock:       clock,
+		capacity:    capacity,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		maxAttempts: maxAttempts,
+		logger:      logger.Session("retry-queue"),
+		wakeup:      make(chan struct{}, 1),
+	}
+}
+
+// Enqueue schedules a retry of a message that just failed to publish,
+// replacing any pending retry of its routes.
+func (q *RetryQueue) Enqueue(subject string, message routing_table.RegistryMessage) {
+	item := &retryItem{subject: subject, message: message, attempts: 1}
+	item.nextAttempt = q.clock.Now().Add(q.backoff(item.attempts))
+
+	q.lock.Lock()
+	q.supersede(message)
+	q.admit(item)
+	q.lock.Unlock()
+
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (q *RetryQueue) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	q.logger.Info("starting")
+	close(ready)
+	q.logger.Info("started")
+
+	for {
+		var timer clock.Timer
+		var timerC <-chan time.Time
+
+		if next, ok := q.nextAttempt(); ok {
+			timer = q.clock.NewTimer(next.Sub(q.clock.Now()))
+			timerC = timer.C()
+		}
+
+		select {
+		case <-timerC:
+			q.retryDue()
+		case <-q.wakeup:
+		case <-signals:
+			q.logger.Info("stopping", lager.Data{"depth": q.Depth()})
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Supersede discards the pending retries of the routes of a message about to
+// be published.
+func (q *RetryQueue) Supersede(message routing_table.RegistryMessage) {
+	q.lock.Lock()
+	q.supersede(message)
+	q.sendDepth()
+	q.lock.Unlock()
+}
+
+func (q *RetryQueue) Depth() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.depth()
+}
+
+func (q *RetryQueue) retryDue() {
+	now := q.clock.Now()
+
+	q.lock.Lock()
+	due := []*retryItem{}
+	q.unregistrations, due = takeDue(q.unregistrations, due, now)
+	q.registrations, due = takeDue(q.registrations, due, now)
+	q.inFlight = due
+	q.lock.Unlock()
+
+	for _, item := range due {
+		q.retry(item)
+	}
+
+	q.lock.Lock()
+	q.inFlight = nil
+	q.sendDepth()
+	q.lock.Unlock()
+}
+
+// retry publishes a copy of the item without holding the lock, so that a
+// slow publish never holds up emitting. A newer message for the same routes
+// published meanwhile removes them from the item, so they are not retried
+// again, but may be overtaken by this attempt; a registration overtaken by an
+// unregistration is repaired by the next periodic emit.
+func (q *RetryQueue) retry(item *retryItem) {
+	q.lock.Lock()
+	subject, message := item.subject, item.message
+	q.lock.Unlock()
+
+	if len(message.URIs) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		q.lock.Lock()
+		q.drop(item, "failed-to-marshal")
+		q.lock.Unlock()
+		return
+	}
+
+	publishRetries.Increment()
+	err = q.natsClient.Publish(subject, payload)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if err == nil {
+		q.logger.Debug("retry-succeeded", lager.Data{"subject": subject, "attempts": item.attempts + 1})
+		return
+	}
+
+	item.attempts++
+	if len(item.message.URIs) == 0 {
+		// superseded while publishing
+		return
+	}
+	if item.attempts >= q.maxAttempts {
+		q.drop(item, "max-attempts-reached")
+		return
+	}
+
+	q.logger.Error("retry-failed", err, lager.Data{"subject": item.subject, "attempts": item.attempts})
+	item.nextAttempt = q.clock.Now().Add(q.backoff(item.attempts))
+	q.admit(item)
+}
+
+func (q *RetryQueue) nextAttempt() (time.Time, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var next time.Time
+	found := false
+	for _, items := range [][]*retryItem{q.unregistrations, q.registrations} {
+		for _, item := range items {
+			if !found || item.nextAttempt.Before(next) {
+				next = item.nextAttempt
+				found = true
+			}
+		}
+	}
+
+	return next, found
+}
+
+func (q *RetryQueue) backoff(attempts int) time.Duration {
+	delay := q.baseDelay
+	for i := 1; i < attempts && delay < q.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > q.maxDelay {
+		delay = q.maxDelay
+	}
+	return delay
+}
+
+// admit queues the item unless the queue is full, in which case an
+// unregistration evicts the oldest registration and a registration is
+// dropped.
+func (q *RetryQueue) admit(item *retryItem) {
+	if q.depth() >= q.capacity {
+		if item.subject == UnregisterSubject && len(q.registrations) > 0 {
+			q.drop(q.registrations[0], "queue-full")
+			q.registrations = q.registrations[1:]
+		} else {
+			q.drop(item, "queue-full")
+			return
+		}
+	}
+	q.push(item)
+}
+
+// supersede removes the routes of the message from every pending retry,
+// including those being published, and discards the retries left without
+// any.
+func (q *RetryQueue) supersede(message routing_table.RegistryMessage) {
+	for _, item := range q.inFlight {
+		trimURIs(item, message)
+	}
+	q.unregistrations = supersede(q.unregistrations, message)
+	q.registrations = supersede(q.registrations, message)
+}
+
+func (q *RetryQueue) push(item *retryItem) {
+	if item.subject == UnregisterSubject {
+		q.unregistrations = append(q.unregistrations, item)
+	} else {
+		q.registrations = append(q.registrations, item)
+	}
+	q.sendDepth()
+}
+
+func (q *RetryQueue) drop(item *retryItem, reason string) {
+	publishDrops.Increment()
+	q.logger.Info("dropped-message", lager.Data{
+		"subject":  item.subject,
+		"message":  item.message,
+		"attempts": item.attempts,
+		"reason":   reason,
+	})
+}
+
+func (q *RetryQueue) depth() int {
+	return len(q.unregistrations) + len(q.registrations)
+}
+
+// sendDepth sends the depth of the queue if it changed since it was last
+// sent, since it is called for every published message.
+func (q *RetryQueue) sendDepth() {
+	depth := q.depth()
+	if depth == q.sentDepth {
+		return
+	}
+
+	err := retryQueueDepth.Send(depth)
+	if err != nil {
+		q.logger.Error("failed-to-send-retry-queue-depth-metric", err)
+		return
+	}
+	q.sentDepth = depth
+}
+
+func takeDue(items []*retryItem, due []*retryItem, now time.Time) ([]*retryItem, []*retryItem) {
+	remaining := items[:0]
+	for _, item := range items {
+		if item.nextAttempt.After(now) {
+			remaining = append(remaining, item)
+		} else {
+			due = append(due, item)
+		}
+	}
+	return remaining, due
+}
+
+func supersede(items []*retryItem, message routing_table.RegistryMessage) []*retryItem {
+	remaining := items[:0]
+	for _, item := range items {
+		trimURIs(item, message)
+		if len(item.message.URIs) > 0 {
+			remaining = append(remaining, item)
+		}
+	}
+	return remaining
+}
+
+// trimURIs removes the URIs of the message from the item if both are for the
+// same endpoint.
+func trimURIs(item *retryItem, message routing_table.RegistryMessage) {
+	if item.message.Host != message.Host || item.message.Port != message.Port {
+		return
+	}
+
+	superseded := make(map[string]struct{}, len(message.URIs))
+	for _, uri := range message.URIs {
+		superseded[uri] = struct{}{}
+	}
+
+	uris := []string{}
+	for _, uri := range item.message.URIs {
+		if _, ok := superseded[uri]; !ok {
+			uris = append(uris, uri)
+		}
+	}
+	item.message.URIs = uris
+}
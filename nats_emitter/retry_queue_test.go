@@ -0,0 +1,227 @@
+package nats_emitter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apcera/nats"
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/cloudfoundry/gunk/diegonats"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryQueue", func() {
+	var (
+		natsClient       *diegonats.FakeNATSClient
+		clock            *fakeclock.FakeClock
+		retryQueue       *nats_emitter.RetryQueue
+		process          ifrit.Process
+		fakeMetricSender *fake_metrics_sender.FakeMetricSender
+
+		lock      sync.Mutex
+		published map[string][]string
+		failing   bool
+	)
+
+	messageFor := func(host string, uris ...string) routing_table.RegistryMessage {
+		return routing_table.RegistryMessage{Host: host, Port: 61000, URIs: uris}
+	}
+
+	payloadOf := func(message routing_table.RegistryMessage) string {
+		payload, err := json.Marshal(message)
+		Expect(err).NotTo(HaveOccurred())
+		return string(payload)
+	}
+
+	publishedTo := func(subject string) func() []string {
+		return func() []string {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]string{}, published[subject]...)
+		}
+	}
+
+	BeforeEach(func() {
+		natsClient = diegonats.NewFakeClient()
+		clock = fakeclock.NewFakeClock(time.Now())
+		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+		metrics.Initialize(fakeMetricSender, nil)
+
+		published = map[string][]string{}
+		failing = false
+
+		for _, subject := range []string{"router.register", "router.unregister"} {
+			subject := subject
+			natsClient.WhenPublishing(subject, func(msg *nats.Msg) error {
+				lock.Lock()
+				defer lock.Unlock()
+
+				if failing {
+					return errors.New("still down")
+				}
+				published[subject] = append(published[subject], string(msg.Data))
+				return nil
+			})
+		}
+
+		retryQueue = nats_emitter.NewRetryQueue(natsClient, clock, 2, time.Second, 4*time.Second, 3, lagertest.NewTestLogger("test"))
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(retryQueue)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("republishes queued messages after the backoff", func() {
+		retryQueue.Enqueue("router.register", messageFor("1.1.1.1", "app.example.com"))
+		Eventually(clock.WatcherCount).Should(Equal(1))
+
+		clock.Increment(999 * time.Millisecond)
+		Consistently(publishedTo("router.register")).Should(BeEmpty())
+
+		clock.Increment(time.Millisecond)
+		Eventually(publishedTo("router.register")).Should(Equal([]string{payloadOf(messageFor("1.1.1.1", "app.example.com"))}))
+		Eventually(retryQueue.Depth).Should(BeZero())
+		Expect(fakeMetricSender.GetCounter("MessagePublishRetries")).To(BeEquivalentTo(1))
+	})
+
+	Context("when a newer message for the same route is queued", func() {
+		BeforeEach(func() {
+			retryQueue.Enqueue("router.unregister", messageFor("1.1.1.1", "app.example.com", "other.example.com"))
+			retryQueue.Enqueue("router.register", messageFor("1.1.1.1", "app.example.com"))
+		})
+
+		It("only retries the routes of the older message that were not superseded", func() {
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			clock.Increment(time.Second)
+
+			Eventually(publishedTo("router.register")).Should(Equal([]string{payloadOf(messageFor("1.1.1.1", "app.example.com"))}))
+			Eventually(publishedTo("router.unregister")).Should(Equal([]string{payloadOf(messageFor("1.1.1.1", "other.example.com"))}))
+		})
+	})
+
+	Context("when a newer message for the same route is published", func() {
+		BeforeEach(func() {
+			retryQueue.Enqueue("router.unregister", messageFor("1.1.1.1", "app.example.com"))
+			retryQueue.Enqueue("router.unregister", messageFor("2.2.2.2", "app.example.com"))
+			retryQueue.Supersede(messageFor("1.1.1.1", "app.example.com"))
+		})
+
+		It("discards the pending retry", func() {
+			Expect(retryQueue.Depth()).To(Equal(1))
+
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			clock.Increment(time.Second)
+
+			Eventually(publishedTo("router.unregister")).Should(Equal([]string{payloadOf(messageFor("2.2.2.2", "app.example.com"))}))
+			Consistently(publishedTo("router.unregister")).Should(HaveLen(1))
+		})
+	})
+
+	Context("when a newer message does not concern any pending retry", func() {
+		BeforeEach(func() {
+			retryQueue.Enqueue("router.unregister", messageFor("1.1.1.1", "app.example.com"))
+		})
+
+		It("does not send the queue depth again", func() {
+			Expect(fakeMetricSender.GetValue("MessageRetryQueueDepth").Value).To(BeEquivalentTo(1))
+			Expect(metrics.SendValue("MessageRetryQueueDepth", 42, "Metric")).To(Succeed())
+
+			retryQueue.Supersede(messageFor("2.2.2.2", "app.example.com"))
+			Expect(fakeMetricSender.GetValue("MessageRetryQueueDepth").Value).To(BeEquivalentTo(42))
+
+			retryQueue.Supersede(messageFor("1.1.1.1", "app.example.com"))
+			Expect(fakeMetricSender.GetValue("MessageRetryQueueDepth").Value).To(BeEquivalentTo(0))
+		})
+	})
+
+	It("does not hold up emitting while publishing a retry", func() {
+		published := make(chan struct{})
+		natsClient.WhenPublishing("router.register", func(*nats.Msg) error {
+			retryQueue.Supersede(messageFor("2.2.2.2", "app.example.com"))
+			close(published)
+			return nil
+		})
+
+		retryQueue.Enqueue("router.register", messageFor("1.1.1.1", "app.example.com"))
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(time.Second)
+
+		Eventually(published).Should(BeClosed())
+	})
+
+	Context("when the retries keep failing", func() {
+		BeforeEach(func() {
+			failing = true
+			retryQueue = nats_emitter.NewRetryQueue(natsClient, clock, 2, time.Second, 4*time.Second, 5, lagertest.NewTestLogger("test"))
+		})
+
+		It("backs off exponentially up to the maximum delay and then drops the message", func() {
+			enqueuedAt := clock.Now()
+			retryQueue.Enqueue("router.unregister", messageFor("1.1.1.1", "app.example.com"))
+
+			attempts := []time.Duration{}
+			natsClient.WhenPublishing("router.unregister", func(*nats.Msg) error {
+				lock.Lock()
+				defer lock.Unlock()
+				attempts = append(attempts, clock.Now().Sub(enqueuedAt))
+				return errors.New("still down")
+			})
+
+			Eventually(func() uint64 {
+				clock.Increment(250 * time.Millisecond)
+				return fakeMetricSender.GetCounter("MessagePublishDrops")
+			}).Should(BeEquivalentTo(1))
+
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(attempts).To(HaveLen(4))
+			Expect(attempts[0]).To(BeNumerically("~", 1*time.Second, 250*time.Millisecond))
+			Expect(attempts[1]).To(BeNumerically("~", 3*time.Second, 500*time.Millisecond))
+			Expect(attempts[2]).To(BeNumerically("~", 7*time.Second, 750*time.Millisecond))
+			Expect(attempts[3]).To(BeNumerically("~", 11*time.Second, 1000*time.Millisecond))
+			Expect(retryQueue.Depth()).To(BeZero())
+		})
+	})
+
+	Context("when the queue is full", func() {
+		BeforeEach(func() {
+			retryQueue.Enqueue("router.register", messageFor("1.1.1.1", "app.example.com"))
+			retryQueue.Enqueue("router.register", messageFor("2.2.2.2", "app.example.com"))
+		})
+
+		It("drops new registrations", func() {
+			retryQueue.Enqueue("router.register", messageFor("3.3.3.3", "app.example.com"))
+
+			Expect(retryQueue.Depth()).To(Equal(2))
+			Expect(fakeMetricSender.GetCounter("MessagePublishDrops")).To(BeEquivalentTo(1))
+			Expect(fakeMetricSender.GetValue("MessageRetryQueueDepth").Value).To(BeEquivalentTo(2))
+		})
+
+		It("evicts the oldest registration to make room for an unregistration", func() {
+			retryQueue.Enqueue("router.unregister", messageFor("3.3.3.3", "app.example.com"))
+			Expect(retryQueue.Depth()).To(Equal(2))
+
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			clock.Increment(time.Second)
+
+			Eventually(publishedTo("router.unregister")).Should(Equal([]string{payloadOf(messageFor("3.3.3.3", "app.example.com"))}))
+			Eventually(publishedTo("router.register")).Should(Equal([]string{payloadOf(messageFor("2.2.2.2", "app.example.com"))}))
+		})
+	})
+})
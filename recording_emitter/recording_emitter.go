@@ -0,0 +1,97 @@
+package recording_emitter
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	RegisterSubject      = "router.register"
+	UnregisterSubject    = "router.unregister"
+	TCPRegisterSubject   = "tcp.register"
+	TCPUnregisterSubject = "tcp.unregister"
+)
+
+// Record is a single line of recorder output.
+type Record struct {
+	Subject string      `json:"subject"`
+	Message interface{} `json:"message"`
+}
+
+// Recorder writes every message it is asked to emit to a writer as
+// newline-delimited JSON instead of publishing it. It is safe for concurrent
+// use, so the same Recorder can back both the HTTP and TCP emitters.
+type Recorder struct {
+	writer io.Writer
+	lock   sync.Mutex
+	logger lager.Logger
+}
+
+func NewRecorder(writer io.Writer, logger lager.Logger) *Recorder {
+	return &Recorder{
+		writer: writer,
+		logger: logger.Session("recording-emitter"),
+	}
+}
+
+func (r *Recorder) Emitter() nats_emitter.NATSEmitter {
+	return httpRecorder{r}
+}
+
+func (r *Recorder) TCPEmitter() tcp_emitter.TCPEmitter {
+	return tcpRecorder{r}
+}
+
+func (r *Recorder) record(records []Record) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	encoder := json.NewEncoder(r.writer)
+	for _, record := range records {
+		err := encoder.Encode(record)
+		if err != nil {
+			r.logger.Error("failed-to-record-message", err, lager.Data{"subject": record.Subject})
+			return err
+		}
+	}
+
+	return nil
+}
+
+type httpRecorder struct {
+	*Recorder
+}
+
+func (r httpRecorder) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	records := make([]Record, 0, len(messagesToEmit.RegistrationMessages)+len(messagesToEmit.UnregistrationMessages))
+	for _, message := range messagesToEmit.RegistrationMessages {
+		records = append(records, Record{Subject: RegisterSubject, Message: message})
+	}
+	for _, message := range messagesToEmit.UnregistrationMessages {
+		records = append(records, Record{Subject: UnregisterSubject, Message: message})
+	}
+
+	return r.record(records)
+}
+
+type tcpRecorder struct {
+	*Recorder
+}
+
+func (r tcpRecorder) Emit(messagesToEmit routing_table.TCPMessagesToEmit) error {
+	records := make([]Record, 0, len(messagesToEmit.RegistrationMessages)+len(messagesToEmit.UnregistrationMessages))
+	for _, message := range messagesToEmit.RegistrationMessages {
+		records = append(records, Record{Subject: TCPRegisterSubject, Message: message})
+	}
+	for _, message := range messagesToEmit.UnregistrationMessages {
+		records = append(records, Record{Subject: TCPUnregisterSubject, Message: message})
+	}
+
+	return r.record(records)
+}
@@ -0,0 +1,13 @@
+package recording_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRecordingEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Recording Emitter Suite")
+}
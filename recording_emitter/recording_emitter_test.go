@@ -0,0 +1,81 @@
+package recording_emitter_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/route-emitter/recording_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+var _ = Describe("Recorder", func() {
+	var (
+		buffer   *bytes.Buffer
+		recorder *recording_emitter.Recorder
+	)
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		recorder = recording_emitter.NewRecorder(buffer, lagertest.NewTestLogger("test"))
+	})
+
+	lines := func() []string {
+		return strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	}
+
+	Describe("Emitter", func() {
+		It("records registrations and unregistrations as newline-delimited JSON", func() {
+			err := recorder.Emitter().Emit(routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{
+					{URIs: []string{"foo.com"}, Host: "1.1.1.1", Port: 11},
+				},
+				UnregistrationMessages: []routing_table.RegistryMessage{
+					{URIs: []string{"bar.com"}, Host: "2.2.2.2", Port: 22},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lines()).To(HaveLen(2))
+			Expect(lines()[0]).To(MatchJSON(`{"subject":"router.register","message":{"uris":["foo.com"],"host":"1.1.1.1","port":11}}`))
+			Expect(lines()[1]).To(MatchJSON(`{"subject":"router.unregister","message":{"uris":["bar.com"],"host":"2.2.2.2","port":22}}`))
+		})
+	})
+
+	Describe("TCPEmitter", func() {
+		It("records tcp mappings as newline-delimited JSON", func() {
+			err := recorder.TCPEmitter().Emit(routing_table.TCPMessagesToEmit{
+				RegistrationMessages: []routing_table.TCPMappingMessage{
+					{RouterGroupGuid: "rg-1", ExternalPort: 61000, BackendHost: "1.1.1.1", BackendPort: 11},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lines()).To(HaveLen(1))
+			Expect(lines()[0]).To(MatchJSON(`{"subject":"tcp.register","message":{"router_group_guid":"rg-1","port":61000,"backend_ip":"1.1.1.1","backend_port":11}}`))
+		})
+	})
+
+	Context("when the writer fails", func() {
+		BeforeEach(func() {
+			recorder = recording_emitter.NewRecorder(failingWriter{}, lagertest.NewTestLogger("test"))
+		})
+
+		It("returns the error", func() {
+			err := recorder.Emitter().Emit(routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{{URIs: []string{"foo.com"}}},
+			})
+			Expect(err).To(MatchError("disk full"))
+		})
+	})
+})
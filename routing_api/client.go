@@ -0,0 +1,121 @@
+package routing_api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	UpsertRoutesPath           = "/routing/v1/routes"
+	DeleteRoutesPath           = "/routing/v1/routes"
+	UpsertTcpRouteMappingsPath = "/routing/v1/tcp_routes/create"
+	DeleteTcpRouteMappingsPath = "/routing/v1/tcp_routes/delete"
+)
+
+type Route struct {
+	Route           string `json:"route"`
+	Port            uint32 `json:"port"`
+	IP              string `json:"ip"`
+	TTL             int    `json:"ttl,omitempty"`
+	LogGuid         string `json:"log_guid,omitempty"`
+	RouteServiceUrl string `json:"route_service_url,omitempty"`
+}
+
+type TcpRouteMapping struct {
+	RouterGroupGuid string `json:"router_group_guid"`
+	ExternalPort    uint32 `json:"port"`
+	HostIP          string `json:"backend_ip"`
+	HostPort        uint32 `json:"backend_port"`
+	TTL             int    `json:"ttl,omitempty"`
+}
+
+//go:generate counterfeiter -o fake_routing_api/fake_client.go . Client
+type Client interface {
+	UpsertRoutes(routes []Route) error
+	DeleteRoutes(routes []Route) error
+	UpsertTcpRouteMappings(mappings []TcpRouteMapping) error
+	DeleteTcpRouteMappings(mappings []TcpRouteMapping) error
+}
+
+type client struct {
+	url          string
+	httpClient   *http.Client
+	tokenFetcher TokenFetcher
+}
+
+// NewClient returns a Routing API client. If tokenFetcher is nil requests are
+// sent without an Authorization header.
+func NewClient(url string, httpClient *http.Client, tokenFetcher TokenFetcher) Client {
+	return &client{
+		url:          strings.TrimRight(url, "/"),
+		httpClient:   httpClient,
+		tokenFetcher: tokenFetcher,
+	}
+}
+
+func (c *client) UpsertRoutes(routes []Route) error {
+	return c.do("POST", UpsertRoutesPath, routes)
+}
+
+func (c *client) DeleteRoutes(routes []Route) error {
+	return c.do("DELETE", DeleteRoutesPath, routes)
+}
+
+func (c *client) UpsertTcpRouteMappings(mappings []TcpRouteMapping) error {
+	return c.do("POST", UpsertTcpRouteMappingsPath, mappings)
+}
+
+func (c *client) DeleteTcpRouteMappings(mappings []TcpRouteMapping) error {
+	return c.do("POST", DeleteTcpRouteMappingsPath, mappings)
+}
+
+func (c *client) do(method, path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.send(method, path, payload, false)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized && c.tokenFetcher != nil {
+		// the token may have been revoked or expired early, so try once more with a fresh one
+		response.Body.Close()
+		response, err = c.send(method, path, payload, true)
+		if err != nil {
+			return err
+		}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		responseBody, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("routing api returned status %d: %s", response.StatusCode, string(responseBody))
+	}
+
+	return nil
+}
+
+func (c *client) send(method, path string, payload []byte, forceTokenUpdate bool) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	if c.tokenFetcher != nil {
+		token, err := c.tokenFetcher.FetchToken(forceTokenUpdate)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", "bearer "+token)
+	}
+
+	return c.httpClient.Do(request)
+}
@@ -0,0 +1,173 @@
+package routing_api_test
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api/fake_routing_api"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server *ghttp.Server
+		client routing_api.Client
+
+		routes   []routing_api.Route
+		mappings []routing_api.TcpRouteMapping
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = routing_api.NewClient(server.URL()+"/", &http.Client{}, nil)
+
+		routes = []routing_api.Route{
+			{Route: "foo.example.com", Port: 11, IP: "1.1.1.1", TTL: 120, LogGuid: "log-guid"},
+		}
+		mappings = []routing_api.TcpRouteMapping{
+			{RouterGroupGuid: "rg-1", ExternalPort: 61000, HostIP: "1.1.1.1", HostPort: 11, TTL: 120},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("UpsertRoutes", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", routing_api.UpsertRoutesPath),
+				ghttp.VerifyHeaderKV("Content-Type", "application/json"),
+				ghttp.VerifyJSON(`[{"route":"foo.example.com","port":11,"ip":"1.1.1.1","ttl":120,"log_guid":"log-guid"}]`),
+				ghttp.RespondWith(http.StatusCreated, nil),
+			))
+		})
+
+		It("posts the routes", func() {
+			Expect(client.UpsertRoutes(routes)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Describe("DeleteRoutes", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("DELETE", routing_api.DeleteRoutesPath),
+				ghttp.VerifyJSON(`[{"route":"foo.example.com","port":11,"ip":"1.1.1.1","ttl":120,"log_guid":"log-guid"}]`),
+				ghttp.RespondWith(http.StatusNoContent, nil),
+			))
+		})
+
+		It("deletes the routes", func() {
+			Expect(client.DeleteRoutes(routes)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Describe("UpsertTcpRouteMappings", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", routing_api.UpsertTcpRouteMappingsPath),
+				ghttp.VerifyHeaderKV("Content-Type", "application/json"),
+				ghttp.VerifyJSON(`[{"router_group_guid":"rg-1","port":61000,"backend_ip":"1.1.1.1","backend_port":11,"ttl":120}]`),
+				ghttp.RespondWith(http.StatusCreated, nil),
+			))
+		})
+
+		It("posts the mappings", func() {
+			Expect(client.UpsertTcpRouteMappings(mappings)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Describe("DeleteTcpRouteMappings", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", routing_api.DeleteTcpRouteMappingsPath),
+				ghttp.VerifyJSON(`[{"router_group_guid":"rg-1","port":61000,"backend_ip":"1.1.1.1","backend_port":11,"ttl":120}]`),
+				ghttp.RespondWith(http.StatusNoContent, nil),
+			))
+		})
+
+		It("posts the mappings to delete", func() {
+			Expect(client.DeleteTcpRouteMappings(mappings)).To(Succeed())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when a token fetcher is configured", func() {
+		var tokenFetcher *fake_routing_api.FakeTokenFetcher
+
+		BeforeEach(func() {
+			tokenFetcher = new(fake_routing_api.FakeTokenFetcher)
+			tokenFetcher.FetchTokenStub = func(forceUpdate bool) (string, error) {
+				if forceUpdate {
+					return "fresh-token", nil
+				}
+				return "cached-token", nil
+			}
+
+			client = routing_api.NewClient(server.URL(), &http.Client{}, tokenFetcher)
+		})
+
+		It("sends the token as a bearer token", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "bearer cached-token"),
+				ghttp.RespondWith(http.StatusCreated, nil),
+			))
+
+			Expect(client.UpsertRoutes(routes)).To(Succeed())
+			Expect(tokenFetcher.FetchTokenCallCount()).To(Equal(1))
+			Expect(tokenFetcher.FetchTokenArgsForCall(0)).To(BeFalse())
+		})
+
+		Context("when the routing api rejects the token", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyHeaderKV("Authorization", "bearer cached-token"),
+						ghttp.RespondWith(http.StatusUnauthorized, nil),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyHeaderKV("Authorization", "bearer fresh-token"),
+						ghttp.VerifyJSON(`[{"route":"foo.example.com","port":11,"ip":"1.1.1.1","ttl":120,"log_guid":"log-guid"}]`),
+						ghttp.RespondWith(http.StatusCreated, nil),
+					),
+				)
+			})
+
+			It("retries once with a fresh token", func() {
+				Expect(client.UpsertRoutes(routes)).To(Succeed())
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+				Expect(tokenFetcher.FetchTokenArgsForCall(1)).To(BeTrue())
+			})
+		})
+
+		Context("when fetching the token fails", func() {
+			BeforeEach(func() {
+				tokenFetcher.FetchTokenStub = nil
+				tokenFetcher.FetchTokenReturns("", errors.New("no token for you"))
+			})
+
+			It("returns the error without calling the routing api", func() {
+				Expect(client.UpsertRoutes(routes)).To(MatchError("no token for you"))
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the routing api responds with an error", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusInternalServerError, "boom"))
+		})
+
+		It("returns an error including the status code", func() {
+			err := client.UpsertTcpRouteMappings(mappings)
+			Expect(err).To(MatchError(ContainSubstring("500")))
+		})
+	})
+})
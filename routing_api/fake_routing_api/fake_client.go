@@ -0,0 +1,173 @@
+// This file was generated by counterfeiter
+package fake_routing_api
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
+)
+
+type FakeClient struct {
+	UpsertRoutesStub        func(routes []routing_api.Route) error
+	upsertRoutesMutex       sync.RWMutex
+	upsertRoutesArgsForCall []struct {
+		routes []routing_api.Route
+	}
+	upsertRoutesReturns struct {
+		result1 error
+	}
+	DeleteRoutesStub        func(routes []routing_api.Route) error
+	deleteRoutesMutex       sync.RWMutex
+	deleteRoutesArgsForCall []struct {
+		routes []routing_api.Route
+	}
+	deleteRoutesReturns struct {
+		result1 error
+	}
+	UpsertTcpRouteMappingsStub        func(mappings []routing_api.TcpRouteMapping) error
+	upsertTcpRouteMappingsMutex       sync.RWMutex
+	upsertTcpRouteMappingsArgsForCall []struct {
+		mappings []routing_api.TcpRouteMapping
+	}
+	upsertTcpRouteMappingsReturns struct {
+		result1 error
+	}
+	DeleteTcpRouteMappingsStub        func(mappings []routing_api.TcpRouteMapping) error
+	deleteTcpRouteMappingsMutex       sync.RWMutex
+	deleteTcpRouteMappingsArgsForCall []struct {
+		mappings []routing_api.TcpRouteMapping
+	}
+	deleteTcpRouteMappingsReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeClient) UpsertRoutes(routes []routing_api.Route) error {
+	fake.upsertRoutesMutex.Lock()
+	fake.upsertRoutesArgsForCall = append(fake.upsertRoutesArgsForCall, struct {
+		routes []routing_api.Route
+	}{routes})
+	fake.upsertRoutesMutex.Unlock()
+	if fake.UpsertRoutesStub != nil {
+		return fake.UpsertRoutesStub(routes)
+	} else {
+		return fake.upsertRoutesReturns.result1
+	}
+}
+
+func (fake *FakeClient) UpsertRoutesCallCount() int {
+	fake.upsertRoutesMutex.RLock()
+	defer fake.upsertRoutesMutex.RUnlock()
+	return len(fake.upsertRoutesArgsForCall)
+}
+
+func (fake *FakeClient) UpsertRoutesArgsForCall(i int) []routing_api.Route {
+	fake.upsertRoutesMutex.RLock()
+	defer fake.upsertRoutesMutex.RUnlock()
+	return fake.upsertRoutesArgsForCall[i].routes
+}
+
+func (fake *FakeClient) UpsertRoutesReturns(result1 error) {
+	fake.UpsertRoutesStub = nil
+	fake.upsertRoutesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) DeleteRoutes(routes []routing_api.Route) error {
+	fake.deleteRoutesMutex.Lock()
+	fake.deleteRoutesArgsForCall = append(fake.deleteRoutesArgsForCall, struct {
+		routes []routing_api.Route
+	}{routes})
+	fake.deleteRoutesMutex.Unlock()
+	if fake.DeleteRoutesStub != nil {
+		return fake.DeleteRoutesStub(routes)
+	} else {
+		return fake.deleteRoutesReturns.result1
+	}
+}
+
+func (fake *FakeClient) DeleteRoutesCallCount() int {
+	fake.deleteRoutesMutex.RLock()
+	defer fake.deleteRoutesMutex.RUnlock()
+	return len(fake.deleteRoutesArgsForCall)
+}
+
+func (fake *FakeClient) DeleteRoutesArgsForCall(i int) []routing_api.Route {
+	fake.deleteRoutesMutex.RLock()
+	defer fake.deleteRoutesMutex.RUnlock()
+	return fake.deleteRoutesArgsForCall[i].routes
+}
+
+func (fake *FakeClient) DeleteRoutesReturns(result1 error) {
+	fake.DeleteRoutesStub = nil
+	fake.deleteRoutesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) UpsertTcpRouteMappings(mappings []routing_api.TcpRouteMapping) error {
+	fake.upsertTcpRouteMappingsMutex.Lock()
+	fake.upsertTcpRouteMappingsArgsForCall = append(fake.upsertTcpRouteMappingsArgsForCall, struct {
+		mappings []routing_api.TcpRouteMapping
+	}{mappings})
+	fake.upsertTcpRouteMappingsMutex.Unlock()
+	if fake.UpsertTcpRouteMappingsStub != nil {
+		return fake.UpsertTcpRouteMappingsStub(mappings)
+	} else {
+		return fake.upsertTcpRouteMappingsReturns.result1
+	}
+}
+
+func (fake *FakeClient) UpsertTcpRouteMappingsCallCount() int {
+	fake.upsertTcpRouteMappingsMutex.RLock()
+	defer fake.upsertTcpRouteMappingsMutex.RUnlock()
+	return len(fake.upsertTcpRouteMappingsArgsForCall)
+}
+
+func (fake *FakeClient) UpsertTcpRouteMappingsArgsForCall(i int) []routing_api.TcpRouteMapping {
+	fake.upsertTcpRouteMappingsMutex.RLock()
+	defer fake.upsertTcpRouteMappingsMutex.RUnlock()
+	return fake.upsertTcpRouteMappingsArgsForCall[i].mappings
+}
+
+func (fake *FakeClient) UpsertTcpRouteMappingsReturns(result1 error) {
+	fake.UpsertTcpRouteMappingsStub = nil
+	fake.upsertTcpRouteMappingsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) DeleteTcpRouteMappings(mappings []routing_api.TcpRouteMapping) error {
+	fake.deleteTcpRouteMappingsMutex.Lock()
+	fake.deleteTcpRouteMappingsArgsForCall = append(fake.deleteTcpRouteMappingsArgsForCall, struct {
+		mappings []routing_api.TcpRouteMapping
+	}{mappings})
+	fake.deleteTcpRouteMappingsMutex.Unlock()
+	if fake.DeleteTcpRouteMappingsStub != nil {
+		return fake.DeleteTcpRouteMappingsStub(mappings)
+	} else {
+		return fake.deleteTcpRouteMappingsReturns.result1
+	}
+}
+
+func (fake *FakeClient) DeleteTcpRouteMappingsCallCount() int {
+	fake.deleteTcpRouteMappingsMutex.RLock()
+	defer fake.deleteTcpRouteMappingsMutex.RUnlock()
+	return len(fake.deleteTcpRouteMappingsArgsForCall)
+}
+
+func (fake *FakeClient) DeleteTcpRouteMappingsArgsForCall(i int) []routing_api.TcpRouteMapping {
+	fake.deleteTcpRouteMappingsMutex.RLock()
+	defer fake.deleteTcpRouteMappingsMutex.RUnlock()
+	return fake.deleteTcpRouteMappingsArgsForCall[i].mappings
+}
+
+func (fake *FakeClient) DeleteTcpRouteMappingsReturns(result1 error) {
+	fake.DeleteTcpRouteMappingsStub = nil
+	fake.deleteTcpRouteMappingsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ routing_api.Client = new(FakeClient)
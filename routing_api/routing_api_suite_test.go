@@ -0,0 +1,13 @@
+package routing_api_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRoutingApi(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Routing API Suite")
+}
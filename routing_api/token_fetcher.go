@@ -0,0 +1,101 @@
+package routing_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+// tokens are refreshed this long before the authorization server says they expire
+const TokenExpiryBuffer = 30 * time.Second
+
+//go:generate counterfeiter -o fake_routing_api/fake_token_fetcher.go . TokenFetcher
+type TokenFetcher interface {
+	FetchToken(forceUpdate bool) (string, error)
+}
+
+type oauthTokenFetcher struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	clock        clock.Clock
+
+	lock      sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// NewOAuthTokenFetcher fetches bearer tokens using the OAuth2 client
+// credentials grant and caches them until shortly before they expire.
+func NewOAuthTokenFetcher(tokenURL, clientID, clientSecret string, httpClient *http.Client, clock clock.Clock) TokenFetcher {
+	return &oauthTokenFetcher{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   httpClient,
+		clock:        clock,
+	}
+}
+
+func (f *oauthTokenFetcher) FetchToken(forceUpdate bool) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !forceUpdate && f.token != "" && f.clock.Now().Before(f.expiresAt) {
+		return f.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+
+	request, err := http.NewRequest("POST", f.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth(f.clientID, f.clientSecret)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := f.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned status %d: %s", response.StatusCode, string(body))
+	}
+
+	var token tokenResponse
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return "", err
+	}
+
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token response did not contain an access token")
+	}
+
+	f.token = token.AccessToken
+	f.expiresAt = f.clock.Now().Add(time.Duration(token.ExpiresIn)*time.Second - TokenExpiryBuffer)
+
+	return f.token, nil
+}
@@ -0,0 +1,83 @@
+package routing_api_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OAuthTokenFetcher", func() {
+	var (
+		server       *ghttp.Server
+		clock        *fakeclock.FakeClock
+		tokenFetcher routing_api.TokenFetcher
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		clock = fakeclock.NewFakeClock(time.Now())
+		tokenFetcher = routing_api.NewOAuthTokenFetcher(server.URL()+"/oauth/token", "client-id", "client-secret", &http.Client{}, clock)
+
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/oauth/token"),
+				ghttp.VerifyBasicAuth("client-id", "client-secret"),
+				ghttp.VerifyForm(map[string][]string{"grant_type": {"client_credentials"}}),
+				ghttp.RespondWith(http.StatusOK, `{"access_token":"token-1","expires_in":60}`),
+			),
+			ghttp.RespondWith(http.StatusOK, `{"access_token":"token-2","expires_in":60}`),
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fetches a token using the client credentials grant", func() {
+		token, err := tokenFetcher.FetchToken(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("token-1"))
+	})
+
+	It("caches the token until shortly before it expires", func() {
+		_, err := tokenFetcher.FetchToken(false)
+		Expect(err).NotTo(HaveOccurred())
+
+		clock.Increment(60*time.Second - routing_api.TokenExpiryBuffer - time.Second)
+		token, err := tokenFetcher.FetchToken(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("token-1"))
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+
+		clock.Increment(time.Second)
+		token, err = tokenFetcher.FetchToken(false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("token-2"))
+	})
+
+	It("fetches a new token when forced to", func() {
+		_, err := tokenFetcher.FetchToken(false)
+		Expect(err).NotTo(HaveOccurred())
+
+		token, err := tokenFetcher.FetchToken(true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("token-2"))
+	})
+
+	Context("when the authorization server rejects the request", func() {
+		BeforeEach(func() {
+			server.SetHandler(0, ghttp.RespondWith(http.StatusUnauthorized, "nope"))
+		})
+
+		It("returns an error", func() {
+			_, err := tokenFetcher.FetchToken(false)
+			Expect(err).To(MatchError(ContainSubstring("401")))
+		})
+	})
+})
@@ -5,6 +5,8 @@ import "code.cloudfoundry.org/bbs/models"
 type ActualLRPRoutingInfo struct {
 	ActualLRP  *models.ActualLRP
 	Evacuating bool
+	// AvailabilityZone is the zone of the cell running the actual, when known.
+	AvailabilityZone string
 }
 
 func NewActualLRPRoutingInfo(actualLRPGroup *models.ActualLRPGroup) *ActualLRPRoutingInfo {
@@ -10,23 +10,127 @@ import (
 type RoutesByRoutingKey map[RoutingKey]Routes
 type EndpointsByRoutingKey map[RoutingKey][]Endpoint
 
-func RoutesByRoutingKeyFromSchedulingInfos(schedulingInfos []*models.DesiredLRPSchedulingInfo) RoutesByRoutingKey {
+// RouteRejection describes a route URI that was left out of the table, either
+// because its path is invalid or because a policy rejected its hostname or
+// route service. RouteServiceUrl is only set when the route service was
+// rejected.
+type RouteRejection struct {
+	ProcessGuid     string
+	URI             string
+	RouteServiceUrl string
+	Err             error
+}
+
+// RoutesByRoutingKeyFromSchedulingInfos collects the routes of every desired
+// LRP; see RoutesFromSchedulingInfo. Nil policies allow everything.
+func RoutesByRoutingKeyFromSchedulingInfos(
+	schedulingInfos []*models.DesiredLRPSchedulingInfo,
+	hostnames HostnamePolicy,
+	routeServices RouteServicePolicy,
+) (RoutesByRoutingKey, []RouteRejection) {
 	routesByRoutingKey := RoutesByRoutingKey{}
+	rejections := []RouteRejection{}
 	for _, desired := range schedulingInfos {
-		routes, err := cfroutes.CFRoutesFromRoutingInfo(desired.Routes)
-		if err == nil && len(routes) > 0 {
-			for _, cfRoute := range routes {
-				key := RoutingKey{ProcessGuid: desired.ProcessGuid, ContainerPort: cfRoute.Port}
-				routesByRoutingKey[key] = Routes{
-					Hostnames:       cfRoute.Hostnames,
-					LogGuid:         desired.LogGuid,
-					RouteServiceUrl: cfRoute.RouteServiceUrl,
+		routes, rejected := RoutesFromSchedulingInfo(desired, hostnames, routeServices)
+		for key, route := range routes {
+			routesByRoutingKey[key] = route
+		}
+		rejections = append(rejections, rejected...)
+	}
+
+	return routesByRoutingKey, rejections
+}
+
+// RoutesFromSchedulingInfo returns the desired LRP's routes by container port.
+// Entries sharing a port are merged, so one port may serve several hostname
+// and path combinations; the route service url and weight come from the first
+// entry that sets them. URIs with invalid paths or that the hostname policy
+// rejects are left out and returned as rejections. An entry whose route service
+// is rejected is left out entirely rather than registered without it, so
+// traffic never bypasses the route service. Nil policies allow everything.
+func RoutesFromSchedulingInfo(
+	schedulingInfo *models.DesiredLRPSchedulingInfo,
+	hostnames HostnamePolicy,
+	routeServices RouteServicePolicy,
+) (RoutesByRoutingKey, []RouteRejection) {
+	routesByRoutingKey := RoutesByRoutingKey{}
+	rejections := []RouteRejection{}
+
+	cfRoutes, err := cfroutes.CFRoutesFromRoutingInfo(schedulingInfo.Routes)
+	if err != nil || len(cfRoutes) == 0 {
+		return routesByRoutingKey, rejections
+	}
+
+	tags := RouteTagsFor(schedulingInfo)
+	for _, cfRoute := range cfRoutes {
+		if routeServices != nil {
+			err := routeServices.Check(cfRoute.RouteServiceUrl)
+			if err != nil {
+				for _, uri := range cfRoute.URIs() {
+					rejections = append(rejections, RouteRejection{
+						ProcessGuid:     schedulingInfo.ProcessGuid,
+						URI:             uri,
+						RouteServiceUrl: cfRoute.RouteServiceUrl,
+						Err:             err,
+					})
 				}
+				continue
 			}
 		}
+
+		invalidErr := cfRoute.Validate()
+
+		uris := []string{}
+		for _, uri := range cfRoute.URIs() {
+			err := invalidErr
+			if err == nil && hostnames != nil {
+				err = hostnames.Check(schedulingInfo.ProcessGuid, uri)
+			}
+
+			if err != nil {
+				rejections = append(rejections, RouteRejection{ProcessGuid: schedulingInfo.ProcessGuid, URI: uri, Err: err})
+				continue
+			}
+			uris = append(uris, uri)
+		}
+
+		if len(uris) == 0 && len(cfRoute.Hostnames) > 0 {
+			continue
+		}
+
+		key := RoutingKey{ProcessGuid: schedulingInfo.ProcessGuid, ContainerPort: cfRoute.Port}
+		routes, found := routesByRoutingKey[key]
+		if !found {
+			routes = Routes{LogGuid: schedulingInfo.LogGuid, Tags: tags}
+		}
+
+		routes.Hostnames = appendMissing(routes.Hostnames, uris)
+		if routes.RouteServiceUrl == "" {
+			routes.RouteServiceUrl = cfRoute.RouteServiceUrl
+		}
+		if routes.Weight == 0 {
+			routes.Weight = cfRoute.Weight
+		}
+		routesByRoutingKey[key] = routes
 	}
 
-	return routesByRoutingKey
+	return routesByRoutingKey, rejections
+}
+
+func appendMissing(values []string, more []string) []string {
+	for _, value := range more {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
 }
 
 func EndpointsByRoutingKeyFromActuals(actuals []*ActualLRPRoutingInfo) EndpointsByRoutingKey {
@@ -57,12 +161,15 @@ func EndpointsFromActual(actualLRPInfo *ActualLRPRoutingInfo) (map[uint32]Endpoi
 	for _, portMapping := range actual.Ports {
 		if portMapping != nil {
 			endpoint := Endpoint{
-				InstanceGuid:  actual.InstanceGuid,
-				Host:          actual.Address,
-				Domain:        actual.Domain,
-				Port:          portMapping.HostPort,
-				ContainerPort: portMapping.ContainerPort,
-				Evacuating:    actualLRPInfo.Evacuating,
+				InstanceGuid:     actual.InstanceGuid,
+				Index:            actual.Index,
+				CellId:           actual.CellId,
+				AvailabilityZone: actualLRPInfo.AvailabilityZone,
+				Host:             actual.Address,
+				Domain:           actual.Domain,
+				Port:             portMapping.HostPort,
+				ContainerPort:    portMapping.ContainerPort,
+				Evacuating:       actualLRPInfo.Evacuating,
 			}
 			endpoints[portMapping.ContainerPort] = endpoint
 		}
@@ -14,16 +14,17 @@ var _ = Describe("ByRoutingKey", func() {
 		It("should build a map of routes", func() {
 			abcRoutes := cfroutes.CFRoutes{
 				{Hostnames: []string{"foo.com", "bar.com"}, Port: 8080, RouteServiceUrl: "https://something.creative"},
-				{Hostnames: []string{"foo.example.com"}, Port: 9090},
+				{Hostnames: []string{"foo.example.com"}, Port: 9090, Weight: 3},
 			}
 			defRoutes := cfroutes.CFRoutes{
 				{Hostnames: []string{"baz.com"}, Port: 8080},
 			}
 
-			routes := routing_table.RoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
-				{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"), Routes: abcRoutes.RoutingInfo()},
+			routes, rejections := routing_table.RoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
+				{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"), Routes: abcRoutes.RoutingInfo(), Annotation: `{"app_name":"abc-app"}`},
 				{DesiredLRPKey: models.NewDesiredLRPKey("def", "tests", "def-guid"), Routes: defRoutes.RoutingInfo()},
-			})
+			}, nil, nil)
+			Expect(rejections).To(BeEmpty())
 
 			Expect(routes).To(HaveLen(3))
 			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 8080}].Hostnames).To(Equal([]string{"foo.com", "bar.com"}))
@@ -32,19 +33,133 @@ var _ = Describe("ByRoutingKey", func() {
 
 			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 9090}].Hostnames).To(Equal([]string{"foo.example.com"}))
 			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 9090}].LogGuid).To(Equal("abc-guid"))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 9090}].Weight).To(Equal(uint32(3)))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 9090}].Tags).To(Equal(map[string]string{
+				"process_guid": "abc",
+				"app_name":     "abc-app",
+			}))
 
 			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 8080}].Hostnames).To(Equal([]string{"baz.com"}))
 			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 8080}].LogGuid).To(Equal("def-guid"))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 8080}].Weight).To(BeZero())
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 8080}].Tags).To(Equal(map[string]string{"process_guid": "def"}))
 		})
 
 		Context("when the routing info is nil", func() {
 			It("should not be included in the results", func() {
-				routes := routing_table.RoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
+				routes, _ := routing_table.RoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
 					{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"), Routes: nil},
-				})
+				}, nil, nil)
 				Expect(routes).To(HaveLen(0))
 			})
 		})
+
+		Context("when a hostname policy is given", func() {
+			It("leaves out and reports the rejected hostnames of every desired LRP", func() {
+				policy, err := routing_table.NewHostnamePolicy(routing_table.HostnameRules{Denied: []string{"*.denied.com"}})
+				Expect(err).NotTo(HaveOccurred())
+
+				abcRoutes := cfroutes.CFRoutes{{Hostnames: []string{"foo.com", "foo.denied.com"}, Port: 8080}}
+				defRoutes := cfroutes.CFRoutes{{Hostnames: []string{"bar.denied.com"}, Port: 8080}}
+
+				routes, rejections := routing_table.RoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
+					{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"), Routes: abcRoutes.RoutingInfo()},
+					{DesiredLRPKey: models.NewDesiredLRPKey("def", "tests", "def-guid"), Routes: defRoutes.RoutingInfo()},
+				}, policy, nil)
+
+				Expect(routes).To(HaveLen(1))
+				Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 8080}].Hostnames).To(Equal([]string{"foo.com"}))
+				Expect(rejections).To(ConsistOf(
+					routing_table.RouteRejection{ProcessGuid: "abc", URI: "foo.denied.com", Err: routing_table.ErrHostnameDenied},
+					routing_table.RouteRejection{ProcessGuid: "def", URI: "bar.denied.com", Err: routing_table.ErrHostnameDenied},
+				))
+			})
+		})
+	})
+
+	Describe("RoutesFromSchedulingInfo", func() {
+		var schedulingInfo *models.DesiredLRPSchedulingInfo
+
+		BeforeEach(func() {
+			schedulingInfo = &models.DesiredLRPSchedulingInfo{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid")}
+		})
+
+		setRoutes := func(routes cfroutes.CFRoutes) {
+			schedulingInfo.Routes = routes.RoutingInfo()
+		}
+
+		It("appends context paths to the hostnames", func() {
+			setRoutes(cfroutes.CFRoutes{
+				{Hostnames: []string{"foo.com", "bar.com"}, Port: 8080, Path: "/api"},
+			})
+
+			routes, rejections := routing_table.RoutesFromSchedulingInfo(schedulingInfo, nil, nil)
+			Expect(rejections).To(BeEmpty())
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 8080}].Hostnames).To(Equal([]string{"foo.com/api", "bar.com/api"}))
+		})
+
+		It("merges the routes that share a container port", func() {
+			setRoutes(cfroutes.CFRoutes{
+				{Hostnames: []string{"foo.com"}, Port: 8080},
+				{Hostnames: []string{"foo.com", "bar.com"}, Port: 8080, Path: "/api", RouteServiceUrl: "https://rs.example.com"},
+				{Hostnames: []string{"foo.com"}, Port: 8080},
+			})
+
+			routes, rejections := routing_table.RoutesFromSchedulingInfo(schedulingInfo, nil, nil)
+			Expect(rejections).To(BeEmpty())
+			Expect(routes).To(HaveLen(1))
+
+			merged := routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 8080}]
+			Expect(merged.Hostnames).To(Equal([]string{"foo.com", "foo.com/api", "bar.com/api"}))
+			Expect(merged.RouteServiceUrl).To(Equal("https://rs.example.com"))
+			Expect(merged.LogGuid).To(Equal("abc-guid"))
+		})
+
+		It("leaves out routes with invalid paths and reports them", func() {
+			setRoutes(cfroutes.CFRoutes{
+				{Hostnames: []string{"foo.com"}, Port: 8080, Path: "/api/"},
+				{Hostnames: []string{"bar.com/v2"}, Port: 8080},
+				{Hostnames: []string{"baz.com"}, Port: 9090, Path: "/api?x=1"},
+			})
+
+			routes, rejections := routing_table.RoutesFromSchedulingInfo(schedulingInfo, nil, nil)
+			Expect(rejections).To(HaveLen(2))
+			Expect(rejections[0].URI).To(Equal("foo.com/api/"))
+			Expect(rejections[0].Err).To(MatchError(ContainSubstring(`invalid path "/api/"`)))
+			Expect(rejections[1].URI).To(Equal("baz.com/api?x=1"))
+			Expect(routes).To(HaveLen(1))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 8080}].Hostnames).To(Equal([]string{"bar.com/v2"}))
+		})
+
+		Context("when a route service policy is given", func() {
+			var policy routing_table.RouteServicePolicy
+
+			BeforeEach(func() {
+				var err error
+				policy, err = routing_table.NewRouteServicePolicy(routing_table.RouteServiceRules{DeniedNetworks: []string{"10.0.0.0/8"}})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("leaves out entries bound to rejected route services and reports each of their URIs", func() {
+				setRoutes(cfroutes.CFRoutes{
+					{Hostnames: []string{"foo.com"}, Port: 8080, RouteServiceUrl: "http://rs.example.com"},
+					{Hostnames: []string{"bar.com"}, Port: 8080, Path: "/api", RouteServiceUrl: "https://rs.example.com"},
+					{Hostnames: []string{"baz.com", "qux.com"}, Port: 9090, RouteServiceUrl: "https://10.0.0.1"},
+				})
+
+				routes, rejections := routing_table.RoutesFromSchedulingInfo(schedulingInfo, nil, policy)
+				Expect(rejections).To(Equal([]routing_table.RouteRejection{
+					{ProcessGuid: "abc", URI: "foo.com", RouteServiceUrl: "http://rs.example.com", Err: routing_table.ErrRouteServiceUrlInsecure},
+					{ProcessGuid: "abc", URI: "baz.com", RouteServiceUrl: "https://10.0.0.1", Err: routing_table.ErrRouteServiceUrlInternal},
+					{ProcessGuid: "abc", URI: "qux.com", RouteServiceUrl: "https://10.0.0.1", Err: routing_table.ErrRouteServiceUrlInternal},
+				}))
+
+				Expect(routes).To(HaveLen(1))
+				route := routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 8080}]
+				Expect(route.Hostnames).To(Equal([]string{"bar.com/api"}))
+				Expect(route.RouteServiceUrl).To(Equal("https://rs.example.com"))
+			})
+		})
 	})
 
 	Describe("EndpointsByRoutingKeyFromActuals", func() {
@@ -79,11 +194,11 @@ var _ = Describe("ByRoutingKey", func() {
 			Expect(endpoints).To(HaveLen(3))
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(HaveLen(2))
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(ContainElement(routing_table.Endpoint{Host: "1.1.1.1", Domain: "domain", Port: 11, ContainerPort: 44}))
-			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(ContainElement(routing_table.Endpoint{Host: "2.2.2.2", Domain: "domain", Port: 22, ContainerPort: 44}))
+			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 44}]).To(ContainElement(routing_table.Endpoint{Index: 1, Host: "2.2.2.2", Domain: "domain", Port: 22, ContainerPort: 44}))
 
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(HaveLen(2))
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(ContainElement(routing_table.Endpoint{Host: "1.1.1.1", Domain: "domain", Port: 66, ContainerPort: 99}))
-			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(ContainElement(routing_table.Endpoint{Host: "2.2.2.2", Domain: "domain", Port: 88, ContainerPort: 99}))
+			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 99}]).To(ContainElement(routing_table.Endpoint{Index: 1, Host: "2.2.2.2", Domain: "domain", Port: 88, ContainerPort: 99}))
 
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 55}]).To(HaveLen(1))
 			Expect(endpoints[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 55}]).To(ContainElement(routing_table.Endpoint{Host: "3.3.3.3", Domain: "domain", Port: 33, ContainerPort: 55}))
@@ -98,17 +213,61 @@ var _ = Describe("ByRoutingKey", func() {
 					ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", "cell-id"),
 					ActualLRPNetInfo:     models.NewActualLRPNetInfo("1.1.1.1", models.NewPortMapping(11, 44), models.NewPortMapping(66, 99)),
 				},
-				Evacuating: true,
+				Evacuating:       true,
+				AvailabilityZone: "z1",
 			})
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(endpoints).To(ConsistOf([]routing_table.Endpoint{
-				routing_table.Endpoint{Host: "1.1.1.1", Domain: "domain", Port: 11, InstanceGuid: "instance-guid", ContainerPort: 44, Evacuating: true},
-				routing_table.Endpoint{Host: "1.1.1.1", Domain: "domain", Port: 66, InstanceGuid: "instance-guid", ContainerPort: 99, Evacuating: true},
+				routing_table.Endpoint{Host: "1.1.1.1", Domain: "domain", Port: 11, InstanceGuid: "instance-guid", CellId: "cell-id", AvailabilityZone: "z1", ContainerPort: 44, Evacuating: true},
+				routing_table.Endpoint{Host: "1.1.1.1", Domain: "domain", Port: 66, InstanceGuid: "instance-guid", CellId: "cell-id", AvailabilityZone: "z1", ContainerPort: 99, Evacuating: true},
 			}))
 		})
 	})
 
+	Describe("RouteTagsFor", func() {
+		var schedulingInfo *models.DesiredLRPSchedulingInfo
+
+		BeforeEach(func() {
+			schedulingInfo = &models.DesiredLRPSchedulingInfo{DesiredLRPKey: models.NewDesiredLRPKey("process-guid", "tests", "log-guid")}
+		})
+
+		It("tags the process guid", func() {
+			Expect(routing_table.RouteTagsFor(schedulingInfo)).To(Equal(map[string]string{"process_guid": "process-guid"}))
+		})
+
+		Context("when the annotation carries app metadata", func() {
+			BeforeEach(func() {
+				schedulingInfo.Annotation = `{
+					"app_name": "my-app",
+					"space_name": "my-space",
+					"organization_name": "my-org",
+					"instances": 3,
+					"secret": "not-a-tag"
+				}`
+			})
+
+			It("includes the known string values", func() {
+				Expect(routing_table.RouteTagsFor(schedulingInfo)).To(Equal(map[string]string{
+					"process_guid":      "process-guid",
+					"app_name":          "my-app",
+					"space_name":        "my-space",
+					"organization_name": "my-org",
+				}))
+			})
+		})
+
+		Context("when the annotation is not a JSON object", func() {
+			BeforeEach(func() {
+				schedulingInfo.Annotation = "1454535325.2348"
+			})
+
+			It("ignores it", func() {
+				Expect(routing_table.RouteTagsFor(schedulingInfo)).To(Equal(map[string]string{"process_guid": "process-guid"}))
+			})
+		})
+	})
+
 	Describe("RoutingKeysFromActual", func() {
 		It("creates a list of keys for an actual LRP", func() {
 			keys := routing_table.RoutingKeysFromActual(&models.ActualLRP{
@@ -138,7 +297,7 @@ var _ = Describe("ByRoutingKey", func() {
 		It("creates a list of keys for an actual LRP", func() {
 			routes := cfroutes.CFRoutes{
 				{Hostnames: []string{"foo.com", "bar.com"}, Port: 8080},
-				{Hostnames: []string{"foo.example.com"}, Port: 9090},
+				{Hostnames: []string{"foo.example.com"}, Port: 9090, Weight: 3},
 			}
 
 			schedulingInfo := &models.DesiredLRPSchedulingInfo{
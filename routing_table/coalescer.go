@@ -0,0 +1,172 @@
+package routing_table
+
+type routeKey struct {
+	Host string
+	Port uint32
+	URI  string
+}
+
+type messageKey struct {
+	Host              string
+	Port              uint32
+	App               string
+	RouteServiceUrl   string
+	PrivateInstanceId string
+	Weight            uint32
+}
+
+type coalescedRoute struct {
+	message  RegistryMessage
+	register bool
+	// registered is true when a registration for the route has been added
+	// without an earlier unregistration; an unregistration then cancels it,
+	// unless the route was registered before the window.
+	registered bool
+	cancelled  bool
+}
+
+// MessageCoalescer merges the messages produced by successive table updates
+// into the smallest equivalent set, keyed by host, port and URI. The most
+// recent message for a route wins, except that a registration followed by an
+// unregistration of the same route cancels out entirely if the table did not
+// have the route when the window began. Otherwise the router may hold the
+// route, and the unregistration is kept.
+type MessageCoalescer struct {
+	routes   map[routeKey]*coalescedRoute
+	order    []routeKey
+	received int
+
+	before           map[RoutingKey]RoutableEndpoints
+	registeredBefore map[routeKey]struct{}
+}
+
+func NewMessageCoalescer() *MessageCoalescer {
+	return &MessageCoalescer{
+		routes: map[routeKey]*coalescedRoute{},
+	}
+}
+
+// Begin records the table entries before the window's first update, calling
+// entries only if no window has begun since the last Flush. Without them, no
+// registration is cancelled.
+func (c *MessageCoalescer) Begin(entries func() map[RoutingKey]RoutableEndpoints) {
+	if c.before != nil {
+		return
+	}
+	c.before = entries()
+}
+
+func (c *MessageCoalescer) Add(messagesToEmit MessagesToEmit) {
+	c.received += len(messagesToEmit.RegistrationMessages) + len(messagesToEmit.UnregistrationMessages)
+
+	for _, message := range messagesToEmit.RegistrationMessages {
+		c.add(message, true)
+	}
+	for _, message := range messagesToEmit.UnregistrationMessages {
+		c.add(message, false)
+	}
+}
+
+func (c *MessageCoalescer) add(message RegistryMessage, register bool) {
+	for _, uri := range message.URIs {
+		key := routeKey{Host: message.Host, Port: message.Port, URI: uri}
+
+		existing, ok := c.routes[key]
+		if !ok {
+			c.routes[key] = &coalescedRoute{message: message, register: register, registered: register}
+			c.order = append(c.order, key)
+			continue
+		}
+
+		if existing.cancelled {
+			*existing = coalescedRoute{message: message, register: register, registered: register}
+			continue
+		}
+
+		if !register && existing.registered && !c.wasRegistered(key) {
+			existing.cancelled = true
+			continue
+		}
+
+		existing.message = message
+		existing.register = register
+	}
+}
+
+// wasRegistered reports whether the route may have been registered before the
+// window, indexing the recorded entries the first time it is needed.
+func (c *MessageCoalescer) wasRegistered(key routeKey) bool {
+	if c.before == nil {
+		return true
+	}
+
+	if c.registeredBefore == nil {
+		c.registeredBefore = map[routeKey]struct{}{}
+		for _, entry := range c.before {
+			for _, endpoint := range entry.Endpoints {
+				for hostname := range entry.Hostnames {
+					c.registeredBefore[routeKey{Host: endpoint.Host, Port: endpoint.Port, URI: hostname}] = struct{}{}
+				}
+			}
+		}
+	}
+
+	_, ok := c.registeredBefore[key]
+	return ok
+}
+
+// Len returns the number of messages added since the last Flush.
+func (c *MessageCoalescer) Len() int {
+	return c.received
+}
+
+// Flush returns the coalesced messages, regrouping URIs that share an
+// endpoint into a single message, and resets the coalescer. It also returns
+// the number of messages that were added.
+func (c *MessageCoalescer) Flush() (MessagesToEmit, int) {
+	messagesToEmit := MessagesToEmit{}
+	registrations := map[messageKey]int{}
+	unregistrations := map[messageKey]int{}
+
+	for _, key := range c.order {
+		route := c.routes[key]
+		if route.cancelled {
+			continue
+		}
+
+		mKey := messageKey{
+			Host:              route.message.Host,
+			Port:              route.message.Port,
+			App:               route.message.App,
+			RouteServiceUrl:   route.message.RouteServiceUrl,
+			PrivateInstanceId: route.message.PrivateInstanceId,
+			Weight:            route.message.Weight,
+		}
+
+		messages := &messagesToEmit.UnregistrationMessages
+		indexes := unregistrations
+		if route.register {
+			messages = &messagesToEmit.RegistrationMessages
+			indexes = registrations
+		}
+
+		if i, ok := indexes[mKey]; ok {
+			(*messages)[i].URIs = append((*messages)[i].URIs, key.URI)
+			continue
+		}
+
+		message := route.message
+		message.URIs = []string{key.URI}
+		indexes[mKey] = len(*messages)
+		*messages = append(*messages, message)
+	}
+
+	received := c.received
+	c.routes = map[routeKey]*coalescedRoute{}
+	c.order = nil
+	c.received = 0
+	c.before = nil
+	c.registeredBefore = nil
+
+	return messagesToEmit, received
+}
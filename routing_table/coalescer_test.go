@@ -0,0 +1,149 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/cloudfoundry-incubator/route-emitter/routing_table/matchers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MessageCoalescer", func() {
+	var coalescer *routing_table.MessageCoalescer
+
+	endpoint1 := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11}
+	endpoint2 := routing_table.Endpoint{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22}
+
+	register := func(endpoint routing_table.Endpoint, hostnames ...string) routing_table.MessagesToEmit {
+		return routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{
+				routing_table.RegistryMessageFor(endpoint, routing_table.Routes{Hostnames: hostnames, LogGuid: "log-guid"}),
+			},
+		}
+	}
+
+	unregister := func(endpoint routing_table.Endpoint, hostnames ...string) routing_table.MessagesToEmit {
+		return routing_table.MessagesToEmit{
+			UnregistrationMessages: []routing_table.RegistryMessage{
+				routing_table.RegistryMessageFor(endpoint, routing_table.Routes{Hostnames: hostnames, LogGuid: "log-guid"}),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		coalescer = routing_table.NewMessageCoalescer()
+	})
+
+	It("merges registrations for the same endpoint", func() {
+		coalescer.Add(register(endpoint1, "foo.com"))
+		coalescer.Add(register(endpoint1, "bar.com"))
+		coalescer.Add(register(endpoint1, "foo.com"))
+		coalescer.Add(register(endpoint2, "foo.com"))
+
+		messagesToEmit, received := coalescer.Flush()
+		Expect(received).To(Equal(4))
+		Expect(messagesToEmit).To(MatchMessagesToEmit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{
+				routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{"foo.com", "bar.com"}, LogGuid: "log-guid"}),
+				routing_table.RegistryMessageFor(endpoint2, routing_table.Routes{Hostnames: []string{"foo.com"}, LogGuid: "log-guid"}),
+			},
+		}))
+	})
+
+	Context("when the table did not have the route before the window", func() {
+		BeforeEach(func() {
+			coalescer.Begin(func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+				return map[routing_table.RoutingKey]routing_table.RoutableEndpoints{}
+			})
+		})
+
+		It("cancels a registration followed by an unregistration of the same route", func() {
+			coalescer.Add(register(endpoint1, "foo.com", "bar.com"))
+			coalescer.Add(unregister(endpoint1, "foo.com"))
+
+			messagesToEmit, _ := coalescer.Flush()
+			Expect(messagesToEmit).To(MatchMessagesToEmit(register(endpoint1, "bar.com")))
+		})
+
+		It("registers a route again after a cancelled registration", func() {
+			coalescer.Add(register(endpoint1, "foo.com"))
+			coalescer.Add(unregister(endpoint1, "foo.com"))
+			coalescer.Add(register(endpoint1, "foo.com"))
+
+			messagesToEmit, _ := coalescer.Flush()
+			Expect(messagesToEmit).To(MatchMessagesToEmit(register(endpoint1, "foo.com")))
+		})
+	})
+
+	Context("when the table had the route before the window", func() {
+		BeforeEach(func() {
+			coalescer.Begin(func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+				return map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+					{ProcessGuid: "pg-1", ContainerPort: 8080}: {
+						Hostnames: map[string]struct{}{"foo.com": struct{}{}},
+						Endpoints: map[routing_table.EndpointKey]routing_table.Endpoint{{InstanceGuid: "ig-1"}: endpoint1},
+					},
+				}
+			})
+		})
+
+		It("keeps the unregistration that follows a registration", func() {
+			coalescer.Add(register(endpoint1, "foo.com", "bar.com"))
+			coalescer.Add(unregister(endpoint1, "foo.com", "bar.com"))
+
+			messagesToEmit, _ := coalescer.Flush()
+			Expect(messagesToEmit).To(MatchMessagesToEmit(unregister(endpoint1, "foo.com")))
+		})
+
+		It("only records the entries once per window", func() {
+			calls := 0
+			coalescer.Begin(func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+				calls++
+				return nil
+			})
+			Expect(calls).To(BeZero())
+
+			coalescer.Flush()
+			coalescer.Begin(func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+				calls++
+				return map[routing_table.RoutingKey]routing_table.RoutableEndpoints{}
+			})
+			Expect(calls).To(Equal(1))
+		})
+	})
+
+	It("keeps the unregistration that follows a registration when the window has not begun", func() {
+		coalescer.Add(register(endpoint1, "foo.com"))
+		coalescer.Add(unregister(endpoint1, "foo.com"))
+
+		messagesToEmit, _ := coalescer.Flush()
+		Expect(messagesToEmit).To(MatchMessagesToEmit(unregister(endpoint1, "foo.com")))
+	})
+
+	It("keeps only the registration when an unregistration is followed by a registration", func() {
+		coalescer.Add(unregister(endpoint1, "foo.com"))
+		coalescer.Add(register(endpoint1, "foo.com"))
+
+		messagesToEmit, _ := coalescer.Flush()
+		Expect(messagesToEmit).To(MatchMessagesToEmit(register(endpoint1, "foo.com")))
+	})
+
+	It("keeps the unregistration when a route is unregistered, registered and unregistered again", func() {
+		coalescer.Add(unregister(endpoint1, "foo.com"))
+		coalescer.Add(register(endpoint1, "foo.com"))
+		coalescer.Add(unregister(endpoint1, "foo.com"))
+
+		messagesToEmit, _ := coalescer.Flush()
+		Expect(messagesToEmit).To(MatchMessagesToEmit(unregister(endpoint1, "foo.com")))
+	})
+
+	It("resets after a flush", func() {
+		coalescer.Add(register(endpoint1, "foo.com"))
+		coalescer.Flush()
+
+		Expect(coalescer.Len()).To(BeZero())
+		messagesToEmit, received := coalescer.Flush()
+		Expect(received).To(BeZero())
+		Expect(messagesToEmit).To(BeZero())
+	})
+})
@@ -15,6 +15,20 @@ type FakeRoutingTable struct {
 	routeCountReturns     struct {
 		result1 int
 	}
+	EntriesStub        func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+	entriesMutex       sync.RWMutex
+	entriesArgsForCall []struct{}
+	entriesReturns     struct {
+		result1 map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+	}
+	RoutingKeysForHostnameStub        func(hostname string) []routing_table.RoutingKey
+	routingKeysForHostnameMutex       sync.RWMutex
+	routingKeysForHostnameArgsForCall []struct {
+		hostname string
+	}
+	routingKeysForHostnameReturns struct {
+		result1 []routing_table.RoutingKey
+	}
 	SwapStub        func(newTable routing_table.RoutingTable, domains models.DomainSet) routing_table.MessagesToEmit
 	swapMutex       sync.RWMutex
 	swapArgsForCall []struct {
@@ -66,6 +80,16 @@ type FakeRoutingTable struct {
 	messagesToEmitReturns     struct {
 		result1 routing_table.MessagesToEmit
 	}
+	SetHostnameConflictPolicyStub        func(policy routing_table.HostnameConflictPolicy)
+	setHostnameConflictPolicyMutex       sync.RWMutex
+	setHostnameConflictPolicyArgsForCall []struct {
+		policy routing_table.HostnameConflictPolicy
+	}
+	ForgetProcessGuidsStub        func(forget func(processGuid string) bool)
+	forgetProcessGuidsMutex       sync.RWMutex
+	forgetProcessGuidsArgsForCall []struct {
+		forget func(processGuid string) bool
+	}
 }
 
 func (fake *FakeRoutingTable) RouteCount() int {
@@ -92,6 +116,62 @@ func (fake *FakeRoutingTable) RouteCountReturns(result1 int) {
 	}{result1}
 }
 
+func (fake *FakeRoutingTable) Entries() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+	fake.entriesMutex.Lock()
+	fake.entriesArgsForCall = append(fake.entriesArgsForCall, struct{}{})
+	fake.entriesMutex.Unlock()
+	if fake.EntriesStub != nil {
+		return fake.EntriesStub()
+	} else {
+		return fake.entriesReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) EntriesCallCount() int {
+	fake.entriesMutex.RLock()
+	defer fake.entriesMutex.RUnlock()
+	return len(fake.entriesArgsForCall)
+}
+
+func (fake *FakeRoutingTable) EntriesReturns(result1 map[routing_table.RoutingKey]routing_table.RoutableEndpoints) {
+	fake.EntriesStub = nil
+	fake.entriesReturns = struct {
+		result1 map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+	}{result1}
+}
+
+func (fake *FakeRoutingTable) RoutingKeysForHostname(hostname string) []routing_table.RoutingKey {
+	fake.routingKeysForHostnameMutex.Lock()
+	fake.routingKeysForHostnameArgsForCall = append(fake.routingKeysForHostnameArgsForCall, struct {
+		hostname string
+	}{hostname})
+	fake.routingKeysForHostnameMutex.Unlock()
+	if fake.RoutingKeysForHostnameStub != nil {
+		return fake.RoutingKeysForHostnameStub(hostname)
+	} else {
+		return fake.routingKeysForHostnameReturns.result1
+	}
+}
+
+func (fake *FakeRoutingTable) RoutingKeysForHostnameCallCount() int {
+	fake.routingKeysForHostnameMutex.RLock()
+	defer fake.routingKeysForHostnameMutex.RUnlock()
+	return len(fake.routingKeysForHostnameArgsForCall)
+}
+
+func (fake *FakeRoutingTable) RoutingKeysForHostnameArgsForCall(i int) string {
+	fake.routingKeysForHostnameMutex.RLock()
+	defer fake.routingKeysForHostnameMutex.RUnlock()
+	return fake.routingKeysForHostnameArgsForCall[i].hostname
+}
+
+func (fake *FakeRoutingTable) RoutingKeysForHostnameReturns(result1 []routing_table.RoutingKey) {
+	fake.RoutingKeysForHostnameStub = nil
+	fake.routingKeysForHostnameReturns = struct {
+		result1 []routing_table.RoutingKey
+	}{result1}
+}
+
 func (fake *FakeRoutingTable) Swap(newTable routing_table.RoutingTable, domains models.DomainSet) routing_table.MessagesToEmit {
 	fake.swapMutex.Lock()
 	fake.swapArgsForCall = append(fake.swapArgsForCall, struct {
@@ -281,4 +361,50 @@ func (fake *FakeRoutingTable) MessagesToEmitReturns(result1 routing_table.Messag
 	}{result1}
 }
 
+func (fake *FakeRoutingTable) SetHostnameConflictPolicy(policy routing_table.HostnameConflictPolicy) {
+	fake.setHostnameConflictPolicyMutex.Lock()
+	fake.setHostnameConflictPolicyArgsForCall = append(fake.setHostnameConflictPolicyArgsForCall, struct {
+		policy routing_table.HostnameConflictPolicy
+	}{policy})
+	fake.setHostnameConflictPolicyMutex.Unlock()
+	if fake.SetHostnameConflictPolicyStub != nil {
+		fake.SetHostnameConflictPolicyStub(policy)
+	}
+}
+
+func (fake *FakeRoutingTable) SetHostnameConflictPolicyCallCount() int {
+	fake.setHostnameConflictPolicyMutex.RLock()
+	defer fake.setHostnameConflictPolicyMutex.RUnlock()
+	return len(fake.setHostnameConflictPolicyArgsForCall)
+}
+
+func (fake *FakeRoutingTable) SetHostnameConflictPolicyArgsForCall(i int) routing_table.HostnameConflictPolicy {
+	fake.setHostnameConflictPolicyMutex.RLock()
+	defer fake.setHostnameConflictPolicyMutex.RUnlock()
+	return fake.setHostnameConflictPolicyArgsForCall[i].policy
+}
+
+func (fake *FakeRoutingTable) ForgetProcessGuids(forget func(processGuid string) bool) {
+	fake.forgetProcessGuidsMutex.Lock()
+	fake.forgetProcessGuidsArgsForCall = append(fake.forgetProcessGuidsArgsForCall, struct {
+		forget func(processGuid string) bool
+	}{forget})
+	fake.forgetProcessGuidsMutex.Unlock()
+	if fake.ForgetProcessGuidsStub != nil {
+		fake.ForgetProcessGuidsStub(forget)
+	}
+}
+
+func (fake *FakeRoutingTable) ForgetProcessGuidsCallCount() int {
+	fake.forgetProcessGuidsMutex.RLock()
+	defer fake.forgetProcessGuidsMutex.RUnlock()
+	return len(fake.forgetProcessGuidsArgsForCall)
+}
+
+func (fake *FakeRoutingTable) ForgetProcessGuidsArgsForCall(i int) func(processGuid string) bool {
+	fake.forgetProcessGuidsMutex.RLock()
+	defer fake.forgetProcessGuidsMutex.RUnlock()
+	return fake.forgetProcessGuidsArgsForCall[i].forget
+}
+
 var _ routing_table.RoutingTable = new(FakeRoutingTable)
@@ -0,0 +1,312 @@
+// This file was generated by counterfeiter
+package fake_routing_table
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+type FakeTCPRoutingTable struct {
+	RouteCountStub        func() int
+	routeCountMutex       sync.RWMutex
+	routeCountArgsForCall []struct{}
+	routeCountReturns     struct {
+		result1 int
+	}
+	SwapStub        func(newTable routing_table.TCPRoutingTable, domains models.DomainSet) routing_table.TCPMessagesToEmit
+	swapMutex       sync.RWMutex
+	swapArgsForCall []struct {
+		newTable routing_table.TCPRoutingTable
+		domains  models.DomainSet
+	}
+	swapReturns struct {
+		result1 routing_table.TCPMessagesToEmit
+	}
+	SetRoutesStub        func(key routing_table.RoutingKey, routes routing_table.TCPRoutes) routing_table.TCPMessagesToEmit
+	setRoutesMutex       sync.RWMutex
+	setRoutesArgsForCall []struct {
+		key    routing_table.RoutingKey
+		routes routing_table.TCPRoutes
+	}
+	setRoutesReturns struct {
+		result1 routing_table.TCPMessagesToEmit
+	}
+	RemoveRoutesStub        func(key routing_table.RoutingKey, modTag *models.ModificationTag) routing_table.TCPMessagesToEmit
+	removeRoutesMutex       sync.RWMutex
+	removeRoutesArgsForCall []struct {
+		key    routing_table.RoutingKey
+		modTag *models.ModificationTag
+	}
+	removeRoutesReturns struct {
+		result1 routing_table.TCPMessagesToEmit
+	}
+	AddEndpointStub        func(key routing_table.RoutingKey, endpoint routing_table.Endpoint) routing_table.TCPMessagesToEmit
+	addEndpointMutex       sync.RWMutex
+	addEndpointArgsForCall []struct {
+		key      routing_table.RoutingKey
+		endpoint routing_table.Endpoint
+	}
+	addEndpointReturns struct {
+		result1 routing_table.TCPMessagesToEmit
+	}
+	RemoveEndpointStub        func(key routing_table.RoutingKey, endpoint routing_table.Endpoint) routing_table.TCPMessagesToEmit
+	removeEndpointMutex       sync.RWMutex
+	removeEndpointArgsForCall []struct {
+		key      routing_table.RoutingKey
+		endpoint routing_table.Endpoint
+	}
+	removeEndpointReturns struct {
+		result1 routing_table.TCPMessagesToEmit
+	}
+	MessagesToEmitStub        func() routing_table.TCPMessagesToEmit
+	messagesToEmitMutex       sync.RWMutex
+	messagesToEmitArgsForCall []struct{}
+	messagesToEmitReturns     struct {
+		result1 routing_table.TCPMessagesToEmit
+	}
+	ForgetProcessGuidsStub        func(forget func(processGuid string) bool)
+	forgetProcessGuidsMutex       sync.RWMutex
+	forgetProcessGuidsArgsForCall []struct {
+		forget func(processGuid string) bool
+	}
+}
+
+func (fake *FakeTCPRoutingTable) RouteCount() int {
+	fake.routeCountMutex.Lock()
+	fake.routeCountArgsForCall = append(fake.routeCountArgsForCall, struct{}{})
+	fake.routeCountMutex.Unlock()
+	if fake.RouteCountStub != nil {
+		return fake.RouteCountStub()
+	} else {
+		return fake.routeCountReturns.result1
+	}
+}
+
+func (fake *FakeTCPRoutingTable) RouteCountCallCount() int {
+	fake.routeCountMutex.RLock()
+	defer fake.routeCountMutex.RUnlock()
+	return len(fake.routeCountArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) RouteCountReturns(result1 int) {
+	fake.RouteCountStub = nil
+	fake.routeCountReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakeTCPRoutingTable) Swap(newTable routing_table.TCPRoutingTable, domains models.DomainSet) routing_table.TCPMessagesToEmit {
+	fake.swapMutex.Lock()
+	fake.swapArgsForCall = append(fake.swapArgsForCall, struct {
+		newTable routing_table.TCPRoutingTable
+		domains  models.DomainSet
+	}{newTable, domains})
+	fake.swapMutex.Unlock()
+	if fake.SwapStub != nil {
+		return fake.SwapStub(newTable, domains)
+	} else {
+		return fake.swapReturns.result1
+	}
+}
+
+func (fake *FakeTCPRoutingTable) SwapCallCount() int {
+	fake.swapMutex.RLock()
+	defer fake.swapMutex.RUnlock()
+	return len(fake.swapArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) SwapArgsForCall(i int) (routing_table.TCPRoutingTable, models.DomainSet) {
+	fake.swapMutex.RLock()
+	defer fake.swapMutex.RUnlock()
+	return fake.swapArgsForCall[i].newTable, fake.swapArgsForCall[i].domains
+}
+
+func (fake *FakeTCPRoutingTable) SwapReturns(result1 routing_table.TCPMessagesToEmit) {
+	fake.SwapStub = nil
+	fake.swapReturns = struct {
+		result1 routing_table.TCPMessagesToEmit
+	}{result1}
+}
+
+func (fake *FakeTCPRoutingTable) SetRoutes(key routing_table.RoutingKey, routes routing_table.TCPRoutes) routing_table.TCPMessagesToEmit {
+	fake.setRoutesMutex.Lock()
+	fake.setRoutesArgsForCall = append(fake.setRoutesArgsForCall, struct {
+		key    routing_table.RoutingKey
+		routes routing_table.TCPRoutes
+	}{key, routes})
+	fake.setRoutesMutex.Unlock()
+	if fake.SetRoutesStub != nil {
+		return fake.SetRoutesStub(key, routes)
+	} else {
+		return fake.setRoutesReturns.result1
+	}
+}
+
+func (fake *FakeTCPRoutingTable) SetRoutesCallCount() int {
+	fake.setRoutesMutex.RLock()
+	defer fake.setRoutesMutex.RUnlock()
+	return len(fake.setRoutesArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) SetRoutesArgsForCall(i int) (routing_table.RoutingKey, routing_table.TCPRoutes) {
+	fake.setRoutesMutex.RLock()
+	defer fake.setRoutesMutex.RUnlock()
+	return fake.setRoutesArgsForCall[i].key, fake.setRoutesArgsForCall[i].routes
+}
+
+func (fake *FakeTCPRoutingTable) SetRoutesReturns(result1 routing_table.TCPMessagesToEmit) {
+	fake.SetRoutesStub = nil
+	fake.setRoutesReturns = struct {
+		result1 routing_table.TCPMessagesToEmit
+	}{result1}
+}
+
+func (fake *FakeTCPRoutingTable) RemoveRoutes(key routing_table.RoutingKey, modTag *models.ModificationTag) routing_table.TCPMessagesToEmit {
+	fake.removeRoutesMutex.Lock()
+	fake.removeRoutesArgsForCall = append(fake.removeRoutesArgsForCall, struct {
+		key    routing_table.RoutingKey
+		modTag *models.ModificationTag
+	}{key, modTag})
+	fake.removeRoutesMutex.Unlock()
+	if fake.RemoveRoutesStub != nil {
+		return fake.RemoveRoutesStub(key, modTag)
+	} else {
+		return fake.removeRoutesReturns.result1
+	}
+}
+
+func (fake *FakeTCPRoutingTable) RemoveRoutesCallCount() int {
+	fake.removeRoutesMutex.RLock()
+	defer fake.removeRoutesMutex.RUnlock()
+	return len(fake.removeRoutesArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) RemoveRoutesArgsForCall(i int) (routing_table.RoutingKey, *models.ModificationTag) {
+	fake.removeRoutesMutex.RLock()
+	defer fake.removeRoutesMutex.RUnlock()
+	return fake.removeRoutesArgsForCall[i].key, fake.removeRoutesArgsForCall[i].modTag
+}
+
+func (fake *FakeTCPRoutingTable) RemoveRoutesReturns(result1 routing_table.TCPMessagesToEmit) {
+	fake.RemoveRoutesStub = nil
+	fake.removeRoutesReturns = struct {
+		result1 routing_table.TCPMessagesToEmit
+	}{result1}
+}
+
+func (fake *FakeTCPRoutingTable) AddEndpoint(key routing_table.RoutingKey, endpoint routing_table.Endpoint) routing_table.TCPMessagesToEmit {
+	fake.addEndpointMutex.Lock()
+	fake.addEndpointArgsForCall = append(fake.addEndpointArgsForCall, struct {
+		key      routing_table.RoutingKey
+		endpoint routing_table.Endpoint
+	}{key, endpoint})
+	fake.addEndpointMutex.Unlock()
+	if fake.AddEndpointStub != nil {
+		return fake.AddEndpointStub(key, endpoint)
+	} else {
+		return fake.addEndpointReturns.result1
+	}
+}
+
+func (fake *FakeTCPRoutingTable) AddEndpointCallCount() int {
+	fake.addEndpointMutex.RLock()
+	defer fake.addEndpointMutex.RUnlock()
+	return len(fake.addEndpointArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) AddEndpointArgsForCall(i int) (routing_table.RoutingKey, routing_table.Endpoint) {
+	fake.addEndpointMutex.RLock()
+	defer fake.addEndpointMutex.RUnlock()
+	return fake.addEndpointArgsForCall[i].key, fake.addEndpointArgsForCall[i].endpoint
+}
+
+func (fake *FakeTCPRoutingTable) AddEndpointReturns(result1 routing_table.TCPMessagesToEmit) {
+	fake.AddEndpointStub = nil
+	fake.addEndpointReturns = struct {
+		result1 routing_table.TCPMessagesToEmit
+	}{result1}
+}
+
+func (fake *FakeTCPRoutingTable) RemoveEndpoint(key routing_table.RoutingKey, endpoint routing_table.Endpoint) routing_table.TCPMessagesToEmit {
+	fake.removeEndpointMutex.Lock()
+	fake.removeEndpointArgsForCall = append(fake.removeEndpointArgsForCall, struct {
+		key      routing_table.RoutingKey
+		endpoint routing_table.Endpoint
+	}{key, endpoint})
+	fake.removeEndpointMutex.Unlock()
+	if fake.RemoveEndpointStub != nil {
+		return fake.RemoveEndpointStub(key, endpoint)
+	} else {
+		return fake.removeEndpointReturns.result1
+	}
+}
+
+func (fake *FakeTCPRoutingTable) RemoveEndpointCallCount() int {
+	fake.removeEndpointMutex.RLock()
+	defer fake.removeEndpointMutex.RUnlock()
+	return len(fake.removeEndpointArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) RemoveEndpointArgsForCall(i int) (routing_table.RoutingKey, routing_table.Endpoint) {
+	fake.removeEndpointMutex.RLock()
+	defer fake.removeEndpointMutex.RUnlock()
+	return fake.removeEndpointArgsForCall[i].key, fake.removeEndpointArgsForCall[i].endpoint
+}
+
+func (fake *FakeTCPRoutingTable) RemoveEndpointReturns(result1 routing_table.TCPMessagesToEmit) {
+	fake.RemoveEndpointStub = nil
+	fake.removeEndpointReturns = struct {
+		result1 routing_table.TCPMessagesToEmit
+	}{result1}
+}
+
+func (fake *FakeTCPRoutingTable) MessagesToEmit() routing_table.TCPMessagesToEmit {
+	fake.messagesToEmitMutex.Lock()
+	fake.messagesToEmitArgsForCall = append(fake.messagesToEmitArgsForCall, struct{}{})
+	fake.messagesToEmitMutex.Unlock()
+	if fake.MessagesToEmitStub != nil {
+		return fake.MessagesToEmitStub()
+	} else {
+		return fake.messagesToEmitReturns.result1
+	}
+}
+
+func (fake *FakeTCPRoutingTable) MessagesToEmitCallCount() int {
+	fake.messagesToEmitMutex.RLock()
+	defer fake.messagesToEmitMutex.RUnlock()
+	return len(fake.messagesToEmitArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) MessagesToEmitReturns(result1 routing_table.TCPMessagesToEmit) {
+	fake.MessagesToEmitStub = nil
+	fake.messagesToEmitReturns = struct {
+		result1 routing_table.TCPMessagesToEmit
+	}{result1}
+}
+
+func (fake *FakeTCPRoutingTable) ForgetProcessGuids(forget func(processGuid string) bool) {
+	fake.forgetProcessGuidsMutex.Lock()
+	fake.forgetProcessGuidsArgsForCall = append(fake.forgetProcessGuidsArgsForCall, struct {
+		forget func(processGuid string) bool
+	}{forget})
+	fake.forgetProcessGuidsMutex.Unlock()
+	if fake.ForgetProcessGuidsStub != nil {
+		fake.ForgetProcessGuidsStub(forget)
+	}
+}
+
+func (fake *FakeTCPRoutingTable) ForgetProcessGuidsCallCount() int {
+	fake.forgetProcessGuidsMutex.RLock()
+	defer fake.forgetProcessGuidsMutex.RUnlock()
+	return len(fake.forgetProcessGuidsArgsForCall)
+}
+
+func (fake *FakeTCPRoutingTable) ForgetProcessGuidsArgsForCall(i int) func(processGuid string) bool {
+	fake.forgetProcessGuidsMutex.RLock()
+	defer fake.forgetProcessGuidsMutex.RUnlock()
+	return fake.forgetProcessGuidsArgsForCall[i].forget
+}
+
+var _ routing_table.TCPRoutingTable = new(FakeTCPRoutingTable)
@@ -0,0 +1,173 @@
+package routing_table
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+)
+
+var (
+	ErrHostnameDenied     = errors.New("hostname is denied")
+	ErrHostnameNotAllowed = errors.New("hostname is not allowed")
+	ErrHostnameReserved   = errors.New("hostname is reserved for other processes")
+)
+
+// HostnamePolicy decides which hostnames a process may register. Check
+// returns nil if the process guid may register the route URI, and the reason
+// otherwise.
+type HostnamePolicy interface {
+	Check(processGuid, uri string) error
+}
+
+// HostnameRules configure a HostnamePolicy. Each hostname pattern is either an
+// exact hostname, a wildcard such as *.example.com matching any subdomain, or
+// a regular expression prefixed with ~. Matching ignores case and any context
+// path.
+//
+// Denied hostnames are always rejected. Reserved hostnames may only be
+// registered by the listed process guids, which need not also be allowed.
+// When Allowed is not empty, every other hostname must match it.
+type HostnameRules struct {
+	Allowed  []string            `json:"allowed,omitempty"`
+	Denied   []string            `json:"denied,omitempty"`
+	Reserved []ReservedHostnames `json:"reserved,omitempty"`
+}
+
+type ReservedHostnames struct {
+	Hostnames    []string `json:"hostnames"`
+	ProcessGuids []string `json:"process_guids"`
+}
+
+type hostnamePolicy struct {
+	allowed  []hostnamePattern
+	denied   []hostnamePattern
+	reserved []reservedPatterns
+}
+
+type reservedPatterns struct {
+	patterns []hostnamePattern
+	owners   map[string]struct{}
+}
+
+// NewHostnamePolicy compiles the rules, returning an error naming the first
+// invalid pattern.
+func NewHostnamePolicy(rules HostnameRules) (HostnamePolicy, error) {
+	policy := &hostnamePolicy{}
+
+	var err error
+	policy.allowed, err = parseHostnamePatterns(rules.Allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.denied, err = parseHostnamePatterns(rules.Denied)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, reserved := range rules.Reserved {
+		patterns, err := parseHostnamePatterns(reserved.Hostnames)
+		if err != nil {
+			return nil, err
+		}
+
+		owners := map[string]struct{}{}
+		for _, processGuid := range reserved.ProcessGuids {
+			owners[processGuid] = struct{}{}
+		}
+
+		policy.reserved = append(policy.reserved, reservedPatterns{patterns: patterns, owners: owners})
+	}
+
+	return policy, nil
+}
+
+func (p *hostnamePolicy) Check(processGuid, uri string) error {
+	hostname, _ := cfroutes.SplitURI(uri)
+
+	if matchesAny(p.denied, hostname) {
+		return ErrHostnameDenied
+	}
+
+	for _, reserved := range p.reserved {
+		if matchesAny(reserved.patterns, hostname) {
+			if _, ok := reserved.owners[processGuid]; ok {
+				return nil
+			}
+			return ErrHostnameReserved
+		}
+	}
+
+	if len(p.allowed) > 0 && !matchesAny(p.allowed, hostname) {
+		return ErrHostnameNotAllowed
+	}
+
+	return nil
+}
+
+type hostnamePattern struct {
+	exact  string
+	suffix string
+	regexp *regexp.Regexp
+}
+
+func parseHostnamePatterns(patterns []string) ([]hostnamePattern, error) {
+	parsed := make([]hostnamePattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := parseHostnamePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+func parseHostnamePattern(pattern string) (hostnamePattern, error) {
+	switch {
+	case strings.HasPrefix(pattern, "~"):
+		re, err := regexp.Compile("(?i)" + pattern[1:])
+		if err != nil {
+			return hostnamePattern{}, fmt.Errorf("invalid hostname pattern %q: %s", pattern, err)
+		}
+		return hostnamePattern{regexp: re}, nil
+
+	case strings.HasPrefix(pattern, "*."):
+		suffix := strings.ToLower(pattern[1:])
+		if strings.Contains(suffix, "*") || len(suffix) < 2 {
+			return hostnamePattern{}, fmt.Errorf("invalid hostname pattern %q: wildcards must be a single leading label", pattern)
+		}
+		return hostnamePattern{suffix: suffix}, nil
+
+	case pattern == "":
+		return hostnamePattern{}, errors.New("invalid hostname pattern: must not be empty")
+
+	case strings.Contains(pattern, "*"):
+		return hostnamePattern{}, fmt.Errorf("invalid hostname pattern %q: wildcards must be a single leading label", pattern)
+	}
+
+	exact, _ := cfroutes.SplitURI(pattern)
+	return hostnamePattern{exact: exact}, nil
+}
+
+func (p hostnamePattern) matches(hostname string) bool {
+	switch {
+	case p.regexp != nil:
+		return p.regexp.MatchString(hostname)
+	case p.suffix != "":
+		return len(hostname) > len(p.suffix) && strings.HasSuffix(hostname, p.suffix)
+	}
+	return hostname == p.exact
+}
+
+func matchesAny(patterns []hostnamePattern, hostname string) bool {
+	for _, pattern := range patterns {
+		if pattern.matches(hostname) {
+			return true
+		}
+	}
+	return false
+}
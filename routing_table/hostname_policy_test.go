@@ -0,0 +1,114 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HostnamePolicy", func() {
+	var (
+		rules  routing_table.HostnameRules
+		policy routing_table.HostnamePolicy
+	)
+
+	BeforeEach(func() {
+		rules = routing_table.HostnameRules{}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		policy, err = routing_table.NewHostnamePolicy(rules)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("with no rules", func() {
+		It("allows every hostname", func() {
+			Expect(policy.Check("pg", "anything.example.com")).To(Succeed())
+		})
+	})
+
+	Context("with an allow-list", func() {
+		BeforeEach(func() {
+			rules.Allowed = []string{"apps.example.com", "*.apps.example.com", `~^api-[0-9]+\.example\.com$`}
+		})
+
+		It("allows exact matches, ignoring case and trailing dots", func() {
+			Expect(policy.Check("pg", "APPS.example.com.")).To(Succeed())
+		})
+
+		It("allows subdomains of wildcards at any depth", func() {
+			Expect(policy.Check("pg", "foo.apps.example.com")).To(Succeed())
+			Expect(policy.Check("pg", "foo.bar.apps.example.com")).To(Succeed())
+		})
+
+		It("allows regular expression matches", func() {
+			Expect(policy.Check("pg", "api-42.example.com")).To(Succeed())
+			Expect(policy.Check("pg", "api-x.example.com")).To(Equal(routing_table.ErrHostnameNotAllowed))
+		})
+
+		It("ignores context paths", func() {
+			Expect(policy.Check("pg", "foo.apps.example.com/api")).To(Succeed())
+		})
+
+		It("rejects everything else", func() {
+			Expect(policy.Check("pg", "example.com")).To(Equal(routing_table.ErrHostnameNotAllowed))
+			Expect(policy.Check("pg", "evilapps.example.com")).To(Equal(routing_table.ErrHostnameNotAllowed))
+		})
+	})
+
+	Context("with a deny-list", func() {
+		BeforeEach(func() {
+			rules.Allowed = []string{"*.example.com"}
+			rules.Denied = []string{"admin.example.com"}
+		})
+
+		It("rejects denied hostnames even when they are allowed", func() {
+			Expect(policy.Check("pg", "admin.example.com")).To(Equal(routing_table.ErrHostnameDenied))
+			Expect(policy.Check("pg", "foo.example.com")).To(Succeed())
+		})
+	})
+
+	Context("with reserved hostnames", func() {
+		BeforeEach(func() {
+			rules.Allowed = []string{"*.apps.example.com"}
+			rules.Denied = []string{"secret.system.example.com"}
+			rules.Reserved = []routing_table.ReservedHostnames{
+				{Hostnames: []string{"*.system.example.com"}, ProcessGuids: []string{"uaa-guid", "login-guid"}},
+			}
+		})
+
+		It("allows them only for their process guids", func() {
+			Expect(policy.Check("uaa-guid", "uaa.system.example.com")).To(Succeed())
+			Expect(policy.Check("login-guid", "login.system.example.com")).To(Succeed())
+			Expect(policy.Check("app-guid", "uaa.system.example.com")).To(Equal(routing_table.ErrHostnameReserved))
+		})
+
+		It("still rejects denied hostnames", func() {
+			Expect(policy.Check("uaa-guid", "secret.system.example.com")).To(Equal(routing_table.ErrHostnameDenied))
+		})
+	})
+
+	Describe("invalid patterns", func() {
+		It("rejects invalid regular expressions", func() {
+			_, err := routing_table.NewHostnamePolicy(routing_table.HostnameRules{Allowed: []string{"~(unclosed"}})
+			Expect(err).To(MatchError(ContainSubstring(`invalid hostname pattern "~(unclosed"`)))
+		})
+
+		It("rejects wildcards that are not a single leading label", func() {
+			_, err := routing_table.NewHostnamePolicy(routing_table.HostnameRules{Denied: []string{"foo.*.example.com"}})
+			Expect(err).To(HaveOccurred())
+
+			_, err = routing_table.NewHostnamePolicy(routing_table.HostnameRules{Denied: []string{"*.*.example.com"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects empty patterns", func() {
+			_, err := routing_table.NewHostnamePolicy(routing_table.HostnameRules{
+				Reserved: []routing_table.ReservedHostnames{{Hostnames: []string{""}}},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
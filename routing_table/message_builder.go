@@ -1,6 +1,10 @@
 package routing_table
 
-import "code.cloudfoundry.org/bbs/models"
+import (
+	"reflect"
+
+	"code.cloudfoundry.org/bbs/models"
+)
 
 type MessageBuilder interface {
 	RegistrationsFor(existingEntry, newEntry *RoutableEndpoints) MessagesToEmit
@@ -82,7 +86,7 @@ func (MessagesToEmitBuilder) RegistrationsFor(existingEntry, newEntry *RoutableE
 	}
 
 	// only new entry OR something changed between existing and new entry
-	if existingEntry == nil || hostnamesHaveChanged(existingEntry, newEntry) || routeServiceUrlHasChanged(existingEntry, newEntry) {
+	if existingEntry == nil || hostnamesHaveChanged(existingEntry, newEntry) || routeServiceUrlHasChanged(existingEntry, newEntry) || routeMetadataHasChanged(existingEntry, newEntry) {
 		for _, endpoint := range newEntry.Endpoints {
 			message := RegistryMessageFor(endpoint, newEntry.routes())
 			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, message)
@@ -138,6 +142,8 @@ func (MessagesToEmitBuilder) UnregistrationsFor(existingEntry, newEntry *Routabl
 				message := RegistryMessageFor(endpoint, Routes{
 					Hostnames: hostnamesThatDisappeared,
 					LogGuid:   existingEntry.LogGuid,
+					Weight:    existingEntry.Weight,
+					Tags:      existingEntry.Tags,
 				})
 				messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, message)
 			}
@@ -164,3 +170,7 @@ func hostnamesHaveChanged(existingEntry, newEntry *RoutableEndpoints) bool {
 func routeServiceUrlHasChanged(existingEntry, newEntry *RoutableEndpoints) bool {
 	return newEntry.RouteServiceUrl != existingEntry.RouteServiceUrl
 }
+
+func routeMetadataHasChanged(existingEntry, newEntry *RoutableEndpoints) bool {
+	return newEntry.Weight != existingEntry.Weight || !reflect.DeepEqual(newEntry.Tags, existingEntry.Tags)
+}
@@ -231,6 +231,48 @@ var _ = Describe("MessagesToEmitBuilder", func() {
 				})
 			})
 
+			Context("when the weight changes", func() {
+				BeforeEach(func() {
+					existingEntry = &routing_table.RoutableEndpoints{
+						Hostnames: map[string]struct{}{hostname1: struct{}{}},
+						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+					}
+					newEntry.Weight = 4
+				})
+
+				It("emits a registration", func() {
+					expected := routing_table.MessagesToEmit{
+						RegistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1}, Weight: 4}),
+						},
+					}
+					Expect(messages).To(MatchMessagesToEmit(expected))
+				})
+			})
+
+			Context("when the tags change", func() {
+				BeforeEach(func() {
+					existingEntry = &routing_table.RoutableEndpoints{
+						Hostnames: map[string]struct{}{hostname1: struct{}{}},
+						Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{endpoint1}),
+						Tags:      map[string]string{"app_name": "old-name"},
+					}
+					newEntry.Tags = map[string]string{"app_name": "new-name"}
+				})
+
+				It("emits a registration", func() {
+					expected := routing_table.MessagesToEmit{
+						RegistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{
+								Hostnames: []string{hostname1},
+								Tags:      map[string]string{"app_name": "new-name"},
+							}),
+						},
+					}
+					Expect(messages).To(MatchMessagesToEmit(expected))
+				})
+			})
+
 			Context("when hostnames change", func() {
 				BeforeEach(func() {
 					existingEntry = &routing_table.RoutableEndpoints{
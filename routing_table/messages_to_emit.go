@@ -20,6 +20,25 @@ func (m MessagesToEmit) RouteUnregistrationCount() uint64 {
 	return routeCount(m.UnregistrationMessages)
 }
 
+// ProcessGuids returns the process guids the messages concern, found through
+// the process_guid tag of each message. If any message lacks the tag, it
+// returns false, and every process guid should be considered affected.
+func (m MessagesToEmit) ProcessGuids() (map[string]bool, bool) {
+	processGuids := map[string]bool{}
+
+	for _, messages := range [][]RegistryMessage{m.RegistrationMessages, m.UnregistrationMessages} {
+		for _, message := range messages {
+			processGuid, ok := message.Tags["process_guid"]
+			if !ok {
+				return nil, false
+			}
+			processGuids[processGuid] = true
+		}
+	}
+
+	return processGuids, true
+}
+
 func routeCount(messages []RegistryMessage) uint64 {
 	var count uint64
 	for _, message := range messages {
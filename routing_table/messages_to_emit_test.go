@@ -88,4 +88,33 @@ var _ = Describe("MessagesToEmit", func() {
 			})
 		})
 	})
+
+	Describe("ProcessGuids", func() {
+		BeforeEach(func() {
+			messagesToEmit.RegistrationMessages = []routing_table.RegistryMessage{
+				{URIs: []string{"host1.example.com"}, Tags: map[string]string{"process_guid": "pg-1"}},
+			}
+			messagesToEmit.UnregistrationMessages = []routing_table.RegistryMessage{
+				{URIs: []string{"host2.example.com"}, Tags: map[string]string{"process_guid": "pg-2"}},
+				{URIs: []string{"host3.example.com"}, Tags: map[string]string{"process_guid": "pg-1"}},
+			}
+		})
+
+		It("returns the process guids tagged on every message", func() {
+			processGuids, ok := messagesToEmit.ProcessGuids()
+			Expect(ok).To(BeTrue())
+			Expect(processGuids).To(Equal(map[string]bool{"pg-1": true, "pg-2": true}))
+		})
+
+		Context("when a message has no process guid tag", func() {
+			BeforeEach(func() {
+				messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, messages1[0])
+			})
+
+			It("reports that the process guids are unknown", func() {
+				_, ok := messagesToEmit.ProcessGuids()
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
 })
@@ -1,5 +1,7 @@
 package routing_table
 
+import "strconv"
+
 type RegistryMessage struct {
 	Host              string            `json:"host"`
 	Port              uint32            `json:"port"`
@@ -7,23 +9,51 @@ type RegistryMessage struct {
 	App               string            `json:"app,omitempty"`
 	RouteServiceUrl   string            `json:"route_service_url,omitempty"`
 	PrivateInstanceId string            `json:"private_instance_id,omitempty"`
+	Weight            uint32            `json:"weight,omitempty"`
 	Tags              map[string]string `json:"tags,omitempty"`
 }
 
+// RegistryMessageFor builds the message registering the endpoint under the
+// routes. Its tags combine the desired LRP's tags with what is known about the
+// instance behind the endpoint.
 func RegistryMessageFor(endpoint Endpoint, routes Routes) RegistryMessage {
 	return RegistryMessage{
-		URIs: routes.Hostnames,
-		Host: endpoint.Host,
-		Port: endpoint.Port,
-		App:  routes.LogGuid,
-		Tags: map[string]string{"component": "route-emitter"},
+		URIs:   routes.Hostnames,
+		Host:   endpoint.Host,
+		Port:   endpoint.Port,
+		App:    routes.LogGuid,
+		Weight: routes.Weight,
+		Tags:   registryTagsFor(endpoint, routes),
 
 		PrivateInstanceId: endpoint.InstanceGuid,
 		RouteServiceUrl:   routes.RouteServiceUrl,
 	}
 }
 
+func registryTagsFor(endpoint Endpoint, routes Routes) map[string]string {
+	tags := map[string]string{"component": "route-emitter"}
+	for name, value := range routes.Tags {
+		tags[name] = value
+	}
+
+	if endpoint.InstanceGuid != "" {
+		tags["instance_index"] = strconv.Itoa(int(endpoint.Index))
+	}
+	if endpoint.CellId != "" {
+		tags["cell_id"] = endpoint.CellId
+	}
+	if endpoint.Domain != "" {
+		tags["domain"] = endpoint.Domain
+	}
+	if endpoint.AvailabilityZone != "" {
+		tags["availability_zone"] = endpoint.AvailabilityZone
+	}
+
+	return tags
+}
+
 type RouterGreetingMessage struct {
-	MinimumRegisterInterval int `json:"minimumRegisterIntervalInSeconds"`
-	PruneThresholdInSeconds int `json:"pruneThresholdInSeconds"`
+	MinimumRegisterInterval   int  `json:"minimumRegisterIntervalInSeconds"`
+	PruneThresholdInSeconds   int  `json:"pruneThresholdInSeconds"`
+	SupportsBatchRegistration bool `json:"supportsBatchRegistration,omitempty"`
 }
@@ -53,6 +53,17 @@ var _ = Describe("RegistryMessage", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(message).To(Equal(expectedMessage))
 		})
+
+		It("includes the weight when it is set", func() {
+			expectedMessage.Weight = 3
+
+			payload, err := json.Marshal(expectedMessage)
+			Expect(err).NotTo(HaveOccurred())
+
+			var fields map[string]interface{}
+			Expect(json.Unmarshal(payload, &fields)).To(Succeed())
+			Expect(fields).To(HaveKeyWithValue("weight", BeNumerically("==", 3)))
+		})
 	})
 
 	Describe("RegistryMessageFor", func() {
@@ -69,8 +80,52 @@ var _ = Describe("RegistryMessage", func() {
 				RouteServiceUrl: "https://hello.com",
 			}
 
+			expectedMessage.Tags["instance_index"] = "0"
+
 			message := routing_table.RegistryMessageFor(endpoint, routes)
 			Expect(message).To(Equal(expectedMessage))
 		})
+
+		It("tags the message with the routes and the instance behind the endpoint", func() {
+			endpoint := routing_table.Endpoint{
+				InstanceGuid:     "instance-guid",
+				Index:            2,
+				CellId:           "cell-id",
+				AvailabilityZone: "z1",
+				Domain:           "cf-apps",
+				Host:             "1.1.1.1",
+				Port:             61001,
+				ContainerPort:    11,
+			}
+			routes := routing_table.Routes{
+				Hostnames:       []string{"host-1.example.com", "host-2.example.com"},
+				LogGuid:         "app-guid",
+				RouteServiceUrl: "https://hello.com",
+				Weight:          7,
+				Tags:            map[string]string{"process_guid": "process-guid", "app_name": "my-app"},
+			}
+
+			message := routing_table.RegistryMessageFor(endpoint, routes)
+			Expect(message.Weight).To(Equal(uint32(7)))
+			Expect(message.Tags).To(Equal(map[string]string{
+				"component":         "route-emitter",
+				"process_guid":      "process-guid",
+				"app_name":          "my-app",
+				"instance_index":    "2",
+				"cell_id":           "cell-id",
+				"domain":            "cf-apps",
+				"availability_zone": "z1",
+			}))
+		})
+
+		It("does not modify the tags of the routes", func() {
+			routes := routing_table.Routes{
+				Hostnames: []string{"host-1.example.com"},
+				Tags:      map[string]string{"process_guid": "process-guid"},
+			}
+
+			routing_table.RegistryMessageFor(routing_table.Endpoint{InstanceGuid: "instance-guid", CellId: "cell-id"}, routes)
+			Expect(routes.Tags).To(Equal(map[string]string{"process_guid": "process-guid"}))
+		})
 	})
 })
@@ -0,0 +1,98 @@
+package routing_table
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrRouteServiceUrlInvalid  = errors.New("route service url is invalid")
+	ErrRouteServiceUrlInsecure = errors.New("route service url must use https")
+	ErrRouteServiceUrlInternal = errors.New("route service url points at a denied network")
+)
+
+// RouteServicePolicy decides which route service urls routes may be bound
+// to. Check returns nil for an empty url, since the route has no route
+// service.
+type RouteServicePolicy interface {
+	Check(routeServiceUrl string) error
+}
+
+// RouteServiceRules configure a RouteServicePolicy. Route services must be
+// https urls unless AllowInsecure is set, and their host may not be an IP
+// address within DeniedNetworks, given in CIDR notation, or localhost.
+// Hostnames are not resolved.
+type RouteServiceRules struct {
+	AllowInsecure  bool
+	DeniedNetworks []string
+}
+
+type routeServicePolicy struct {
+	allowInsecure  bool
+	deniedNetworks []*net.IPNet
+}
+
+// NewRouteServicePolicy returns an error if a denied network is not valid
+// CIDR notation.
+func NewRouteServicePolicy(rules RouteServiceRules) (RouteServicePolicy, error) {
+	policy := &routeServicePolicy{allowInsecure: rules.AllowInsecure}
+
+	for _, cidr := range rules.DeniedNetworks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denied network %q: %s", cidr, err)
+		}
+		policy.deniedNetworks = append(policy.deniedNetworks, network)
+	}
+
+	return policy, nil
+}
+
+func (p *routeServicePolicy) Check(routeServiceUrl string) error {
+	if routeServiceUrl == "" {
+		return nil
+	}
+
+	u, err := url.Parse(routeServiceUrl)
+	if err != nil || u.Host == "" {
+		return ErrRouteServiceUrlInvalid
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+	case "http":
+		if !p.allowInsecure {
+			return ErrRouteServiceUrlInsecure
+		}
+	default:
+		return ErrRouteServiceUrlInvalid
+	}
+
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+
+	if host == "" {
+		return ErrRouteServiceUrlInvalid
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrRouteServiceUrlInternal
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	for _, network := range p.deniedNetworks {
+		if network.Contains(ip) {
+			return ErrRouteServiceUrlInternal
+		}
+	}
+
+	return nil
+}
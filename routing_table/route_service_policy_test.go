@@ -0,0 +1,82 @@
+package routing_table_test
+
+import (
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RouteServicePolicy", func() {
+	var (
+		rules  routing_table.RouteServiceRules
+		policy routing_table.RouteServicePolicy
+	)
+
+	BeforeEach(func() {
+		rules = routing_table.RouteServiceRules{
+			DeniedNetworks: []string{"10.0.0.0/8", "127.0.0.0/8", "fc00::/7"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		policy, err = routing_table.NewRouteServicePolicy(rules)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("allows routes without a route service", func() {
+		Expect(policy.Check("")).To(Succeed())
+	})
+
+	It("allows https urls to public hosts", func() {
+		Expect(policy.Check("https://rs.example.com")).To(Succeed())
+		Expect(policy.Check("HTTPS://rs.example.com:8443/path?q=1")).To(Succeed())
+		Expect(policy.Check("https://8.8.8.8")).To(Succeed())
+	})
+
+	It("rejects unparseable urls and urls without a host", func() {
+		Expect(policy.Check("https://rs.example.com/%zz")).To(Equal(routing_table.ErrRouteServiceUrlInvalid))
+		Expect(policy.Check("rs.example.com")).To(Equal(routing_table.ErrRouteServiceUrlInvalid))
+		Expect(policy.Check("https://:443")).To(Equal(routing_table.ErrRouteServiceUrlInvalid))
+	})
+
+	It("rejects schemes other than http and https", func() {
+		Expect(policy.Check("ftp://rs.example.com")).To(Equal(routing_table.ErrRouteServiceUrlInvalid))
+	})
+
+	It("rejects http urls", func() {
+		Expect(policy.Check("http://rs.example.com")).To(Equal(routing_table.ErrRouteServiceUrlInsecure))
+	})
+
+	Context("when insecure route services are allowed", func() {
+		BeforeEach(func() {
+			rules.AllowInsecure = true
+		})
+
+		It("allows http urls", func() {
+			Expect(policy.Check("http://rs.example.com")).To(Succeed())
+		})
+
+		It("still rejects hosts on denied networks", func() {
+			Expect(policy.Check("http://10.1.2.3")).To(Equal(routing_table.ErrRouteServiceUrlInternal))
+		})
+	})
+
+	It("rejects IP addresses on denied networks", func() {
+		Expect(policy.Check("https://10.1.2.3:8443")).To(Equal(routing_table.ErrRouteServiceUrlInternal))
+		Expect(policy.Check("https://127.0.0.1")).To(Equal(routing_table.ErrRouteServiceUrlInternal))
+		Expect(policy.Check("https://[fd00::1]:443")).To(Equal(routing_table.ErrRouteServiceUrlInternal))
+	})
+
+	It("rejects localhost", func() {
+		Expect(policy.Check("https://localhost")).To(Equal(routing_table.ErrRouteServiceUrlInternal))
+		Expect(policy.Check("https://LOCALHOST.:8443")).To(Equal(routing_table.ErrRouteServiceUrlInternal))
+		Expect(policy.Check("https://rs.localhost")).To(Equal(routing_table.ErrRouteServiceUrlInternal))
+	})
+
+	It("rejects invalid denied networks", func() {
+		_, err := routing_table.NewRouteServicePolicy(routing_table.RouteServiceRules{DeniedNetworks: []string{"10.0.0.0"}})
+		Expect(err).To(MatchError(ContainSubstring(`invalid denied network "10.0.0.0"`)))
+	})
+})
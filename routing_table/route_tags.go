@@ -0,0 +1,39 @@
+package routing_table
+
+import (
+	"encoding/json"
+
+	"code.cloudfoundry.org/bbs/models"
+)
+
+// annotationTags are the keys copied from a desired LRP's annotation into the
+// tags of its registrations, when the annotation is a JSON object.
+var annotationTags = []string{
+	"app_id",
+	"app_name",
+	"space_id",
+	"space_name",
+	"organization_id",
+	"organization_name",
+	"process_type",
+}
+
+// RouteTagsFor returns the tags shared by every registration of the desired
+// LRP: its process guid, plus the app, space and organization metadata found in
+// its annotation. Annotations that are not JSON objects are ignored.
+func RouteTagsFor(schedulingInfo *models.DesiredLRPSchedulingInfo) map[string]string {
+	tags := map[string]string{"process_guid": schedulingInfo.ProcessGuid}
+
+	metadata := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(schedulingInfo.Annotation), &metadata); err != nil {
+		return tags
+	}
+
+	for _, name := range annotationTags {
+		if value, ok := metadata[name].(string); ok && value != "" {
+			tags[name] = value
+		}
+	}
+
+	return tags
+}
@@ -1,18 +1,35 @@
 package routing_table
 
 import (
+	"sort"
 	"sync"
 
 	"code.cloudfoundry.org/bbs/models"
-	"github.com/cloudfoundry-incubator/runtime-schema/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
 	"github.com/pivotal-golang/lager"
 )
 
 var addressCollisions = metric.Counter("AddressCollisions")
+var hostnameCollisions = metric.Counter("HostnameCollisions")
+
+// HostnameConflictPolicy determines what the table does when a hostname is
+// claimed by more than one process guid.
+type HostnameConflictPolicy string
+
+const (
+	// AllowHostnameConflicts registers every claimant of a hostname.
+	AllowHostnameConflicts HostnameConflictPolicy = "allow"
+	// RejectHostnameConflicts only registers the process that claimed the
+	// hostname first.
+	RejectHostnameConflicts HostnameConflictPolicy = "reject"
+)
 
 //go:generate counterfeiter -o fake_routing_table/fake_routing_table.go . RoutingTable
 type RoutingTable interface {
 	RouteCount() int
+	Entries() map[RoutingKey]RoutableEndpoints
+	RoutingKeysForHostname(hostname string) []RoutingKey
 
 	Swap(newTable RoutingTable, domains models.DomainSet) MessagesToEmit
 
@@ -22,6 +39,9 @@ type RoutingTable interface {
 	RemoveEndpoint(key RoutingKey, endpoint Endpoint) MessagesToEmit
 
 	MessagesToEmit() MessagesToEmit
+
+	SetHostnameConflictPolicy(policy HostnameConflictPolicy)
+	ForgetProcessGuids(forget func(processGuid string) bool)
 }
 
 type noopLocker struct{}
@@ -30,11 +50,16 @@ func (noopLocker) Lock()   {}
 func (noopLocker) Unlock() {}
 
 type routingTable struct {
-	entries        map[RoutingKey]RoutableEndpoints
-	addressEntries map[Address]EndpointKey // for collision detection
+	entries         map[RoutingKey]RoutableEndpoints
+	addressEntries  map[Address]EndpointKey            // for collision detection
+	hostnameEntries map[string]map[RoutingKey]struct{} // reverse index of entries by lowercased hostname, without context paths
 	sync.Locker
 	messageBuilder MessageBuilder
-	logger         lager.Logger
+	conflictPolicy HostnameConflictPolicy
+	// conflicts holds, by route, the process guids reported as claiming it
+	// together, so that a conflict is only reported when it appears
+	conflicts map[string]map[string]struct{}
+	logger    lager.Logger
 }
 
 func NewTempTable(routes RoutesByRoutingKey, endpointsByKey EndpointsByRoutingKey) RoutingTable {
@@ -46,6 +71,9 @@ func NewTempTable(routes RoutesByRoutingKey, endpointsByKey EndpointsByRoutingKe
 			Hostnames:       routesAsMap(entry.Hostnames),
 			LogGuid:         entry.LogGuid,
 			RouteServiceUrl: entry.RouteServiceUrl,
+			Weight:          entry.Weight,
+			Tags:            entry.Tags,
+			ModificationTag: entry.ModificationTag,
 		}
 	}
 
@@ -62,21 +90,37 @@ func NewTempTable(routes RoutesByRoutingKey, endpointsByKey EndpointsByRoutingKe
 	}
 
 	return &routingTable{
-		entries:        entries,
-		addressEntries: addressEntries,
-		Locker:         noopLocker{},
-		messageBuilder: NoopMessageBuilder{},
+		entries:         entries,
+		addressEntries:  addressEntries,
+		hostnameEntries: hostnameIndexFor(entries),
+		Locker:          noopLocker{},
+		messageBuilder:  NoopMessageBuilder{},
 	}
 }
 
-func NewTable(logger lager.Logger) RoutingTable {
+func NewTable(logger lager.Logger, conflictPolicy HostnameConflictPolicy) RoutingTable {
 	return &routingTable{
-		entries:        make(map[RoutingKey]RoutableEndpoints),
-		addressEntries: make(map[Address]EndpointKey),
-		Locker:         &sync.Mutex{},
-		messageBuilder: MessagesToEmitBuilder{},
-		logger:         logger,
+		entries:         make(map[RoutingKey]RoutableEndpoints),
+		addressEntries:  make(map[Address]EndpointKey),
+		hostnameEntries: make(map[string]map[RoutingKey]struct{}),
+		Locker:          &sync.Mutex{},
+		messageBuilder:  MessagesToEmitBuilder{},
+		conflictPolicy:  conflictPolicy,
+		conflicts:       make(map[string]map[string]struct{}),
+		logger:          logger,
+	}
+}
+
+// SetHostnameConflictPolicy changes the policy applied to subsequent changes;
+// existing conflicts are resolved, and reported again, under the new policy at
+// the next Swap.
+func (table *routingTable) SetHostnameConflictPolicy(policy HostnameConflictPolicy) {
+	table.Lock()
+	if policy != table.conflictPolicy {
+		table.conflicts = make(map[string]map[string]struct{})
 	}
+	table.conflictPolicy = policy
+	table.Unlock()
 }
 
 func (table *routingTable) RouteCount() int {
@@ -91,6 +135,37 @@ func (table *routingTable) RouteCount() int {
 	return count
 }
 
+// Entries returns a copy of the current table contents that is safe to read
+// while the table continues to be modified.
+func (table *routingTable) Entries() map[RoutingKey]RoutableEndpoints {
+	table.Lock()
+
+	entries := make(map[RoutingKey]RoutableEndpoints, len(table.entries))
+	for key, entry := range table.entries {
+		entries[key] = entry.copy()
+	}
+
+	table.Unlock()
+	return entries
+}
+
+// RoutingKeysForHostname returns the keys of all entries that currently
+// claim a route on the given hostname, under any context path. Hostnames are
+// matched regardless of case.
+func (table *routingTable) RoutingKeysForHostname(hostname string) []RoutingKey {
+	host, _ := cfroutes.SplitURI(hostname)
+
+	table.Lock()
+
+	keys := make([]RoutingKey, 0, len(table.hostnameEntries[host]))
+	for key := range table.hostnameEntries[host] {
+		keys = append(keys, key)
+	}
+
+	table.Unlock()
+	return keys
+}
+
 func (table *routingTable) Swap(t RoutingTable, domains models.DomainSet) MessagesToEmit {
 	messagesToEmit := MessagesToEmit{}
 
@@ -98,11 +173,11 @@ func (table *routingTable) Swap(t RoutingTable, domains models.DomainSet) Messag
 	if !ok {
 		return messagesToEmit
 	}
-	newEntries := newTable.entries
 	updatedEntries := make(map[RoutingKey]RoutableEndpoints)
 	updatedAddressEntries := make(map[Address]EndpointKey)
 
 	table.Lock()
+	newEntries := table.resolveSwapHostnameConflicts(newTable.entries)
 	for key, newEntry := range newEntries {
 		// See if we have a match
 		existingEntry, _ := table.entries[key]
@@ -134,11 +209,33 @@ func (table *routingTable) Swap(t RoutingTable, domains models.DomainSet) Messag
 
 	table.entries = updatedEntries
 	table.addressEntries = updatedAddressEntries
+	table.hostnameEntries = hostnameIndexFor(updatedEntries)
 	table.Unlock()
 
 	return messagesToEmit
 }
 
+// ForgetProcessGuids removes the entries of the process guids forget matches
+// without emitting anything, for routes that another emitter has taken over.
+func (table *routingTable) ForgetProcessGuids(forget func(processGuid string) bool) {
+	table.Lock()
+	defer table.Unlock()
+
+	for key, entry := range table.entries {
+		if !forget(key.ProcessGuid) {
+			continue
+		}
+
+		delete(table.entries, key)
+		table.reindexHostnames(key, entry, RoutableEndpoints{})
+		for _, endpoint := range entry.Endpoints {
+			if table.addressEntries[endpoint.address()] == endpoint.key() {
+				delete(table.addressEntries, endpoint.address())
+			}
+		}
+	}
+}
+
 func (table *routingTable) MessagesToEmit() MessagesToEmit {
 	table.Lock()
 
@@ -161,12 +258,15 @@ func (table *routingTable) SetRoutes(key RoutingKey, routes Routes) MessagesToEm
 	}
 
 	newEntry := currentEntry.copy()
-	newEntry.Hostnames = routesAsMap(routes.Hostnames)
+	newEntry.Hostnames = table.resolveHostnameConflicts(key, currentEntry, routesAsMap(routes.Hostnames))
 	newEntry.LogGuid = routes.LogGuid
 	newEntry.ModificationTag = routes.ModificationTag
 	newEntry.RouteServiceUrl = routes.RouteServiceUrl
+	newEntry.Weight = routes.Weight
+	newEntry.Tags = routes.Tags
 
 	table.entries[key] = newEntry
+	table.reindexHostnames(key, currentEntry, newEntry)
 
 	return table.emit(key, currentEntry, newEntry)
 }
@@ -184,6 +284,7 @@ func (table *routingTable) RemoveRoutes(key RoutingKey, modTag *models.Modificat
 	newEntry.Endpoints = currentEntry.Endpoints
 
 	table.entries[key] = newEntry
+	table.reindexHostnames(key, currentEntry, newEntry)
 
 	return table.emit(key, currentEntry, newEntry)
 }
@@ -233,6 +334,195 @@ func (table *routingTable) RemoveEndpoint(key RoutingKey, endpoint Endpoint) Mes
 	return table.emit(key, currentEntry, newEntry)
 }
 
+// resolveHostnameConflicts reports routes that are already claimed by a
+// different process guid and, when conflicts are rejected, drops the ones
+// this entry did not already hold. Routes conflict when their hostnames match
+// regardless of case and their context paths are the same; different context
+// paths on one hostname may belong to different processes.
+func (table *routingTable) resolveHostnameConflicts(key RoutingKey, currentEntry RoutableEndpoints, hostnames map[string]struct{}) map[string]struct{} {
+	for uri := range hostnames {
+		host, path := cfroutes.SplitURI(uri)
+		route := host + path
+
+		claimants := []string{}
+		for existingKey := range table.hostnameEntries[host] {
+			if existingKey.ProcessGuid != key.ProcessGuid && len(table.entries[existingKey].urisFor(route)) > 0 {
+				claimants = append(claimants, existingKey.ProcessGuid)
+			}
+		}
+		if len(claimants) == 0 {
+			continue
+		}
+
+		sort.Strings(claimants)
+		rejected := table.conflictPolicy == RejectHostnameConflicts && len(currentEntry.urisFor(route)) == 0
+		table.reportHostnameConflict(table.conflicts, route, claimants[0], key.ProcessGuid, rejected)
+
+		if rejected {
+			delete(hostnames, uri)
+		}
+	}
+
+	return hostnames
+}
+
+// resolveSwapHostnameConflicts applies the conflict policy to a complete set
+// of entries. Process guids that already held a route in this table keep it;
+// otherwise the lowest process guid wins so the outcome is stable across
+// syncs.
+func (table *routingTable) resolveSwapHostnameConflicts(entries map[RoutingKey]RoutableEndpoints) map[RoutingKey]RoutableEndpoints {
+	resolved := entries
+	copied := false
+
+	previous := table.conflicts
+	table.conflicts = make(map[string]map[string]struct{})
+
+	for route, keys := range routeIndexFor(entries) {
+		processGuids := map[string]struct{}{}
+		for key := range keys {
+			processGuids[key.ProcessGuid] = struct{}{}
+		}
+		if len(processGuids) < 2 {
+			continue
+		}
+
+		host, _ := cfroutes.SplitURI(route)
+		winner := ""
+		for key := range table.hostnameEntries[host] {
+			if len(table.entries[key].urisFor(route)) == 0 {
+				continue
+			}
+			if _, ok := processGuids[key.ProcessGuid]; ok && (winner == "" || key.ProcessGuid < winner) {
+				winner = key.ProcessGuid
+			}
+		}
+		if winner == "" {
+			for processGuid := range processGuids {
+				if winner == "" || processGuid < winner {
+					winner = processGuid
+				}
+			}
+		}
+
+		rejected := table.conflictPolicy == RejectHostnameConflicts
+		for processGuid := range processGuids {
+			if processGuid != winner {
+				table.reportHostnameConflict(previous, route, winner, processGuid, rejected)
+			}
+		}
+
+		if !rejected {
+			continue
+		}
+
+		if !copied {
+			resolved = make(map[RoutingKey]RoutableEndpoints, len(entries))
+			for key, entry := range entries {
+				resolved[key] = entry
+			}
+			copied = true
+		}
+
+		for key, uris := range keys {
+			if key.ProcessGuid == winner {
+				continue
+			}
+			entry := resolved[key].copy()
+			for _, uri := range uris {
+				delete(entry.Hostnames, uri)
+			}
+			resolved[key] = entry
+		}
+	}
+
+	return resolved
+}
+
+// reportHostnameConflict records the conflict and reports it unless previous
+// already holds both process guids for the route.
+func (table *routingTable) reportHostnameConflict(previous map[string]map[string]struct{}, route, existingProcessGuid, newProcessGuid string, rejected bool) {
+	_, knownExisting := previous[route][existingProcessGuid]
+	_, knownNew := previous[route][newProcessGuid]
+
+	processGuids, ok := table.conflicts[route]
+	if !ok {
+		processGuids = map[string]struct{}{}
+		table.conflicts[route] = processGuids
+	}
+	processGuids[existingProcessGuid] = struct{}{}
+	processGuids[newProcessGuid] = struct{}{}
+
+	if knownExisting && knownNew {
+		return
+	}
+
+	hostnameCollisions.Add(1)
+	table.logger.Info("collision-detected-with-hostname", lager.Data{
+		"hostname":       route,
+		"process_guid_a": existingProcessGuid,
+		"process_guid_b": newProcessGuid,
+		"rejected":       rejected,
+	})
+}
+
+func (table *routingTable) reindexHostnames(key RoutingKey, oldEntry RoutableEndpoints, newEntry RoutableEndpoints) {
+	newHosts := newEntry.hosts()
+
+	for host := range oldEntry.hosts() {
+		if _, ok := newHosts[host]; ok {
+			continue
+		}
+
+		keys := table.hostnameEntries[host]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(table.hostnameEntries, host)
+		}
+	}
+
+	for host := range newHosts {
+		keys, ok := table.hostnameEntries[host]
+		if !ok {
+			keys = map[RoutingKey]struct{}{}
+			table.hostnameEntries[host] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func hostnameIndexFor(entries map[RoutingKey]RoutableEndpoints) map[string]map[RoutingKey]struct{} {
+	index := make(map[string]map[RoutingKey]struct{})
+	for key, entry := range entries {
+		for host := range entry.hosts() {
+			keys, ok := index[host]
+			if !ok {
+				keys = map[RoutingKey]struct{}{}
+				index[host] = keys
+			}
+			keys[key] = struct{}{}
+		}
+	}
+	return index
+}
+
+// routeIndexFor indexes entries by route, keeping the URIs each entry
+// registers for the route.
+func routeIndexFor(entries map[RoutingKey]RoutableEndpoints) map[string]map[RoutingKey][]string {
+	index := make(map[string]map[RoutingKey][]string)
+	for key, entry := range entries {
+		for uri := range entry.Hostnames {
+			route := routeOf(uri)
+			keys, ok := index[route]
+			if !ok {
+				keys = map[RoutingKey][]string{}
+				index[route] = keys
+			}
+			keys[key] = append(keys[key], uri)
+		}
+	}
+	return index
+}
+
 func (table *routingTable) emit(key RoutingKey, oldEntry RoutableEndpoints, newEntry RoutableEndpoints) MessagesToEmit {
 	messagesToEmit := table.messageBuilder.RegistrationsFor(&oldEntry, &newEntry)
 	messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&oldEntry, &newEntry, nil))
@@ -0,0 +1,83 @@
+package routing_table
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+const benchmarkRouteCount = 100000
+
+func newBenchmarkTable(b *testing.B) *routingTable {
+	table := NewTable(lagertest.NewTestLogger("benchmark"), AllowHostnameConflicts).(*routingTable)
+	for i := 0; i < benchmarkRouteCount; i++ {
+		key := RoutingKey{ProcessGuid: fmt.Sprintf("process-guid-%d", i), ContainerPort: 8080}
+		table.SetRoutes(key, Routes{Hostnames: []string{benchmarkHostname(i)}, LogGuid: "log-guid"})
+	}
+	b.ResetTimer()
+	return table
+}
+
+func benchmarkHostname(i int) string {
+	return fmt.Sprintf("app-%d.example.com", i)
+}
+
+func BenchmarkRoutingKeysForHostname(b *testing.B) {
+	table := newBenchmarkTable(b)
+
+	for i := 0; i < b.N; i++ {
+		keys := table.RoutingKeysForHostname(benchmarkHostname(i % benchmarkRouteCount))
+		if len(keys) != 1 {
+			b.Fatalf("expected 1 key, got %d", len(keys))
+		}
+	}
+}
+
+// BenchmarkScanEntriesForHostname measures the lookup as it had to be done
+// before the hostname index existed, for comparison.
+func BenchmarkScanEntriesForHostname(b *testing.B) {
+	table := newBenchmarkTable(b)
+
+	for i := 0; i < b.N; i++ {
+		hostname := benchmarkHostname(i % benchmarkRouteCount)
+		keys := []RoutingKey{}
+
+		table.Lock()
+		for key, entry := range table.entries {
+			if entry.hasHostname(hostname) {
+				keys = append(keys, key)
+			}
+		}
+		table.Unlock()
+
+		if len(keys) != 1 {
+			b.Fatalf("expected 1 key, got %d", len(keys))
+		}
+	}
+}
+
+func BenchmarkSetRoutes(b *testing.B) {
+	table := newBenchmarkTable(b)
+
+	for i := 0; i < b.N; i++ {
+		n := i % benchmarkRouteCount
+		key := RoutingKey{ProcessGuid: fmt.Sprintf("process-guid-%d", n), ContainerPort: 8080}
+		table.SetRoutes(key, Routes{Hostnames: []string{benchmarkHostname(n), benchmarkHostname(n + benchmarkRouteCount)}, LogGuid: "log-guid"})
+	}
+}
+
+func BenchmarkSwap(b *testing.B) {
+	table := newBenchmarkTable(b)
+
+	routes := RoutesByRoutingKey{}
+	for i := 0; i < benchmarkRouteCount; i++ {
+		key := RoutingKey{ProcessGuid: fmt.Sprintf("process-guid-%d", i), ContainerPort: 8080}
+		routes[key] = Routes{Hostnames: []string{benchmarkHostname(i)}, LogGuid: "log-guid"}
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		table.Swap(NewTempTable(routes, EndpointsByRoutingKey{}), nil)
+	}
+}
@@ -1,6 +1,9 @@
 package routing_table
 
-import "code.cloudfoundry.org/bbs/models"
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+)
 
 type EndpointKey struct {
 	InstanceGuid string
@@ -13,13 +16,16 @@ type Address struct {
 }
 
 type Endpoint struct {
-	InstanceGuid    string
-	Host            string
-	Domain          string
-	Port            uint32
-	ContainerPort   uint32
-	Evacuating      bool
-	ModificationTag *models.ModificationTag
+	InstanceGuid     string
+	Index            int32
+	CellId           string
+	AvailabilityZone string
+	Host             string
+	Domain           string
+	Port             uint32
+	ContainerPort    uint32
+	Evacuating       bool
+	ModificationTag  *models.ModificationTag
 }
 
 func (e Endpoint) key() EndpointKey {
@@ -31,9 +37,13 @@ func (e Endpoint) address() Address {
 }
 
 type Routes struct {
+	// Hostnames are the URIs registered with the router: a hostname,
+	// optionally followed by a context path.
 	Hostnames       []string
 	LogGuid         string
 	RouteServiceUrl string
+	Weight          uint32
+	Tags            map[string]string
 	ModificationTag *models.ModificationTag
 }
 
@@ -43,6 +53,8 @@ type RoutableEndpoints struct {
 	LogGuid         string
 	ModificationTag *models.ModificationTag
 	RouteServiceUrl string
+	Weight          uint32
+	Tags            map[string]string
 }
 
 type RoutingKey struct {
@@ -57,6 +69,14 @@ func NewRoutableEndpoints() RoutableEndpoints {
 	}
 }
 
+// BoundToRouteService reports whether the entry's traffic must pass through a
+// route service. Only the router sends it there, so outputs that hand the
+// endpoints to any other proxy or client leave such entries out rather than
+// let their traffic bypass the service.
+func (entry RoutableEndpoints) BoundToRouteService() bool {
+	return entry.RouteServiceUrl != ""
+}
+
 func (entry RoutableEndpoints) hasEndpoint(endpoint Endpoint) bool {
 	key := endpoint.key()
 	_, found := entry.Endpoints[key]
@@ -72,6 +92,36 @@ func (entry RoutableEndpoints) hasHostname(hostname string) bool {
 	return ok
 }
 
+// hosts returns the lowercased hostnames of the entry's URIs, without their
+// context paths.
+func (entry RoutableEndpoints) hosts() map[string]struct{} {
+	hosts := make(map[string]struct{}, len(entry.Hostnames))
+	for uri := range entry.Hostnames {
+		host, _ := cfroutes.SplitURI(uri)
+		hosts[host] = struct{}{}
+	}
+	return hosts
+}
+
+// urisFor returns the entry's URIs that route to the same hostname and context
+// path as route, ignoring the case of the hostname.
+func (entry RoutableEndpoints) urisFor(route string) []string {
+	uris := []string{}
+	for uri := range entry.Hostnames {
+		if routeOf(uri) == route {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// routeOf normalizes a URI to the lowercased hostname followed by its context
+// path, so that URIs the router treats as the same route compare equal.
+func routeOf(uri string) string {
+	host, path := cfroutes.SplitURI(uri)
+	return host + path
+}
+
 func (entry RoutableEndpoints) copy() RoutableEndpoints {
 	clone := RoutableEndpoints{
 		Hostnames:       map[string]struct{}{},
@@ -79,6 +129,8 @@ func (entry RoutableEndpoints) copy() RoutableEndpoints {
 		LogGuid:         entry.LogGuid,
 		ModificationTag: entry.ModificationTag,
 		RouteServiceUrl: entry.RouteServiceUrl,
+		Weight:          entry.Weight,
+		Tags:            entry.Tags,
 	}
 
 	for k, v := range entry.Hostnames {
@@ -105,6 +157,8 @@ func (entry RoutableEndpoints) routes() Routes {
 		Hostnames:       hostnames,
 		LogGuid:         entry.LogGuid,
 		RouteServiceUrl: entry.RouteServiceUrl,
+		Weight:          entry.Weight,
+		Tags:            entry.Tags,
 	}
 }
 
@@ -5,6 +5,8 @@ import (
 
 	"code.cloudfoundry.org/bbs/models"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
 	"github.com/pivotal-golang/lager/lagertest"
 
 	. "github.com/cloudfoundry-incubator/route-emitter/routing_table/matchers"
@@ -54,7 +56,7 @@ var _ = Describe("RoutingTable", func() {
 
 	BeforeEach(func() {
 		logger = lagertest.NewTestLogger("test-route-emitter")
-		table = routing_table.NewTable(logger)
+		table = routing_table.NewTable(logger, routing_table.AllowHostnameConflicts)
 	})
 
 	Describe("Swap", func() {
@@ -813,6 +815,25 @@ var _ = Describe("RoutingTable", func() {
 					}
 					Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
 				})
+
+				It("unregisters only the old context path when the path of a route changes", func() {
+					table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, hostname2 + "/api"}, LogGuid: logGuid, ModificationTag: newerTag})
+
+					evenNewerTag := &models.ModificationTag{Epoch: "def", Index: 1}
+					messagesToEmit = table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, hostname2 + "/v2"}, LogGuid: logGuid, ModificationTag: evenNewerTag})
+
+					expected := routing_table.MessagesToEmit{
+						RegistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1, hostname2 + "/v2"}, LogGuid: logGuid}),
+							routing_table.RegistryMessageFor(endpoint2, routing_table.Routes{Hostnames: []string{hostname1, hostname2 + "/v2"}, LogGuid: logGuid}),
+						},
+						UnregistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname2 + "/api"}, LogGuid: logGuid}),
+							routing_table.RegistryMessageFor(endpoint2, routing_table.Routes{Hostnames: []string{hostname2 + "/api"}, LogGuid: logGuid}),
+						},
+					}
+					Expect(messagesToEmit).To(MatchMessagesToEmit(expected))
+				})
 			})
 
 			Context("RemoveRoutes", func() {
@@ -1126,6 +1147,319 @@ var _ = Describe("RoutingTable", func() {
 		})
 	})
 
+	Describe("Entries", func() {
+		BeforeEach(func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+			table.AddEndpoint(key, endpoint1)
+		})
+
+		It("returns the current contents of the table", func() {
+			entries := table.Entries()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[key].Hostnames).To(HaveKey(hostname1))
+			Expect(entries[key].Endpoints).To(ContainElement(endpoint1))
+			Expect(entries[key].LogGuid).To(Equal(logGuid))
+		})
+
+		It("returns a copy that is not affected by later changes", func() {
+			entries := table.Entries()
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid})
+			table.AddEndpoint(key, endpoint2)
+
+			Expect(entries[key].Hostnames).To(HaveLen(1))
+			Expect(entries[key].Hostnames).To(HaveKey(hostname1))
+			Expect(entries[key].Endpoints).To(HaveLen(1))
+		})
+	})
+
+	Describe("RoutingKeysForHostname", func() {
+		otherKey := routing_table.RoutingKey{ProcessGuid: "other-process-guid", ContainerPort: 8080}
+
+		It("returns nothing for an unknown hostname", func() {
+			Expect(table.RoutingKeysForHostname(hostname1)).To(BeEmpty())
+		})
+
+		Context("when routes are set", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid, ModificationTag: currentTag})
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid, ModificationTag: currentTag})
+			})
+
+			It("returns every key claiming the hostname", func() {
+				Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key, otherKey))
+				Expect(table.RoutingKeysForHostname(hostname2)).To(ConsistOf(key))
+			})
+
+			Context("and the routes change", func() {
+				BeforeEach(func() {
+					table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname3}, LogGuid: logGuid, ModificationTag: newerTag})
+				})
+
+				It("updates the index", func() {
+					Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(otherKey))
+					Expect(table.RoutingKeysForHostname(hostname2)).To(BeEmpty())
+					Expect(table.RoutingKeysForHostname(hostname3)).To(ConsistOf(key))
+				})
+			})
+
+			Context("and the routes are removed", func() {
+				BeforeEach(func() {
+					table.RemoveRoutes(key, newerTag)
+				})
+
+				It("removes the key from the index", func() {
+					Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(otherKey))
+					Expect(table.RoutingKeysForHostname(hostname2)).To(BeEmpty())
+				})
+			})
+
+			Context("and the table is swapped", func() {
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTable(
+						routing_table.RoutesByRoutingKey{key: routing_table.Routes{Hostnames: []string{hostname3}, LogGuid: logGuid}},
+						routing_table.EndpointsByRoutingKey{},
+					)
+					table.Swap(tempTable, domains)
+				})
+
+				It("rebuilds the index from the new entries", func() {
+					Expect(table.RoutingKeysForHostname(hostname1)).To(BeEmpty())
+					Expect(table.RoutingKeysForHostname(hostname2)).To(BeEmpty())
+					Expect(table.RoutingKeysForHostname(hostname3)).To(ConsistOf(key))
+				})
+			})
+		})
+
+		Context("when routes have context paths", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1 + "/api", hostname1 + "/v2"}, LogGuid: logGuid, ModificationTag: currentTag})
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid, ModificationTag: currentTag})
+			})
+
+			It("indexes them by hostname", func() {
+				Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key, otherKey))
+			})
+
+			Context("and one of the paths is removed", func() {
+				BeforeEach(func() {
+					table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1 + "/api"}, LogGuid: logGuid, ModificationTag: newerTag})
+				})
+
+				It("keeps the key while another path remains", func() {
+					Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key, otherKey))
+				})
+			})
+		})
+
+		Context("when hostnames differ in case", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{"Foo.Example.com"}, LogGuid: logGuid, ModificationTag: currentTag})
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid, ModificationTag: currentTag})
+			})
+
+			It("matches them regardless of case", func() {
+				Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key, otherKey))
+				Expect(table.RoutingKeysForHostname("FOO.EXAMPLE.COM")).To(ConsistOf(key, otherKey))
+			})
+
+			Context("and the table is swapped", func() {
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTable(
+						routing_table.RoutesByRoutingKey{key: routing_table.Routes{Hostnames: []string{"Foo.Example.com/api"}, LogGuid: logGuid}},
+						routing_table.EndpointsByRoutingKey{},
+					)
+					table.Swap(tempTable, domains)
+				})
+
+				It("rebuilds the index by lowercased hostname", func() {
+					Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key))
+				})
+			})
+		})
+	})
+
+	Describe("ForgetProcessGuids", func() {
+		otherKey := routing_table.RoutingKey{ProcessGuid: "other-process-guid", ContainerPort: 8080}
+
+		BeforeEach(func() {
+			table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+			table.AddEndpoint(key, endpoint1)
+			table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid})
+			table.AddEndpoint(otherKey, endpoint2)
+
+			table.ForgetProcessGuids(func(processGuid string) bool {
+				return processGuid == key.ProcessGuid
+			})
+		})
+
+		It("removes the entries of the matching process guids", func() {
+			entries := table.Entries()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries).To(HaveKey(otherKey))
+			Expect(table.RoutingKeysForHostname(hostname1)).To(BeEmpty())
+		})
+
+		It("does not unregister their routes on the next swap", func() {
+			tempTable := routing_table.NewTempTable(
+				routing_table.RoutesByRoutingKey{otherKey: routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid}},
+				routing_table.EndpointsByRoutingKey{otherKey: []routing_table.Endpoint{endpoint2}},
+			)
+			messagesToEmit = table.Swap(tempTable, domains)
+			Expect(messagesToEmit.UnregistrationMessages).To(BeEmpty())
+		})
+	})
+
+	Describe("hostname conflicts", func() {
+		var fakeMetricSender *fake_metrics_sender.FakeMetricSender
+
+		otherKey := routing_table.RoutingKey{ProcessGuid: "other-process-guid", ContainerPort: 8080}
+		otherEndpoint := routing_table.Endpoint{InstanceGuid: "ig-other", Host: "5.5.5.5", Domain: domain, Port: 55, ContainerPort: 8080, ModificationTag: currentTag}
+
+		BeforeEach(func() {
+			fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+			metrics.Initialize(fakeMetricSender, nil)
+		})
+
+		Context("when another port of the same process claims the hostname", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+				table.SetRoutes(routing_table.RoutingKey{ProcessGuid: key.ProcessGuid, ContainerPort: 9090}, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+			})
+
+			It("is not a conflict", func() {
+				Expect(fakeMetricSender.GetCounter("HostnameCollisions")).To(BeZero())
+			})
+		})
+
+		Context("when another process claims a different context path on the hostname", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1 + "/api"}, LogGuid: logGuid})
+			})
+
+			It("is not a conflict", func() {
+				Expect(fakeMetricSender.GetCounter("HostnameCollisions")).To(BeZero())
+			})
+		})
+
+		Context("when another process claims the hostname in a different case", func() {
+			BeforeEach(func() {
+				table = routing_table.NewTable(logger, routing_table.RejectHostnameConflicts)
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1 + "/api"}, LogGuid: logGuid})
+				table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{"FOO.example.com/api"}, LogGuid: logGuid})
+			})
+
+			It("reports and rejects the conflict", func() {
+				Expect(logger).To(Say(`"hostname":"foo.example.com/api","process_guid_a":"some-process-guid","process_guid_b":"other-process-guid","rejected":true`))
+				Expect(fakeMetricSender.GetCounter("HostnameCollisions")).To(BeEquivalentTo(1))
+				Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key))
+			})
+		})
+
+		Context("when the policy allows conflicts", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+				table.AddEndpoint(otherKey, otherEndpoint)
+				messagesToEmit = table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid})
+			})
+
+			It("registers both claimants", func() {
+				Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key, otherKey))
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					MatchRegistryMessage(routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid})),
+				))
+			})
+
+			It("logs and counts the conflict", func() {
+				Expect(logger).To(Say(`"hostname":"foo.example.com","process_guid_a":"some-process-guid","process_guid_b":"other-process-guid","rejected":false`))
+				Expect(fakeMetricSender.GetCounter("HostnameCollisions")).To(BeEquivalentTo(1))
+			})
+
+			Context("when the table is swapped", func() {
+				thirdKey := routing_table.RoutingKey{ProcessGuid: "third-process-guid", ContainerPort: 8080}
+
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTable(
+						routing_table.RoutesByRoutingKey{
+							key:      routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid},
+							otherKey: routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid},
+							thirdKey: routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid},
+						},
+						routing_table.EndpointsByRoutingKey{otherKey: {otherEndpoint}},
+					)
+					table.Swap(tempTable, domains)
+				})
+
+				It("only reports the conflicts that are new", func() {
+					Expect(logger).To(Say(`"hostname":"bar.example.com","process_guid_a":"other-process-guid","process_guid_b":"third-process-guid","rejected":false`))
+					Expect(fakeMetricSender.GetCounter("HostnameCollisions")).To(BeEquivalentTo(2))
+				})
+			})
+		})
+
+		Context("when the policy rejects conflicts", func() {
+			BeforeEach(func() {
+				table = routing_table.NewTable(logger, routing_table.RejectHostnameConflicts)
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+				table.AddEndpoint(otherKey, otherEndpoint)
+				messagesToEmit = table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid})
+			})
+
+			It("does not register the newer claimant for the conflicting hostname", func() {
+				Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key))
+				Expect(table.RoutingKeysForHostname(hostname2)).To(ConsistOf(otherKey))
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					MatchRegistryMessage(routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid})),
+				))
+			})
+
+			It("logs and counts the rejected conflict", func() {
+				Expect(logger).To(Say(`"hostname":"foo.example.com","process_guid_a":"some-process-guid","process_guid_b":"other-process-guid","rejected":true`))
+				Expect(fakeMetricSender.GetCounter("HostnameCollisions")).To(BeEquivalentTo(1))
+			})
+
+			Context("when the table is swapped", func() {
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTable(
+						routing_table.RoutesByRoutingKey{
+							key:      routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid},
+							otherKey: routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid},
+						},
+						routing_table.EndpointsByRoutingKey{otherKey: {otherEndpoint}},
+					)
+					messagesToEmit = table.Swap(tempTable, domains)
+				})
+
+				It("keeps the hostname with the process that already held it", func() {
+					Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key))
+					Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+						MatchRegistryMessage(routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid})),
+					))
+				})
+
+				It("does not report the conflict again", func() {
+					Expect(fakeMetricSender.GetCounter("HostnameCollisions")).To(BeEquivalentTo(1))
+				})
+			})
+		})
+
+		Context("when the policy is changed to reject conflicts", func() {
+			BeforeEach(func() {
+				table.SetHostnameConflictPolicy(routing_table.RejectHostnameConflicts)
+				table.SetRoutes(key, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: logGuid})
+				table.AddEndpoint(otherKey, otherEndpoint)
+				messagesToEmit = table.SetRoutes(otherKey, routing_table.Routes{Hostnames: []string{hostname1, hostname2}, LogGuid: logGuid})
+			})
+
+			It("rejects subsequent conflicts", func() {
+				Expect(table.RoutingKeysForHostname(hostname1)).To(ConsistOf(key))
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					MatchRegistryMessage(routing_table.RegistryMessageFor(otherEndpoint, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: logGuid})),
+				))
+			})
+		})
+	})
+
 	Describe("RouteCount", func() {
 		It("returns 0 on a new routing table", func() {
 			Expect(table.RouteCount()).To(Equal(0))
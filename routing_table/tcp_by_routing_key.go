@@ -0,0 +1,52 @@
+package routing_table
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_routes"
+)
+
+type TCPRoutesByRoutingKey map[RoutingKey]TCPRoutes
+
+func TCPRoutesByRoutingKeyFromSchedulingInfos(schedulingInfos []*models.DesiredLRPSchedulingInfo) TCPRoutesByRoutingKey {
+	routesByRoutingKey := TCPRoutesByRoutingKey{}
+	for _, desired := range schedulingInfos {
+		for key, routes := range TCPRoutesFromSchedulingInfo(desired) {
+			routesByRoutingKey[key] = routes
+		}
+	}
+
+	return routesByRoutingKey
+}
+
+// TCPRoutesFromSchedulingInfo groups the tcp-router routes of a desired LRP by
+// container port, since a single container port may be exposed on several
+// external ports.
+func TCPRoutesFromSchedulingInfo(schedulingInfo *models.DesiredLRPSchedulingInfo) TCPRoutesByRoutingKey {
+	routesByRoutingKey := TCPRoutesByRoutingKey{}
+
+	routes, err := tcp_routes.TCPRoutesFromRoutingInfo(schedulingInfo.Routes)
+	if err != nil || len(routes) == 0 {
+		return routesByRoutingKey
+	}
+
+	for _, tcpRoute := range routes {
+		key := RoutingKey{ProcessGuid: schedulingInfo.ProcessGuid, ContainerPort: tcpRoute.ContainerPort}
+		entry := routesByRoutingKey[key]
+		entry.LogGuid = schedulingInfo.LogGuid
+		entry.ExternalEndpoints = append(entry.ExternalEndpoints, ExternalEndpointInfo{
+			RouterGroupGuid: tcpRoute.RouterGroupGuid,
+			Port:            tcpRoute.ExternalPort,
+		})
+		routesByRoutingKey[key] = entry
+	}
+
+	return routesByRoutingKey
+}
+
+func TCPRoutingKeysFromSchedulingInfo(schedulingInfo *models.DesiredLRPSchedulingInfo) []RoutingKey {
+	keys := []RoutingKey{}
+	for key := range TCPRoutesFromSchedulingInfo(schedulingInfo) {
+		keys = append(keys, key)
+	}
+	return keys
+}
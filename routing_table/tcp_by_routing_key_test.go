@@ -0,0 +1,73 @@
+package routing_table_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_routes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPByRoutingKey", func() {
+	Describe("TCPRoutesByRoutingKeyFromSchedulingInfos", func() {
+		It("should build a map of tcp routes grouped by container port", func() {
+			abcRoutes := tcp_routes.TCPRoutes{
+				{RouterGroupGuid: "rg-1", ExternalPort: 61000, ContainerPort: 5222},
+				{RouterGroupGuid: "rg-1", ExternalPort: 61001, ContainerPort: 5222},
+				{RouterGroupGuid: "rg-2", ExternalPort: 61002, ContainerPort: 6222},
+			}
+			defRoutes := tcp_routes.TCPRoutes{
+				{RouterGroupGuid: "rg-1", ExternalPort: 61003, ContainerPort: 5222},
+			}
+
+			routes := routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
+				{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"), Routes: abcRoutes.RoutingInfo()},
+				{DesiredLRPKey: models.NewDesiredLRPKey("def", "tests", "def-guid"), Routes: defRoutes.RoutingInfo()},
+			})
+
+			Expect(routes).To(HaveLen(3))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 5222}].ExternalEndpoints).To(ConsistOf(
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "rg-1", Port: 61000},
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "rg-1", Port: 61001},
+			))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 5222}].LogGuid).To(Equal("abc-guid"))
+
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 6222}].ExternalEndpoints).To(ConsistOf(
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "rg-2", Port: 61002},
+			))
+
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 5222}].ExternalEndpoints).To(ConsistOf(
+				routing_table.ExternalEndpointInfo{RouterGroupGuid: "rg-1", Port: 61003},
+			))
+			Expect(routes[routing_table.RoutingKey{ProcessGuid: "def", ContainerPort: 5222}].LogGuid).To(Equal("def-guid"))
+		})
+
+		Context("when the routing info has no tcp routes", func() {
+			It("should not be included in the results", func() {
+				routes := routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{
+					{DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"), Routes: nil},
+				})
+				Expect(routes).To(HaveLen(0))
+			})
+		})
+	})
+
+	Describe("TCPRoutingKeysFromSchedulingInfo", func() {
+		It("creates a routing key for each container port", func() {
+			schedulingInfo := &models.DesiredLRPSchedulingInfo{
+				DesiredLRPKey: models.NewDesiredLRPKey("abc", "tests", "abc-guid"),
+				Routes: tcp_routes.TCPRoutes{
+					{RouterGroupGuid: "rg-1", ExternalPort: 61000, ContainerPort: 5222},
+					{RouterGroupGuid: "rg-1", ExternalPort: 61001, ContainerPort: 5222},
+					{RouterGroupGuid: "rg-1", ExternalPort: 61002, ContainerPort: 6222},
+				}.RoutingInfo(),
+			}
+
+			Expect(routing_table.TCPRoutingKeysFromSchedulingInfo(schedulingInfo)).To(ConsistOf(
+				routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 5222},
+				routing_table.RoutingKey{ProcessGuid: "abc", ContainerPort: 6222},
+			))
+		})
+	})
+})
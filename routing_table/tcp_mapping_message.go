@@ -0,0 +1,17 @@
+package routing_table
+
+type TCPMappingMessage struct {
+	RouterGroupGuid string `json:"router_group_guid"`
+	ExternalPort    uint32 `json:"port"`
+	BackendHost     string `json:"backend_ip"`
+	BackendPort     uint32 `json:"backend_port"`
+}
+
+func TCPMappingMessageFor(endpoint Endpoint, externalEndpoint ExternalEndpointInfo) TCPMappingMessage {
+	return TCPMappingMessage{
+		RouterGroupGuid: externalEndpoint.RouterGroupGuid,
+		ExternalPort:    externalEndpoint.Port,
+		BackendHost:     endpoint.Host,
+		BackendPort:     endpoint.Port,
+	}
+}
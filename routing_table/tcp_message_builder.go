@@ -0,0 +1,154 @@
+package routing_table
+
+import "code.cloudfoundry.org/bbs/models"
+
+type TCPMessageBuilder interface {
+	RegistrationsFor(existingEntry, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit
+	UnfreshRegistrations(existingEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit
+	MergedRegistrations(existingEntry, newEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit
+	UnregistrationsFor(existingEntry, newEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit
+}
+
+type NoopTCPMessageBuilder struct {
+}
+
+func (NoopTCPMessageBuilder) RegistrationsFor(existingEntry, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit {
+	return TCPMessagesToEmit{}
+}
+
+func (NoopTCPMessageBuilder) UnfreshRegistrations(existingEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit {
+	return TCPMessagesToEmit{}
+}
+
+func (NoopTCPMessageBuilder) MergedRegistrations(existingEntry, newEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit {
+	return TCPMessagesToEmit{}
+}
+
+func (NoopTCPMessageBuilder) UnregistrationsFor(existingEntry, newEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit {
+	return TCPMessagesToEmit{}
+}
+
+type TCPMessagesToEmitBuilder struct {
+}
+
+func (TCPMessagesToEmitBuilder) UnfreshRegistrations(existingEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+	for _, endpoint := range existingEntry.Endpoints {
+		if domains != nil && !domains.Contains(endpoint.Domain) {
+			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, tcpMappingMessagesFor(endpoint, existingEntry.ExternalEndpoints)...)
+		}
+	}
+
+	return messagesToEmit
+}
+
+func (TCPMessagesToEmitBuilder) MergedRegistrations(existingEntry, newEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+	if len(newEntry.ExternalEndpoints) == 0 {
+		//no external endpoints, so nothing could possibly be registered
+		return messagesToEmit
+	}
+
+	for _, endpoint := range newEntry.Endpoints {
+		if domains != nil && !domains.Contains(endpoint.Domain) {
+			// Not Fresh, so keep the mappings we already know about
+			for externalEndpoint := range existingEntry.ExternalEndpoints {
+				newEntry.ExternalEndpoints[externalEndpoint] = struct{}{}
+			}
+		}
+	}
+
+	for _, endpoint := range newEntry.Endpoints {
+		messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, tcpMappingMessagesFor(endpoint, newEntry.ExternalEndpoints)...)
+	}
+
+	return messagesToEmit
+}
+
+func (TCPMessagesToEmitBuilder) RegistrationsFor(existingEntry, newEntry *TCPRoutableEndpoints) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+	if len(newEntry.ExternalEndpoints) == 0 {
+		//no external endpoints, so nothing could possibly be registered
+		return messagesToEmit
+	}
+
+	// only new entry OR something changed between existing and new entry
+	if existingEntry == nil || externalEndpointsHaveChanged(existingEntry, newEntry) {
+		for _, endpoint := range newEntry.Endpoints {
+			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, tcpMappingMessagesFor(endpoint, newEntry.ExternalEndpoints)...)
+		}
+		return messagesToEmit
+	}
+
+	//otherwise only register *new* endpoints
+	for _, endpoint := range newEntry.Endpoints {
+		if !existingEntry.hasEndpoint(endpoint) {
+			messagesToEmit.RegistrationMessages = append(messagesToEmit.RegistrationMessages, tcpMappingMessagesFor(endpoint, newEntry.ExternalEndpoints)...)
+		}
+	}
+
+	return messagesToEmit
+}
+
+func (TCPMessagesToEmitBuilder) UnregistrationsFor(existingEntry, newEntry *TCPRoutableEndpoints, domains models.DomainSet) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+
+	if len(existingEntry.ExternalEndpoints) == 0 {
+		// the existing entry has no external endpoints and so there is nothing to unregister
+		return messagesToEmit
+	}
+
+	endpointsThatAreStillPresent := []Endpoint{}
+	for _, endpoint := range existingEntry.Endpoints {
+		if newEntry.hasEndpoint(endpoint) {
+			endpointsThatAreStillPresent = append(endpointsThatAreStillPresent, endpoint)
+		} else {
+			// only unregister if domain is fresh or preforming event processing
+			if domains == nil || domains.Contains(endpoint.Domain) {
+				//if the endpoint has disappeared unregister all its previous mappings
+				messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, tcpMappingMessagesFor(endpoint, existingEntry.ExternalEndpoints)...)
+			}
+		}
+	}
+
+	externalEndpointsThatDisappeared := map[ExternalEndpointInfo]struct{}{}
+	for externalEndpoint := range existingEntry.ExternalEndpoints {
+		if !newEntry.hasExternalEndpoint(externalEndpoint) {
+			externalEndpointsThatDisappeared[externalEndpoint] = struct{}{}
+		}
+	}
+
+	if len(externalEndpointsThatDisappeared) > 0 {
+		for _, endpoint := range endpointsThatAreStillPresent {
+			// only unregister if domain is fresh or preforming event processing
+			if domains == nil || domains.Contains(endpoint.Domain) {
+				//if a endpoint is still present, and external endpoints have disappeared, unregister those mappings
+				messagesToEmit.UnregistrationMessages = append(messagesToEmit.UnregistrationMessages, tcpMappingMessagesFor(endpoint, externalEndpointsThatDisappeared)...)
+			}
+		}
+	}
+
+	return messagesToEmit
+}
+
+func tcpMappingMessagesFor(endpoint Endpoint, externalEndpoints map[ExternalEndpointInfo]struct{}) []TCPMappingMessage {
+	messages := make([]TCPMappingMessage, 0, len(externalEndpoints))
+	for externalEndpoint := range externalEndpoints {
+		messages = append(messages, TCPMappingMessageFor(endpoint, externalEndpoint))
+	}
+	return messages
+}
+
+func externalEndpointsHaveChanged(existingEntry, newEntry *TCPRoutableEndpoints) bool {
+	if len(newEntry.ExternalEndpoints) != len(existingEntry.ExternalEndpoints) {
+		return true
+	}
+
+	for externalEndpoint := range newEntry.ExternalEndpoints {
+		if !existingEntry.hasExternalEndpoint(externalEndpoint) {
+			return true
+		}
+	}
+
+	return false
+}
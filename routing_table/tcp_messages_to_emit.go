@@ -0,0 +1,21 @@
+package routing_table
+
+type TCPMessagesToEmit struct {
+	RegistrationMessages   []TCPMappingMessage
+	UnregistrationMessages []TCPMappingMessage
+}
+
+func (m TCPMessagesToEmit) merge(o TCPMessagesToEmit) TCPMessagesToEmit {
+	return TCPMessagesToEmit{
+		RegistrationMessages:   append(m.RegistrationMessages, o.RegistrationMessages...),
+		UnregistrationMessages: append(m.UnregistrationMessages, o.UnregistrationMessages...),
+	}
+}
+
+func (m TCPMessagesToEmit) RouteRegistrationCount() uint64 {
+	return uint64(len(m.RegistrationMessages))
+}
+
+func (m TCPMessagesToEmit) RouteUnregistrationCount() uint64 {
+	return uint64(len(m.UnregistrationMessages))
+}
@@ -0,0 +1,215 @@
+package routing_table
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter -o fake_routing_table/fake_tcp_routing_table.go . TCPRoutingTable
+type TCPRoutingTable interface {
+	RouteCount() int
+
+	Swap(newTable TCPRoutingTable, domains models.DomainSet) TCPMessagesToEmit
+
+	SetRoutes(key RoutingKey, routes TCPRoutes) TCPMessagesToEmit
+	RemoveRoutes(key RoutingKey, modTag *models.ModificationTag) TCPMessagesToEmit
+	AddEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit
+	RemoveEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit
+
+	MessagesToEmit() TCPMessagesToEmit
+
+	ForgetProcessGuids(forget func(processGuid string) bool)
+}
+
+type tcpRoutingTable struct {
+	entries map[RoutingKey]TCPRoutableEndpoints
+	sync.Locker
+	messageBuilder TCPMessageBuilder
+	logger         lager.Logger
+}
+
+func NewTempTCPTable(routes TCPRoutesByRoutingKey, endpointsByKey EndpointsByRoutingKey) TCPRoutingTable {
+	entries := make(map[RoutingKey]TCPRoutableEndpoints)
+
+	for key, entry := range routes {
+		entries[key] = TCPRoutableEndpoints{
+			ExternalEndpoints: externalEndpointsAsMap(entry.ExternalEndpoints),
+			LogGuid:           entry.LogGuid,
+		}
+	}
+
+	for key, endpoints := range endpointsByKey {
+		entry, ok := entries[key]
+		if !ok {
+			entry = TCPRoutableEndpoints{}
+		}
+		entry.Endpoints = EndpointsAsMap(endpoints)
+		entries[key] = entry
+	}
+
+	return &tcpRoutingTable{
+		entries:        entries,
+		Locker:         noopLocker{},
+		messageBuilder: NoopTCPMessageBuilder{},
+	}
+}
+
+func NewTCPTable(logger lager.Logger) TCPRoutingTable {
+	return &tcpRoutingTable{
+		entries:        make(map[RoutingKey]TCPRoutableEndpoints),
+		Locker:         &sync.Mutex{},
+		messageBuilder: TCPMessagesToEmitBuilder{},
+		logger:         logger,
+	}
+}
+
+func (table *tcpRoutingTable) RouteCount() int {
+	table.Lock()
+
+	count := 0
+	for _, entry := range table.entries {
+		count += len(entry.ExternalEndpoints)
+	}
+
+	table.Unlock()
+	return count
+}
+
+func (table *tcpRoutingTable) Swap(t TCPRoutingTable, domains models.DomainSet) TCPMessagesToEmit {
+	messagesToEmit := TCPMessagesToEmit{}
+
+	newTable, ok := t.(*tcpRoutingTable)
+	if !ok {
+		return messagesToEmit
+	}
+	newEntries := newTable.entries
+	updatedEntries := make(map[RoutingKey]TCPRoutableEndpoints)
+
+	table.Lock()
+	for key, newEntry := range newEntries {
+		existingEntry, _ := table.entries[key]
+
+		//always register everything on sync  NOTE if a merge does occur we may return an altered newEntry
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.MergedRegistrations(&existingEntry, &newEntry, domains))
+		updatedEntries[key] = newEntry
+	}
+
+	for key, existingEntry := range table.entries {
+		newEntry, ok := newEntries[key]
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&existingEntry, &newEntry, domains))
+
+		// maybe reemit old ones no longer found in the new table
+		if !ok {
+			unfreshRegistrations := table.messageBuilder.UnfreshRegistrations(&existingEntry, domains)
+			if len(unfreshRegistrations.RegistrationMessages) > 0 {
+				updatedEntries[key] = existingEntry
+				messagesToEmit = messagesToEmit.merge(unfreshRegistrations)
+			}
+		}
+	}
+
+	table.entries = updatedEntries
+	table.Unlock()
+
+	return messagesToEmit
+}
+
+// ForgetProcessGuids removes the entries of the process guids forget matches
+// without emitting anything, for routes that another emitter has taken over.
+func (table *tcpRoutingTable) ForgetProcessGuids(forget func(processGuid string) bool) {
+	table.Lock()
+	defer table.Unlock()
+
+	for key := range table.entries {
+		if forget(key.ProcessGuid) {
+			delete(table.entries, key)
+		}
+	}
+}
+
+func (table *tcpRoutingTable) MessagesToEmit() TCPMessagesToEmit {
+	table.Lock()
+
+	messagesToEmit := TCPMessagesToEmit{}
+	for _, entry := range table.entries {
+		messagesToEmit = messagesToEmit.merge(table.messageBuilder.RegistrationsFor(nil, &entry))
+	}
+
+	table.Unlock()
+	return messagesToEmit
+}
+
+func (table *tcpRoutingTable) SetRoutes(key RoutingKey, routes TCPRoutes) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	if !currentEntry.ModificationTag.SucceededBy(routes.ModificationTag) {
+		return TCPMessagesToEmit{}
+	}
+
+	newEntry := currentEntry.copy()
+	newEntry.ExternalEndpoints = externalEndpointsAsMap(routes.ExternalEndpoints)
+	newEntry.LogGuid = routes.LogGuid
+	newEntry.ModificationTag = routes.ModificationTag
+
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) RemoveRoutes(key RoutingKey, modTag *models.ModificationTag) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	if !(currentEntry.ModificationTag.Equal(modTag) || currentEntry.ModificationTag.SucceededBy(modTag)) {
+		return TCPMessagesToEmit{}
+	}
+
+	newEntry := NewTCPRoutableEndpoints()
+	newEntry.Endpoints = currentEntry.Endpoints
+
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) AddEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	newEntry := currentEntry.copy()
+	newEntry.Endpoints[endpoint.key()] = endpoint
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) RemoveEndpoint(key RoutingKey, endpoint Endpoint) TCPMessagesToEmit {
+	table.Lock()
+	defer table.Unlock()
+
+	currentEntry := table.entries[key]
+	endpointKey := endpoint.key()
+	currentEndpoint, ok := currentEntry.Endpoints[endpointKey]
+	if !ok || !(currentEndpoint.ModificationTag.Equal(endpoint.ModificationTag) || currentEndpoint.ModificationTag.SucceededBy(endpoint.ModificationTag)) {
+		return TCPMessagesToEmit{}
+	}
+
+	newEntry := currentEntry.copy()
+	delete(newEntry.Endpoints, endpointKey)
+	table.entries[key] = newEntry
+
+	return table.emit(key, currentEntry, newEntry)
+}
+
+func (table *tcpRoutingTable) emit(key RoutingKey, oldEntry TCPRoutableEndpoints, newEntry TCPRoutableEndpoints) TCPMessagesToEmit {
+	messagesToEmit := table.messageBuilder.RegistrationsFor(&oldEntry, &newEntry)
+	messagesToEmit = messagesToEmit.merge(table.messageBuilder.UnregistrationsFor(&oldEntry, &newEntry, nil))
+
+	return messagesToEmit
+}
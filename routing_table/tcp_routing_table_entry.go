@@ -0,0 +1,78 @@
+package routing_table
+
+import "code.cloudfoundry.org/bbs/models"
+
+type ExternalEndpointInfo struct {
+	RouterGroupGuid string
+	Port            uint32
+}
+
+type TCPRoutes struct {
+	ExternalEndpoints []ExternalEndpointInfo
+	LogGuid           string
+	ModificationTag   *models.ModificationTag
+}
+
+type TCPRoutableEndpoints struct {
+	ExternalEndpoints map[ExternalEndpointInfo]struct{}
+	Endpoints         map[EndpointKey]Endpoint
+	LogGuid           string
+	ModificationTag   *models.ModificationTag
+}
+
+func NewTCPRoutableEndpoints() TCPRoutableEndpoints {
+	return TCPRoutableEndpoints{
+		ExternalEndpoints: map[ExternalEndpointInfo]struct{}{},
+		Endpoints:         map[EndpointKey]Endpoint{},
+	}
+}
+
+func (entry TCPRoutableEndpoints) hasEndpoint(endpoint Endpoint) bool {
+	key := endpoint.key()
+	_, found := entry.Endpoints[key]
+	if !found {
+		key.Evacuating = !key.Evacuating
+		_, found = entry.Endpoints[key]
+	}
+	return found
+}
+
+func (entry TCPRoutableEndpoints) hasExternalEndpoint(externalEndpoint ExternalEndpointInfo) bool {
+	_, ok := entry.ExternalEndpoints[externalEndpoint]
+	return ok
+}
+
+func (entry TCPRoutableEndpoints) copy() TCPRoutableEndpoints {
+	clone := TCPRoutableEndpoints{
+		ExternalEndpoints: map[ExternalEndpointInfo]struct{}{},
+		Endpoints:         map[EndpointKey]Endpoint{},
+		LogGuid:           entry.LogGuid,
+		ModificationTag:   entry.ModificationTag,
+	}
+
+	for k, v := range entry.ExternalEndpoints {
+		clone.ExternalEndpoints[k] = v
+	}
+
+	for k, v := range entry.Endpoints {
+		clone.Endpoints[k] = v
+	}
+
+	return clone
+}
+
+func (entry TCPRoutableEndpoints) externalEndpoints() []ExternalEndpointInfo {
+	externalEndpoints := make([]ExternalEndpointInfo, 0, len(entry.ExternalEndpoints))
+	for externalEndpoint := range entry.ExternalEndpoints {
+		externalEndpoints = append(externalEndpoints, externalEndpoint)
+	}
+	return externalEndpoints
+}
+
+func externalEndpointsAsMap(externalEndpoints []ExternalEndpointInfo) map[ExternalEndpointInfo]struct{} {
+	externalEndpointsMap := map[ExternalEndpointInfo]struct{}{}
+	for _, externalEndpoint := range externalEndpoints {
+		externalEndpointsMap[externalEndpoint] = struct{}{}
+	}
+	return externalEndpointsMap
+}
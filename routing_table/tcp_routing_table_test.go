@@ -0,0 +1,213 @@
+package routing_table_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPRoutingTable", func() {
+	var (
+		table          routing_table.TCPRoutingTable
+		messagesToEmit routing_table.TCPMessagesToEmit
+	)
+
+	key := routing_table.RoutingKey{ProcessGuid: "some-process-guid", ContainerPort: 5222}
+
+	externalEndpoint1 := routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61000}
+	externalEndpoint2 := routing_table.ExternalEndpointInfo{RouterGroupGuid: "router-group-guid", Port: 61001}
+
+	domain := "domain"
+
+	currentTag := &models.ModificationTag{Epoch: "abc", Index: 1}
+	newerTag := &models.ModificationTag{Epoch: "def", Index: 0}
+
+	endpoint1 := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Domain: domain, Port: 11, ContainerPort: 5222, ModificationTag: currentTag}
+	endpoint2 := routing_table.Endpoint{InstanceGuid: "ig-2", Host: "2.2.2.2", Domain: domain, Port: 22, ContainerPort: 5222, ModificationTag: currentTag}
+
+	logGuid := "some-log-guid"
+
+	domains := models.NewDomainSet([]string{domain})
+	noFreshDomains := models.NewDomainSet([]string{})
+
+	BeforeEach(func() {
+		table = routing_table.NewTCPTable(lagertest.NewTestLogger("test-route-emitter"))
+	})
+
+	Describe("Swap", func() {
+		Context("when a new routing key arrives with both routes and endpoints", func() {
+			BeforeEach(func() {
+				tempTable := routing_table.NewTempTCPTable(
+					routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{externalEndpoint1}, LogGuid: logGuid}},
+					routing_table.EndpointsByRoutingKey{key: {endpoint1, endpoint2}},
+				)
+
+				messagesToEmit = table.Swap(tempTable, domains)
+			})
+
+			It("emits registrations for each pairing", func() {
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+					routing_table.TCPMappingMessageFor(endpoint2, externalEndpoint1),
+				))
+				Expect(messagesToEmit.UnregistrationMessages).To(BeEmpty())
+			})
+
+			It("counts the external ports as routes", func() {
+				Expect(table.RouteCount()).To(Equal(1))
+			})
+
+			Context("when an external port disappears and the domain is fresh", func() {
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTCPTable(
+						routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{externalEndpoint2}, LogGuid: logGuid}},
+						routing_table.EndpointsByRoutingKey{key: {endpoint1, endpoint2}},
+					)
+
+					messagesToEmit = table.Swap(tempTable, domains)
+				})
+
+				It("registers the new mappings and unregisters the old ones", func() {
+					Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+						routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint2),
+						routing_table.TCPMappingMessageFor(endpoint2, externalEndpoint2),
+					))
+					Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(
+						routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+						routing_table.TCPMappingMessageFor(endpoint2, externalEndpoint1),
+					))
+				})
+			})
+
+			Context("when an external port disappears and the domain is not fresh", func() {
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTCPTable(
+						routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{externalEndpoint2}, LogGuid: logGuid}},
+						routing_table.EndpointsByRoutingKey{key: {endpoint1}},
+					)
+
+					messagesToEmit = table.Swap(tempTable, noFreshDomains)
+				})
+
+				It("keeps registering the old mappings and does not unregister anything", func() {
+					Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+						routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+						routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint2),
+					))
+					Expect(messagesToEmit.UnregistrationMessages).To(BeEmpty())
+				})
+			})
+
+			Context("when the routing key disappears and the domain is fresh", func() {
+				BeforeEach(func() {
+					tempTable := routing_table.NewTempTCPTable(
+						routing_table.TCPRoutesByRoutingKey{},
+						routing_table.EndpointsByRoutingKey{},
+					)
+
+					messagesToEmit = table.Swap(tempTable, domains)
+				})
+
+				It("unregisters all the mappings", func() {
+					Expect(messagesToEmit.RegistrationMessages).To(BeEmpty())
+					Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(
+						routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+						routing_table.TCPMappingMessageFor(endpoint2, externalEndpoint1),
+					))
+				})
+			})
+		})
+	})
+
+	Describe("ForgetProcessGuids", func() {
+		BeforeEach(func() {
+			tempTable := routing_table.NewTempTCPTable(
+				routing_table.TCPRoutesByRoutingKey{key: routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{externalEndpoint1}, LogGuid: logGuid}},
+				routing_table.EndpointsByRoutingKey{key: {endpoint1}},
+			)
+			table.Swap(tempTable, domains)
+
+			table.ForgetProcessGuids(func(processGuid string) bool {
+				return processGuid == key.ProcessGuid
+			})
+		})
+
+		It("does not unregister the mappings of the matching process guids on the next swap", func() {
+			tempTable := routing_table.NewTempTCPTable(
+				routing_table.TCPRoutesByRoutingKey{},
+				routing_table.EndpointsByRoutingKey{},
+			)
+
+			messagesToEmit = table.Swap(tempTable, domains)
+			Expect(messagesToEmit.UnregistrationMessages).To(BeEmpty())
+			Expect(table.RouteCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Processing deltas", func() {
+		Context("when the table is empty", func() {
+			It("does not emit when only endpoints are added", func() {
+				messagesToEmit = table.AddEndpoint(key, endpoint1)
+				Expect(messagesToEmit).To(BeZero())
+			})
+
+			It("does not emit when only routes are set", func() {
+				messagesToEmit = table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{externalEndpoint1}, LogGuid: logGuid})
+				Expect(messagesToEmit).To(BeZero())
+			})
+		})
+
+		Context("when the table has routes and an endpoint", func() {
+			BeforeEach(func() {
+				table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{externalEndpoint1}, LogGuid: logGuid, ModificationTag: currentTag})
+				table.AddEndpoint(key, endpoint1)
+			})
+
+			It("registers a new endpoint", func() {
+				messagesToEmit = table.AddEndpoint(key, endpoint2)
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					routing_table.TCPMappingMessageFor(endpoint2, externalEndpoint1),
+				))
+				Expect(messagesToEmit.UnregistrationMessages).To(BeEmpty())
+			})
+
+			It("unregisters a removed endpoint", func() {
+				messagesToEmit = table.RemoveEndpoint(key, endpoint1)
+				Expect(messagesToEmit.RegistrationMessages).To(BeEmpty())
+				Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(
+					routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+				))
+			})
+
+			It("re-registers and unregisters when the external ports change", func() {
+				messagesToEmit = table.SetRoutes(key, routing_table.TCPRoutes{ExternalEndpoints: []routing_table.ExternalEndpointInfo{externalEndpoint2}, LogGuid: logGuid, ModificationTag: newerTag})
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint2),
+				))
+				Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(
+					routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+				))
+			})
+
+			It("unregisters everything when the routes are removed", func() {
+				messagesToEmit = table.RemoveRoutes(key, newerTag)
+				Expect(messagesToEmit.RegistrationMessages).To(BeEmpty())
+				Expect(messagesToEmit.UnregistrationMessages).To(ConsistOf(
+					routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+				))
+			})
+
+			It("emits every mapping when asked for the messages to emit", func() {
+				table.AddEndpoint(key, endpoint2)
+				messagesToEmit = table.MessagesToEmit()
+				Expect(messagesToEmit.RegistrationMessages).To(ConsistOf(
+					routing_table.TCPMappingMessageFor(endpoint1, externalEndpoint1),
+					routing_table.TCPMappingMessageFor(endpoint2, externalEndpoint1),
+				))
+			})
+		})
+	})
+})
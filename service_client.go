@@ -1,37 +1,74 @@
 package route_emitter
 
 import (
+	"path"
 	"time"
 
 	"github.com/cloudfoundry-incubator/consuladapter"
 	"github.com/cloudfoundry-incubator/locket"
+	"github.com/cloudfoundry-incubator/route-emitter/lock"
+	"github.com/cloudfoundry-incubator/route-emitter/shard"
 	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
 	"github.com/tedsuo/ifrit"
 )
 
-const RouteEmitterLockSchemaKey = "route_emitter_lock"
+const (
+	RouteEmitterLockSchemaKey  = "route_emitter_lock"
+	RouteEmitterShardSchemaKey = "route_emitter_shards"
+)
 
 func RouteEmitterLockSchemaPath() string {
 	return locket.LockSchemaPath(RouteEmitterLockSchemaKey)
 }
 
+func RouteEmitterShardSchemaRoot() string {
+	return locket.LockSchemaPath(RouteEmitterShardSchemaKey)
+}
+
+func RouteEmitterShardSchemaPath(emitterID string) string {
+	return path.Join(RouteEmitterShardSchemaRoot(), emitterID)
+}
+
 type ServiceClient interface {
+	RouteEmitterLock() lock.Lock
 	NewRouteEmitterLockRunner(logger lager.Logger, bulkerID string, retryInterval, lockTTL time.Duration) ifrit.Runner
+	NewRouteEmitterShardPresenceRunner(logger lager.Logger, emitterID string, retryInterval, presenceTTL time.Duration) ifrit.Runner
+	RouteEmitterShardMembers() shard.MemberLister
 }
 
 type serviceClient struct {
 	consulClient consuladapter.Client
 	clock        clock.Clock
+	lock         lock.Lock
 }
 
 func NewServiceClient(consulClient consuladapter.Client, clock clock.Clock) ServiceClient {
+	return NewServiceClientWithLock(consulClient, clock, lock.NewConsulLock(consulClient, RouteEmitterLockSchemaPath(), clock))
+}
+
+// NewServiceClientWithLock returns a service client that elects the active
+// emitter with the given lock instead of a consul lock.
+func NewServiceClientWithLock(consulClient consuladapter.Client, clock clock.Clock, lock lock.Lock) ServiceClient {
 	return serviceClient{
 		consulClient: consulClient,
 		clock:        clock,
+		lock:         lock,
 	}
 }
 
+func (c serviceClient) RouteEmitterLock() lock.Lock {
+	return c.lock
+}
+
 func (c serviceClient) NewRouteEmitterLockRunner(logger lager.Logger, emitterID string, retryInterval, lockTTL time.Duration) ifrit.Runner {
-	return locket.NewLock(logger, c.consulClient, RouteEmitterLockSchemaPath(), []byte(emitterID), c.clock, retryInterval, lockTTL)
+	return c.lock.NewRunner(logger, emitterID, retryInterval, lockTTL)
+}
+
+func (c serviceClient) NewRouteEmitterShardPresenceRunner(logger lager.Logger, emitterID string, retryInterval, presenceTTL time.Duration) ifrit.Runner {
+	return locket.NewPresence(logger, c.consulClient, RouteEmitterShardSchemaPath(emitterID), []byte(emitterID), c.clock, retryInterval, presenceTTL)
+}
+
+func (c serviceClient) RouteEmitterShardMembers() shard.MemberLister {
+	return shard.NewConsulMemberLister(c.consulClient, RouteEmitterShardSchemaRoot()+"/")
 }
@@ -0,0 +1,31 @@
+package shard
+
+import "github.com/cloudfoundry-incubator/consuladapter"
+
+type consulMemberLister struct {
+	consulClient consuladapter.Client
+	prefix       string
+}
+
+// NewConsulMemberLister lists the emitters whose presence keys are held under
+// prefix; each key's value is the emitter's id.
+func NewConsulMemberLister(consulClient consuladapter.Client, prefix string) MemberLister {
+	return &consulMemberLister{
+		consulClient: consulClient,
+		prefix:       prefix,
+	}
+}
+
+func (l *consulMemberLister) ListMembers() ([]string, error) {
+	pairs, _, err := l.consulClient.KV().List(l.prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		members = append(members, string(pair.Value))
+	}
+
+	return members, nil
+}
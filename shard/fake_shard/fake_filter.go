@@ -0,0 +1,83 @@
+// This file was generated by counterfeiter
+package fake_shard
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/shard"
+)
+
+type FakeFilter struct {
+	OwnsStub        func(processGuid string) bool
+	ownsMutex       sync.RWMutex
+	ownsArgsForCall []struct {
+		processGuid string
+	}
+	ownsReturns struct {
+		result1 bool
+	}
+	ChangedStub        func() <-chan struct{}
+	changedMutex       sync.RWMutex
+	changedArgsForCall []struct{}
+	changedReturns     struct {
+		result1 <-chan struct{}
+	}
+}
+
+func (fake *FakeFilter) Owns(processGuid string) bool {
+	fake.ownsMutex.Lock()
+	fake.ownsArgsForCall = append(fake.ownsArgsForCall, struct {
+		processGuid string
+	}{processGuid})
+	fake.ownsMutex.Unlock()
+	if fake.OwnsStub != nil {
+		return fake.OwnsStub(processGuid)
+	} else {
+		return fake.ownsReturns.result1
+	}
+}
+
+func (fake *FakeFilter) OwnsCallCount() int {
+	fake.ownsMutex.RLock()
+	defer fake.ownsMutex.RUnlock()
+	return len(fake.ownsArgsForCall)
+}
+
+func (fake *FakeFilter) OwnsArgsForCall(i int) string {
+	fake.ownsMutex.RLock()
+	defer fake.ownsMutex.RUnlock()
+	return fake.ownsArgsForCall[i].processGuid
+}
+
+func (fake *FakeFilter) OwnsReturns(result1 bool) {
+	fake.OwnsStub = nil
+	fake.ownsReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeFilter) Changed() <-chan struct{} {
+	fake.changedMutex.Lock()
+	fake.changedArgsForCall = append(fake.changedArgsForCall, struct{}{})
+	fake.changedMutex.Unlock()
+	if fake.ChangedStub != nil {
+		return fake.ChangedStub()
+	} else {
+		return fake.changedReturns.result1
+	}
+}
+
+func (fake *FakeFilter) ChangedCallCount() int {
+	fake.changedMutex.RLock()
+	defer fake.changedMutex.RUnlock()
+	return len(fake.changedArgsForCall)
+}
+
+func (fake *FakeFilter) ChangedReturns(result1 <-chan struct{}) {
+	fake.ChangedStub = nil
+	fake.changedReturns = struct {
+		result1 <-chan struct{}
+	}{result1}
+}
+
+var _ shard.Filter = new(FakeFilter)
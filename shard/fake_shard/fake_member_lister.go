@@ -0,0 +1,45 @@
+// This file was generated by counterfeiter
+package fake_shard
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/route-emitter/shard"
+)
+
+type FakeMemberLister struct {
+	ListMembersStub        func() ([]string, error)
+	listMembersMutex       sync.RWMutex
+	listMembersArgsForCall []struct{}
+	listMembersReturns     struct {
+		result1 []string
+		result2 error
+	}
+}
+
+func (fake *FakeMemberLister) ListMembers() ([]string, error) {
+	fake.listMembersMutex.Lock()
+	fake.listMembersArgsForCall = append(fake.listMembersArgsForCall, struct{}{})
+	fake.listMembersMutex.Unlock()
+	if fake.ListMembersStub != nil {
+		return fake.ListMembersStub()
+	} else {
+		return fake.listMembersReturns.result1, fake.listMembersReturns.result2
+	}
+}
+
+func (fake *FakeMemberLister) ListMembersCallCount() int {
+	fake.listMembersMutex.RLock()
+	defer fake.listMembersMutex.RUnlock()
+	return len(fake.listMembersArgsForCall)
+}
+
+func (fake *FakeMemberLister) ListMembersReturns(result1 []string, result2 error) {
+	fake.ListMembersStub = nil
+	fake.listMembersReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+var _ shard.MemberLister = new(FakeMemberLister)
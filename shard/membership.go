@@ -0,0 +1,130 @@
+package shard
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+var shardMembers = metric.Metric("RouteEmitterShardMembers")
+
+//go:generate counterfeiter -o fake_shard/fake_filter.go . Filter
+
+// Filter decides which process guids an emitter is responsible for.
+// Changed is signalled whenever that set may have changed, after which the
+// emitter must resync to pick up or drop routes.
+type Filter interface {
+	Owns(processGuid string) bool
+	Changed() <-chan struct{}
+}
+
+//go:generate counterfeiter -o fake_shard/fake_member_lister.go . MemberLister
+
+// MemberLister returns the ids of the emitters currently sharing the routes.
+type MemberLister interface {
+	ListMembers() ([]string, error)
+}
+
+// Membership keeps a consistent-hash ring of the live emitters up to date and
+// reports which process guids this emitter owns.
+type Membership struct {
+	id           string
+	lister       MemberLister
+	pollInterval time.Duration
+	clock        clock.Clock
+	logger       lager.Logger
+
+	lock sync.RWMutex
+	ring *Ring
+
+	changed chan struct{}
+}
+
+func NewMembership(id string, lister MemberLister, pollInterval time.Duration, clock clock.Clock, logger lager.Logger) *Membership {
+	return &Membership{
+		id:           id,
+		lister:       lister,
+		pollInterval: pollInterval,
+		clock:        clock,
+		logger:       logger.Session("shard-membership", lager.Data{"id": id}),
+		ring:         NewRing([]string{id}, DefaultReplicas),
+		changed:      make(chan struct{}, 1),
+	}
+}
+
+func (m *Membership) Owns(processGuid string) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.ring.Owner(processGuid) == m.id
+}
+
+func (m *Membership) Changed() <-chan struct{} {
+	return m.changed
+}
+
+func (m *Membership) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	m.logger.Info("starting")
+	defer m.logger.Info("finished")
+
+	m.refresh()
+
+	ticker := m.clock.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	close(ready)
+	m.logger.Info("started")
+
+	for {
+		select {
+		case <-ticker.C():
+			m.refresh()
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func (m *Membership) refresh() {
+	listed, err := m.lister.ListMembers()
+	if err != nil {
+		// keep the current ring rather than claiming or dropping everything
+		// while consul is unavailable
+		m.logger.Error("failed-listing-members", err)
+		return
+	}
+
+	members := []string{m.id}
+	for _, member := range listed {
+		if member != m.id {
+			members = append(members, member)
+		}
+	}
+	sort.Strings(members)
+
+	m.lock.Lock()
+	if reflect.DeepEqual(members, m.ring.Members()) {
+		m.lock.Unlock()
+		return
+	}
+	m.ring = NewRing(members, DefaultReplicas)
+	m.lock.Unlock()
+
+	m.logger.Info("members-changed", lager.Data{"members": members})
+
+	err = shardMembers.Send(len(members))
+	if err != nil {
+		m.logger.Error("failed-to-send-shard-members-metric", err)
+	}
+
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,135 @@
+package shard_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/shard"
+	"github.com/cloudfoundry-incubator/route-emitter/shard/fake_shard"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Membership", func() {
+	const pollInterval = 5 * time.Second
+
+	var (
+		lister           *fake_shard.FakeMemberLister
+		clock            *fakeclock.FakeClock
+		fakeMetricSender *fake_metrics_sender.FakeMetricSender
+		membership       *shard.Membership
+		process          ifrit.Process
+		processGuids     []string
+	)
+
+	ownedBy := func(ring *shard.Ring, member string) []string {
+		owned := []string{}
+		for _, processGuid := range processGuids {
+			if ring.Owner(processGuid) == member {
+				owned = append(owned, processGuid)
+			}
+		}
+		return owned
+	}
+
+	owned := func() []string {
+		owned := []string{}
+		for _, processGuid := range processGuids {
+			if membership.Owns(processGuid) {
+				owned = append(owned, processGuid)
+			}
+		}
+		return owned
+	}
+
+	BeforeEach(func() {
+		processGuids = make([]string, 100)
+		for i := range processGuids {
+			processGuids[i] = fmt.Sprintf("process-guid-%d", i)
+		}
+
+		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+		metrics.Initialize(fakeMetricSender, nil)
+
+		lister = new(fake_shard.FakeMemberLister)
+		lister.ListMembersReturns([]string{"emitter-a", "emitter-b"}, nil)
+		clock = fakeclock.NewFakeClock(time.Now())
+		membership = shard.NewMembership("emitter-a", lister, pollInterval, clock, lagertest.NewTestLogger("test"))
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(membership)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("owns its share of the process guids before becoming ready", func() {
+		ring := shard.NewRing([]string{"emitter-a", "emitter-b"}, shard.DefaultReplicas)
+		Expect(owned()).To(Equal(ownedBy(ring, "emitter-a")))
+		Expect(fakeMetricSender.GetValue("RouteEmitterShardMembers").Value).To(BeEquivalentTo(2))
+		Eventually(membership.Changed()).Should(Receive())
+	})
+
+	Context("when a member goes away", func() {
+		JustBeforeEach(func() {
+			Eventually(membership.Changed()).Should(Receive())
+			lister.ListMembersReturns([]string{"emitter-a"}, nil)
+			clock.Increment(pollInterval)
+		})
+
+		It("takes over its process guids and signals the change", func() {
+			Eventually(membership.Changed()).Should(Receive())
+			Expect(owned()).To(Equal(processGuids))
+		})
+	})
+
+	Context("when the members have not changed", func() {
+		JustBeforeEach(func() {
+			Eventually(membership.Changed()).Should(Receive())
+			clock.Increment(pollInterval)
+		})
+
+		It("does not signal a change", func() {
+			Eventually(lister.ListMembersCallCount).Should(Equal(2))
+			Consistently(membership.Changed()).ShouldNot(Receive())
+		})
+	})
+
+	Context("when it is not listed yet", func() {
+		BeforeEach(func() {
+			lister.ListMembersReturns([]string{"emitter-b"}, nil)
+		})
+
+		It("still includes itself", func() {
+			ring := shard.NewRing([]string{"emitter-a", "emitter-b"}, shard.DefaultReplicas)
+			Expect(owned()).To(Equal(ownedBy(ring, "emitter-a")))
+		})
+	})
+
+	Context("when listing the members fails", func() {
+		JustBeforeEach(func() {
+			Eventually(membership.Changed()).Should(Receive())
+			lister.ListMembersReturns(nil, errors.New("consul down"))
+			clock.Increment(pollInterval)
+		})
+
+		It("keeps the current shard", func() {
+			Eventually(lister.ListMembersCallCount).Should(Equal(2))
+			Consistently(membership.Changed()).ShouldNot(Receive())
+
+			ring := shard.NewRing([]string{"emitter-a", "emitter-b"}, shard.DefaultReplicas)
+			Expect(owned()).To(Equal(ownedBy(ring, "emitter-a")))
+		})
+	})
+})
@@ -0,0 +1,79 @@
+package shard
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is the number of points each member is given on the ring;
+// more points spread process guids more evenly between members.
+const DefaultReplicas = 128
+
+// Ring assigns keys to members by consistent hashing, so that adding or
+// removing a member only moves the keys that member gains or loses.
+type Ring struct {
+	members []string
+	points  []uint32
+	owners  map[uint32]string
+}
+
+func NewRing(members []string, replicas int) *Ring {
+	ring := &Ring{
+		members: make([]string, len(members)),
+		points:  make([]uint32, 0, len(members)*replicas),
+		owners:  make(map[uint32]string, len(members)*replicas),
+	}
+
+	copy(ring.members, members)
+	sort.Strings(ring.members)
+
+	for _, member := range ring.members {
+		for i := 0; i < replicas; i++ {
+			point := hash(member + "#" + strconv.Itoa(i))
+			if owner, taken := ring.owners[point]; taken && owner < member {
+				continue
+			}
+			if _, taken := ring.owners[point]; !taken {
+				ring.points = append(ring.points, point)
+			}
+			ring.owners[point] = member
+		}
+	}
+
+	sort.Sort(uint32s(ring.points))
+
+	return ring
+}
+
+// Members returns the sorted members of the ring.
+func (r *Ring) Members() []string {
+	return r.members
+}
+
+// Owner returns the member responsible for key, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.owners[r.points[i]]
+}
+
+func hash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+type uint32s []uint32
+
+func (s uint32s) Len() int           { return len(s) }
+func (s uint32s) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint32s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
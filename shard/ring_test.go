@@ -0,0 +1,66 @@
+package shard_test
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/route-emitter/shard"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ring", func() {
+	var keys []string
+
+	BeforeEach(func() {
+		keys = make([]string, 10000)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("process-guid-%d", i)
+		}
+	})
+
+	It("has no owner when empty", func() {
+		ring := shard.NewRing(nil, shard.DefaultReplicas)
+		Expect(ring.Owner("process-guid")).To(BeEmpty())
+	})
+
+	It("assigns every key to the only member", func() {
+		ring := shard.NewRing([]string{"a"}, shard.DefaultReplicas)
+		for _, key := range keys {
+			Expect(ring.Owner(key)).To(Equal("a"))
+		}
+	})
+
+	It("does not depend on the order of the members", func() {
+		ring1 := shard.NewRing([]string{"a", "b", "c"}, shard.DefaultReplicas)
+		ring2 := shard.NewRing([]string{"c", "a", "b"}, shard.DefaultReplicas)
+		for _, key := range keys {
+			Expect(ring1.Owner(key)).To(Equal(ring2.Owner(key)))
+		}
+	})
+
+	It("spreads keys roughly evenly between members", func() {
+		ring := shard.NewRing([]string{"a", "b", "c", "d"}, shard.DefaultReplicas)
+
+		counts := map[string]int{}
+		for _, key := range keys {
+			counts[ring.Owner(key)]++
+		}
+
+		Expect(counts).To(HaveLen(4))
+		for _, count := range counts {
+			Expect(count).To(BeNumerically("~", len(keys)/4, len(keys)/10))
+		}
+	})
+
+	It("only moves the keys of a member that leaves", func() {
+		before := shard.NewRing([]string{"a", "b", "c", "d"}, shard.DefaultReplicas)
+		after := shard.NewRing([]string{"a", "b", "d"}, shard.DefaultReplicas)
+
+		for _, key := range keys {
+			if before.Owner(key) != "c" {
+				Expect(after.Owner(key)).To(Equal(before.Owner(key)))
+			}
+		}
+	})
+})
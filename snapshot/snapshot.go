@@ -0,0 +1,227 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+)
+
+const Version = 1
+
+var ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+
+type Snapshot struct {
+	Version   int     `json:"version"`
+	CreatedAt int64   `json:"created_at"`
+	Entries   []Entry `json:"entries"`
+}
+
+type Entry struct {
+	ProcessGuid     string            `json:"process_guid"`
+	ContainerPort   uint32            `json:"container_port"`
+	Hostnames       []string          `json:"hostnames"`
+	LogGuid         string            `json:"log_guid,omitempty"`
+	RouteServiceUrl string            `json:"route_service_url,omitempty"`
+	Weight          uint32            `json:"weight,omitempty"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	Endpoints       []Endpoint        `json:"endpoints"`
+
+	ModificationTag *models.ModificationTag `json:"modification_tag,omitempty"`
+}
+
+type Endpoint struct {
+	InstanceGuid     string                  `json:"instance_guid"`
+	Index            int32                   `json:"index"`
+	CellId           string                  `json:"cell_id,omitempty"`
+	AvailabilityZone string                  `json:"availability_zone,omitempty"`
+	Host             string                  `json:"host"`
+	Port             uint32                  `json:"port"`
+	ContainerPort    uint32                  `json:"container_port"`
+	Domain           string                  `json:"domain"`
+	Evacuating       bool                    `json:"evacuating"`
+	ModificationTag  *models.ModificationTag `json:"modification_tag,omitempty"`
+}
+
+func New(entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints, createdAt time.Time) Snapshot {
+	snapshot := Snapshot{
+		Version:   Version,
+		CreatedAt: createdAt.UnixNano(),
+		Entries:   make([]Entry, 0, len(entries)),
+	}
+
+	for key, entry := range entries {
+		hostnames := make([]string, 0, len(entry.Hostnames))
+		for hostname := range entry.Hostnames {
+			hostnames = append(hostnames, hostname)
+		}
+
+		endpoints := make([]Endpoint, 0, len(entry.Endpoints))
+		for _, endpoint := range entry.Endpoints {
+			endpoints = append(endpoints, Endpoint{
+				InstanceGuid:     endpoint.InstanceGuid,
+				Index:            endpoint.Index,
+				CellId:           endpoint.CellId,
+				AvailabilityZone: endpoint.AvailabilityZone,
+				Host:             endpoint.Host,
+				Port:             endpoint.Port,
+				ContainerPort:    endpoint.ContainerPort,
+				Domain:           endpoint.Domain,
+				Evacuating:       endpoint.Evacuating,
+				ModificationTag:  endpoint.ModificationTag,
+			})
+		}
+
+		snapshot.Entries = append(snapshot.Entries, Entry{
+			ProcessGuid:     key.ProcessGuid,
+			ContainerPort:   key.ContainerPort,
+			Hostnames:       hostnames,
+			LogGuid:         entry.LogGuid,
+			RouteServiceUrl: entry.RouteServiceUrl,
+			Weight:          entry.Weight,
+			Tags:            entry.Tags,
+			Endpoints:       endpoints,
+			ModificationTag: entry.ModificationTag,
+		})
+	}
+
+	return snapshot
+}
+
+// RoutingTable returns a temporary table holding the snapshot contents,
+// suitable for swapping into the live table. The policies may have changed
+// since the snapshot was taken, so its routes are checked as those of desired
+// LRPs are: URIs the hostname policy rejects are left out, as are all the
+// URIs of an entry whose route service is rejected, and every rejection is
+// returned. Nil policies allow everything.
+func (s Snapshot) RoutingTable(
+	hostnames routing_table.HostnamePolicy,
+	routeServices routing_table.RouteServicePolicy,
+) (routing_table.RoutingTable, []routing_table.RouteRejection) {
+	routes := routing_table.RoutesByRoutingKey{}
+	endpoints := routing_table.EndpointsByRoutingKey{}
+	rejections := []routing_table.RouteRejection{}
+
+	for _, entry := range s.Entries {
+		key := routing_table.RoutingKey{ProcessGuid: entry.ProcessGuid, ContainerPort: entry.ContainerPort}
+
+		uris, rejected := checkRoutes(entry, hostnames, routeServices)
+		rejections = append(rejections, rejected...)
+		if len(uris) > 0 || len(entry.Hostnames) == 0 {
+			routes[key] = routing_table.Routes{
+				Hostnames:       uris,
+				LogGuid:         entry.LogGuid,
+				RouteServiceUrl: entry.RouteServiceUrl,
+				Weight:          entry.Weight,
+				Tags:            entry.Tags,
+				ModificationTag: entry.ModificationTag,
+			}
+		}
+
+		for _, endpoint := range entry.Endpoints {
+			endpoints[key] = append(endpoints[key], routing_table.Endpoint{
+				InstanceGuid:     endpoint.InstanceGuid,
+				Index:            endpoint.Index,
+				CellId:           endpoint.CellId,
+				AvailabilityZone: endpoint.AvailabilityZone,
+				Host:             endpoint.Host,
+				Port:             endpoint.Port,
+				ContainerPort:    endpoint.ContainerPort,
+				Domain:           endpoint.Domain,
+				Evacuating:       endpoint.Evacuating,
+				ModificationTag:  endpoint.ModificationTag,
+			})
+		}
+	}
+
+	return routing_table.NewTempTable(routes, endpoints), rejections
+}
+
+func checkRoutes(
+	entry Entry,
+	hostnames routing_table.HostnamePolicy,
+	routeServices routing_table.RouteServicePolicy,
+) ([]string, []routing_table.RouteRejection) {
+	rejections := []routing_table.RouteRejection{}
+
+	if routeServices != nil {
+		if err := routeServices.Check(entry.RouteServiceUrl); err != nil {
+			for _, uri := range entry.Hostnames {
+				rejections = append(rejections, routing_table.RouteRejection{
+					ProcessGuid:     entry.ProcessGuid,
+					URI:             uri,
+					RouteServiceUrl: entry.RouteServiceUrl,
+					Err:             err,
+				})
+			}
+			return nil, rejections
+		}
+	}
+
+	if hostnames == nil {
+		return entry.Hostnames, rejections
+	}
+
+	uris := []string{}
+	for _, uri := range entry.Hostnames {
+		if err := hostnames.Check(entry.ProcessGuid, uri); err != nil {
+			rejections = append(rejections, routing_table.RouteRejection{ProcessGuid: entry.ProcessGuid, URI: uri, Err: err})
+			continue
+		}
+		uris = append(uris, uri)
+	}
+	return uris, rejections
+}
+
+// Write atomically replaces the file at path with the snapshot so that a
+// crash mid-write never leaves a truncated snapshot behind.
+func Write(path string, snapshot Snapshot) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(payload)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func Read(path string) (Snapshot, error) {
+	snapshot := Snapshot{}
+
+	payload, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = json.Unmarshal(payload, &snapshot)
+	if err != nil {
+		return snapshot, err
+	}
+
+	if snapshot.Version != Version {
+		return Snapshot{}, ErrUnsupportedVersion
+	}
+
+	return snapshot, nil
+}
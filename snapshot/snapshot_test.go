@@ -0,0 +1,156 @@
+package snapshot_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/snapshot"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		tmpDir string
+		path   string
+		table  routing_table.RoutingTable
+	)
+
+	key := routing_table.RoutingKey{ProcessGuid: "process-guid", ContainerPort: 8080}
+	endpoint := routing_table.Endpoint{
+		InstanceGuid:     "instance-guid",
+		Index:            2,
+		CellId:           "cell-id",
+		AvailabilityZone: "z1",
+		Host:             "1.1.1.1",
+		Port:             61000,
+		ContainerPort:    8080,
+		Domain:           "domain",
+		ModificationTag:  &models.ModificationTag{Epoch: "abc", Index: 1},
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "snapshot")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(tmpDir, "routes.json")
+
+		table = routing_table.NewTable(lagertest.NewTestLogger("test"), routing_table.AllowHostnameConflicts)
+		table.SetRoutes(key, routing_table.Routes{
+			Hostnames:       []string{"foo.example.com"},
+			LogGuid:         "log-guid",
+			RouteServiceUrl: "https://rs.example.com",
+			Weight:          3,
+			Tags:            map[string]string{"process_guid": "process-guid"},
+			ModificationTag: &models.ModificationTag{Epoch: "def", Index: 4},
+		})
+		table.AddEndpoint(key, endpoint)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("round-trips the table contents through a file", func() {
+		Expect(snapshot.Write(path, snapshot.New(table.Entries(), time.Now()))).To(Succeed())
+
+		restored, err := snapshot.Read(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.Version).To(Equal(snapshot.Version))
+
+		newTable := routing_table.NewTable(lagertest.NewTestLogger("test"), routing_table.AllowHostnameConflicts)
+		restoredTable, rejections := restored.RoutingTable(nil, nil)
+		Expect(rejections).To(BeEmpty())
+		messagesToEmit := newTable.Swap(restoredTable, models.DomainSet{})
+
+		Expect(newTable.Entries()).To(Equal(table.Entries()))
+		Expect(messagesToEmit.RegistrationMessages).To(HaveLen(1))
+	})
+
+	Describe("restoring under policies", func() {
+		var restored snapshot.Snapshot
+
+		BeforeEach(func() {
+			table.SetRoutes(key, routing_table.Routes{
+				Hostnames:       []string{"foo.example.com", "bar.internal"},
+				RouteServiceUrl: "https://rs.example.com",
+				ModificationTag: &models.ModificationTag{Epoch: "def", Index: 5},
+			})
+			restored = snapshot.New(table.Entries(), time.Now())
+		})
+
+		It("leaves out the hostnames the hostname policy rejects", func() {
+			hostnamePolicy, err := routing_table.NewHostnamePolicy(routing_table.HostnameRules{Denied: []string{"*.internal"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			restoredTable, rejections := restored.RoutingTable(hostnamePolicy, nil)
+			Expect(rejections).To(Equal([]routing_table.RouteRejection{
+				{ProcessGuid: "process-guid", URI: "bar.internal", Err: routing_table.ErrHostnameDenied},
+			}))
+			Expect(restoredTable.Entries()[key].Hostnames).To(Equal(map[string]struct{}{"foo.example.com": {}}))
+		})
+
+		It("leaves out every route of an entry whose route service is rejected, keeping its endpoints", func() {
+			routeServicePolicy, err := routing_table.NewRouteServicePolicy(routing_table.RouteServiceRules{
+				DeniedNetworks: []string{},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			restored.Entries[0].RouteServiceUrl = "http://rs.example.com"
+
+			restoredTable, rejections := restored.RoutingTable(nil, routeServicePolicy)
+			Expect(rejections).To(HaveLen(2))
+			Expect(rejections[0].RouteServiceUrl).To(Equal("http://rs.example.com"))
+			Expect(restoredTable.Entries()[key].Hostnames).To(BeEmpty())
+			Expect(restoredTable.Entries()[key].Endpoints).To(HaveLen(1))
+		})
+
+		It("keeps the modification tag of the routes", func() {
+			restoredTable, _ := restored.RoutingTable(nil, nil)
+			Expect(restoredTable.Entries()[key].ModificationTag).To(Equal(&models.ModificationTag{Epoch: "def", Index: 5}))
+		})
+	})
+
+	It("does not leave temporary files behind", func() {
+		Expect(snapshot.Write(path, snapshot.New(table.Entries(), time.Now()))).To(Succeed())
+
+		files, err := ioutil.ReadDir(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(files[0].Name()).To(Equal("routes.json"))
+	})
+
+	Context("when the snapshot file does not exist", func() {
+		It("returns an error that satisfies os.IsNotExist", func() {
+			_, err := snapshot.Read(path)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Context("when the snapshot has an unknown version", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(path, []byte(`{"version":999,"entries":[]}`), 0644)).To(Succeed())
+		})
+
+		It("refuses to load it", func() {
+			_, err := snapshot.Read(path)
+			Expect(err).To(Equal(snapshot.ErrUnsupportedVersion))
+		})
+	})
+
+	Context("when the snapshot is corrupt", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(path, []byte(`{"version":`), 0644)).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			_, err := snapshot.Read(path)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
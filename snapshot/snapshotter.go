@@ -0,0 +1,59 @@
+package snapshot
+
+import (
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+type Snapshotter struct {
+	path     string
+	interval time.Duration
+	table    routing_table.RoutingTable
+	clock    clock.Clock
+	logger   lager.Logger
+}
+
+func NewSnapshotter(path string, interval time.Duration, table routing_table.RoutingTable, clock clock.Clock, logger lager.Logger) *Snapshotter {
+	return &Snapshotter{
+		path:     path,
+		interval: interval,
+		table:    table,
+		clock:    clock,
+		logger:   logger.Session("snapshotter", lager.Data{"path": path}),
+	}
+}
+
+func (s *Snapshotter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	s.logger.Info("starting")
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	close(ready)
+	s.logger.Info("started")
+
+	for {
+		select {
+		case <-ticker.C():
+			s.snapshot()
+		case <-signals:
+			s.logger.Info("stopping")
+			s.snapshot()
+			return nil
+		}
+	}
+}
+
+func (s *Snapshotter) snapshot() {
+	entries := s.table.Entries()
+	err := Write(s.path, New(entries, s.clock.Now()))
+	if err != nil {
+		s.logger.Error("failed-to-write-snapshot", err)
+		return
+	}
+
+	s.logger.Debug("wrote-snapshot", lager.Data{"entries": len(entries)})
+}
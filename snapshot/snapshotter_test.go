@@ -0,0 +1,81 @@
+package snapshot_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/snapshot"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshotter", func() {
+	var (
+		tmpDir  string
+		path    string
+		table   *fake_routing_table.FakeRoutingTable
+		clock   *fakeclock.FakeClock
+		process ifrit.Process
+	)
+
+	key := routing_table.RoutingKey{ProcessGuid: "process-guid", ContainerPort: 8080}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "snapshotter")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(tmpDir, "routes.json")
+
+		table = new(fake_routing_table.FakeRoutingTable)
+		table.EntriesReturns(map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+			key: {Hostnames: map[string]struct{}{"foo.example.com": {}}},
+		})
+		clock = fakeclock.NewFakeClock(time.Now())
+
+		snapshotter := snapshot.NewSnapshotter(path, time.Minute, table, clock, lagertest.NewTestLogger("test"))
+		process = ifrit.Invoke(snapshotter)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		os.RemoveAll(tmpDir)
+	})
+
+	readSnapshot := func() (snapshot.Snapshot, error) {
+		return snapshot.Read(path)
+	}
+
+	It("writes a snapshot on every interval", func() {
+		Consistently(func() bool {
+			_, err := readSnapshot()
+			return os.IsNotExist(err)
+		}).Should(BeTrue())
+
+		clock.Increment(time.Minute)
+		Eventually(table.EntriesCallCount).Should(Equal(1))
+
+		Eventually(func() []snapshot.Entry {
+			restored, _ := readSnapshot()
+			return restored.Entries
+		}).Should(HaveLen(1))
+	})
+
+	It("writes a final snapshot when stopped", func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+
+		restored, err := readSnapshot()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.Entries).To(HaveLen(1))
+		Expect(restored.Entries[0].ProcessGuid).To(Equal("process-guid"))
+	})
+})
@@ -3,6 +3,7 @@ package syncer
 import (
 	"encoding/json"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/apcera/nats"
@@ -17,9 +18,15 @@ type Syncer struct {
 	natsClient   diegonats.NATSClient
 	clock        clock.Clock
 	syncInterval time.Duration
+	emitInterval time.Duration
 	events       Events
 	routerGreet  chan time.Duration
 
+	greetingObservers []func(routing_table.RouterGreetingMessage)
+
+	syncIntervalLock    sync.Mutex
+	syncIntervalChanged chan struct{}
+
 	logger lager.Logger
 }
 
@@ -39,13 +46,32 @@ func NewSyncer(
 			Emit: make(chan struct{}, 1),
 		},
 
-		routerGreet: make(chan time.Duration),
+		routerGreet:         make(chan time.Duration),
+		syncIntervalChanged: make(chan struct{}, 1),
 
 		logger: logger.Session("syncer"),
 	}
 }
 
+// NewSyncerWithEmitInterval returns a Syncer that does not greet the router
+// over NATS and instead emits routes on the given fixed interval. It is used
+// when routes are registered through a backend other than NATS.
+func NewSyncerWithEmitInterval(
+	clock clock.Clock,
+	syncInterval time.Duration,
+	emitInterval time.Duration,
+	logger lager.Logger,
+) *Syncer {
+	syncer := NewSyncer(clock, syncInterval, nil, logger)
+	syncer.emitInterval = emitInterval
+	return syncer
+}
+
 func (s *Syncer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if s.natsClient == nil {
+		return s.runWithEmitInterval(signals, ready)
+	}
+
 	s.logger.Info("starting")
 	replyUuid, err := uuid.NewV4()
 	if err != nil {
@@ -85,10 +111,22 @@ GREET_LOOP:
 	}
 	retryGreetingTicker.Stop()
 
+	return s.runLoop(signals, routerPruneInterval)
+}
+
+func (s *Syncer) runWithEmitInterval(signals <-chan os.Signal, ready chan<- struct{}) error {
+	s.logger.Info("starting", lager.Data{"emit-interval": s.emitInterval.String()})
+	close(ready)
+	s.logger.Info("started")
+
+	return s.runLoop(signals, s.emitInterval)
+}
+
+func (s *Syncer) runLoop(signals <-chan os.Signal, routerPruneInterval time.Duration) error {
 	s.sync()
 
 	//now keep emitting at the desired interval, syncing with etcd every syncInterval
-	syncTicker := s.clock.NewTicker(s.syncInterval)
+	syncTicker := s.clock.NewTicker(s.currentSyncInterval())
 	routerTicker := s.clock.NewTicker(routerPruneInterval)
 
 	for {
@@ -104,6 +142,11 @@ GREET_LOOP:
 		case <-syncTicker.C():
 			s.logger.Info("syncing")
 			s.sync()
+		case <-s.syncIntervalChanged:
+			syncInterval := s.currentSyncInterval()
+			s.logger.Info("received-new-sync-interval", lager.Data{"interval": syncInterval.String()})
+			syncTicker.Stop()
+			syncTicker = s.clock.NewTicker(syncInterval)
 		case <-signals:
 			s.logger.Info("stopping")
 			syncTicker.Stop()
@@ -119,6 +162,32 @@ func (s *Syncer) Events() Events {
 	return s.events
 }
 
+// ObserveRouterGreetings registers a function that is called with every
+// greeting received from a router. It must be called before Run.
+func (s *Syncer) ObserveRouterGreetings(observer func(routing_table.RouterGreetingMessage)) {
+	s.greetingObservers = append(s.greetingObservers, observer)
+}
+
+// SetSyncInterval changes how often a full sync is requested. It may be
+// called while the syncer is running.
+func (s *Syncer) SetSyncInterval(syncInterval time.Duration) {
+	s.syncIntervalLock.Lock()
+	s.syncInterval = syncInterval
+	s.syncIntervalLock.Unlock()
+
+	select {
+	case s.syncIntervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Syncer) currentSyncInterval() time.Duration {
+	s.syncIntervalLock.Lock()
+	defer s.syncIntervalLock.Unlock()
+
+	return s.syncInterval
+}
+
 func (s *Syncer) emit() {
 	select {
 	case s.events.Emit <- struct{}{}:
@@ -170,6 +239,10 @@ func (s *Syncer) handleRouterGreet(msg *nats.Msg) {
 		return
 	}
 
+	for _, observer := range s.greetingObservers {
+		observer(response)
+	}
+
 	greetInterval := response.MinimumRegisterInterval
 	s.routerGreet <- time.Duration(greetInterval) * time.Second
 }
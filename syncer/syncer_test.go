@@ -7,6 +7,7 @@ import (
 	"code.cloudfoundry.org/bbs/fake_bbs"
 	"code.cloudfoundry.org/bbs/models"
 	"github.com/apcera/nats"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	"github.com/cloudfoundry-incubator/route-emitter/syncer"
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
 	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
@@ -39,6 +40,9 @@ var _ = Describe("Syncer", func() {
 		clock        *fakeclock.FakeClock
 		clockStep    time.Duration
 		syncInterval time.Duration
+		emitInterval time.Duration
+
+		greetingObserver func(routing_table.RouterGreetingMessage)
 
 		shutdown chan struct{}
 
@@ -56,6 +60,8 @@ var _ = Describe("Syncer", func() {
 		clock = fakeclock.NewFakeClock(time.Now())
 		clockStep = 1 * time.Second
 		syncInterval = 10 * time.Second
+		emitInterval = 0
+		greetingObserver = nil
 
 		startMessages := make(chan *nats.Msg)
 		routerStartMessages = startMessages
@@ -101,7 +107,15 @@ var _ = Describe("Syncer", func() {
 
 	JustBeforeEach(func() {
 		logger := lagertest.NewTestLogger("test")
-		syncerRunner = syncer.NewSyncer(clock, syncInterval, natsClient, logger)
+		if emitInterval > 0 {
+			syncerRunner = syncer.NewSyncerWithEmitInterval(clock, syncInterval, emitInterval, logger)
+		} else {
+			syncerRunner = syncer.NewSyncer(clock, syncInterval, natsClient, logger)
+		}
+
+		if greetingObserver != nil {
+			syncerRunner.ObserveRouterGreetings(greetingObserver)
+		}
 
 		shutdown = make(chan struct{})
 
@@ -162,6 +176,35 @@ var _ = Describe("Syncer", func() {
 					Consistently(greetings, 1).ShouldNot(Receive())
 				})
 			})
+
+			Context("when greeting observers are registered", func() {
+				var observed chan routing_table.RouterGreetingMessage
+
+				JustBeforeEach(func() {
+					routerStartMessages <- &nats.Msg{
+						Data: []byte(`{
+						"minimumRegisterIntervalInSeconds":1,
+						"pruneThresholdInSeconds": 3,
+						"supportsBatchRegistration": true
+						}`),
+					}
+				})
+
+				BeforeEach(func() {
+					observed = make(chan routing_table.RouterGreetingMessage, 1)
+					greetingObserver = func(greeting routing_table.RouterGreetingMessage) {
+						observed <- greeting
+					}
+				})
+
+				It("passes every greeting to the observers", func() {
+					Eventually(observed).Should(Receive(Equal(routing_table.RouterGreetingMessage{
+						MinimumRegisterInterval:   1,
+						PruneThresholdInSeconds:   3,
+						SupportsBatchRegistration: true,
+					})))
+				})
+			})
 		})
 
 		Context("when the router does not emit a router.start", func() {
@@ -211,6 +254,34 @@ var _ = Describe("Syncer", func() {
 		})
 	})
 
+	Describe("emitting on a fixed interval", func() {
+		var greetings chan *nats.Msg
+
+		BeforeEach(func() {
+			emitInterval = 2 * time.Second
+			greetings = make(chan *nats.Msg, 3)
+			natsClient.WhenPublishing("router.greet", func(msg *nats.Msg) error {
+				greetings <- msg
+				return nil
+			})
+		})
+
+		It("syncs immediately without greeting the router", func() {
+			Eventually(syncerRunner.Events().Sync).Should(Receive())
+			Consistently(greetings).ShouldNot(Receive())
+		})
+
+		It("emits routes with the frequency of the emit interval", func() {
+			Eventually(syncerRunner.Events().Emit, 3).Should(Receive())
+			t1 := clock.Now()
+
+			Eventually(syncerRunner.Events().Emit, 3).Should(Receive())
+			t2 := clock.Now()
+
+			Expect(t2.Sub(t1)).To(BeNumerically("~", 2*time.Second, 200*time.Millisecond))
+		})
+	})
+
 	Describe("syncing", func() {
 		BeforeEach(func() {
 			bbsClient.ActualLRPGroupsStub = func(logger lager.Logger, f models.ActualLRPFilter) ([]*models.ActualLRPGroup, error) {
@@ -260,5 +331,26 @@ var _ = Describe("Syncer", func() {
 				Expect(t2.Sub(t1)).To(BeNumerically("~", syncInterval, 100*time.Millisecond))
 			})
 		})
+
+		Context("when the sync interval is changed while running", func() {
+			BeforeEach(func() {
+				syncInterval = 10 * time.Minute
+			})
+
+			It("syncs on the new interval", func() {
+				Eventually(syncerRunner.Events().Sync).Should(Receive())
+				Consistently(syncerRunner.Events().Sync).ShouldNot(Receive())
+
+				syncerRunner.SetSyncInterval(500 * time.Millisecond)
+
+				var t1, t2 time.Time
+				Eventually(syncerRunner.Events().Sync).Should(Receive())
+				t1 = clock.Now()
+				Eventually(syncerRunner.Events().Sync).Should(Receive())
+				t2 = clock.Now()
+
+				Expect(t2.Sub(t1)).To(BeNumerically("~", 500*time.Millisecond, 100*time.Millisecond))
+			})
+		})
 	})
 })
@@ -0,0 +1,79 @@
+package tcp_emitter
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/lager"
+)
+
+var tcpMappingsEmitted = metric.Counter("TCPMappingsEmitted")
+
+//go:generate counterfeiter -o fake_tcp_emitter/fake_tcp_emitter.go . TCPEmitter
+type TCPEmitter interface {
+	Emit(messagesToEmit routing_table.TCPMessagesToEmit) error
+}
+
+type tcpEmitter struct {
+	routingAPIClient routing_api.Client
+	ttl              time.Duration
+	logger           lager.Logger
+}
+
+func New(routingAPIClient routing_api.Client, ttl time.Duration, logger lager.Logger) TCPEmitter {
+	return &tcpEmitter{
+		routingAPIClient: routingAPIClient,
+		ttl:              ttl,
+		logger:           logger.Session("tcp-emitter"),
+	}
+}
+
+func (t *tcpEmitter) Emit(messagesToEmit routing_table.TCPMessagesToEmit) error {
+	var finalError error
+
+	if len(messagesToEmit.RegistrationMessages) > 0 {
+		mappings := t.mappingsFor(messagesToEmit.RegistrationMessages)
+		t.logger.Debug("upserting-tcp-route-mappings", lager.Data{"mappings": mappings})
+		err := t.routingAPIClient.UpsertTcpRouteMappings(mappings)
+		if err != nil {
+			t.logger.Error("failed-to-upsert-tcp-route-mappings", err, lager.Data{"num-mappings": len(mappings)})
+			finalError = err
+		}
+	}
+
+	if len(messagesToEmit.UnregistrationMessages) > 0 {
+		mappings := t.mappingsFor(messagesToEmit.UnregistrationMessages)
+		t.logger.Debug("deleting-tcp-route-mappings", lager.Data{"mappings": mappings})
+		err := t.routingAPIClient.DeleteTcpRouteMappings(mappings)
+		if err != nil {
+			t.logger.Error("failed-to-delete-tcp-route-mappings", err, lager.Data{"num-mappings": len(mappings)})
+			if finalError == nil {
+				finalError = err
+			}
+		}
+	}
+
+	if finalError != nil {
+		return finalError
+	}
+
+	numberOfMessages := uint64(len(messagesToEmit.RegistrationMessages) + len(messagesToEmit.UnregistrationMessages))
+	tcpMappingsEmitted.Add(numberOfMessages)
+	return nil
+}
+
+func (t *tcpEmitter) mappingsFor(messages []routing_table.TCPMappingMessage) []routing_api.TcpRouteMapping {
+	mappings := make([]routing_api.TcpRouteMapping, 0, len(messages))
+	for _, message := range messages {
+		mappings = append(mappings, routing_api.TcpRouteMapping{
+			RouterGroupGuid: message.RouterGroupGuid,
+			ExternalPort:    message.ExternalPort,
+			HostIP:          message.BackendHost,
+			HostPort:        message.BackendPort,
+			TTL:             int(t.ttl / time.Second),
+		})
+	}
+	return mappings
+}
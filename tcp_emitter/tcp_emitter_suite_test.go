@@ -0,0 +1,13 @@
+package tcp_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTcpEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TCP Emitter Suite")
+}
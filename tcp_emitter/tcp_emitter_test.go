@@ -0,0 +1,85 @@
+package tcp_emitter_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_api/fake_routing_api"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
+	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
+	"github.com/cloudfoundry/dropsonde/metrics"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPEmitter", func() {
+	var (
+		emitter          tcp_emitter.TCPEmitter
+		routingAPIClient *fake_routing_api.FakeClient
+		fakeMetricSender *fake_metrics_sender.FakeMetricSender
+	)
+
+	messagesToEmit := routing_table.TCPMessagesToEmit{
+		RegistrationMessages: []routing_table.TCPMappingMessage{
+			{RouterGroupGuid: "rg-1", ExternalPort: 61000, BackendHost: "1.1.1.1", BackendPort: 11},
+			{RouterGroupGuid: "rg-1", ExternalPort: 61001, BackendHost: "2.2.2.2", BackendPort: 22},
+		},
+		UnregistrationMessages: []routing_table.TCPMappingMessage{
+			{RouterGroupGuid: "rg-1", ExternalPort: 61002, BackendHost: "3.3.3.3", BackendPort: 33},
+		},
+	}
+
+	BeforeEach(func() {
+		routingAPIClient = new(fake_routing_api.FakeClient)
+		emitter = tcp_emitter.New(routingAPIClient, 2*time.Minute, lagertest.NewTestLogger("test"))
+		fakeMetricSender = fake_metrics_sender.NewFakeMetricSender()
+		metrics.Initialize(fakeMetricSender, nil)
+	})
+
+	It("upserts the registrations with the configured ttl", func() {
+		Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+
+		Expect(routingAPIClient.UpsertTcpRouteMappingsCallCount()).To(Equal(1))
+		Expect(routingAPIClient.UpsertTcpRouteMappingsArgsForCall(0)).To(ConsistOf(
+			routing_api.TcpRouteMapping{RouterGroupGuid: "rg-1", ExternalPort: 61000, HostIP: "1.1.1.1", HostPort: 11, TTL: 120},
+			routing_api.TcpRouteMapping{RouterGroupGuid: "rg-1", ExternalPort: 61001, HostIP: "2.2.2.2", HostPort: 22, TTL: 120},
+		))
+	})
+
+	It("deletes the unregistrations", func() {
+		Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+
+		Expect(routingAPIClient.DeleteTcpRouteMappingsCallCount()).To(Equal(1))
+		Expect(routingAPIClient.DeleteTcpRouteMappingsArgsForCall(0)).To(ConsistOf(
+			routing_api.TcpRouteMapping{RouterGroupGuid: "rg-1", ExternalPort: 61002, HostIP: "3.3.3.3", HostPort: 33, TTL: 120},
+		))
+	})
+
+	It("counts the emitted mappings", func() {
+		Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+		Expect(fakeMetricSender.GetCounter("TCPMappingsEmitted")).To(BeEquivalentTo(3))
+	})
+
+	Context("when there is nothing to emit", func() {
+		It("does not call the routing api", func() {
+			Expect(emitter.Emit(routing_table.TCPMessagesToEmit{})).To(Succeed())
+			Expect(routingAPIClient.UpsertTcpRouteMappingsCallCount()).To(Equal(0))
+			Expect(routingAPIClient.DeleteTcpRouteMappingsCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the routing api fails to upsert", func() {
+		BeforeEach(func() {
+			routingAPIClient.UpsertTcpRouteMappingsReturns(errors.New("bam"))
+		})
+
+		It("still deletes the unregistrations and returns the error", func() {
+			Expect(emitter.Emit(messagesToEmit)).To(MatchError("bam"))
+			Expect(routingAPIClient.DeleteTcpRouteMappingsCallCount()).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package tcp_routes
+
+import (
+	"encoding/json"
+
+	"code.cloudfoundry.org/bbs/models"
+)
+
+const TCP_ROUTER = "tcp-router"
+
+type TCPRoutes []TCPRoute
+
+type TCPRoute struct {
+	RouterGroupGuid string `json:"router_group_guid"`
+	ExternalPort    uint32 `json:"external_port,omitempty"`
+	ContainerPort   uint32 `json:"container_port"`
+}
+
+func (t TCPRoutes) RoutingInfo() models.Routes {
+	data, _ := json.Marshal(t)
+	routingInfo := json.RawMessage(data)
+	return models.Routes{
+		TCP_ROUTER: &routingInfo,
+	}
+}
+
+func TCPRoutesFromRoutingInfo(routingInfo models.Routes) (TCPRoutes, error) {
+	if routingInfo == nil {
+		return nil, nil
+	}
+
+	data, found := routingInfo[TCP_ROUTER]
+	if !found {
+		return nil, nil
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	tcpRoutes := TCPRoutes{}
+	err := json.Unmarshal(*data, &tcpRoutes)
+
+	return tcpRoutes, err
+}
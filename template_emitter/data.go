@@ -0,0 +1,246 @@
+package template_emitter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+	"github.com/pivotal-golang/lager"
+)
+
+// MaxWeight is the largest server weight HAProxy accepts; larger route
+// weights are capped to it.
+const MaxWeight = 256
+
+// ErrInvalidRoute is logged for routes left out of the configuration.
+var ErrInvalidRoute = errors.New("route hostname or path holds characters not allowed in the configuration")
+
+// Hostnames and paths are written into the proxy configuration unquoted, so
+// only characters that cannot end or open a directive are let through.
+var (
+	hostnamePattern = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+	pathPattern     = regexp.MustCompile(`^[A-Za-z0-9/_.~%-]*$`)
+)
+
+// Data is what templates are rendered with. Backends and hosts are sorted,
+// with wildcard hosts last, so an unchanged routing table always renders the
+// same file.
+type Data struct {
+	Backends []Backend
+	Hosts    []Host
+}
+
+// Backend is a routing key with at least one running instance.
+type Backend struct {
+	Name          string
+	ProcessGuid   string
+	ContainerPort uint32
+	Weight        uint32
+	Servers       []Server
+}
+
+// Server is an instance of a backend. Weight is the route weight of its
+// routing key, capped to MaxWeight, or zero when the route has none.
+type Server struct {
+	Name   string
+	Host   string
+	Port   uint32
+	Weight uint32
+}
+
+// Address returns the server's host:port.
+func (s Server) Address() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// Host is a hostname and the context paths routed on it, longest first so
+// that they can be matched in order.
+type Host struct {
+	Name   string
+	Routes []Route
+}
+
+// Route sends the requests under Path, which is / for routes without a context
+// path, to Backend. Backends names the backends of every routing key that
+// registers the route; when there are several, Backend combines their
+// servers, weighted by each route's weight.
+type Route struct {
+	Path     string
+	Backend  string
+	Backends []string
+}
+
+// NewData collects the entries with running instances. Entries
+// BoundToRouteService are left out, as are, with an error logged, routes
+// whose hostname is not a valid DNS name or whose path holds characters
+// other than letters, digits and /_.~%-.
+func NewData(entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints, logger lager.Logger) Data {
+	data := Data{Backends: []Backend{}, Hosts: []Host{}}
+	backendsByName := map[string]Backend{}
+	backendsByPathByHost := map[string]map[string][]string{}
+
+	for key, entry := range entries {
+		if entry.BoundToRouteService() {
+			continue
+		}
+
+		backend := backendFor(key, entry)
+		if len(backend.Servers) == 0 {
+			continue
+		}
+		data.Backends = append(data.Backends, backend)
+		backendsByName[backend.Name] = backend
+
+		for uri := range entry.Hostnames {
+			host, path := cfroutes.SplitURI(uri)
+			if len(host) > 253 || !hostnamePattern.MatchString(host) || !pathPattern.MatchString(path) {
+				logger.Error("skipping-invalid-route", ErrInvalidRoute, lager.Data{
+					"process_guid": key.ProcessGuid,
+					"route":        uri,
+				})
+				continue
+			}
+			if path == "" {
+				path = "/"
+			}
+			backendsByPath, ok := backendsByPathByHost[host]
+			if !ok {
+				backendsByPath = map[string][]string{}
+				backendsByPathByHost[host] = backendsByPath
+			}
+			backendsByPath[path] = appendMissing(backendsByPath[path], backend.Name)
+		}
+	}
+
+	for host, backendsByPath := range backendsByPathByHost {
+		h := Host{Name: host}
+		for path, backends := range backendsByPath {
+			sort.Strings(backends)
+			route := Route{Path: path, Backend: backends[0], Backends: backends}
+			if len(backends) > 1 {
+				route.Backend = strings.Join(backends, "_")
+				if _, ok := backendsByName[route.Backend]; !ok {
+					combined := combinedBackend(route.Backend, backends, backendsByName)
+					data.Backends = append(data.Backends, combined)
+					backendsByName[combined.Name] = combined
+				}
+			}
+			h.Routes = append(h.Routes, route)
+		}
+		sort.Sort(byLongestPath(h.Routes))
+		data.Hosts = append(data.Hosts, h)
+	}
+	sort.Sort(byName(data.Backends))
+	sort.Sort(byHostname(data.Hosts))
+
+	return data
+}
+
+// combinedBackend holds the servers of several backends, for a route that
+// more than one routing key registers.
+func combinedBackend(name string, backends []string, backendsByName map[string]Backend) Backend {
+	combined := Backend{Name: name, Servers: []Server{}}
+	for _, backend := range backends {
+		combined.Servers = append(combined.Servers, backendsByName[backend].Servers...)
+	}
+	sort.Sort(byServerName(combined.Servers))
+	return combined
+}
+
+func backendFor(key routing_table.RoutingKey, entry routing_table.RoutableEndpoints) Backend {
+	name := fmt.Sprintf("%s-%d", key.ProcessGuid, key.ContainerPort)
+	backend := Backend{
+		Name:          name,
+		ProcessGuid:   key.ProcessGuid,
+		ContainerPort: key.ContainerPort,
+		Weight:        entry.Weight,
+		Servers:       []Server{},
+	}
+
+	weight := entry.Weight
+	if weight > MaxWeight {
+		weight = MaxWeight
+	}
+
+	seen := map[routing_table.Address]struct{}{}
+	for _, endpoint := range entry.Endpoints {
+		address := routing_table.Address{Host: endpoint.Host, Port: endpoint.Port}
+		if _, ok := seen[address]; ok {
+			continue
+		}
+		seen[address] = struct{}{}
+
+		backend.Servers = append(backend.Servers, Server{
+			Name:   fmt.Sprintf("%s-%s-%d", name, endpoint.Host, endpoint.Port),
+			Host:   endpoint.Host,
+			Port:   endpoint.Port,
+			Weight: weight,
+		})
+	}
+	sort.Sort(byServerName(backend.Servers))
+
+	return backend
+}
+
+func appendMissing(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+type byName []Backend
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+
+type byServerName []Server
+
+func (s byServerName) Len() int           { return len(s) }
+func (s byServerName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byServerName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// byHostname puts wildcard hosts after the others, most specific first, so
+// that proxies matching hosts in order prefer exact hostnames.
+type byHostname []Host
+
+func (h byHostname) Len() int      { return len(h) }
+func (h byHostname) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h byHostname) Less(i, j int) bool {
+	if h[i].Wildcard() != h[j].Wildcard() {
+		return !h[i].Wildcard()
+	}
+	if h[i].Wildcard() && len(h[i].Name) != len(h[j].Name) {
+		return len(h[i].Name) > len(h[j].Name)
+	}
+	return h[i].Name < h[j].Name
+}
+
+type byLongestPath []Route
+
+func (r byLongestPath) Len() int      { return len(r) }
+func (r byLongestPath) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byLongestPath) Less(i, j int) bool {
+	if len(r[i].Path) == len(r[j].Path) {
+		return r[i].Path < r[j].Path
+	}
+	return len(r[i].Path) > len(r[j].Path)
+}
+
+// Wildcard reports whether the host is a wildcard such as *.example.com.
+func (h Host) Wildcard() bool {
+	return strings.HasPrefix(h.Name, "*.")
+}
+
+// Suffix returns the domain a wildcard host matches subdomains of, including
+// the leading dot.
+func (h Host) Suffix() string {
+	return strings.TrimPrefix(h.Name, "*")
+}
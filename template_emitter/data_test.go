@@ -0,0 +1,176 @@
+package template_emitter_test
+
+import (
+	"bytes"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/template_emitter"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Data", func() {
+	var (
+		entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+		logger  *lagertest.TestLogger
+	)
+
+	key1 := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	key2 := routing_table.RoutingKey{ProcessGuid: "pg-2", ContainerPort: 8080}
+	key3 := routing_table.RoutingKey{ProcessGuid: "pg-3", ContainerPort: 8080}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+			key1: {
+				Hostnames: map[string]struct{}{"Foo.example.com": {}, "*.apps.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{
+					{InstanceGuid: "ig-2", Host: "1.1.1.1", Port: 12},
+					{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11},
+					{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, Evacuating: true},
+				}),
+			},
+			key2: {
+				Hostnames: map[string]struct{}{"foo.example.com/api": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{{InstanceGuid: "ig-3", Host: "2.2.2.2", Port: 22}}),
+			},
+			key3: {
+				Hostnames: map[string]struct{}{"bar.example.com": {}},
+			},
+		}
+	})
+
+	It("collects the backends with running instances and the routes to them", func() {
+		data := template_emitter.NewData(entries, logger)
+
+		Expect(data.Backends).To(Equal([]template_emitter.Backend{
+			{
+				Name:          "pg-1-8080",
+				ProcessGuid:   "pg-1",
+				ContainerPort: 8080,
+				Servers: []template_emitter.Server{
+					{Name: "pg-1-8080-1.1.1.1-11", Host: "1.1.1.1", Port: 11},
+					{Name: "pg-1-8080-1.1.1.1-12", Host: "1.1.1.1", Port: 12},
+				},
+			},
+			{
+				Name:          "pg-2-8080",
+				ProcessGuid:   "pg-2",
+				ContainerPort: 8080,
+				Servers:       []template_emitter.Server{{Name: "pg-2-8080-2.2.2.2-22", Host: "2.2.2.2", Port: 22}},
+			},
+		}))
+
+		Expect(data.Hosts).To(Equal([]template_emitter.Host{
+			{Name: "foo.example.com", Routes: []template_emitter.Route{
+				{Path: "/api", Backend: "pg-2-8080", Backends: []string{"pg-2-8080"}},
+				{Path: "/", Backend: "pg-1-8080", Backends: []string{"pg-1-8080"}},
+			}},
+			{Name: "*.apps.example.com", Routes: []template_emitter.Route{{Path: "/", Backend: "pg-1-8080", Backends: []string{"pg-1-8080"}}}},
+		}))
+		Expect(data.Hosts[0].Wildcard()).To(BeFalse())
+		Expect(data.Hosts[1].Wildcard()).To(BeTrue())
+		Expect(data.Hosts[1].Suffix()).To(Equal(".apps.example.com"))
+	})
+
+	It("leaves out entries bound to a route service", func() {
+		entry := entries[key2]
+		entry.RouteServiceUrl = "https://rs.example.com"
+		entries[key2] = entry
+
+		data := template_emitter.NewData(entries, logger)
+		Expect(data.Backends).To(HaveLen(1))
+		Expect(data.Hosts[0].Routes).To(Equal([]template_emitter.Route{{Path: "/", Backend: "pg-1-8080", Backends: []string{"pg-1-8080"}}}))
+	})
+
+	It("leaves out and logs routes that could inject configuration", func() {
+		entries[key2] = routing_table.RoutableEndpoints{
+			Hostnames: map[string]struct{}{
+				"evil.example.com;\n}":  {},
+				"evil.example.com/a;{":  {},
+				"evil.example.com/a\nb": {},
+				"ok.example.com/a/b~c":  {},
+			},
+			Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{{InstanceGuid: "ig-3", Host: "2.2.2.2", Port: 22}}),
+		}
+
+		data := template_emitter.NewData(entries, logger)
+		Expect(data.Hosts).To(HaveLen(3))
+		Expect(data.Hosts).To(ContainElement(template_emitter.Host{Name: "ok.example.com", Routes: []template_emitter.Route{
+			{Path: "/a/b~c", Backend: "pg-2-8080", Backends: []string{"pg-2-8080"}},
+		}}))
+
+		Expect(logger.LogMessages()).To(HaveLen(3))
+		Expect(logger).To(gbytes.Say("skipping-invalid-route"))
+	})
+
+	Context("when several routing keys register a route", func() {
+		BeforeEach(func() {
+			entry := entries[key2]
+			entry.Hostnames = map[string]struct{}{"foo.example.com": {}}
+			entry.Weight = 1000
+			entries[key2] = entry
+
+			entry = entries[key1]
+			entry.Weight = 2
+			entries[key1] = entry
+		})
+
+		It("balances the route over the servers of all of them, by weight", func() {
+			data := template_emitter.NewData(entries, logger)
+
+			Expect(data.Hosts[0].Routes).To(Equal([]template_emitter.Route{
+				{Path: "/", Backend: "pg-1-8080_pg-2-8080", Backends: []string{"pg-1-8080", "pg-2-8080"}},
+			}))
+			Expect(data.Backends).To(HaveLen(3))
+			Expect(data.Backends[1]).To(Equal(template_emitter.Backend{
+				Name: "pg-1-8080_pg-2-8080",
+				Servers: []template_emitter.Server{
+					{Name: "pg-1-8080-1.1.1.1-11", Host: "1.1.1.1", Port: 11, Weight: 2},
+					{Name: "pg-1-8080-1.1.1.1-12", Host: "1.1.1.1", Port: 12, Weight: 2},
+					{Name: "pg-2-8080-2.2.2.2-22", Host: "2.2.2.2", Port: 22, Weight: template_emitter.MaxWeight},
+				},
+			}))
+		})
+	})
+
+	Describe("the built-in templates", func() {
+		render := func(name string) string {
+			tmpl, err := template_emitter.LoadTemplate(name)
+			Expect(err).NotTo(HaveOccurred())
+
+			buffer := &bytes.Buffer{}
+			Expect(tmpl.Execute(buffer, template_emitter.NewData(entries, logger))).To(Succeed())
+			return buffer.String()
+		}
+
+		It("renders an HAProxy configuration", func() {
+			config := render("haproxy")
+			Expect(config).To(ContainSubstring("acl host_0 hdr(host),field(1,:),lower -m str foo.example.com\n"))
+			Expect(config).To(ContainSubstring("use_backend pg-2-8080 if host_0 { path /api } || host_0 { path_beg /api/ }\n    use_backend pg-1-8080 if host_0\n"))
+			Expect(config).To(ContainSubstring("acl host_1 hdr(host),field(1,:),lower -m end .apps.example.com\n"))
+			Expect(config).To(ContainSubstring("backend pg-1-8080\n    balance roundrobin\n    server pg-1-8080-1.1.1.1-11 1.1.1.1:11 check\n    server pg-1-8080-1.1.1.1-12 1.1.1.1:12 check\n"))
+		})
+
+		It("renders nginx upstreams and servers", func() {
+			config := render("nginx")
+			Expect(config).To(ContainSubstring("upstream pg-2-8080 {\n    server 2.2.2.2:22;\n}\n"))
+			Expect(config).To(ContainSubstring("server_name *.apps.example.com;"))
+			Expect(config).To(ContainSubstring("location = /api {\n        proxy_pass http://pg-2-8080;"))
+			Expect(config).To(ContainSubstring("location /api/ {\n        proxy_pass http://pg-2-8080;"))
+			Expect(config).To(ContainSubstring("location / {\n        proxy_pass http://pg-1-8080;"))
+		})
+
+		It("weights the servers of routes with a weight", func() {
+			entry := entries[key2]
+			entry.Weight = 3
+			entries[key2] = entry
+
+			Expect(render("haproxy")).To(ContainSubstring("server pg-2-8080-2.2.2.2-22 2.2.2.2:22 check weight 3\n"))
+			Expect(render("nginx")).To(ContainSubstring("upstream pg-2-8080 {\n    server 2.2.2.2:22 weight=3;\n}\n"))
+		})
+	})
+})
@@ -0,0 +1,203 @@
+package template_emitter
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+const DefaultDebounce = time.Second
+
+var (
+	templateRenders        = metric.Counter("TemplateRenders")
+	templateReloads        = metric.Counter("TemplateReloads")
+	templateReloadFailures = metric.Counter("TemplateReloadFailures")
+)
+
+// LoadTemplate returns the built-in template with the given name, or else
+// parses the template file at that path.
+func LoadTemplate(nameOrPath string) (*template.Template, error) {
+	text, ok := BuiltinTemplates[nameOrPath]
+	if !ok {
+		contents, err := ioutil.ReadFile(nameOrPath)
+		if err != nil {
+			return nil, err
+		}
+		text = string(contents)
+	}
+
+	return template.New(filepath.Base(nameOrPath)).Option("missingkey=error").Parse(text)
+}
+
+// Emitter renders the whole routing table through a template to a file for a
+// proxy such as HAProxy or nginx, and then runs a command to reload it. It
+// implements the emitter interface, but only schedules a render: renders run
+// in Run, at most once per debounce interval, however many messages arrive in
+// between. The file is only replaced, and the proxy reloaded, if the rendered
+// configuration changed.
+type Emitter struct {
+	table         routing_table.RoutingTable
+	template      *template.Template
+	path          string
+	reloadCommand string
+	debounce      time.Duration
+	clock         clock.Clock
+	logger        lager.Logger
+
+	triggers      chan struct{}
+	reloadPending bool
+}
+
+func New(
+	table routing_table.RoutingTable,
+	tmpl *template.Template,
+	path string,
+	reloadCommand string,
+	debounce time.Duration,
+	clock clock.Clock,
+	logger lager.Logger,
+) *Emitter {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	return &Emitter{
+		table:         table,
+		template:      tmpl,
+		path:          path,
+		reloadCommand: reloadCommand,
+		debounce:      debounce,
+		clock:         clock,
+		logger:        logger.Session("template-emitter", lager.Data{"path": path}),
+		triggers:      make(chan struct{}, 1),
+	}
+}
+
+func (e *Emitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	if len(messagesToEmit.RegistrationMessages) == 0 && len(messagesToEmit.UnregistrationMessages) == 0 {
+		return nil
+	}
+
+	select {
+	case e.triggers <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run renders the template debounce after the first message of every burst.
+// Nothing is rendered until the first message, so the file is left alone
+// until the routing table has been synced.
+func (e *Emitter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	e.logger.Info("starting")
+	close(ready)
+	e.logger.Info("started")
+
+	var timer clock.Timer
+	var timerC <-chan time.Time
+
+	for {
+		select {
+		case <-e.triggers:
+			if timerC == nil {
+				timer = e.clock.NewTimer(e.debounce)
+				timerC = timer.C()
+			}
+
+		case <-timerC:
+			timerC = nil
+			e.render()
+
+		case <-signals:
+			e.logger.Info("stopping")
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+	}
+}
+
+func (e *Emitter) render() {
+	buffer := &bytes.Buffer{}
+	err := e.template.Execute(buffer, NewData(e.table.Entries(), e.logger))
+	if err != nil {
+		e.logger.Error("failed-to-render-template", err)
+		return
+	}
+	templateRenders.Increment()
+
+	existing, err := ioutil.ReadFile(e.path)
+	if err == nil && bytes.Equal(existing, buffer.Bytes()) {
+		e.logger.Debug("configuration-unchanged")
+		if e.reloadPending {
+			e.reload()
+		}
+		return
+	}
+
+	err = writeFile(e.path, buffer.Bytes())
+	if err != nil {
+		e.logger.Error("failed-to-write-configuration", err)
+		return
+	}
+	e.logger.Info("wrote-configuration", lager.Data{"bytes": buffer.Len()})
+
+	e.reloadPending = true
+	e.reload()
+}
+
+func (e *Emitter) reload() {
+	if e.reloadCommand == "" {
+		e.reloadPending = false
+		return
+	}
+
+	output, err := exec.Command("/bin/sh", "-c", e.reloadCommand).CombinedOutput()
+	if err != nil {
+		e.logger.Error("failed-to-reload", err, lager.Data{"command": e.reloadCommand, "output": string(output)})
+		templateReloadFailures.Increment()
+		return
+	}
+
+	e.logger.Info("reloaded", lager.Data{"command": e.reloadCommand})
+	templateReloads.Increment()
+	e.reloadPending = false
+}
+
+// writeFile atomically replaces the file at path, so the proxy never reads a
+// partially written configuration.
+func writeFile(path string, contents []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(contents)
+	if err == nil {
+		err = tmpFile.Chmod(0644)
+	}
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("writing %s: %s", path, err)
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
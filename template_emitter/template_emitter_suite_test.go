@@ -0,0 +1,13 @@
+package template_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTemplateEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Template Emitter Suite")
+}
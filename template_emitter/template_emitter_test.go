@@ -0,0 +1,169 @@
+package template_emitter_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/template_emitter"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Emitter", func() {
+	var (
+		tmpDir        string
+		path          string
+		reloadsPath   string
+		reloadCommand string
+		table         *fake_routing_table.FakeRoutingTable
+		clock         *fakeclock.FakeClock
+		hostname      string
+		emitter       *template_emitter.Emitter
+		process       ifrit.Process
+	)
+
+	key := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	messagesToEmit := routing_table.MessagesToEmit{
+		RegistrationMessages: []routing_table.RegistryMessage{{URIs: []string{"foo.example.com"}}},
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "template-emitter")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(tmpDir, "proxy.conf")
+		reloadsPath = filepath.Join(tmpDir, "reloads")
+		reloadCommand = "echo reloaded >> " + reloadsPath
+
+		hostname = "foo.example.com"
+		table = new(fake_routing_table.FakeRoutingTable)
+		table.EntriesStub = func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+			return map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+				key: {
+					Hostnames: map[string]struct{}{hostname: {}},
+					Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11}}),
+				},
+			}
+		}
+		clock = fakeclock.NewFakeClock(time.Now())
+	})
+
+	JustBeforeEach(func() {
+		tmpl := template.Must(template.New("test").Parse(`{{range .Hosts}}{{.Name}}{{end}}`))
+		emitter = template_emitter.New(table, tmpl, path, reloadCommand, time.Second, clock, lagertest.NewTestLogger("test"))
+		process = ifrit.Invoke(emitter)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		os.RemoveAll(tmpDir)
+	})
+
+	readFile := func(path string) string {
+		contents, _ := ioutil.ReadFile(path)
+		return string(contents)
+	}
+
+	reloads := func() int {
+		return strings.Count(readFile(reloadsPath), "reloaded")
+	}
+
+	emitAndWait := func() {
+		Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(time.Second)
+	}
+
+	It("does not render anything until routes are emitted", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{})).To(Succeed())
+		Consistently(clock.WatcherCount).Should(BeZero())
+		Expect(path).NotTo(BeAnExistingFile())
+	})
+
+	It("renders the template to the file and reloads once per burst", func() {
+		for i := 0; i < 10; i++ {
+			Expect(emitter.Emit(messagesToEmit)).To(Succeed())
+		}
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		Expect(path).NotTo(BeAnExistingFile())
+
+		clock.Increment(time.Second)
+		Eventually(func() string { return readFile(path) }).Should(Equal("foo.example.com"))
+		Eventually(reloads).Should(Equal(1))
+		Consistently(reloads).Should(Equal(1))
+		Expect(table.EntriesCallCount()).To(Equal(1))
+	})
+
+	It("leaves the file alone and does not reload when the configuration is unchanged", func() {
+		emitAndWait()
+		Eventually(reloads).Should(Equal(1))
+
+		emitAndWait()
+		Eventually(table.EntriesCallCount).Should(Equal(2))
+		Consistently(reloads).Should(Equal(1))
+	})
+
+	It("replaces the file and reloads when the configuration changes", func() {
+		emitAndWait()
+		Eventually(reloads).Should(Equal(1))
+
+		hostname = "bar.example.com"
+		emitAndWait()
+		Eventually(func() string { return readFile(path) }).Should(Equal("bar.example.com"))
+		Eventually(reloads).Should(Equal(2))
+	})
+
+	Context("when the reload command fails", func() {
+		BeforeEach(func() {
+			reloadCommand = "test -e " + filepath.Join(tmpDir, "ok") + " && echo reloaded >> " + reloadsPath
+		})
+
+		It("retries the reload on the next render even if the configuration is unchanged", func() {
+			emitAndWait()
+			Eventually(func() string { return readFile(path) }).Should(Equal("foo.example.com"))
+			Consistently(reloads).Should(BeZero())
+
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "ok"), nil, 0644)).To(Succeed())
+			emitAndWait()
+			Eventually(reloads).Should(Equal(1))
+		})
+	})
+
+	Describe("LoadTemplate", func() {
+		It("returns the built-in templates by name", func() {
+			tmpl, err := template_emitter.LoadTemplate("haproxy")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpl.Name()).To(Equal("haproxy"))
+		})
+
+		It("parses template files", func() {
+			templatePath := filepath.Join(tmpDir, "custom.tmpl")
+			Expect(ioutil.WriteFile(templatePath, []byte(`{{len .Backends}}`), 0644)).To(Succeed())
+
+			tmpl, err := template_emitter.LoadTemplate(templatePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tmpl.Name()).To(Equal("custom.tmpl"))
+		})
+
+		It("fails for missing files and invalid templates", func() {
+			_, err := template_emitter.LoadTemplate(filepath.Join(tmpDir, "missing.tmpl"))
+			Expect(err).To(HaveOccurred())
+
+			templatePath := filepath.Join(tmpDir, "invalid.tmpl")
+			Expect(ioutil.WriteFile(templatePath, []byte(`{{range}}`), 0644)).To(Succeed())
+			_, err = template_emitter.LoadTemplate(templatePath)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,86 @@
+package template_emitter
+
+// Built-in templates, selected by name instead of a template file path. When
+// several routing keys register the same route, requests are balanced over
+// the servers of all of them, weighted by the route weights.
+var BuiltinTemplates = map[string]string{
+	"haproxy": haproxyTemplate,
+	"nginx":   nginxTemplate,
+}
+
+// haproxyTemplate renders a complete HAProxy configuration listening for HTTP
+// on port 80.
+const haproxyTemplate = `# Generated by route-emitter. Do not edit; changes are overwritten.
+global
+    maxconn 4096
+
+defaults
+    mode http
+    option forwardfor
+    option http-server-close
+    timeout connect 5s
+    timeout client 60s
+    timeout server 60s
+
+frontend http
+    bind *:80
+{{- range $i, $host := .Hosts}}
+{{- if $host.Wildcard}}
+    acl host_{{$i}} hdr(host),field(1,:),lower -m end {{$host.Suffix}}
+{{- else}}
+    acl host_{{$i}} hdr(host),field(1,:),lower -m str {{$host.Name}}
+{{- end}}
+{{- range $host.Routes}}
+{{- if eq .Path "/"}}
+    use_backend {{.Backend}} if host_{{$i}}
+{{- else}}
+    use_backend {{.Backend}} if host_{{$i}} { path {{.Path}} } || host_{{$i}} { path_beg {{.Path}}/ }
+{{- end}}
+{{- end}}
+{{- end}}
+{{range .Backends}}
+backend {{.Name}}
+    balance roundrobin
+{{- range .Servers}}
+    server {{.Name}} {{.Address}} check{{if .Weight}} weight {{.Weight}}{{end}}
+{{- end}}
+{{end -}}
+`
+
+// nginxTemplate renders upstream and server blocks to be included in the http
+// block of an nginx configuration, listening on port 80.
+const nginxTemplate = `# Generated by route-emitter. Do not edit; changes are overwritten.
+{{range .Backends}}
+upstream {{.Name}} {
+{{- range .Servers}}
+    server {{.Address}}{{if .Weight}} weight={{.Weight}}{{end}};
+{{- end}}
+}
+{{end}}
+{{- range .Hosts}}
+server {
+    listen 80;
+    server_name {{.Name}};
+{{range .Routes}}
+{{- if eq .Path "/"}}
+    location / {
+        proxy_pass http://{{.Backend}};
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    }
+{{- else}}
+    location = {{.Path}} {
+        proxy_pass http://{{.Backend}};
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    }
+    location {{.Path}}/ {
+        proxy_pass http://{{.Backend}};
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    }
+{{- end}}
+{{end -}}
+}
+{{end -}}
+`
@@ -1,6 +1,7 @@
 package watcher
 
 import (
+	"math/rand"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -9,11 +10,14 @@ import (
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/bbs/events"
 	"code.cloudfoundry.org/bbs/models"
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
 	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/shard"
 	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_routes"
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
-	"github.com/cloudfoundry-incubator/runtime-schema/metric"
 	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
 )
@@ -26,25 +30,72 @@ var (
 
 	routesRegistered   = metric.Counter("RoutesRegistered")
 	routesUnregistered = metric.Counter("RoutesUnregistered")
+
+	tcpRoutesRegistered   = metric.Counter("TCPRoutesRegistered")
+	tcpRoutesUnregistered = metric.Counter("TCPRoutesUnregistered")
+
+	messagesCoalesced        = metric.Counter("MessagesCoalesced")
+	messagesCoalescedEmitted = metric.Counter("MessagesCoalescedEmitted")
+	messageCoalescingRatio   = metric.Metric("MessageCoalescingPercent")
+
+	routesRejected             = metric.Counter("RoutesRejected")
+	routeServiceRoutesRejected = metric.Counter("RouteServiceRoutesRejected")
 )
 
 type Watcher struct {
 	bbsClient  bbs.Client
 	clock      clock.Clock
 	table      routing_table.RoutingTable
+	tcpTable   routing_table.TCPRoutingTable
 	emitter    nats_emitter.NATSEmitter
+	tcpEmitter tcp_emitter.TCPEmitter
 	syncEvents syncer.Events
 	logger     lager.Logger
+
+	coalesceWindow     time.Duration
+	coalesceMaxLatency time.Duration
+	coalescer          *routing_table.MessageCoalescer
+	coalesceStartedAt  time.Time
+	coalesceTimer      clock.Timer
+
+	resubscribeMinBackoff time.Duration
+	resubscribeMaxBackoff time.Duration
+
+	cellID      string
+	shardFilter shard.Filter
+	// cellZones maps cell ids to availability zones as of the last sync. It
+	// is only replaced between syncs, never modified.
+	cellZones map[string]string
+
+	hostnamePolicy     routing_table.HostnamePolicy
+	routeServicePolicy routing_table.RouteServicePolicy
+	policyLock         sync.RWMutex
+	// rejections holds the routes currently rejected for each process guid,
+	// so that a rejection is only reported when it first appears.
+	rejections map[string]map[rejectedRoute]struct{}
+
+	emitOnStart           bool
+	syncObservers         []func(time.Time)
+	subscriptionObservers []func(bool)
 }
 
 type syncEndEvent struct {
-	table    routing_table.RoutingTable
-	domains  models.DomainSet
-	callback func(routing_table.RoutingTable)
+	table      routing_table.RoutingTable
+	tcpTable   routing_table.TCPRoutingTable
+	domains    models.DomainSet
+	cellZones  map[string]string
+	rejections []routing_table.RouteRejection
+	callback   func(routing_table.RoutingTable)
 
 	logger lager.Logger
 }
 
+type rejectedRoute struct {
+	URI             string
+	RouteServiceUrl string
+	Reason          string
+}
+
 type set map[interface{}]struct{}
 
 func (set set) contains(value interface{}) bool {
@@ -60,7 +111,15 @@ func NewWatcher(
 	bbsClient bbs.Client,
 	clock clock.Clock,
 	table routing_table.RoutingTable,
+	tcpTable routing_table.TCPRoutingTable,
 	emitter nats_emitter.NATSEmitter,
+	tcpEmitter tcp_emitter.TCPEmitter,
+	coalesceWindow time.Duration,
+	coalesceMaxLatency time.Duration,
+	resubscribeMinBackoff time.Duration,
+	resubscribeMaxBackoff time.Duration,
+	cellID string,
+	shardFilter shard.Filter,
 	syncEvents syncer.Events,
 	logger lager.Logger,
 ) *Watcher {
@@ -68,12 +127,69 @@ func NewWatcher(
 		bbsClient:  bbsClient,
 		clock:      clock,
 		table:      table,
+		tcpTable:   tcpTable,
 		emitter:    emitter,
+		tcpEmitter: tcpEmitter,
 		syncEvents: syncEvents,
 		logger:     logger.Session("watcher"),
+
+		coalesceWindow:     coalesceWindow,
+		coalesceMaxLatency: coalesceMaxLatency,
+		coalescer:          routing_table.NewMessageCoalescer(),
+
+		resubscribeMinBackoff: resubscribeMinBackoff,
+		resubscribeMaxBackoff: resubscribeMaxBackoff,
+
+		cellID:      cellID,
+		shardFilter: shardFilter,
+
+		rejections: map[string]map[rejectedRoute]struct{}{},
 	}
 }
 
+// SetHostnamePolicy restricts the hostnames processes may register. It may be
+// called while the watcher is running; routes that were registered before are
+// only re-evaluated when their desired LRP changes or at the next sync.
+func (watcher *Watcher) SetHostnamePolicy(policy routing_table.HostnamePolicy) {
+	watcher.policyLock.Lock()
+	watcher.hostnamePolicy = policy
+	watcher.policyLock.Unlock()
+}
+
+// SetRouteServicePolicy restricts the route services routes may be bound to.
+// Like SetHostnamePolicy, it may be called while the watcher is running.
+func (watcher *Watcher) SetRouteServicePolicy(policy routing_table.RouteServicePolicy) {
+	watcher.policyLock.Lock()
+	watcher.routeServicePolicy = policy
+	watcher.policyLock.Unlock()
+}
+
+func (watcher *Watcher) currentPolicies() (routing_table.HostnamePolicy, routing_table.RouteServicePolicy) {
+	watcher.policyLock.RLock()
+	defer watcher.policyLock.RUnlock()
+	return watcher.hostnamePolicy, watcher.routeServicePolicy
+}
+
+// EmitOnStart makes Run emit the table as soon as it starts, rather than
+// waiting for the first sync to complete, for a table restored from a
+// snapshot. It must be called before Run.
+func (watcher *Watcher) EmitOnStart() {
+	watcher.emitOnStart = true
+}
+
+// ObserveSyncs registers a function that is called with the time each
+// successful sync completed. It must be called before Run.
+func (watcher *Watcher) ObserveSyncs(observer func(time.Time)) {
+	watcher.syncObservers = append(watcher.syncObservers, observer)
+}
+
+// ObserveEventSubscription registers a function that is called whenever the
+// subscription to BBS events is established or lost. It must be called before
+// Run.
+func (watcher *Watcher) ObserveEventSubscription(observer func(subscribed bool)) {
+	watcher.subscriptionObservers = append(watcher.subscriptionObservers, observer)
+}
+
 func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	watcher.logger.Info("starting")
 
@@ -81,21 +197,56 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 	watcher.logger.Info("started")
 	defer watcher.logger.Info("finished")
 
+	if watcher.emitOnStart {
+		watcher.emit(watcher.logger.Session("emit-restored"))
+	}
+
 	var cachedEvents map[string]models.Event
 
 	eventChan := make(chan models.Event)
 	syncEndChan := make(chan syncEndEvent)
 
 	syncing := false
+	// resyncPending is set when a resync is asked for during a sync, which may
+	// have fetched its state before the events it is meant to catch up on
+	resyncPending := false
 
 	var eventSource atomic.Value
 	var stopEventSource int32
+	stopBackoff := make(chan struct{})
+	resubscribedChan := make(chan struct{}, 1)
 
 	startEventSource := func() {
 		go func() {
 			var err error
 			var es events.EventSource
 
+			failures := 0
+			subscribed := false
+
+			backoff := func() bool {
+				failures++
+				delay := watcher.resubscribeBackoff(failures)
+				watcher.logger.Info("backing-off-before-resubscribing", lager.Data{
+					"attempt": failures,
+					"delay":   delay.String(),
+				})
+
+				if delay == 0 {
+					return true
+				}
+
+				timer := watcher.clock.NewTimer(delay)
+				defer timer.Stop()
+
+				select {
+				case <-timer.C():
+					return true
+				case <-stopBackoff:
+					return false
+				}
+			}
+
 			for {
 				if atomic.LoadInt32(&stopEventSource) == 1 {
 					watcher.logger.Info("stop-event-source-received")
@@ -105,57 +256,122 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 				es, err = watcher.bbsClient.SubscribeToEvents(watcher.logger)
 				if err != nil {
 					watcher.logger.Error("failed-subscribing-to-events", err)
+					watcher.notifySubscription(false)
+					if !backoff() {
+						return
+					}
 					continue
 				}
 
 				watcher.logger.Info("succeeded-subscribing-to-events")
 				eventSource.Store(es)
+				watcher.notifySubscription(true)
+
+				// events may have been missed while we were not subscribed, so
+				// ask for a full sync to catch up
+				if subscribed {
+					select {
+					case resubscribedChan <- struct{}{}:
+					default:
+					}
+				}
+				subscribed = true
 
 				var event models.Event
 				for {
 					event, err = es.Next()
 					if err != nil {
 						watcher.logger.Error("failed-getting-next-event", err)
-						// wait a bit before retrying
-						time.Sleep(time.Second)
+						watcher.notifySubscription(false)
 						break
 					}
 
+					failures = 0
+
 					if event != nil {
 						eventChan <- event
 					}
 				}
+
+				if atomic.LoadInt32(&stopEventSource) == 1 {
+					continue
+				}
+
+				if !backoff() {
+					return
+				}
 			}
 		}()
 	}
 
+	var shardChanged <-chan struct{}
+	if watcher.shardFilter != nil {
+		shardChanged = watcher.shardFilter.Changed()
+	}
+
 	startedEventSource := false
+	startSync := func() {
+		if syncing {
+			return
+		}
+
+		logger := watcher.logger.Session("sync")
+		logger.Info("starting")
+
+		cachedEvents = make(map[string]models.Event)
+		syncing = true
+
+		if !startedEventSource {
+			startedEventSource = true
+			startEventSource()
+		}
+
+		go watcher.sync(logger, syncEndChan)
+	}
+
 	for {
+		var coalesceTimerC <-chan time.Time
+		if watcher.coalesceTimer != nil {
+			coalesceTimerC = watcher.coalesceTimer.C()
+		}
+
 		select {
-		case <-watcher.syncEvents.Sync:
-			if syncing == false {
-				logger := watcher.logger.Session("sync")
-				logger.Info("starting")
+		case <-coalesceTimerC:
+			watcher.flushCoalesced(watcher.logger.Session("flush-coalesced"))
 
-				cachedEvents = make(map[string]models.Event)
-				syncing = true
+		case <-watcher.syncEvents.Sync:
+			startSync()
 
-				if !startedEventSource {
-					startedEventSource = true
-					startEventSource()
-				}
+		case <-resubscribedChan:
+			watcher.logger.Info("resyncing-after-resubscribe")
+			if syncing {
+				resyncPending = true
+			}
+			startSync()
 
-				go watcher.sync(logger, syncEndChan)
+		case <-shardChanged:
+			watcher.logger.Info("resyncing-after-shard-change")
+			if syncing {
+				resyncPending = true
 			}
+			startSync()
 
 		case syncEnd := <-syncEndChan:
+			watcher.flushCoalesced(syncEnd.logger)
 			watcher.completeSync(syncEnd, cachedEvents)
 			cachedEvents = nil
 			syncing = false
 			syncEnd.logger.Info("complete")
 
+			if resyncPending {
+				resyncPending = false
+				watcher.logger.Info("starting-pending-resync")
+				startSync()
+			}
+
 		case <-watcher.syncEvents.Emit:
 			logger := watcher.logger.Session("emit")
+			watcher.flushCoalesced(logger)
 			watcher.emit(logger)
 
 		case event := <-eventChan:
@@ -171,7 +387,9 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 
 		case <-signals:
 			watcher.logger.Info("stopping")
+			watcher.flushCoalesced(watcher.logger)
 			atomic.StoreInt32(&stopEventSource, 1)
+			close(stopBackoff)
 			if es := eventSource.Load(); es != nil {
 				err := es.(events.EventSource).Close()
 				if err != nil {
@@ -183,6 +401,25 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 	}
 }
 
+// resubscribeBackoff doubles the minimum backoff for each consecutive failure,
+// up to the maximum, and picks a random delay between half that and all of it
+// so that emitters don't resubscribe to a recovering BBS in lockstep.
+func (watcher *Watcher) resubscribeBackoff(failures int) time.Duration {
+	delay := watcher.resubscribeMinBackoff
+	for i := 1; i < failures && delay < watcher.resubscribeMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > watcher.resubscribeMaxBackoff {
+		delay = watcher.resubscribeMaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(int64(delay)-half+1))
+}
+
 func (watcher *Watcher) emit(logger lager.Logger) {
 	messagesToEmit := watcher.table.MessagesToEmit()
 
@@ -197,6 +434,16 @@ func (watcher *Watcher) emit(logger lager.Logger) {
 	if err != nil {
 		logger.Error("failed-to-send-routes-total-metric", err)
 	}
+
+	if watcher.tcpEmitter != nil {
+		tcpMessagesToEmit := watcher.tcpTable.MessagesToEmit()
+
+		logger.Debug("emitting-tcp-messages", lager.Data{"messages": tcpMessagesToEmit})
+		err = watcher.tcpEmitter.Emit(tcpMessagesToEmit)
+		if err != nil {
+			logger.Error("failed-to-emit-tcp-routes", err)
+		}
+	}
 }
 
 func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent) {
@@ -213,6 +460,7 @@ func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent)
 	var getSchedulingInfosErr error
 	var domains models.DomainSet
 	var getDomainErr error
+	var cellZones map[string]string
 
 	wg := sync.WaitGroup{}
 
@@ -221,7 +469,10 @@ func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent)
 		defer wg.Done()
 
 		logger.Debug("getting-actual-lrps")
-		actualLRPGroups, err := watcher.bbsClient.ActualLRPGroups(logger, models.ActualLRPFilter{})
+		// when running on a single cell only that cell's actuals are routed,
+		// but desireds are still fetched globally since any of them may place
+		// an instance on this cell before the next sync
+		actualLRPGroups, err := watcher.bbsClient.ActualLRPGroups(logger, models.ActualLRPFilter{CellID: watcher.cellID})
 		if err != nil {
 			logger.Error("failed-getting-actual-lrps", err)
 			getActualLRPsErr = err
@@ -232,6 +483,9 @@ func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent)
 		runningActualLRPs = make([]*routing_table.ActualLRPRoutingInfo, 0, len(actualLRPGroups))
 		for _, actualLRPGroup := range actualLRPGroups {
 			actualLRP, evacuating := actualLRPGroup.Resolve()
+			if !watcher.owns(actualLRP.ProcessGuid) {
+				continue
+			}
 			if actualLRP.State == models.ActualLRPStateRunning {
 				runningActualLRPs = append(runningActualLRPs, &routing_table.ActualLRPRoutingInfo{
 					ActualLRP:  actualLRP,
@@ -254,6 +508,16 @@ func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent)
 			return
 		}
 		logger.Debug("succeeded-getting-scheduling-infos", lager.Data{"num-desired-responses": len(schedulingInfos)})
+
+		if watcher.shardFilter != nil {
+			owned := schedulingInfos[:0]
+			for _, schedulingInfo := range schedulingInfos {
+				if watcher.owns(schedulingInfo.ProcessGuid) {
+					owned = append(owned, schedulingInfo)
+				}
+			}
+			schedulingInfos = owned
+		}
 	}()
 
 	wg.Add(1)
@@ -271,19 +535,54 @@ func (watcher *Watcher) sync(logger lager.Logger, syncEndChan chan syncEndEvent)
 		logger.Debug("succeeded-getting-domains", lager.Data{"num-domains": len(domains)})
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		logger.Debug("getting-cells")
+		cells, err := watcher.bbsClient.Cells(logger)
+		if err != nil {
+			// zones only enrich registration tags, so a failure here falls
+			// back to the zones from the last sync instead of failing it
+			logger.Error("failed-getting-cells", err)
+			cellZones = watcher.cellZones
+			return
+		}
+
+		cellZones = make(map[string]string, len(cells))
+		for _, cell := range cells {
+			cellZones[cell.CellId] = cell.Zone
+		}
+		logger.Debug("succeeded-getting-cells", lager.Data{"num-cells": len(cells)})
+	}()
+
 	wg.Wait()
 
 	if getActualLRPsErr != nil || getSchedulingInfosErr != nil || getDomainErr != nil {
 		return
 	}
 
-	newTable := routing_table.NewTempTable(
-		routing_table.RoutesByRoutingKeyFromSchedulingInfos(schedulingInfos),
-		routing_table.EndpointsByRoutingKeyFromActuals(runningActualLRPs),
+	for _, actualLRPInfo := range runningActualLRPs {
+		actualLRPInfo.AvailabilityZone = cellZones[actualLRPInfo.ActualLRP.CellId]
+	}
+
+	runningEndpoints := routing_table.EndpointsByRoutingKeyFromActuals(runningActualLRPs)
+
+	hostnamePolicy, routeServicePolicy := watcher.currentPolicies()
+	routes, rejections := routing_table.RoutesByRoutingKeyFromSchedulingInfos(schedulingInfos, hostnamePolicy, routeServicePolicy)
+
+	newTable := routing_table.NewTempTable(routes, runningEndpoints)
+
+	newTCPTable := routing_table.NewTempTCPTable(
+		routing_table.TCPRoutesByRoutingKeyFromSchedulingInfos(schedulingInfos),
+		runningEndpoints,
 	)
 
 	endEvent.table = newTable
+	endEvent.tcpTable = newTCPTable
 	endEvent.domains = domains
+	endEvent.cellZones = cellZones
+	endEvent.rejections = rejections
 	endEvent.callback = func(table routing_table.RoutingTable) {
 		after := watcher.clock.Now()
 		err := routeSyncDuration.Send(after.Sub(before))
@@ -307,11 +606,18 @@ func (watcher *Watcher) completeSync(syncEnd syncEndEvent, cachedEvents map[stri
 		return
 	}
 
+	watcher.cellZones = syncEnd.cellZones
+	watcher.rejections = reportRejections(logger, watcher.rejections, syncEnd.rejections)
+
 	emitter := watcher.emitter
 	watcher.emitter = nil
+	tcpEmitter := watcher.tcpEmitter
+	watcher.tcpEmitter = nil
 
 	table := watcher.table
 	watcher.table = syncEnd.table
+	tcpTable := watcher.tcpTable
+	watcher.tcpTable = syncEnd.tcpTable
 
 	logger.Debug("handling-cached-events")
 	for _, e := range cachedEvents {
@@ -321,6 +627,18 @@ func (watcher *Watcher) completeSync(syncEnd syncEndEvent, cachedEvents map[stri
 
 	watcher.table = table
 	watcher.emitter = emitter
+	watcher.tcpTable = tcpTable
+	watcher.tcpEmitter = tcpEmitter
+
+	if watcher.shardFilter != nil {
+		// the routes of process guids this emitter no longer owns are now
+		// registered by their new owner, and unregistering them here could
+		// remove them from the router after it has, so they are dropped
+		// without emitting anything
+		forget := func(processGuid string) bool { return !watcher.owns(processGuid) }
+		watcher.table.ForgetProcessGuids(forget)
+		watcher.tcpTable.ForgetProcessGuids(forget)
+	}
 
 	messages := watcher.table.Swap(syncEnd.table, syncEnd.domains)
 	logger.Debug("start-emitting-messages", lager.Data{
@@ -333,12 +651,44 @@ func (watcher *Watcher) completeSync(syncEnd syncEndEvent, cachedEvents map[stri
 		"num-unregistration-messages": len(messages.UnregistrationMessages),
 	})
 
+	tcpMessages := watcher.tcpTable.Swap(syncEnd.tcpTable, syncEnd.domains)
+	logger.Debug("start-emitting-tcp-messages", lager.Data{
+		"num-registration-messages":   len(tcpMessages.RegistrationMessages),
+		"num-unregistration-messages": len(tcpMessages.UnregistrationMessages),
+	})
+	watcher.emitTCPMessages(logger, tcpMessages)
+	logger.Debug("done-emitting-tcp-messages", lager.Data{
+		"num-registration-messages":   len(tcpMessages.RegistrationMessages),
+		"num-unregistration-messages": len(tcpMessages.UnregistrationMessages),
+	})
+
 	if syncEnd.callback != nil {
 		syncEnd.callback(watcher.table)
 	}
+
+	completedAt := watcher.clock.Now()
+	for _, observer := range watcher.syncObservers {
+		observer(completedAt)
+	}
+}
+
+func (watcher *Watcher) notifySubscription(subscribed bool) {
+	for _, observer := range watcher.subscriptionObservers {
+		observer(subscribed)
+	}
 }
 
 func (watcher *Watcher) handleEvent(logger lager.Logger, event models.Event) {
+	if processGuid, ok := eventProcessGuid(event); ok && !watcher.owns(processGuid) {
+		return
+	}
+
+	// the events cached during a sync are replayed into the new table before
+	// it is swapped in, with no emitter, and must not start a window
+	if watcher.coalesceWindow > 0 && watcher.emitter != nil {
+		watcher.coalescer.Begin(watcher.table.Entries)
+	}
+
 	switch event := event.(type) {
 	case *models.DesiredLRPCreatedEvent:
 		schedulingInfo := event.DesiredLrp.DesiredLRPSchedulingInfo()
@@ -351,25 +701,83 @@ func (watcher *Watcher) handleEvent(logger lager.Logger, event models.Event) {
 		schedulingInfo := event.DesiredLrp.DesiredLRPSchedulingInfo()
 		watcher.handleDesiredDelete(logger, &schedulingInfo)
 	case *models.ActualLRPCreatedEvent:
-		watcher.handleActualCreate(logger, routing_table.NewActualLRPRoutingInfo(event.ActualLrpGroup))
+		actualLRPInfo := watcher.actualLRPRoutingInfo(event.ActualLrpGroup)
+		if watcher.isLocal(actualLRPInfo) {
+			watcher.handleActualCreate(logger, actualLRPInfo)
+		}
 	case *models.ActualLRPChangedEvent:
-		watcher.handleActualUpdate(logger,
-			routing_table.NewActualLRPRoutingInfo(event.Before),
-			routing_table.NewActualLRPRoutingInfo(event.After),
-		)
+		before := watcher.actualLRPRoutingInfo(event.Before)
+		after := watcher.actualLRPRoutingInfo(event.After)
+		switch beforeLocal, afterLocal := watcher.isLocal(before), watcher.isLocal(after); {
+		case beforeLocal && afterLocal:
+			watcher.handleActualUpdate(logger, before, after)
+		case beforeLocal:
+			watcher.handleActualDelete(logger, before)
+		case afterLocal:
+			watcher.handleActualCreate(logger, after)
+		}
 	case *models.ActualLRPRemovedEvent:
-		watcher.handleActualDelete(logger, routing_table.NewActualLRPRoutingInfo(event.ActualLrpGroup))
+		actualLRPInfo := watcher.actualLRPRoutingInfo(event.ActualLrpGroup)
+		if watcher.isLocal(actualLRPInfo) {
+			watcher.handleActualDelete(logger, actualLRPInfo)
+		}
 	default:
 		logger.Info("did-not-handle-unrecognizable-event", lager.Data{"event-type": event.EventType()})
 	}
 }
 
+func (watcher *Watcher) actualLRPRoutingInfo(actualLRPGroup *models.ActualLRPGroup) *routing_table.ActualLRPRoutingInfo {
+	actualLRPInfo := routing_table.NewActualLRPRoutingInfo(actualLRPGroup)
+	if actualLRPInfo.ActualLRP != nil {
+		actualLRPInfo.AvailabilityZone = watcher.cellZones[actualLRPInfo.ActualLRP.CellId]
+	}
+	return actualLRPInfo
+}
+
+// owns reports whether the process guid falls in this emitter's shard, which is
+// always the case unless routing is sharded between several emitters.
+func (watcher *Watcher) owns(processGuid string) bool {
+	return watcher.shardFilter == nil || watcher.shardFilter.Owns(processGuid)
+}
+
+func eventProcessGuid(event models.Event) (string, bool) {
+	switch event := event.(type) {
+	case *models.DesiredLRPCreatedEvent:
+		return event.DesiredLrp.ProcessGuid, true
+	case *models.DesiredLRPChangedEvent:
+		return event.After.ProcessGuid, true
+	case *models.DesiredLRPRemovedEvent:
+		return event.DesiredLrp.ProcessGuid, true
+	case *models.ActualLRPCreatedEvent:
+		actualLRP, _ := event.ActualLrpGroup.Resolve()
+		return actualLRP.ProcessGuid, true
+	case *models.ActualLRPChangedEvent:
+		actualLRP, _ := event.After.Resolve()
+		return actualLRP.ProcessGuid, true
+	case *models.ActualLRPRemovedEvent:
+		actualLRP, _ := event.ActualLrpGroup.Resolve()
+		return actualLRP.ProcessGuid, true
+	}
+	return "", false
+}
+
+// isLocal reports whether the watcher is responsible for routing to the given
+// actual, which is always the case unless it is restricted to a single cell.
+func (watcher *Watcher) isLocal(actualLRPInfo *routing_table.ActualLRPRoutingInfo) bool {
+	if watcher.cellID == "" {
+		return true
+	}
+
+	return actualLRPInfo.ActualLRP != nil && actualLRPInfo.ActualLRP.CellId == watcher.cellID
+}
+
 func (watcher *Watcher) handleDesiredCreate(logger lager.Logger, schedulingInfo *models.DesiredLRPSchedulingInfo) {
 	logger = logger.Session("handle-desired-create", desiredLRPData(schedulingInfo))
 	logger.Info("starting")
 	defer logger.Info("complete")
 
 	watcher.setRoutesForDesired(logger, schedulingInfo)
+	watcher.setTCPRoutesForDesired(logger, schedulingInfo)
 }
 
 func (watcher *Watcher) handleDesiredUpdate(logger lager.Logger, before, after *models.DesiredLRPSchedulingInfo) {
@@ -396,25 +804,103 @@ func (watcher *Watcher) handleDesiredUpdate(logger lager.Logger, before, after *
 			watcher.emitMessages(logger, messagesToEmit)
 		}
 	}
+
+	afterTCPKeysSet := watcher.setTCPRoutesForDesired(logger, after)
+
+	for _, key := range routing_table.TCPRoutingKeysFromSchedulingInfo(before) {
+		if !afterTCPKeysSet.contains(key) {
+			messagesToEmit := watcher.tcpTable.RemoveRoutes(key, &after.ModificationTag)
+			watcher.emitTCPMessages(logger, messagesToEmit)
+		}
+	}
 }
 
 func (watcher *Watcher) setRoutesForDesired(logger lager.Logger, schedulingInfo *models.DesiredLRPSchedulingInfo) set {
-	routingKeys := routing_table.RoutingKeysFromSchedulingInfo(schedulingInfo)
-	routes, _ := cfroutes.CFRoutesFromRoutingInfo(schedulingInfo.Routes)
+	hostnamePolicy, routeServicePolicy := watcher.currentPolicies()
+	routesByRoutingKey, rejections := routing_table.RoutesFromSchedulingInfo(schedulingInfo, hostnamePolicy, routeServicePolicy)
+	current := reportRejections(logger, watcher.rejections, rejections)
+	if routes, ok := current[schedulingInfo.ProcessGuid]; ok {
+		watcher.rejections[schedulingInfo.ProcessGuid] = routes
+	} else {
+		delete(watcher.rejections, schedulingInfo.ProcessGuid)
+	}
+
 	routingKeySet := set{}
+	for _, key := range routing_table.RoutingKeysFromSchedulingInfo(schedulingInfo) {
+		routes, found := routesByRoutingKey[key]
+		if !found || routingKeySet.contains(key) {
+			continue
+		}
 
-	for _, key := range routingKeys {
 		routingKeySet.add(key)
-		for _, route := range routes {
-			if key.ContainerPort == route.Port {
-				messagesToEmit := watcher.table.SetRoutes(key, routing_table.Routes{
-					Hostnames:       route.Hostnames,
-					LogGuid:         schedulingInfo.LogGuid,
-					RouteServiceUrl: route.RouteServiceUrl,
-				})
-				watcher.emitMessages(logger, messagesToEmit)
-			}
+		messagesToEmit := watcher.table.SetRoutes(key, routes)
+		watcher.emitMessages(logger, messagesToEmit)
+	}
+
+	return routingKeySet
+}
+
+// reportRejections logs and counts the rejections that previous does not
+// already hold, and returns all of them by process guid.
+func reportRejections(
+	logger lager.Logger,
+	previous map[string]map[rejectedRoute]struct{},
+	rejections []routing_table.RouteRejection,
+) map[string]map[rejectedRoute]struct{} {
+	current := map[string]map[rejectedRoute]struct{}{}
+
+	var newRejections, routeServiceRejections uint64
+	for _, rejection := range rejections {
+		route := rejectedRoute{
+			URI:             rejection.URI,
+			RouteServiceUrl: rejection.RouteServiceUrl,
+			Reason:          rejection.Err.Error(),
+		}
+
+		routes, ok := current[rejection.ProcessGuid]
+		if !ok {
+			routes = map[rejectedRoute]struct{}{}
+			current[rejection.ProcessGuid] = routes
+		}
+		if _, ok := routes[route]; ok {
+			continue
+		}
+		routes[route] = struct{}{}
+
+		if _, ok := previous[rejection.ProcessGuid][route]; ok {
+			continue
 		}
+
+		newRejections++
+		data := lager.Data{
+			"process-guid": rejection.ProcessGuid,
+			"route":        rejection.URI,
+			"reason":       rejection.Err.Error(),
+		}
+		if rejection.RouteServiceUrl != "" {
+			data["route-service-url"] = rejection.RouteServiceUrl
+			routeServiceRejections++
+		}
+		logger.Info("rejected-route", data)
+	}
+
+	if newRejections > 0 {
+		routesRejected.Add(newRejections)
+	}
+	if routeServiceRejections > 0 {
+		routeServiceRoutesRejected.Add(routeServiceRejections)
+	}
+
+	return current
+}
+
+func (watcher *Watcher) setTCPRoutesForDesired(logger lager.Logger, schedulingInfo *models.DesiredLRPSchedulingInfo) set {
+	routingKeySet := set{}
+
+	for key, routes := range routing_table.TCPRoutesFromSchedulingInfo(schedulingInfo) {
+		routingKeySet.add(key)
+		messagesToEmit := watcher.tcpTable.SetRoutes(key, routes)
+		watcher.emitTCPMessages(logger, messagesToEmit)
 	}
 
 	return routingKeySet
@@ -425,11 +911,19 @@ func (watcher *Watcher) handleDesiredDelete(logger lager.Logger, schedulingInfo
 	logger.Info("starting")
 	defer logger.Info("complete")
 
+	delete(watcher.rejections, schedulingInfo.ProcessGuid)
+
 	for _, key := range routing_table.RoutingKeysFromSchedulingInfo(schedulingInfo) {
 		messagesToEmit := watcher.table.RemoveRoutes(key, &schedulingInfo.ModificationTag)
 
 		watcher.emitMessages(logger, messagesToEmit)
 	}
+
+	for _, key := range routing_table.TCPRoutingKeysFromSchedulingInfo(schedulingInfo) {
+		messagesToEmit := watcher.tcpTable.RemoveRoutes(key, &schedulingInfo.ModificationTag)
+
+		watcher.emitTCPMessages(logger, messagesToEmit)
+	}
 }
 
 func (watcher *Watcher) handleActualCreate(logger lager.Logger, actualLRPInfo *routing_table.ActualLRPRoutingInfo) {
@@ -481,6 +975,9 @@ func (watcher *Watcher) addAndEmit(logger lager.Logger, actualLRPInfo *routing_t
 			if key.ContainerPort == endpoint.ContainerPort {
 				messagesToEmit := watcher.table.AddEndpoint(key, endpoint)
 				watcher.emitMessages(logger, messagesToEmit)
+
+				tcpMessagesToEmit := watcher.tcpTable.AddEndpoint(key, endpoint)
+				watcher.emitTCPMessages(logger, tcpMessagesToEmit)
 			}
 		}
 	}
@@ -499,23 +996,99 @@ func (watcher *Watcher) removeAndEmit(logger lager.Logger, actualLRPInfo *routin
 			if key.ContainerPort == endpoint.ContainerPort {
 				messagesToEmit := watcher.table.RemoveEndpoint(key, endpoint)
 				watcher.emitMessages(logger, messagesToEmit)
+
+				tcpMessagesToEmit := watcher.tcpTable.RemoveEndpoint(key, endpoint)
+				watcher.emitTCPMessages(logger, tcpMessagesToEmit)
 			}
 		}
 	}
 }
 
 func (watcher *Watcher) emitMessages(logger lager.Logger, messagesToEmit routing_table.MessagesToEmit) {
-	if watcher.emitter != nil {
-		logger.Debug("emit-messages", lager.Data{"messages": messagesToEmit})
-		watcher.emitter.Emit(messagesToEmit)
-		routesRegistered.Add(messagesToEmit.RouteRegistrationCount())
-		routesUnregistered.Add(messagesToEmit.RouteUnregistrationCount())
+	if watcher.emitter == nil {
+		return
+	}
+
+	if watcher.coalesceWindow > 0 {
+		watcher.coalesce(messagesToEmit)
+		return
+	}
+
+	watcher.sendMessages(logger, messagesToEmit)
+}
+
+func (watcher *Watcher) sendMessages(logger lager.Logger, messagesToEmit routing_table.MessagesToEmit) {
+	logger.Debug("emit-messages", lager.Data{"messages": messagesToEmit})
+	watcher.emitter.Emit(messagesToEmit)
+	routesRegistered.Add(messagesToEmit.RouteRegistrationCount())
+	routesUnregistered.Add(messagesToEmit.RouteUnregistrationCount())
+}
+
+// coalesce holds messages until no new ones have arrived for the coalescing
+// window, or until the oldest held message has waited for the maximum
+// latency, whichever comes first.
+func (watcher *Watcher) coalesce(messagesToEmit routing_table.MessagesToEmit) {
+	if len(messagesToEmit.RegistrationMessages) == 0 && len(messagesToEmit.UnregistrationMessages) == 0 {
+		return
+	}
+
+	now := watcher.clock.Now()
+	if watcher.coalescer.Len() == 0 {
+		watcher.coalesceStartedAt = now
+	}
+	watcher.coalescer.Add(messagesToEmit)
+
+	flushIn := watcher.coalesceWindow
+	if watcher.coalesceMaxLatency > 0 {
+		if untilDeadline := watcher.coalesceStartedAt.Add(watcher.coalesceMaxLatency).Sub(now); untilDeadline < flushIn {
+			flushIn = untilDeadline
+		}
+	}
+
+	if watcher.coalesceTimer != nil {
+		watcher.coalesceTimer.Stop()
+	}
+	watcher.coalesceTimer = watcher.clock.NewTimer(flushIn)
+}
+
+func (watcher *Watcher) flushCoalesced(logger lager.Logger) {
+	if watcher.coalesceTimer != nil {
+		watcher.coalesceTimer.Stop()
+		watcher.coalesceTimer = nil
+	}
+
+	messagesToEmit, received := watcher.coalescer.Flush()
+	if received == 0 {
+		return
+	}
+
+	emitted := len(messagesToEmit.RegistrationMessages) + len(messagesToEmit.UnregistrationMessages)
+
+	messagesCoalesced.Add(uint64(received))
+	messagesCoalescedEmitted.Add(uint64(emitted))
+	err := messageCoalescingRatio.Send(100 * (received - emitted) / received)
+	if err != nil {
+		logger.Error("failed-to-send-message-coalescing-ratio-metric", err)
+	}
+
+	if emitted > 0 {
+		watcher.sendMessages(logger, messagesToEmit)
+	}
+}
+
+func (watcher *Watcher) emitTCPMessages(logger lager.Logger, messagesToEmit routing_table.TCPMessagesToEmit) {
+	if watcher.tcpEmitter != nil {
+		logger.Debug("emit-tcp-messages", lager.Data{"messages": messagesToEmit})
+		watcher.tcpEmitter.Emit(messagesToEmit)
+		tcpRoutesRegistered.Add(messagesToEmit.RouteRegistrationCount())
+		tcpRoutesUnregistered.Add(messagesToEmit.RouteUnregistrationCount())
 	}
 }
 
 func desiredLRPData(schedulingInfo *models.DesiredLRPSchedulingInfo) lager.Data {
 	logRoutes := make(models.Routes)
 	logRoutes[cfroutes.CF_ROUTER] = schedulingInfo.Routes[cfroutes.CF_ROUTER]
+	logRoutes[tcp_routes.TCP_ROUTER] = schedulingInfo.Routes[tcp_routes.TCP_ROUTER]
 
 	return lager.Data{
 		"process-guid": schedulingInfo.ProcessGuid,
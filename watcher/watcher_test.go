@@ -23,7 +23,11 @@ import (
 	"github.com/cloudfoundry-incubator/route-emitter/nats_emitter/fake_nats_emitter"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
 	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	. "github.com/cloudfoundry-incubator/route-emitter/routing_table/matchers"
+	"github.com/cloudfoundry-incubator/route-emitter/shard/fake_shard"
 	"github.com/cloudfoundry-incubator/route-emitter/syncer"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_emitter/fake_tcp_emitter"
+	"github.com/cloudfoundry-incubator/route-emitter/tcp_routes"
 	"github.com/cloudfoundry-incubator/route-emitter/watcher"
 	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
 	fake_metrics_sender "github.com/cloudfoundry/dropsonde/metric_sender/fake"
@@ -32,6 +36,11 @@ import (
 
 const logGuid = "some-log-guid"
 
+const (
+	resubscribeMinBackoff = time.Second
+	resubscribeMaxBackoff = 10 * time.Second
+)
+
 type EventHolder struct {
 	event models.Event
 }
@@ -55,7 +64,9 @@ var _ = Describe("Watcher", func() {
 		eventSource *eventfakes.FakeEventSource
 		bbsClient   *fake_bbs.FakeClient
 		table       *fake_routing_table.FakeRoutingTable
+		tcpTable    *fake_routing_table.FakeTCPRoutingTable
 		emitter     *fake_nats_emitter.FakeNATSEmitter
+		tcpEmitter  *fake_tcp_emitter.FakeTCPEmitter
 		syncEvents  syncer.Events
 
 		clock          *fakeclock.FakeClock
@@ -65,6 +76,7 @@ var _ = Describe("Watcher", func() {
 		expectedRoutes     []string
 		expectedRoutingKey routing_table.RoutingKey
 		expectedCFRoute    cfroutes.CFRoute
+		expectedRouteTags  map[string]string
 
 		expectedAdditionalRoutes     []string
 		expectedAdditionalRoutingKey routing_table.RoutingKey
@@ -86,7 +98,9 @@ var _ = Describe("Watcher", func() {
 		bbsClient.DomainsReturns([]string{expectedDomain}, nil)
 
 		table = &fake_routing_table.FakeRoutingTable{}
+		tcpTable = &fake_routing_table.FakeTCPRoutingTable{}
 		emitter = &fake_nats_emitter.FakeNATSEmitter{}
+		tcpEmitter = &fake_tcp_emitter.FakeTCPEmitter{}
 		syncEvents = syncer.Events{
 			Sync: make(chan struct{}),
 			Emit: make(chan struct{}),
@@ -101,7 +115,7 @@ var _ = Describe("Watcher", func() {
 
 		clock = fakeclock.NewFakeClock(time.Now())
 
-		watcherProcess = watcher.NewWatcher(bbsClient, clock, table, emitter, syncEvents, logger)
+		watcherProcess = watcher.NewWatcher(bbsClient, clock, table, tcpTable, emitter, tcpEmitter, 0, 0, resubscribeMinBackoff, resubscribeMaxBackoff, "", nil, syncEvents, logger)
 
 		expectedRoutes = []string{"route-1", "route-2"}
 		expectedCFRoute = cfroutes.CFRoute{Hostnames: expectedRoutes, Port: expectedContainerPort, RouteServiceUrl: expectedRouteServiceUrl}
@@ -109,6 +123,7 @@ var _ = Describe("Watcher", func() {
 			ProcessGuid:   expectedProcessGuid,
 			ContainerPort: expectedContainerPort,
 		}
+		expectedRouteTags = map[string]string{"process_guid": expectedProcessGuid}
 
 		expectedAdditionalRoutes = []string{"additional-1", "additional-2"}
 		expectedAdditionalCFRoute = cfroutes.CFRoute{Hostnames: expectedAdditionalRoutes, Port: expectedAdditionalContainerPort}
@@ -162,6 +177,23 @@ var _ = Describe("Watcher", func() {
 			syncEvents.Sync <- struct{}{}
 			Eventually(bbsClient.SubscribeToEventsCallCount).Should(BeNumerically(">", 0))
 		})
+
+		It("does not emit an empty table", func() {
+			Consistently(emitter.EmitCallCount).Should(Equal(0))
+		})
+
+		Context("when asked to emit on start", func() {
+			BeforeEach(func() {
+				table.MessagesToEmitReturns(dummyMessagesToEmit)
+				watcherProcess.EmitOnStart()
+			})
+
+			It("emits them without waiting for a sync", func() {
+				Eventually(emitter.EmitCallCount).Should(Equal(1))
+				Expect(emitter.EmitArgsForCall(0)).To(Equal(dummyMessagesToEmit))
+				Expect(bbsClient.SubscribeToEventsCallCount()).To(Equal(0))
+			})
+		})
 	})
 
 	Describe("Desired LRP changes", func() {
@@ -199,7 +231,111 @@ var _ = Describe("Watcher", func() {
 
 				key, routes := table.SetRoutesArgsForCall(0)
 				Expect(key).To(Equal(expectedRoutingKey))
-				Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl}))
+				Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl, Tags: expectedRouteTags}))
+			})
+
+			Context("when the route has a context path", func() {
+				BeforeEach(func() {
+					pathRoute := expectedCFRoute
+					pathRoute.Path = "/api"
+					routes := cfroutes.CFRoutes{pathRoute}.RoutingInfo()
+					desiredLRP.Routes = &routes
+				})
+
+				It("sets the hostnames with the path on the table", func() {
+					Eventually(table.SetRoutesCallCount).Should(Equal(1))
+
+					_, routes := table.SetRoutesArgsForCall(0)
+					Expect(routes.Hostnames).To(Equal([]string{"route-1/api", "route-2/api"}))
+				})
+			})
+
+			Context("when the route has an invalid context path", func() {
+				BeforeEach(func() {
+					invalidRoute := expectedCFRoute
+					invalidRoute.Path = "/api/../admin"
+					routes := cfroutes.CFRoutes{invalidRoute, expectedAdditionalCFRoute}.RoutingInfo()
+					desiredLRP.Routes = &routes
+				})
+
+				It("logs the invalid route and sets only the valid ones", func() {
+					Eventually(logger).Should(Say("rejected-route"))
+					Eventually(table.SetRoutesCallCount).Should(Equal(1))
+
+					key, _ := table.SetRoutesArgsForCall(0)
+					Expect(key).To(Equal(expectedAdditionalRoutingKey))
+				})
+			})
+
+			Context("when the hostname policy rejects some of the hostnames", func() {
+				BeforeEach(func() {
+					policy, err := routing_table.NewHostnamePolicy(routing_table.HostnameRules{Denied: []string{"route-2"}})
+					Expect(err).NotTo(HaveOccurred())
+					watcherProcess.SetHostnamePolicy(policy)
+				})
+
+				It("sets only the allowed hostnames on the table", func() {
+					Eventually(table.SetRoutesCallCount).Should(Equal(1))
+
+					_, routes := table.SetRoutesArgsForCall(0)
+					Expect(routes.Hostnames).To(Equal([]string{"route-1"}))
+				})
+
+				It("logs and counts the rejected route", func() {
+					Eventually(logger).Should(Say(`rejected-route.*"reason":"hostname is denied","route":"route-2"`))
+					Eventually(func() uint64 {
+						return fakeMetricSender.GetCounter("RoutesRejected")
+					}).Should(BeEquivalentTo(1))
+				})
+			})
+
+			Context("when the route service policy rejects the route service", func() {
+				BeforeEach(func() {
+					insecureRoute := expectedCFRoute
+					insecureRoute.RouteServiceUrl = "http://so.good.com"
+					routes := cfroutes.CFRoutes{insecureRoute, expectedAdditionalCFRoute}.RoutingInfo()
+					desiredLRP.Routes = &routes
+
+					policy, err := routing_table.NewRouteServicePolicy(routing_table.RouteServiceRules{})
+					Expect(err).NotTo(HaveOccurred())
+					watcherProcess.SetRouteServicePolicy(policy)
+				})
+
+				It("does not set the routes bound to it", func() {
+					Eventually(table.SetRoutesCallCount).Should(Equal(1))
+
+					key, _ := table.SetRoutesArgsForCall(0)
+					Expect(key).To(Equal(expectedAdditionalRoutingKey))
+				})
+
+				It("logs and counts the rejected routes", func() {
+					Eventually(logger).Should(Say(`rejected-route.*"reason":"route service url must use https","route":"route-1","route-service-url":"http://so.good.com"`))
+					Eventually(func() uint64 {
+						return fakeMetricSender.GetCounter("RouteServiceRoutesRejected")
+					}).Should(BeEquivalentTo(2))
+				})
+			})
+
+			Context("when the route is weighted and the desired LRP carries app metadata", func() {
+				BeforeEach(func() {
+					weightedRoute := expectedCFRoute
+					weightedRoute.Weight = 4
+					routes := cfroutes.CFRoutes{weightedRoute}.RoutingInfo()
+					desiredLRP.Routes = &routes
+					desiredLRP.Annotation = `{"app_name":"my-app","space_name":"my-space"}`
+				})
+
+				It("sets the weight and tags on the table", func() {
+					Eventually(table.SetRoutesCallCount).Should(Equal(1))
+
+					_, routes := table.SetRoutesArgsForCall(0)
+					Expect(routes.Weight).To(Equal(uint32(4)))
+					Expect(routes.Tags).To(Equal(map[string]string{
+						"process_guid": expectedProcessGuid,
+						"app_name":     "my-app",
+						"space_name":   "my-space",
+					}))
+				})
 			})
 
 			It("sends a 'routes registered' metric", func() {
@@ -264,11 +400,11 @@ var _ = Describe("Watcher", func() {
 
 					key, routes := table.SetRoutesArgsForCall(0)
 					Expect(key).To(Equal(expectedRoutingKey))
-					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl}))
+					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl, Tags: expectedRouteTags}))
 
 					key, routes = table.SetRoutesArgsForCall(1)
 					Expect(key).To(Equal(expectedAdditionalRoutingKey))
-					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedAdditionalRoutes, LogGuid: logGuid}))
+					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedAdditionalRoutes, LogGuid: logGuid, Tags: expectedRouteTags}))
 				})
 
 				It("emits whatever the table tells it to emit", func() {
@@ -324,7 +460,23 @@ var _ = Describe("Watcher", func() {
 				Eventually(table.SetRoutesCallCount).Should(Equal(1))
 				key, routes := table.SetRoutesArgsForCall(0)
 				Expect(key).To(Equal(expectedRoutingKey))
-				Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid}))
+				Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, Tags: expectedRouteTags}))
+			})
+
+			Context("when only the context path changes", func() {
+				BeforeEach(func() {
+					routes := cfroutes.CFRoutes{{Hostnames: expectedRoutes, Port: expectedContainerPort, Path: "/v2"}}.RoutingInfo()
+					changedDesiredLRP.Routes = &routes
+				})
+
+				It("updates the routes in place instead of removing them", func() {
+					Eventually(table.SetRoutesCallCount).Should(Equal(1))
+					key, routes := table.SetRoutesArgsForCall(0)
+					Expect(key).To(Equal(expectedRoutingKey))
+					Expect(routes.Hostnames).To(Equal([]string{"route-1/v2", "route-2/v2"}))
+
+					Consistently(table.RemoveRoutesCallCount).Should(Equal(0))
+				})
 			})
 
 			It("sends a 'routes registered' metric", func() {
@@ -391,7 +543,7 @@ var _ = Describe("Watcher", func() {
 
 					key, routes := table.SetRoutesArgsForCall(0)
 					Expect(key).To(Equal(expectedRoutingKey))
-					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl}))
+					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl, Tags: expectedRouteTags}))
 				})
 
 				It("emits whatever the table tells it to emit", func() {
@@ -413,11 +565,11 @@ var _ = Describe("Watcher", func() {
 
 					key, routes := table.SetRoutesArgsForCall(0)
 					Expect(key).To(Equal(expectedRoutingKey))
-					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl}))
+					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedRoutes, LogGuid: logGuid, RouteServiceUrl: expectedRouteServiceUrl, Tags: expectedRouteTags}))
 
 					key, routes = table.SetRoutesArgsForCall(1)
 					Expect(key).To(Equal(expectedAdditionalRoutingKey))
-					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedAdditionalRoutes, LogGuid: logGuid}))
+					Expect(routes).To(Equal(routing_table.Routes{Hostnames: expectedAdditionalRoutes, LogGuid: logGuid, Tags: expectedRouteTags}))
 				})
 
 				It("emits whatever the table tells it to emit", func() {
@@ -562,6 +714,392 @@ var _ = Describe("Watcher", func() {
 		})
 	})
 
+	Describe("coalescing messages", func() {
+		var (
+			actualLRPGroup *models.ActualLRPGroup
+			endpoint       routing_table.Endpoint
+		)
+
+		BeforeEach(func() {
+			watcherProcess = watcher.NewWatcher(bbsClient, clock, table, tcpTable, emitter, tcpEmitter, time.Second, 5*time.Second, resubscribeMinBackoff, resubscribeMaxBackoff, "", nil, syncEvents, logger)
+
+			actualLRPGroup = &models.ActualLRPGroup{
+				Instance: &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey(expectedProcessGuid, 1, "domain"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey(expectedInstanceGuid, "cell-id"),
+					ActualLRPNetInfo:     models.NewActualLRPNetInfo(expectedHost, models.NewPortMapping(expectedExternalPort, expectedContainerPort)),
+					State:                models.ActualLRPStateRunning,
+				},
+			}
+			endpoint = routing_table.Endpoint{InstanceGuid: expectedInstanceGuid, Host: expectedHost, Port: expectedExternalPort}
+
+			table.AddEndpointStub = func(key routing_table.RoutingKey, e routing_table.Endpoint) routing_table.MessagesToEmit {
+				return routing_table.MessagesToEmit{
+					RegistrationMessages: []routing_table.RegistryMessage{
+						routing_table.RegistryMessageFor(endpoint, routing_table.Routes{Hostnames: []string{"foo.com", "bar.com"}, LogGuid: logGuid}),
+					},
+				}
+			}
+		})
+
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(table.SwapCallCount).Should(Equal(1))
+		})
+
+		sendEvent := func(event models.Event) {
+			nextEvent.Store(EventHolder{event})
+			Eventually(func() interface{} { return nextEvent.Load() }).Should(Equal(nilEventHolder))
+		}
+
+		It("emits the merged messages once the window passes without new events", func() {
+			sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+			Eventually(table.AddEndpointCallCount).Should(Equal(1))
+			sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+			Eventually(table.AddEndpointCallCount).Should(Equal(2))
+
+			Consistently(emitter.EmitCallCount).Should(Equal(0))
+
+			clock.Increment(time.Second)
+			Eventually(emitter.EmitCallCount).Should(Equal(1))
+			Expect(emitter.EmitArgsForCall(0)).To(MatchMessagesToEmit(routing_table.MessagesToEmit{
+				RegistrationMessages: []routing_table.RegistryMessage{
+					routing_table.RegistryMessageFor(endpoint, routing_table.Routes{Hostnames: []string{"foo.com", "bar.com"}, LogGuid: logGuid}),
+				},
+			}))
+
+			Expect(fakeMetricSender.GetCounter("MessagesCoalesced")).To(BeEquivalentTo(2))
+			Expect(fakeMetricSender.GetCounter("MessagesCoalescedEmitted")).To(BeEquivalentTo(1))
+			Expect(fakeMetricSender.GetValue("MessageCoalescingPercent").Value).To(BeEquivalentTo(50))
+		})
+
+		Context("when an endpoint is registered and unregistered within the window", func() {
+			BeforeEach(func() {
+				table.RemoveEndpointStub = func(key routing_table.RoutingKey, e routing_table.Endpoint) routing_table.MessagesToEmit {
+					return routing_table.MessagesToEmit{
+						UnregistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint, routing_table.Routes{Hostnames: []string{"foo.com", "bar.com"}, LogGuid: logGuid}),
+						},
+					}
+				}
+				table.EntriesReturns(map[routing_table.RoutingKey]routing_table.RoutableEndpoints{})
+			})
+
+			It("emits nothing", func() {
+				sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+				Eventually(table.AddEndpointCallCount).Should(Equal(1))
+				sendEvent(models.NewActualLRPRemovedEvent(actualLRPGroup))
+				Eventually(table.RemoveEndpointCallCount).Should(Equal(1))
+
+				clock.Increment(time.Second)
+				Eventually(func() uint64 { return fakeMetricSender.GetCounter("MessagesCoalesced") }).Should(BeEquivalentTo(2))
+				Expect(emitter.EmitCallCount()).To(Equal(0))
+				Expect(table.EntriesCallCount()).To(Equal(1))
+			})
+
+			Context("and the table had the endpoint before the window", func() {
+				BeforeEach(func() {
+					table.EntriesReturns(map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+						{ProcessGuid: expectedProcessGuid, ContainerPort: expectedContainerPort}: {
+							Hostnames: map[string]struct{}{"foo.com": struct{}{}},
+							Endpoints: map[routing_table.EndpointKey]routing_table.Endpoint{{InstanceGuid: expectedInstanceGuid}: endpoint},
+						},
+					})
+				})
+
+				It("keeps the unregistration of the routes it had", func() {
+					sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+					Eventually(table.AddEndpointCallCount).Should(Equal(1))
+					sendEvent(models.NewActualLRPRemovedEvent(actualLRPGroup))
+					Eventually(table.RemoveEndpointCallCount).Should(Equal(1))
+
+					clock.Increment(time.Second)
+					Eventually(emitter.EmitCallCount).Should(Equal(1))
+					Expect(emitter.EmitArgsForCall(0)).To(MatchMessagesToEmit(routing_table.MessagesToEmit{
+						UnregistrationMessages: []routing_table.RegistryMessage{
+							routing_table.RegistryMessageFor(endpoint, routing_table.Routes{Hostnames: []string{"foo.com"}, LogGuid: logGuid}),
+						},
+					}))
+				})
+			})
+		})
+
+		Context("when events keep arriving", func() {
+			It("flushes once the maximum latency is reached", func() {
+				for i := 1; i <= 5; i++ {
+					sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+					Eventually(table.AddEndpointCallCount).Should(Equal(i))
+					Eventually(clock.WatcherCount).Should(Equal(1))
+					clock.Increment(999 * time.Millisecond)
+				}
+				Expect(emitter.EmitCallCount()).To(Equal(0))
+
+				sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+				Eventually(table.AddEndpointCallCount).Should(Equal(6))
+				Eventually(clock.WatcherCount).Should(Equal(1))
+				clock.Increment(5 * time.Millisecond)
+
+				Eventually(emitter.EmitCallCount).Should(Equal(1))
+			})
+		})
+
+		It("flushes pending messages before a periodic emit", func() {
+			sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+			Eventually(table.AddEndpointCallCount).Should(Equal(1))
+
+			syncEvents.Emit <- struct{}{}
+			Eventually(emitter.EmitCallCount).Should(Equal(2))
+			Expect(emitter.EmitArgsForCall(0).RegistrationMessages).To(HaveLen(1))
+		})
+
+		Context("when events are cached during a sync", func() {
+			var (
+				syncs   int32
+				release chan struct{}
+			)
+
+			BeforeEach(func() {
+				syncs = 0
+				release = make(chan struct{})
+
+				bbsClient.DesiredLRPSchedulingInfosStub = func(lager.Logger, models.DesiredLRPFilter) ([]*models.DesiredLRPSchedulingInfo, error) {
+					if atomic.AddInt32(&syncs, 1) > 1 {
+						<-release
+					}
+					return nil, nil
+				}
+			})
+
+			It("begins the next window from the watcher's table rather than the synced one", func() {
+				syncEvents.Sync <- struct{}{}
+				Eventually(func() int32 { return atomic.LoadInt32(&syncs) }).Should(Equal(int32(2)))
+
+				sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+				Eventually(logger).Should(Say("caching-event"))
+
+				close(release)
+				Eventually(table.SwapCallCount).Should(Equal(2))
+				Expect(table.EntriesCallCount()).To(Equal(0))
+
+				sendEvent(models.NewActualLRPCreatedEvent(actualLRPGroup))
+				Eventually(table.AddEndpointCallCount).Should(Equal(1))
+				Expect(table.EntriesCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("restricting the watcher to a single cell", func() {
+		var localLRP, remoteLRP *models.ActualLRP
+
+		newActualLRP := func(instanceGuid, cellID string) *models.ActualLRP {
+			return &models.ActualLRP{
+				ActualLRPKey:         models.NewActualLRPKey(expectedProcessGuid, 1, "domain"),
+				ActualLRPInstanceKey: models.NewActualLRPInstanceKey(instanceGuid, cellID),
+				ActualLRPNetInfo:     models.NewActualLRPNetInfo(expectedHost, models.NewPortMapping(expectedExternalPort, expectedContainerPort)),
+				State:                models.ActualLRPStateRunning,
+			}
+		}
+
+		BeforeEach(func() {
+			watcherProcess = watcher.NewWatcher(bbsClient, clock, table, tcpTable, emitter, tcpEmitter, 0, 0, resubscribeMinBackoff, resubscribeMaxBackoff, "local-cell", nil, syncEvents, logger)
+
+			localLRP = newActualLRP("local-instance", "local-cell")
+			remoteLRP = newActualLRP("remote-instance", "remote-cell")
+		})
+
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(emitter.EmitCallCount).ShouldNot(Equal(0))
+		})
+
+		It("only fetches the cell's actual LRPs when syncing", func() {
+			Expect(bbsClient.ActualLRPGroupsCallCount()).To(Equal(1))
+			_, filter := bbsClient.ActualLRPGroupsArgsForCall(0)
+			Expect(filter).To(Equal(models.ActualLRPFilter{CellID: "local-cell"}))
+
+			Expect(bbsClient.DesiredLRPSchedulingInfosCallCount()).To(Equal(1))
+			_, desiredFilter := bbsClient.DesiredLRPSchedulingInfosArgsForCall(0)
+			Expect(desiredFilter).To(Equal(models.DesiredLRPFilter{}))
+		})
+
+		It("adds endpoints for actual LRPs created on the cell", func() {
+			nextEvent.Store(EventHolder{models.NewActualLRPCreatedEvent(&models.ActualLRPGroup{Instance: remoteLRP})})
+			Eventually(func() interface{} { return nextEvent.Load() }).Should(Equal(nilEventHolder))
+			nextEvent.Store(EventHolder{models.NewActualLRPCreatedEvent(&models.ActualLRPGroup{Instance: localLRP})})
+
+			Eventually(table.AddEndpointCallCount).Should(Equal(1))
+			_, endpoint := table.AddEndpointArgsForCall(0)
+			Expect(endpoint.InstanceGuid).To(Equal("local-instance"))
+			Consistently(table.AddEndpointCallCount).Should(Equal(1))
+		})
+
+		It("ignores actual LRPs removed from other cells", func() {
+			nextEvent.Store(EventHolder{models.NewActualLRPRemovedEvent(&models.ActualLRPGroup{Instance: remoteLRP})})
+			Eventually(func() interface{} { return nextEvent.Load() }).Should(Equal(nilEventHolder))
+			Consistently(table.RemoveEndpointCallCount).Should(Equal(0))
+		})
+
+		It("removes the endpoint when an actual LRP moves off the cell", func() {
+			movedLRP := newActualLRP("local-instance", "remote-cell")
+			nextEvent.Store(EventHolder{models.NewActualLRPChangedEvent(
+				&models.ActualLRPGroup{Instance: localLRP},
+				&models.ActualLRPGroup{Instance: movedLRP},
+			)})
+
+			Eventually(table.RemoveEndpointCallCount).Should(Equal(1))
+			_, endpoint := table.RemoveEndpointArgsForCall(0)
+			Expect(endpoint.InstanceGuid).To(Equal("local-instance"))
+			Expect(table.AddEndpointCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("sharding routes between emitters", func() {
+		var (
+			shardFilter  *fake_shard.FakeFilter
+			shardChanged chan struct{}
+		)
+
+		newSchedulingInfo := func(processGuid string) *models.DesiredLRPSchedulingInfo {
+			return &models.DesiredLRPSchedulingInfo{
+				DesiredLRPKey: models.NewDesiredLRPKey(processGuid, "domain", logGuid),
+				Routes: cfroutes.CFRoutes{
+					cfroutes.CFRoute{Hostnames: []string{processGuid + ".example.com"}, Port: expectedContainerPort},
+				}.RoutingInfo(),
+			}
+		}
+
+		newActualLRPGroup := func(processGuid string) *models.ActualLRPGroup {
+			return &models.ActualLRPGroup{
+				Instance: &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey(processGuid, 0, "domain"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey(processGuid+"-instance", "cell-id"),
+					ActualLRPNetInfo:     models.NewActualLRPNetInfo(expectedHost, models.NewPortMapping(expectedExternalPort, expectedContainerPort)),
+					State:                models.ActualLRPStateRunning,
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			shardChanged = make(chan struct{})
+			shardFilter = new(fake_shard.FakeFilter)
+			shardFilter.ChangedReturns(shardChanged)
+			shardFilter.OwnsStub = func(processGuid string) bool {
+				return processGuid == "owned-guid"
+			}
+
+			bbsClient.DesiredLRPSchedulingInfosReturns([]*models.DesiredLRPSchedulingInfo{
+				newSchedulingInfo("owned-guid"),
+				newSchedulingInfo("other-guid"),
+			}, nil)
+			bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{
+				newActualLRPGroup("owned-guid"),
+				newActualLRPGroup("other-guid"),
+			}, nil)
+
+			watcherProcess = watcher.NewWatcher(bbsClient, clock, table, tcpTable, emitter, tcpEmitter, 0, 0, resubscribeMinBackoff, resubscribeMaxBackoff, "", shardFilter, syncEvents, logger)
+		})
+
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(table.SwapCallCount).Should(Equal(1))
+		})
+
+		It("only syncs the routes in its shard", func() {
+			tempTable, _ := table.SwapArgsForCall(0)
+			entries := tempTable.Entries()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries).To(HaveKey(routing_table.RoutingKey{ProcessGuid: "owned-guid", ContainerPort: expectedContainerPort}))
+		})
+
+		It("ignores events for process guids outside its shard", func() {
+			nextEvent.Store(EventHolder{models.NewActualLRPCreatedEvent(newActualLRPGroup("other-guid"))})
+			Eventually(func() interface{} { return nextEvent.Load() }).Should(Equal(nilEventHolder))
+			nextEvent.Store(EventHolder{models.NewActualLRPCreatedEvent(newActualLRPGroup("owned-guid"))})
+
+			Eventually(table.AddEndpointCallCount).Should(Equal(1))
+			key, _ := table.AddEndpointArgsForCall(0)
+			Expect(key.ProcessGuid).To(Equal("owned-guid"))
+			Consistently(table.AddEndpointCallCount).Should(Equal(1))
+		})
+
+		It("resyncs when the shard changes", func() {
+			shardChanged <- struct{}{}
+			Eventually(table.SwapCallCount).Should(Equal(2))
+			Expect(bbsClient.ActualLRPGroupsCallCount()).To(Equal(2))
+		})
+
+		It("forgets the routes of process guids outside its shard before swapping", func() {
+			Expect(table.ForgetProcessGuidsCallCount()).To(Equal(1))
+			forget := table.ForgetProcessGuidsArgsForCall(0)
+			Expect(forget("other-guid")).To(BeTrue())
+			Expect(forget("owned-guid")).To(BeFalse())
+
+			Expect(tcpTable.ForgetProcessGuidsCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("enforcing a hostname policy during syncs", func() {
+		BeforeEach(func() {
+			bbsClient.DesiredLRPSchedulingInfosReturns([]*models.DesiredLRPSchedulingInfo{
+				{
+					DesiredLRPKey: models.NewDesiredLRPKey("app-guid", "domain", logGuid),
+					Routes: cfroutes.CFRoutes{
+						{Hostnames: []string{"app.apps.example.com", "uaa.system.example.com"}, Port: expectedContainerPort},
+					}.RoutingInfo(),
+				},
+				{
+					DesiredLRPKey: models.NewDesiredLRPKey("uaa-guid", "domain", logGuid),
+					Routes: cfroutes.CFRoutes{
+						{Hostnames: []string{"uaa.system.example.com"}, Port: expectedContainerPort},
+					}.RoutingInfo(),
+				},
+			}, nil)
+
+			policy, err := routing_table.NewHostnamePolicy(routing_table.HostnameRules{
+				Allowed: []string{"*.apps.example.com"},
+				Reserved: []routing_table.ReservedHostnames{
+					{Hostnames: []string{"*.system.example.com"}, ProcessGuids: []string{"uaa-guid"}},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			watcherProcess.SetHostnamePolicy(policy)
+		})
+
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(table.SwapCallCount).Should(Equal(1))
+		})
+
+		It("only syncs the hostnames the policy allows", func() {
+			tempTable, _ := table.SwapArgsForCall(0)
+			entries := tempTable.Entries()
+
+			appEntry := entries[routing_table.RoutingKey{ProcessGuid: "app-guid", ContainerPort: expectedContainerPort}]
+			Expect(appEntry.Hostnames).To(Equal(map[string]struct{}{"app.apps.example.com": struct{}{}}))
+
+			uaaEntry := entries[routing_table.RoutingKey{ProcessGuid: "uaa-guid", ContainerPort: expectedContainerPort}]
+			Expect(uaaEntry.Hostnames).To(Equal(map[string]struct{}{"uaa.system.example.com": struct{}{}}))
+		})
+
+		It("counts the rejected routes", func() {
+			Eventually(func() uint64 {
+				return fakeMetricSender.GetCounter("RoutesRejected")
+			}).Should(BeEquivalentTo(1))
+		})
+
+		It("does not report the same rejections again on the next sync", func() {
+			Eventually(func() uint64 {
+				return fakeMetricSender.GetCounter("RoutesRejected")
+			}).Should(BeEquivalentTo(1))
+
+			syncEvents.Sync <- struct{}{}
+			Eventually(table.SwapCallCount).Should(Equal(2))
+			Consistently(func() uint64 {
+				return fakeMetricSender.GetCounter("RoutesRejected")
+			}).Should(BeEquivalentTo(1))
+		})
+	})
+
 	Describe("Actual LRP changes", func() {
 		JustBeforeEach(func() {
 			syncEvents.Sync <- struct{}{}
@@ -632,6 +1170,24 @@ var _ = Describe("Watcher", func() {
 					Expect(endpoint).To(Equal(endpoints[key.ContainerPort]))
 				})
 
+				Context("when the last sync found the zone of the actual's cell", func() {
+					BeforeEach(func() {
+						bbsClient.CellsReturns([]*models.CellPresence{
+							{CellId: "cell-id", Zone: "z1"},
+							{CellId: "other-cell-id", Zone: "z2"},
+						}, nil)
+					})
+
+					It("adds the endpoints with the cell's availability zone", func() {
+						Eventually(table.AddEndpointCallCount).Should(Equal(2))
+
+						_, endpoint := table.AddEndpointArgsForCall(0)
+						Expect(endpoint.CellId).To(Equal("cell-id"))
+						Expect(endpoint.Index).To(BeEquivalentTo(1))
+						Expect(endpoint.AvailabilityZone).To(Equal("z1"))
+					})
+				})
+
 				It("should emit whatever the table tells it to emit", func() {
 					Eventually(emitter.EmitCallCount).Should(Equal(3))
 
@@ -744,6 +1300,8 @@ var _ = Describe("Watcher", func() {
 					Expect(key).To(Equal(expectedRoutingKey))
 					Expect(endpoint).To(Equal(routing_table.Endpoint{
 						InstanceGuid:  expectedInstanceGuid,
+						Index:         1,
+						CellId:        "cell-id",
 						Host:          expectedHost,
 						Domain:        expectedDomain,
 						Port:          expectedExternalPort,
@@ -754,6 +1312,8 @@ var _ = Describe("Watcher", func() {
 					Expect(key).To(Equal(expectedAdditionalRoutingKey))
 					Expect(endpoint).To(Equal(routing_table.Endpoint{
 						InstanceGuid:  expectedInstanceGuid,
+						Index:         1,
+						CellId:        "cell-id",
 						Host:          expectedHost,
 						Domain:        expectedDomain,
 						Port:          expectedAdditionalExternalPort,
@@ -827,6 +1387,8 @@ var _ = Describe("Watcher", func() {
 					Expect(key).To(Equal(expectedRoutingKey))
 					Expect(endpoint).To(Equal(routing_table.Endpoint{
 						InstanceGuid:  expectedInstanceGuid,
+						Index:         1,
+						CellId:        "cell-id",
 						Host:          expectedHost,
 						Domain:        expectedDomain,
 						Port:          expectedExternalPort,
@@ -837,6 +1399,8 @@ var _ = Describe("Watcher", func() {
 					Expect(key).To(Equal(expectedAdditionalRoutingKey))
 					Expect(endpoint).To(Equal(routing_table.Endpoint{
 						InstanceGuid:  expectedInstanceGuid,
+						Index:         1,
+						CellId:        "cell-id",
 						Host:          expectedHost,
 						Domain:        expectedDomain,
 						Port:          expectedAdditionalExternalPort,
@@ -941,6 +1505,8 @@ var _ = Describe("Watcher", func() {
 					Expect(key).To(Equal(expectedRoutingKey))
 					Expect(endpoint).To(Equal(routing_table.Endpoint{
 						InstanceGuid:  expectedInstanceGuid,
+						Index:         1,
+						CellId:        "cell-id",
 						Host:          expectedHost,
 						Domain:        expectedDomain,
 						Port:          expectedExternalPort,
@@ -951,6 +1517,8 @@ var _ = Describe("Watcher", func() {
 					Expect(key).To(Equal(expectedAdditionalRoutingKey))
 					Expect(endpoint).To(Equal(routing_table.Endpoint{
 						InstanceGuid:  expectedInstanceGuid,
+						Index:         1,
+						CellId:        "cell-id",
 						Host:          expectedHost,
 						Domain:        expectedDomain,
 						Port:          expectedAdditionalExternalPort,
@@ -1044,7 +1612,26 @@ var _ = Describe("Watcher", func() {
 		})
 
 		It("re-subscribes", func() {
-			Eventually(bbsClient.SubscribeToEventsCallCount, 2*time.Second).Should(BeNumerically(">", 5))
+			Eventually(func() int {
+				clock.Increment(resubscribeMaxBackoff)
+				return bbsClient.SubscribeToEventsCallCount()
+			}, 2*time.Second).Should(BeNumerically(">", 5))
+		})
+
+		It("backs off exponentially between attempts", func() {
+			Eventually(bbsClient.SubscribeToEventsCallCount).Should(Equal(1))
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			Consistently(bbsClient.SubscribeToEventsCallCount).Should(Equal(1))
+
+			clock.Increment(resubscribeMinBackoff)
+			Eventually(bbsClient.SubscribeToEventsCallCount).Should(Equal(2))
+			Eventually(clock.WatcherCount).Should(Equal(1))
+
+			clock.Increment(resubscribeMinBackoff / 2)
+			Consistently(bbsClient.SubscribeToEventsCallCount).Should(Equal(2))
+
+			clock.Increment(3 * resubscribeMinBackoff / 2)
+			Eventually(bbsClient.SubscribeToEventsCallCount).Should(Equal(3))
 		})
 
 		It("does not exit", func() {
@@ -1052,6 +1639,122 @@ var _ = Describe("Watcher", func() {
 		})
 	})
 
+	Context("when the event source recovers after an error", func() {
+		var failNext int32
+
+		BeforeEach(func() {
+			failNext = 0
+
+			var closed int32
+			eventSource.CloseStub = func() error {
+				atomic.StoreInt32(&closed, 1)
+				return nil
+			}
+			eventSource.NextStub = func() (models.Event, error) {
+				time.Sleep(10 * time.Millisecond)
+				if atomic.CompareAndSwapInt32(&failNext, 1, 0) {
+					return nil, errors.New("next-error")
+				}
+				if atomic.LoadInt32(&closed) == 1 {
+					return nil, errors.New("closed")
+				}
+				return nil, nil
+			}
+		})
+
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(table.SwapCallCount).Should(Equal(1))
+		})
+
+		It("re-subscribes and performs a full sync", func() {
+			atomic.StoreInt32(&failNext, 1)
+			Eventually(clock.WatcherCount).Should(Equal(1))
+			clock.Increment(resubscribeMinBackoff)
+
+			Eventually(bbsClient.SubscribeToEventsCallCount).Should(Equal(2))
+			Eventually(table.SwapCallCount).Should(Equal(2))
+			Expect(bbsClient.ActualLRPGroupsCallCount()).To(Equal(2))
+		})
+
+		Context("while a sync is running", func() {
+			var (
+				syncs   int32
+				release chan struct{}
+			)
+
+			BeforeEach(func() {
+				syncs = 0
+				release = make(chan struct{})
+
+				bbsClient.DesiredLRPSchedulingInfosStub = func(lager.Logger, models.DesiredLRPFilter) ([]*models.DesiredLRPSchedulingInfo, error) {
+					if atomic.AddInt32(&syncs, 1) == 2 {
+						<-release
+					}
+					return nil, nil
+				}
+			})
+
+			It("performs another full sync once it completes", func() {
+				syncEvents.Sync <- struct{}{}
+				Eventually(func() int32 { return atomic.LoadInt32(&syncs) }).Should(Equal(int32(2)))
+
+				atomic.StoreInt32(&failNext, 1)
+				Eventually(clock.WatcherCount).Should(Equal(1))
+				clock.Increment(resubscribeMinBackoff)
+				Eventually(logger).Should(Say("resyncing-after-resubscribe"))
+
+				close(release)
+				Eventually(table.SwapCallCount).Should(Equal(3))
+				Expect(bbsClient.ActualLRPGroupsCallCount()).To(Equal(3))
+			})
+		})
+	})
+
+	Describe("observing the watcher", func() {
+		var (
+			syncTimes     chan time.Time
+			subscriptions chan bool
+		)
+
+		BeforeEach(func() {
+			syncTimes = make(chan time.Time, 10)
+			subscriptions = make(chan bool, 10)
+
+			watcherProcess.ObserveSyncs(func(at time.Time) {
+				syncTimes <- at
+			})
+			watcherProcess.ObserveEventSubscription(func(subscribed bool) {
+				subscriptions <- subscribed
+			})
+		})
+
+		It("reports when a sync completes", func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(syncTimes).Should(Receive(Equal(clock.Now())))
+		})
+
+		It("reports when events are subscribed to and when the subscription is lost", func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(subscriptions).Should(Receive(BeTrue()))
+
+			eventSource.Close()
+			Eventually(subscriptions).Should(Receive(BeFalse()))
+		})
+
+		Context("when the sync fails", func() {
+			BeforeEach(func() {
+				bbsClient.DomainsReturns(nil, errors.New("bam"))
+			})
+
+			It("does not report it", func() {
+				syncEvents.Sync <- struct{}{}
+				Eventually(bbsClient.DomainsCallCount).Should(Equal(1))
+				Consistently(syncTimes).ShouldNot(Receive())
+			})
+		})
+	})
+
 	Describe("interrupting the process", func() {
 		It("should be possible to SIGINT the route emitter", func() {
 			process.Signal(os.Interrupt)
@@ -1059,6 +1762,190 @@ var _ = Describe("Watcher", func() {
 		})
 	})
 
+	Describe("TCP routes", func() {
+		const (
+			expectedRouterGroupGuid = "router-group-guid"
+			expectedTCPExternalPort = 61000
+		)
+
+		var (
+			expectedTCPRoute         tcp_routes.TCPRoute
+			dummyTCPMessagesToEmit   routing_table.TCPMessagesToEmit
+			expectedExternalEndpoint routing_table.ExternalEndpointInfo
+		)
+
+		BeforeEach(func() {
+			expectedTCPRoute = tcp_routes.TCPRoute{
+				RouterGroupGuid: expectedRouterGroupGuid,
+				ExternalPort:    expectedTCPExternalPort,
+				ContainerPort:   expectedContainerPort,
+			}
+			expectedExternalEndpoint = routing_table.ExternalEndpointInfo{
+				RouterGroupGuid: expectedRouterGroupGuid,
+				Port:            expectedTCPExternalPort,
+			}
+
+			dummyEndpoint := routing_table.Endpoint{InstanceGuid: expectedInstanceGuid, Host: expectedHost, Port: expectedExternalPort}
+			dummyTCPMessagesToEmit = routing_table.TCPMessagesToEmit{
+				RegistrationMessages: []routing_table.TCPMappingMessage{
+					routing_table.TCPMappingMessageFor(dummyEndpoint, expectedExternalEndpoint),
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			syncEvents.Sync <- struct{}{}
+			Eventually(tcpEmitter.EmitCallCount).ShouldNot(Equal(0))
+		})
+
+		Context("when a desired LRP with tcp routes is created", func() {
+			var desiredLRP *models.DesiredLRP
+
+			BeforeEach(func() {
+				routes := tcp_routes.TCPRoutes{expectedTCPRoute}.RoutingInfo()
+				desiredLRP = &models.DesiredLRP{
+					Action: models.WrapAction(&models.RunAction{
+						User: "me",
+						Path: "ls",
+					}),
+					Domain:      "tests",
+					ProcessGuid: expectedProcessGuid,
+					Ports:       []uint32{expectedContainerPort},
+					Routes:      &routes,
+					LogGuid:     logGuid,
+				}
+			})
+
+			JustBeforeEach(func() {
+				tcpTable.SetRoutesReturns(dummyTCPMessagesToEmit)
+
+				nextEvent.Store(EventHolder{models.NewDesiredLRPCreatedEvent(desiredLRP)})
+			})
+
+			It("sets the tcp routes on the tcp table", func() {
+				Eventually(tcpTable.SetRoutesCallCount).Should(Equal(1))
+
+				key, routes := tcpTable.SetRoutesArgsForCall(0)
+				Expect(key).To(Equal(expectedRoutingKey))
+				Expect(routes).To(Equal(routing_table.TCPRoutes{
+					ExternalEndpoints: []routing_table.ExternalEndpointInfo{expectedExternalEndpoint},
+					LogGuid:           logGuid,
+				}))
+			})
+
+			It("does not set any http routes", func() {
+				Eventually(tcpTable.SetRoutesCallCount).Should(Equal(1))
+				Expect(table.SetRoutesCallCount()).To(Equal(0))
+			})
+
+			It("emits whatever the tcp table tells it to emit", func() {
+				Eventually(tcpEmitter.EmitCallCount).Should(Equal(2))
+				Expect(tcpEmitter.EmitArgsForCall(1)).To(Equal(dummyTCPMessagesToEmit))
+			})
+
+			It("sends a 'tcp routes registered' metric", func() {
+				Eventually(func() uint64 {
+					return fakeMetricSender.GetCounter("TCPRoutesRegistered")
+				}).Should(BeEquivalentTo(1))
+			})
+		})
+
+		Context("when a desired LRP loses its tcp routes", func() {
+			var event models.Event
+
+			BeforeEach(func() {
+				routes := tcp_routes.TCPRoutes{expectedTCPRoute}.RoutingInfo()
+				originalDesiredLRP := &models.DesiredLRP{
+					Action: models.WrapAction(&models.RunAction{
+						User: "me",
+						Path: "ls",
+					}),
+					Domain:      "tests",
+					ProcessGuid: expectedProcessGuid,
+					LogGuid:     logGuid,
+					Routes:      &routes,
+				}
+				changedDesiredLRP := &models.DesiredLRP{
+					Action: models.WrapAction(&models.RunAction{
+						User: "me",
+						Path: "ls",
+					}),
+					Domain:          "tests",
+					ProcessGuid:     expectedProcessGuid,
+					LogGuid:         logGuid,
+					ModificationTag: &models.ModificationTag{Epoch: "abcd", Index: 1},
+				}
+				event = models.NewDesiredLRPChangedEvent(originalDesiredLRP, changedDesiredLRP)
+			})
+
+			JustBeforeEach(func() {
+				nextEvent.Store(EventHolder{event})
+			})
+
+			It("removes the tcp routes from the tcp table", func() {
+				Eventually(tcpTable.RemoveRoutesCallCount).Should(Equal(1))
+
+				key, modTag := tcpTable.RemoveRoutesArgsForCall(0)
+				Expect(key).To(Equal(expectedRoutingKey))
+				Expect(modTag).To(Equal(&models.ModificationTag{Epoch: "abcd", Index: 1}))
+			})
+		})
+
+		Context("when a running actual LRP is created", func() {
+			var event models.Event
+
+			BeforeEach(func() {
+				actualLRP := &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey(expectedProcessGuid, 1, "domain"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey(expectedInstanceGuid, "cell-id"),
+					ActualLRPNetInfo:     models.NewActualLRPNetInfo(expectedHost, models.NewPortMapping(expectedExternalPort, expectedContainerPort)),
+					State:                models.ActualLRPStateRunning,
+				}
+
+				tcpTable.AddEndpointReturns(dummyTCPMessagesToEmit)
+				event = models.NewActualLRPCreatedEvent(&models.ActualLRPGroup{Instance: actualLRP})
+			})
+
+			// events received during the first sync are applied to the synced
+			// table rather than the fake one
+			JustBeforeEach(func() {
+				nextEvent.Store(EventHolder{event})
+			})
+
+			It("adds the endpoint to the tcp table and emits", func() {
+				Eventually(tcpTable.AddEndpointCallCount).Should(Equal(1))
+
+				key, endpoint := tcpTable.AddEndpointArgsForCall(0)
+				Expect(key).To(Equal(expectedRoutingKey))
+				Expect(endpoint.Host).To(Equal(expectedHost))
+				Expect(endpoint.Port).To(BeEquivalentTo(expectedExternalPort))
+
+				Eventually(tcpEmitter.EmitCallCount).Should(Equal(2))
+				Expect(tcpEmitter.EmitArgsForCall(1)).To(Equal(dummyTCPMessagesToEmit))
+			})
+		})
+
+		Context("when the syncer asks for an emit", func() {
+			JustBeforeEach(func() {
+				tcpTable.MessagesToEmitReturns(dummyTCPMessagesToEmit)
+				syncEvents.Emit <- struct{}{}
+			})
+
+			It("emits the whole tcp table", func() {
+				Eventually(tcpEmitter.EmitCallCount).Should(Equal(2))
+				Expect(tcpEmitter.EmitArgsForCall(1)).To(Equal(dummyTCPMessagesToEmit))
+			})
+		})
+
+		Context("when a sync completes", func() {
+			It("swaps the tcp tables", func() {
+				Eventually(tcpTable.SwapCallCount).Should(Equal(1))
+				_, domains := tcpTable.SwapArgsForCall(0)
+				Expect(domains).To(Equal(models.NewDomainSet([]string{expectedDomain})))
+			})
+		})
+	})
+
 	Describe("Sync Events", func() {
 		var nextEvent chan models.Event
 
@@ -1111,8 +1998,8 @@ var _ = Describe("Watcher", func() {
 			currentTag := &models.ModificationTag{Epoch: "abc", Index: 1}
 			hostname1 := "foo.example.com"
 			hostname2 := "bar.example.com"
-			endpoint1 := routing_table.Endpoint{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, ContainerPort: 8080, Evacuating: false, ModificationTag: currentTag}
-			endpoint2 := routing_table.Endpoint{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22, ContainerPort: 8080, Evacuating: false, ModificationTag: currentTag}
+			endpoint1 := routing_table.Endpoint{InstanceGuid: "ig-1", Index: 1, CellId: "cell-id", Domain: "domain", Host: "1.1.1.1", Port: 11, ContainerPort: 8080, Evacuating: false, ModificationTag: currentTag}
+			endpoint2 := routing_table.Endpoint{InstanceGuid: "ig-2", Index: 1, CellId: "cell-id", Domain: "domain", Host: "2.2.2.2", Port: 22, ContainerPort: 8080, Evacuating: false, ModificationTag: currentTag}
 
 			schedulingInfo1 := &models.DesiredLRPSchedulingInfo{
 				DesiredLRPKey: models.NewDesiredLRPKey("pg-1", "tests", "lg1"),
@@ -1293,8 +2180,9 @@ var _ = Describe("Watcher", func() {
 							Evacuating: false,
 						}
 
+						routes, _ := routing_table.RoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{schedulingInfo1, schedulingInfo2}, nil, nil)
 						tempTable := routing_table.NewTempTable(
-							routing_table.RoutesByRoutingKeyFromSchedulingInfos([]*models.DesiredLRPSchedulingInfo{schedulingInfo1, schedulingInfo2}),
+							routes,
 							routing_table.EndpointsByRoutingKeyFromActuals([]*routing_table.ActualLRPRoutingInfo{
 								actualLRPRoutingInfo1,
 								actualLRPRoutingInfo2,
@@ -1302,10 +2190,10 @@ var _ = Describe("Watcher", func() {
 						)
 
 						domains := models.NewDomainSet([]string{"domain"})
-						table := routing_table.NewTable(logger)
+						table := routing_table.NewTable(logger, routing_table.AllowHostnameConflicts)
 						table.Swap(tempTable, domains)
 
-						watcherProcess = watcher.NewWatcher(bbsClient, clock, table, emitter, syncEvents, logger)
+						watcherProcess = watcher.NewWatcher(bbsClient, clock, table, routing_table.NewTCPTable(logger), emitter, tcpEmitter, 0, 0, resubscribeMinBackoff, resubscribeMaxBackoff, "", nil, syncEvents, logger)
 
 						bbsClient.DesiredLRPSchedulingInfosStub = func(logger lager.Logger, f models.DesiredLRPFilter) ([]*models.DesiredLRPSchedulingInfo, error) {
 							defer GinkgoRecover()
@@ -1326,10 +2214,10 @@ var _ = Describe("Watcher", func() {
 						Eventually(emitter.EmitCallCount).Should(Equal(1))
 						Expect(emitter.EmitArgsForCall(0)).To(Equal(routing_table.MessagesToEmit{
 							RegistrationMessages: []routing_table.RegistryMessage{
-								routing_table.RegistryMessageFor(endpoint2, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: "lg2"}),
+								routing_table.RegistryMessageFor(endpoint2, routing_table.Routes{Hostnames: []string{hostname2}, LogGuid: "lg2", Tags: routing_table.RouteTagsFor(schedulingInfo2)}),
 							},
 							UnregistrationMessages: []routing_table.RegistryMessage{
-								routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "lg1", RouteServiceUrl: "https://rs.example.com"}),
+								routing_table.RegistryMessageFor(endpoint1, routing_table.Routes{Hostnames: []string{hostname1}, LogGuid: "lg1", RouteServiceUrl: "https://rs.example.com", Tags: routing_table.RouteTagsFor(schedulingInfo1)}),
 							},
 						}))
 					})
@@ -0,0 +1,232 @@
+package xds_emitter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/routing-info/cfroutes"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ClusterName names the cluster, and its load assignment, serving the routing
+// key.
+func ClusterName(key routing_table.RoutingKey) string {
+	return fmt.Sprintf("%s-%d", key.ProcessGuid, key.ContainerPort)
+}
+
+// ClusterFor returns an EDS cluster whose endpoints are served over the same
+// aggregated (ADS) stream.
+func ClusterFor(key routing_table.RoutingKey, connectTimeout time.Duration) *cluster.Cluster {
+	return &cluster.Cluster{
+		Name:                 ClusterName(key),
+		ConnectTimeout:       durationpb.New(connectTimeout),
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+		EdsClusterConfig: &cluster.Cluster_EdsClusterConfig{
+			EdsConfig: &core.ConfigSource{
+				ResourceApiVersion:    core.ApiVersion_V3,
+				ConfigSourceSpecifier: &core.ConfigSource_Ads{Ads: &core.AggregatedConfigSource{}},
+			},
+		},
+	}
+}
+
+// LoadAssignmentFor returns the entry's endpoints grouped into localities by
+// availability zone. An endpoint that is evacuating and its replacement are
+// both included; endpoints sharing an address are only included once.
+func LoadAssignmentFor(key routing_table.RoutingKey, entry routing_table.RoutableEndpoints) *endpoint.ClusterLoadAssignment {
+	addressesByZone := map[string]map[routing_table.Address]struct{}{}
+	for _, e := range entry.Endpoints {
+		addresses, ok := addressesByZone[e.AvailabilityZone]
+		if !ok {
+			addresses = map[routing_table.Address]struct{}{}
+			addressesByZone[e.AvailabilityZone] = addresses
+		}
+		addresses[routing_table.Address{Host: e.Host, Port: e.Port}] = struct{}{}
+	}
+
+	zones := make([]string, 0, len(addressesByZone))
+	for zone := range addressesByZone {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	localities := make([]*endpoint.LocalityLbEndpoints, 0, len(zones))
+	for _, zone := range zones {
+		addresses := make([]routing_table.Address, 0, len(addressesByZone[zone]))
+		for address := range addressesByZone[zone] {
+			addresses = append(addresses, address)
+		}
+		sort.Sort(byAddress(addresses))
+
+		locality := &endpoint.LocalityLbEndpoints{}
+		if zone != "" {
+			locality.Locality = &core.Locality{Zone: zone}
+		}
+		for _, address := range addresses {
+			locality.LbEndpoints = append(locality.LbEndpoints, lbEndpointFor(address))
+		}
+		localities = append(localities, locality)
+	}
+
+	return &endpoint.ClusterLoadAssignment{
+		ClusterName: ClusterName(key),
+		Endpoints:   localities,
+	}
+}
+
+func lbEndpointFor(address routing_table.Address) *endpoint.LbEndpoint {
+	return &endpoint.LbEndpoint{
+		HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{
+				Address: &core.Address{
+					Address: &core.Address_SocketAddress{
+						SocketAddress: &core.SocketAddress{
+							Protocol:      core.SocketAddress_TCP,
+							Address:       address.Host,
+							PortSpecifier: &core.SocketAddress_PortValue{PortValue: address.Port},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+type clusterWeight struct {
+	name   string
+	weight uint32
+}
+
+// RouteConfigurationFor returns a route configuration with a virtual host for
+// every hostname in the entries. Each of a virtual host's routes matches one
+// context path, longest first, and sends requests to the clusters of the
+// routing keys registering that path; when several do, traffic is split by
+// their weights. Entries BoundToRouteService are left out.
+func RouteConfigurationFor(name string, entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints) *route.RouteConfiguration {
+	clustersByPathByHost := map[string]map[string][]clusterWeight{}
+	for key, entry := range entries {
+		if entry.BoundToRouteService() {
+			continue
+		}
+
+		for uri := range entry.Hostnames {
+			host, path := cfroutes.SplitURI(uri)
+			clustersByPath, ok := clustersByPathByHost[host]
+			if !ok {
+				clustersByPath = map[string][]clusterWeight{}
+				clustersByPathByHost[host] = clustersByPath
+			}
+			clustersByPath[path] = appendCluster(clustersByPath[path], clusterWeight{name: ClusterName(key), weight: entry.Weight})
+		}
+	}
+
+	hosts := make([]string, 0, len(clustersByPathByHost))
+	for host := range clustersByPathByHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	virtualHosts := make([]*route.VirtualHost, 0, len(hosts))
+	for _, host := range hosts {
+		virtualHosts = append(virtualHosts, virtualHostFor(host, clustersByPathByHost[host]))
+	}
+
+	return &route.RouteConfiguration{
+		Name:         name,
+		VirtualHosts: virtualHosts,
+	}
+}
+
+func appendCluster(clusters []clusterWeight, c clusterWeight) []clusterWeight {
+	for _, existing := range clusters {
+		if existing.name == c.name {
+			return clusters
+		}
+	}
+	return append(clusters, c)
+}
+
+func virtualHostFor(host string, clustersByPath map[string][]clusterWeight) *route.VirtualHost {
+	paths := make([]string, 0, len(clustersByPath))
+	for path := range clustersByPath {
+		paths = append(paths, path)
+	}
+	sort.Sort(byLongestPath(paths))
+
+	virtualHost := &route.VirtualHost{
+		Name:    host,
+		Domains: []string{host},
+	}
+	for _, path := range paths {
+		virtualHost.Routes = append(virtualHost.Routes, &route.Route{
+			Match:  routeMatchFor(path),
+			Action: &route.Route_Route{Route: routeActionFor(clustersByPath[path])},
+		})
+	}
+
+	return virtualHost
+}
+
+func routeMatchFor(path string) *route.RouteMatch {
+	if path == "" {
+		return &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}}
+	}
+	return &route.RouteMatch{PathSpecifier: &route.RouteMatch_PathSeparatedPrefix{PathSeparatedPrefix: path}}
+}
+
+func routeActionFor(clusters []clusterWeight) *route.RouteAction {
+	if len(clusters) == 1 {
+		return &route.RouteAction{ClusterSpecifier: &route.RouteAction_Cluster{Cluster: clusters[0].name}}
+	}
+
+	sort.Sort(byClusterName(clusters))
+
+	weighted := &route.WeightedCluster{}
+	for _, c := range clusters {
+		weight := c.weight
+		if weight == 0 {
+			weight = 1
+		}
+		weighted.Clusters = append(weighted.Clusters, &route.WeightedCluster_ClusterWeight{
+			Name:   c.name,
+			Weight: wrapperspb.UInt32(weight),
+		})
+	}
+
+	return &route.RouteAction{ClusterSpecifier: &route.RouteAction_WeightedClusters{WeightedClusters: weighted}}
+}
+
+type byAddress []routing_table.Address
+
+func (a byAddress) Len() int      { return len(a) }
+func (a byAddress) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAddress) Less(i, j int) bool {
+	if a[i].Host == a[j].Host {
+		return a[i].Port < a[j].Port
+	}
+	return a[i].Host < a[j].Host
+}
+
+type byLongestPath []string
+
+func (p byLongestPath) Len() int      { return len(p) }
+func (p byLongestPath) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byLongestPath) Less(i, j int) bool {
+	if len(p[i]) == len(p[j]) {
+		return p[i] < p[j]
+	}
+	return len(p[i]) > len(p[j])
+}
+
+type byClusterName []clusterWeight
+
+func (c byClusterName) Len() int           { return len(c) }
+func (c byClusterName) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c byClusterName) Less(i, j int) bool { return c[i].name < c[j].name }
@@ -0,0 +1,125 @@
+package xds_emitter_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/xds_emitter"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resources", func() {
+	key1 := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	key2 := routing_table.RoutingKey{ProcessGuid: "pg-2", ContainerPort: 8080}
+
+	Describe("ClusterFor", func() {
+		It("returns an EDS cluster named after the routing key", func() {
+			c := xds_emitter.ClusterFor(key1, 5*time.Second)
+			Expect(c.Name).To(Equal("pg-1-8080"))
+			Expect(c.GetType()).To(Equal(cluster.Cluster_EDS))
+			Expect(c.ConnectTimeout.AsDuration()).To(Equal(5 * time.Second))
+			Expect(c.EdsClusterConfig.EdsConfig.GetAds()).NotTo(BeNil())
+		})
+	})
+
+	Describe("LoadAssignmentFor", func() {
+		It("groups the endpoints by availability zone and de-duplicates addresses", func() {
+			entry := routing_table.NewRoutableEndpoints()
+			entry.Endpoints = routing_table.EndpointsAsMap([]routing_table.Endpoint{
+				{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, AvailabilityZone: "z2"},
+				{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22, AvailabilityZone: "z1"},
+				{InstanceGuid: "ig-3", Host: "1.1.1.1", Port: 12, AvailabilityZone: "z2"},
+				{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11, AvailabilityZone: "z2", Evacuating: true},
+			})
+
+			assignment := xds_emitter.LoadAssignmentFor(key1, entry)
+			Expect(assignment.ClusterName).To(Equal("pg-1-8080"))
+			Expect(assignment.Endpoints).To(HaveLen(2))
+
+			Expect(assignment.Endpoints[0].Locality.Zone).To(Equal("z1"))
+			Expect(assignment.Endpoints[0].LbEndpoints).To(HaveLen(1))
+			address := assignment.Endpoints[0].LbEndpoints[0].GetEndpoint().Address.GetSocketAddress()
+			Expect(address.Address).To(Equal("2.2.2.2"))
+			Expect(address.GetPortValue()).To(Equal(uint32(22)))
+
+			Expect(assignment.Endpoints[1].Locality.Zone).To(Equal("z2"))
+			Expect(assignment.Endpoints[1].LbEndpoints).To(HaveLen(2))
+			Expect(assignment.Endpoints[1].LbEndpoints[0].GetEndpoint().Address.GetSocketAddress().GetPortValue()).To(Equal(uint32(11)))
+			Expect(assignment.Endpoints[1].LbEndpoints[1].GetEndpoint().Address.GetSocketAddress().GetPortValue()).To(Equal(uint32(12)))
+		})
+
+		It("returns no localities for an entry without endpoints", func() {
+			Expect(xds_emitter.LoadAssignmentFor(key1, routing_table.NewRoutableEndpoints()).Endpoints).To(BeEmpty())
+		})
+	})
+
+	Describe("RouteConfigurationFor", func() {
+		var entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+
+		BeforeEach(func() {
+			entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+				key1: {Hostnames: map[string]struct{}{"foo.example.com": {}, "foo.example.com/api/v2": {}, "Bar.example.com": {}}},
+				key2: {Hostnames: map[string]struct{}{"foo.example.com/api": {}}},
+			}
+		})
+
+		It("has a virtual host for every hostname, matching the longest paths first", func() {
+			config := xds_emitter.RouteConfigurationFor("my-routes", entries)
+			Expect(config.Name).To(Equal("my-routes"))
+			Expect(config.VirtualHosts).To(HaveLen(2))
+
+			bar := config.VirtualHosts[0]
+			Expect(bar.Domains).To(Equal([]string{"bar.example.com"}))
+			Expect(bar.Routes).To(HaveLen(1))
+			Expect(bar.Routes[0].Match.GetPrefix()).To(Equal("/"))
+			Expect(bar.Routes[0].GetRoute().GetCluster()).To(Equal("pg-1-8080"))
+
+			foo := config.VirtualHosts[1]
+			Expect(foo.Domains).To(Equal([]string{"foo.example.com"}))
+			Expect(foo.Routes).To(HaveLen(3))
+			Expect(foo.Routes[0].Match.GetPathSeparatedPrefix()).To(Equal("/api/v2"))
+			Expect(foo.Routes[0].GetRoute().GetCluster()).To(Equal("pg-1-8080"))
+			Expect(foo.Routes[1].Match.GetPathSeparatedPrefix()).To(Equal("/api"))
+			Expect(foo.Routes[1].GetRoute().GetCluster()).To(Equal("pg-2-8080"))
+			Expect(foo.Routes[2].Match.GetPrefix()).To(Equal("/"))
+		})
+
+		It("splits traffic by weight between routing keys sharing a route", func() {
+			entries[key2] = routing_table.RoutableEndpoints{Hostnames: map[string]struct{}{"foo.example.com": {}}, Weight: 3}
+
+			config := xds_emitter.RouteConfigurationFor("my-routes", entries)
+			foo := config.VirtualHosts[1]
+			Expect(foo.Routes).To(HaveLen(2))
+
+			weighted := foo.Routes[1].GetRoute().GetWeightedClusters()
+			Expect(weighted).NotTo(BeNil())
+			Expect(weighted.Clusters).To(HaveLen(2))
+			Expect(weighted.Clusters[0].Name).To(Equal("pg-1-8080"))
+			Expect(weighted.Clusters[0].Weight.GetValue()).To(Equal(uint32(1)))
+			Expect(weighted.Clusters[1].Name).To(Equal("pg-2-8080"))
+			Expect(weighted.Clusters[1].Weight.GetValue()).To(Equal(uint32(3)))
+		})
+
+		It("leaves out entries bound to a route service", func() {
+			entries[key2] = routing_table.RoutableEndpoints{
+				Hostnames:       map[string]struct{}{"baz.example.com": {}},
+				RouteServiceUrl: "https://rs.example.com",
+			}
+
+			config := xds_emitter.RouteConfigurationFor("my-routes", entries)
+			for _, virtualHost := range config.VirtualHosts {
+				Expect(virtualHost.Domains).NotTo(ContainElement("baz.example.com"))
+			}
+		})
+
+		It("returns no virtual hosts for an empty table", func() {
+			config := xds_emitter.RouteConfigurationFor("my-routes", nil)
+			Expect(config.VirtualHosts).To(BeEmpty())
+			Expect(config).To(BeAssignableToTypeOf(&route.RouteConfiguration{}))
+		})
+	})
+})
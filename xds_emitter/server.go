@@ -0,0 +1,63 @@
+package xds_emitter
+
+import (
+	"context"
+	"net"
+	"os"
+
+	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
+	discoveryservice "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	endpointservice "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
+	routeservice "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	server "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+	"google.golang.org/grpc"
+)
+
+// NewServer returns a runner serving the cache over gRPC on address, both as
+// an aggregated discovery service and as separate cluster, endpoint and route
+// discovery services. Clusters expect their load assignments over the
+// aggregated stream.
+func NewServer(address string, xdsCache cache.Cache, logger lager.Logger) ifrit.Runner {
+	logger = logger.Session("xds-server", lager.Data{"address": address})
+
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			logger.Error("failed-to-listen", err)
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		xdsServer := server.NewServer(ctx, xdsCache, nil)
+		grpcServer := grpc.NewServer()
+		discoveryservice.RegisterAggregatedDiscoveryServiceServer(grpcServer, xdsServer)
+		clusterservice.RegisterClusterDiscoveryServiceServer(grpcServer, xdsServer)
+		endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, xdsServer)
+		routeservice.RegisterRouteDiscoveryServiceServer(grpcServer, xdsServer)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- grpcServer.Serve(listener)
+		}()
+
+		logger.Info("started")
+		close(ready)
+
+		select {
+		case <-signals:
+			logger.Info("stopping")
+			// discovery streams only end once the server's context is done
+			cancel()
+			grpcServer.GracefulStop()
+			return nil
+		case err := <-errs:
+			logger.Error("failed-to-serve", err)
+			return err
+		}
+	})
+}
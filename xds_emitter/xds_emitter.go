@@ -0,0 +1,305 @@
+package xds_emitter
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/metric"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+	"google.golang.org/protobuf/proto"
+)
+
+const DefaultRouteConfigurationName = "route-emitter"
+
+// UpdateInterval is how long Run waits after a change before updating the
+// resources, so that bursts of changes cause a single update.
+const UpdateInterval = time.Second
+
+var (
+	xdsClusters        = metric.Metric("XDSClustersTotal")
+	xdsClustersUpdated = metric.Counter("XDSClustersUpdated")
+)
+
+// Emitter serves the routing table to Envoy as xDS resources: a cluster and
+// a load assignment for every routing key, and a single route configuration
+// with a virtual host for every hostname. Entries BoundToRouteService are left
+// out. It implements the emitter interface, so it can be used in place of, or
+// alongside, the NATS emitter.
+//
+// Emit queues the process guids the messages concern, found through the
+// process_guid tag of each message; a message without one causes every
+// routing key to be updated. Run updates the resources, right away the first
+// time and then at most once per UpdateInterval, and only publishes the ones
+// that changed, so that their versions, and Envoy, are left alone otherwise.
+type Emitter struct {
+	table           routing_table.RoutingTable
+	routeConfigName string
+	connectTimeout  time.Duration
+	clock           clock.Clock
+	logger          lager.Logger
+
+	clusters  *cache.LinearCache
+	endpoints *cache.LinearCache
+	routes    *cache.LinearCache
+
+	// published and routeConfig are only used by Run
+	published   map[routing_table.RoutingKey]publishedResources
+	routeConfig *route.RouteConfiguration
+
+	lock                sync.Mutex
+	pendingProcessGuids map[string]bool
+	pendingAll          bool
+
+	updates chan struct{}
+}
+
+type publishedResources struct {
+	cluster        *cluster.Cluster
+	loadAssignment *endpoint.ClusterLoadAssignment
+}
+
+func New(table routing_table.RoutingTable, routeConfigName string, connectTimeout time.Duration, clock clock.Clock, logger lager.Logger) *Emitter {
+	if routeConfigName == "" {
+		routeConfigName = DefaultRouteConfigurationName
+	}
+
+	return &Emitter{
+		table:           table,
+		routeConfigName: routeConfigName,
+		connectTimeout:  connectTimeout,
+		clock:           clock,
+		logger:          logger.Session("xds-emitter"),
+
+		clusters:  cache.NewLinearCache(resource.ClusterType),
+		endpoints: cache.NewLinearCache(resource.EndpointType),
+		routes:    cache.NewLinearCache(resource.RouteType),
+
+		published: map[routing_table.RoutingKey]publishedResources{},
+
+		pendingProcessGuids: map[string]bool{},
+
+		updates: make(chan struct{}, 1),
+	}
+}
+
+// Cache returns the cache the xDS server answers discovery requests from.
+// Resources are versioned individually, so clients of the incremental
+// protocol only receive the clusters and load assignments that changed.
+func (e *Emitter) Cache() cache.Cache {
+	return &cache.MuxCache{
+		Classify: func(request *cache.Request) string {
+			return request.TypeUrl
+		},
+		ClassifyDelta: func(request *cache.DeltaRequest) string {
+			return request.TypeUrl
+		},
+		Caches: map[string]cache.Cache{
+			resource.ClusterType:  e.clusters,
+			resource.EndpointType: e.endpoints,
+			resource.RouteType:    e.routes,
+		},
+	}
+}
+
+func (e *Emitter) Emit(messagesToEmit routing_table.MessagesToEmit) error {
+	if len(messagesToEmit.RegistrationMessages) == 0 && len(messagesToEmit.UnregistrationMessages) == 0 {
+		return nil
+	}
+
+	processGuids, known := messagesToEmit.ProcessGuids()
+
+	e.lock.Lock()
+	if known {
+		for processGuid := range processGuids {
+			e.pendingProcessGuids[processGuid] = true
+		}
+	} else {
+		e.pendingAll = true
+	}
+	e.lock.Unlock()
+
+	select {
+	case e.updates <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (e *Emitter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	e.logger.Info("starting")
+	close(ready)
+	e.logger.Info("started")
+
+	var timer clock.Timer
+	var timerC <-chan time.Time
+
+	for {
+		select {
+		case <-e.updates:
+			if e.routeConfig == nil {
+				e.update()
+			} else if timerC == nil {
+				timer = e.clock.NewTimer(UpdateInterval)
+				timerC = timer.C()
+			}
+
+		case <-timerC:
+			timerC = nil
+			e.update()
+
+		case <-signals:
+			e.logger.Info("stopping")
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+	}
+}
+
+// update publishes the resources of the queued process guids that changed.
+// New and changed clusters are published before the route configuration that
+// refers to them, and removed clusters only after it, so Envoy never routes to
+// a cluster it does not know.
+func (e *Emitter) update() {
+	e.lock.Lock()
+	processGuids, all := e.pendingProcessGuids, e.pendingAll
+	e.pendingProcessGuids, e.pendingAll = map[string]bool{}, false
+	e.lock.Unlock()
+
+	if len(processGuids) == 0 && !all {
+		return
+	}
+
+	affected := func(processGuid string) bool {
+		return all || processGuids[processGuid]
+	}
+
+	entries := map[routing_table.RoutingKey]routing_table.RoutableEndpoints{}
+	for key, entry := range e.table.Entries() {
+		if !entry.BoundToRouteService() {
+			entries[key] = entry
+		}
+	}
+
+	published := map[routing_table.RoutingKey]publishedResources{}
+	clusters := map[string]types.Resource{}
+	endpoints := map[string]types.Resource{}
+	for key, entry := range entries {
+		if !affected(key.ProcessGuid) {
+			continue
+		}
+
+		name := ClusterName(key)
+		current := publishedResources{
+			cluster:        ClusterFor(key, e.connectTimeout),
+			loadAssignment: LoadAssignmentFor(key, entry),
+		}
+		previous, ok := e.published[key]
+		if !ok || !proto.Equal(previous.cluster, current.cluster) {
+			clusters[name] = current.cluster
+		}
+		if !ok || !proto.Equal(previous.loadAssignment, current.loadAssignment) {
+			endpoints[name] = current.loadAssignment
+		}
+		published[key] = current
+	}
+
+	removed := []routing_table.RoutingKey{}
+	removedNames := []string{}
+	for key := range e.published {
+		if _, ok := entries[key]; !ok && affected(key.ProcessGuid) {
+			removed = append(removed, key)
+			removedNames = append(removedNames, ClusterName(key))
+		}
+	}
+
+	routeConfig := RouteConfigurationFor(e.routeConfigName, entries)
+	routeConfigChanged := e.routeConfig == nil || !proto.Equal(e.routeConfig, routeConfig)
+
+	if len(clusters) == 0 && len(endpoints) == 0 && len(removedNames) == 0 && !routeConfigChanged {
+		return
+	}
+
+	err := e.publish(clusters, endpoints, routeConfig, routeConfigChanged, removedNames)
+	if err != nil {
+		// publish everything affected again on the next update
+		e.lock.Lock()
+		for processGuid := range processGuids {
+			e.pendingProcessGuids[processGuid] = true
+		}
+		e.pendingAll = e.pendingAll || all
+		e.lock.Unlock()
+		return
+	}
+
+	for key, resources := range published {
+		e.published[key] = resources
+	}
+	for _, key := range removed {
+		delete(e.published, key)
+	}
+	if routeConfigChanged {
+		e.routeConfig = routeConfig
+	}
+
+	e.logger.Debug("updated-resources", lager.Data{"updated": len(clusters) + len(endpoints), "removed": len(removedNames)})
+	xdsClustersUpdated.Add(uint64(len(clusters) + len(removedNames)))
+
+	err = xdsClusters.Send(len(e.published))
+	if err != nil {
+		e.logger.Error("failed-to-send-xds-clusters-metric", err)
+	}
+}
+
+func (e *Emitter) publish(clusters, endpoints map[string]types.Resource, routeConfig *route.RouteConfiguration, routeConfigChanged bool, removedNames []string) error {
+	if len(clusters) > 0 {
+		err := e.clusters.UpdateResources(clusters, nil)
+		if err != nil {
+			e.logger.Error("failed-to-update-clusters", err)
+			return err
+		}
+	}
+
+	if len(endpoints) > 0 {
+		err := e.endpoints.UpdateResources(endpoints, nil)
+		if err != nil {
+			e.logger.Error("failed-to-update-load-assignments", err)
+			return err
+		}
+	}
+
+	if routeConfigChanged {
+		err := e.routes.UpdateResource(e.routeConfigName, routeConfig)
+		if err != nil {
+			e.logger.Error("failed-to-update-route-configuration", err)
+			return err
+		}
+	}
+
+	if len(removedNames) > 0 {
+		err := e.endpoints.UpdateResources(nil, removedNames)
+		if err != nil {
+			e.logger.Error("failed-to-remove-load-assignments", err)
+			return err
+		}
+
+		err = e.clusters.UpdateResources(nil, removedNames)
+		if err != nil {
+			e.logger.Error("failed-to-remove-clusters", err)
+			return err
+		}
+	}
+
+	return nil
+}
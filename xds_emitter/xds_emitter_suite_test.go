@@ -0,0 +1,13 @@
+package xds_emitter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestXdsEmitter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "XDS Emitter Suite")
+}
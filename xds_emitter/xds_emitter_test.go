@@ -0,0 +1,200 @@
+package xds_emitter_test
+
+import (
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/routing_table/fake_routing_table"
+	"github.com/cloudfoundry-incubator/route-emitter/xds_emitter"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cache "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Emitter", func() {
+	var (
+		table   *fake_routing_table.FakeRoutingTable
+		clock   *fakeclock.FakeClock
+		emitter *xds_emitter.Emitter
+		entries map[routing_table.RoutingKey]routing_table.RoutableEndpoints
+		process ifrit.Process
+	)
+
+	key1 := routing_table.RoutingKey{ProcessGuid: "pg-1", ContainerPort: 8080}
+	key2 := routing_table.RoutingKey{ProcessGuid: "pg-2", ContainerPort: 8080}
+
+	registration := func(processGuid string) routing_table.RegistryMessage {
+		return routing_table.RegistryMessage{URIs: []string{"foo.example.com"}, Tags: map[string]string{"process_guid": processGuid}}
+	}
+
+	resources := func(typeURL string) map[string]types.Resource {
+		return emitter.Cache().(*cache.MuxCache).Caches[typeURL].(*cache.LinearCache).GetResources()
+	}
+
+	routeConfig := func() types.Resource {
+		return resources(resource.RouteType)[xds_emitter.DefaultRouteConfigurationName]
+	}
+
+	// emitAndUpdate emits the messages after the first update, and lets the
+	// update interval pass
+	emitAndUpdate := func(messages routing_table.MessagesToEmit) {
+		calls := table.EntriesCallCount()
+		Expect(emitter.Emit(messages)).To(Succeed())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(xds_emitter.UpdateInterval)
+		Eventually(table.EntriesCallCount).Should(Equal(calls + 1))
+	}
+
+	BeforeEach(func() {
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{
+			key1: {
+				Hostnames: map[string]struct{}{"foo.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{{InstanceGuid: "ig-1", Host: "1.1.1.1", Port: 11}}),
+			},
+			key2: {
+				Hostnames: map[string]struct{}{"bar.example.com": {}},
+				Endpoints: routing_table.EndpointsAsMap([]routing_table.Endpoint{{InstanceGuid: "ig-2", Host: "2.2.2.2", Port: 22}}),
+			},
+		}
+
+		table = new(fake_routing_table.FakeRoutingTable)
+		table.EntriesStub = func() map[routing_table.RoutingKey]routing_table.RoutableEndpoints {
+			return entries
+		}
+
+		clock = fakeclock.NewFakeClock(time.Now())
+		emitter = xds_emitter.New(table, "", time.Second, clock, lagertest.NewTestLogger("test"))
+		process = ifrit.Invoke(emitter)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("does nothing when there are no messages", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{})).To(Succeed())
+		Consistently(table.EntriesCallCount).Should(BeZero())
+		Expect(resources(resource.ClusterType)).To(BeEmpty())
+	})
+
+	It("only publishes the routing keys of the process guids in the messages", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-1")},
+		})).To(Succeed())
+
+		Eventually(func() map[string]types.Resource { return resources(resource.ClusterType) }).Should(HaveKey("pg-1-8080"))
+		Expect(resources(resource.ClusterType)).NotTo(HaveKey("pg-2-8080"))
+
+		assignment := resources(resource.EndpointType)["pg-1-8080"].(*endpoint.ClusterLoadAssignment)
+		Expect(assignment.Endpoints[0].LbEndpoints[0].GetEndpoint().Address.GetSocketAddress().Address).To(Equal("1.1.1.1"))
+	})
+
+	It("publishes every routing key when a message has no process guid", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{{URIs: []string{"foo.example.com"}}},
+		})).To(Succeed())
+
+		Eventually(func() map[string]types.Resource { return resources(resource.ClusterType) }).Should(HaveLen(2))
+		Expect(resources(resource.EndpointType)).To(HaveLen(2))
+	})
+
+	It("publishes a route configuration with every hostname in the table", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-1")},
+		})).To(Succeed())
+
+		Eventually(routeConfig).ShouldNot(BeNil())
+		config := routeConfig().(*route.RouteConfiguration)
+		Expect(config.VirtualHosts).To(HaveLen(2))
+	})
+
+	It("removes the resources of routing keys that left the table", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-1"), registration("pg-2")},
+		})).To(Succeed())
+		Eventually(func() map[string]types.Resource { return resources(resource.ClusterType) }).Should(HaveLen(2))
+
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{key1: entries[key1]}
+		emitAndUpdate(routing_table.MessagesToEmit{
+			UnregistrationMessages: []routing_table.RegistryMessage{registration("pg-2")},
+		})
+
+		Eventually(func() map[string]types.Resource { return resources(resource.ClusterType) }).Should(HaveLen(1))
+		Expect(resources(resource.ClusterType)).To(HaveKey("pg-1-8080"))
+		Expect(resources(resource.EndpointType)).To(HaveLen(1))
+
+		config := routeConfig().(*route.RouteConfiguration)
+		Expect(config.VirtualHosts).To(HaveLen(1))
+		Expect(config.VirtualHosts[0].Domains).To(Equal([]string{"foo.example.com"}))
+	})
+
+	It("leaves out the routing keys bound to a route service", func() {
+		bound := entries[key2]
+		bound.RouteServiceUrl = "https://rs.example.com"
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{key1: entries[key1], key2: bound}
+
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-1"), registration("pg-2")},
+		})).To(Succeed())
+
+		Eventually(routeConfig).ShouldNot(BeNil())
+		Expect(resources(resource.ClusterType)).To(HaveLen(1))
+		Expect(resources(resource.ClusterType)).To(HaveKey("pg-1-8080"))
+		Expect(resources(resource.EndpointType)).To(HaveLen(1))
+		Expect(routeConfig().(*route.RouteConfiguration).VirtualHosts).To(HaveLen(1))
+	})
+
+	It("updates at most once per update interval", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-1")},
+		})).To(Succeed())
+		Eventually(routeConfig).ShouldNot(BeNil())
+
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-1")},
+		})).To(Succeed())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-2")},
+		})).To(Succeed())
+		Consistently(table.EntriesCallCount).Should(Equal(1))
+
+		clock.Increment(xds_emitter.UpdateInterval)
+		Eventually(table.EntriesCallCount).Should(Equal(2))
+		Eventually(func() map[string]types.Resource { return resources(resource.ClusterType) }).Should(HaveLen(2))
+	})
+
+	It("only republishes the resources that changed", func() {
+		Expect(emitter.Emit(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{registration("pg-1"), registration("pg-2")},
+		})).To(Succeed())
+		Eventually(routeConfig).ShouldNot(BeNil())
+
+		clusters := resources(resource.ClusterType)
+		assignments := resources(resource.EndpointType)
+		config := routeConfig()
+
+		changed := entries[key2]
+		changed.Endpoints = routing_table.EndpointsAsMap([]routing_table.Endpoint{{InstanceGuid: "ig-3", Host: "3.3.3.3", Port: 33}})
+		entries = map[routing_table.RoutingKey]routing_table.RoutableEndpoints{key1: entries[key1], key2: changed}
+		emitAndUpdate(routing_table.MessagesToEmit{
+			RegistrationMessages: []routing_table.RegistryMessage{{URIs: []string{"foo.example.com"}}},
+		})
+
+		Eventually(func() types.Resource { return resources(resource.EndpointType)["pg-2-8080"] }).ShouldNot(BeIdenticalTo(assignments["pg-2-8080"]))
+		Expect(resources(resource.EndpointType)["pg-1-8080"]).To(BeIdenticalTo(assignments["pg-1-8080"]))
+		Expect(resources(resource.ClusterType)["pg-1-8080"]).To(BeIdenticalTo(clusters["pg-1-8080"]))
+		Expect(resources(resource.ClusterType)["pg-2-8080"]).To(BeIdenticalTo(clusters["pg-2-8080"]))
+		Expect(routeConfig()).To(BeIdenticalTo(config))
+	})
+})